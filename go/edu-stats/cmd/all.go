@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"database/sql"
 	"fmt"
 	"strings"
 	"time"
@@ -9,12 +10,15 @@ import (
 	"github.com/aallbrig/proficiency-comparison/internal/database"
 	"github.com/aallbrig/proficiency-comparison/internal/downloaders"
 	"github.com/aallbrig/proficiency-comparison/internal/generators"
+	"github.com/aallbrig/proficiency-comparison/internal/schema"
+	"github.com/aallbrig/proficiency-comparison/internal/validate"
 )
 
 var (
-	years  string
-	dryRun bool
-	force  bool
+	years        string
+	dryRun       bool
+	force        bool
+	maxRevisions int
 )
 
 var allCmd = &cobra.Command{
@@ -30,6 +34,7 @@ func init() {
 	allCmd.Flags().StringVar(&years, "years", "1970-2025", "Year range to download (format: YYYY-YYYY)")
 	allCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Simulate without downloading data")
 	allCmd.Flags().BoolVar(&force, "force", false, "Force re-download all data (ignore resume)")
+	allCmd.Flags().IntVar(&maxRevisions, "max-revisions", 0, "Fail a download that would revise more than N stored values (0 = no limit)")
 }
 
 func parseYears(yearRange string) (int, int, error) {
@@ -86,6 +91,7 @@ func runAll(cmd *cobra.Command, args []string) error {
 		{"download-nces", func() error { return runDownloadNCES(startYear, endYear, dryRun) }},
 		{"download-naep", func() error { return runDownloadNAEP(startYear, endYear, dryRun) }},
 		{"download-ecls", func() error { return runDownloadECLS(startYear, endYear, dryRun) }},
+		{"validate", func() error { return runValidate(cmd, args) }},
 		{"generate-assets", func() error { return runGenerateAssets(cmd, args) }},
 	}
 
@@ -184,6 +190,7 @@ func runDownloadWorldBank(startYear, endYear int, dryRun bool) error {
 	defer db.Close()
 	
 	downloader := downloaders.NewWorldBankDownloader(db)
+	downloader.MaxRevisions = maxRevisions
 	return downloader.Download(startYear, endYear, dryRun)
 }
 
@@ -204,6 +211,7 @@ func runDownloadCensus(startYear, endYear int, dryRun bool) error {
 	defer db.Close()
 	
 	downloader := downloaders.NewCensusDownloader(db)
+	downloader.MaxRevisions = maxRevisions
 	return downloader.Download(startYear, endYear, dryRun)
 }
 
@@ -224,6 +232,7 @@ func runDownloadNCES(startYear, endYear int, dryRun bool) error {
 	defer db.Close()
 	
 	downloader := downloaders.NewNCESDownloader(db)
+	downloader.MaxRevisions = maxRevisions
 	return downloader.Download(startYear, endYear, dryRun)
 }
 
@@ -244,6 +253,7 @@ func runDownloadNAEP(startYear, endYear int, dryRun bool) error {
 	defer db.Close()
 	
 	downloader := downloaders.NewNAEPDownloader(db)
+	downloader.MaxRevisions = maxRevisions
 	return downloader.Download(startYear, endYear, dryRun)
 }
 
@@ -267,10 +277,36 @@ func runDownloadECLS(startYear, endYear int, dryRun bool) error {
 	return downloader.Download(startYear, endYear, dryRun)
 }
 
+var (
+	skipValidation bool
+	resample       string
+	defaultSeries  []string
+	schemaVersion  int
+	fullGenerate   bool
+	chartFormats   string
+	noContent      bool
+	withAnalytics  bool
+)
+
 var generateAssetsCmd = &cobra.Command{
 	Use:   "generate-assets",
 	Short: "Generate Hugo JSON assets from database",
-	RunE:  runGenerateAssets,
+	// --charts takes an optional value, so "--charts svg,png" would leave
+	// svg,png as an argument; reject it rather than drawing SVG only.
+	Args: cobra.NoArgs,
+	RunE: runGenerateAssets,
+}
+
+func init() {
+	generateAssetsCmd.Flags().BoolVar(&skipValidation, "skip-validation", false, "Generate even if the last validation run found errors")
+	generateAssetsCmd.Flags().StringVar(&resample, "resample", "none", "Fill missing years with an annual series: none, linear, locf or spline")
+	generateAssetsCmd.Flags().StringArrayVar(&defaultSeries, "default-series", nil, "Headline series for a stat: stat:col=value,... (repeatable)")
+	generateAssetsCmd.Flags().BoolVar(&fullGenerate, "full", false, "Regenerate every stat, even those whose tables have not changed")
+	generateAssetsCmd.Flags().IntVar(&schemaVersion, "schema-version", schema.Current, "Output contract version to write (1 = original stat and index shape)")
+	generateAssetsCmd.Flags().StringVar(&chartFormats, "charts", "", "Also draw each stat's chart into data/charts: svg, png or svg,png (--charts alone means svg)")
+	generateAssetsCmd.Flags().Lookup("charts").NoOptDefVal = generators.ChartSVG
+	generateAssetsCmd.Flags().BoolVar(&withAnalytics, "analytics", false, "Add each series' trend analysis (CAGR, rolling averages, trend, change points) to the stat files")
+	generateAssetsCmd.Flags().BoolVar(&noContent, "no-content", false, "Only write static/data; skip the Hugo content pages per stat, birth year and generation")
 }
 
 func runGenerateAssets(cmd *cobra.Command, args []string) error {
@@ -279,7 +315,67 @@ func runGenerateAssets(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	defer db.Close()
-	
+
+	method, err := generators.ParseResampleMethod(resample)
+	if err != nil {
+		return err
+	}
+
 	generator := generators.NewHugoGenerator(db)
+	if err := schema.CheckVersion(schemaVersion); err != nil {
+		return err
+	}
+	generator.Resample = method
+	generator.SchemaVersion = schemaVersion
+	generator.Full = fullGenerate
+	generator.NoContent = noContent
+	generator.Analytics = withAnalytics
+	if generator.Charts, err = generators.ParseChartFormats(chartFormats); err != nil {
+		return err
+	}
+	generator.DefaultSeries = make(map[string]generators.SeriesSelector)
+	for _, s := range defaultSeries {
+		stat, selector, err := generators.ParseSeriesSelector(s)
+		if err != nil {
+			return err
+		}
+		generator.DefaultSeries[stat] = selector
+	}
+	return generateValidated(db, generator)
+}
+
+// generateValidated runs generator once checkValidation passes. Every
+// command that writes the site data goes through it.
+func generateValidated(db *sql.DB, generator *generators.HugoGenerator) error {
+	if err := checkValidation(db); err != nil {
+		return err
+	}
 	return generator.GenerateAll()
 }
+
+// checkValidation refuses to publish data that has not passed validation:
+// validation must have run, found no errors, and seen the data as it is now.
+// --skip-validation turns the check off.
+func checkValidation(db *sql.DB) error {
+	if skipValidation {
+		return nil
+	}
+	runID, errors, err := validate.LatestRun(db)
+	if err != nil {
+		return fmt.Errorf("could not read validation results: %w; pass --skip-validation to generate anyway", err)
+	}
+	if runID == "" {
+		return fmt.Errorf("the data has never been validated; run 'edu-stats step validate', or pass --skip-validation")
+	}
+	if errors > 0 {
+		return fmt.Errorf("last validation run %s found %d errors; fix the data and re-run 'edu-stats step validate', or pass --skip-validation", runID, errors)
+	}
+	current, err := validate.Current(db, runID)
+	if err != nil {
+		return fmt.Errorf("could not compare validation run %s with the data: %w", runID, err)
+	}
+	if !current {
+		return fmt.Errorf("data changed after validation run %s; re-run 'edu-stats step validate', or pass --skip-validation", runID)
+	}
+	return nil
+}
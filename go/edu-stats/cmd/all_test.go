@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAssetsRejectsDetachedChartList(t *testing.T) {
+	defer func() { chartFormats = "" }()
+	for _, tc := range []struct {
+		args    []string
+		wantErr bool
+	}{
+		{[]string{"--charts", "svg,png"}, true},
+		{[]string{"--charts=svg,png"}, false},
+		{[]string{"--charts"}, false},
+	} {
+		flags := generateAssetsCmd.Flags()
+		if err := flags.Parse(tc.args); err != nil {
+			t.Fatalf("parse %v: %v", tc.args, err)
+		}
+		err := generateAssetsCmd.ValidateArgs(flags.Args())
+		if (err != nil) != tc.wantErr {
+			t.Errorf("generate-assets %v: err = %v, want error %v", tc.args, err, tc.wantErr)
+		}
+	}
+}
+
+func TestCheckValidationRequiresACurrentCleanRun(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`
+		CREATE TABLE validation_results (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			run_id TEXT NOT NULL, rule TEXT NOT NULL, severity TEXT NOT NULL, data_version INTEGER
+		);
+		CREATE TABLE data_versions (table_name TEXT PRIMARY KEY, version INTEGER NOT NULL DEFAULT 0);
+		INSERT INTO data_versions (table_name, version) VALUES ('graduation_rates', 3);`); err != nil {
+		t.Fatal(err)
+	}
+	check := func(want string) {
+		t.Helper()
+		err := checkValidation(db)
+		if want == "" && err != nil {
+			t.Errorf("checkValidation: %v, want it to pass", err)
+		}
+		if want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("checkValidation = %v, want an error containing %q", err, want)
+		}
+	}
+
+	check("never been validated")
+	if _, err := db.Exec(`INSERT INTO validation_results (run_id, rule, severity, data_version) VALUES ('run-1', 'summary', 'ok', 3)`); err != nil {
+		t.Fatal(err)
+	}
+	check("")
+	// A load after the clean run makes it stale.
+	if _, err := db.Exec(`UPDATE data_versions SET version = 4`); err != nil {
+		t.Fatal(err)
+	}
+	check("data changed after validation run run-1")
+
+	skipValidation = true
+	defer func() { skipValidation = false }()
+	check("")
+}
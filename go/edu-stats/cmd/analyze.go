@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aallbrig/proficiency-comparison/internal/analytics"
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/generators"
+	"github.com/spf13/cobra"
+)
+
+var (
+	analyzeWindow     int
+	analyzeConfidence float64
+	analyzeAlpha      float64
+	analyzeAllSeries  bool
+	analyzeFormat     string
+)
+
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze <stat>",
+	Short: "Show a stat's trend, compound change, rolling averages and change points",
+	Long: `Describe how a stat's series moves instead of reading it off a chart.
+
+For the headline series (and every breakdown with --all-series) this prints
+the compound annual change from the first to the last measurement, the
+least-squares trend per year with a confidence interval for the slope, the
+structural change points where the trend breaks, and each year's trailing
+average. Only measured years are used.
+
+Change points come from binary segmentation: the split whose separate lines
+before and after fit best is kept when a Chow test rejects a single line at
+--alpha, and each side is searched again.
+
+The same figures are added to the stat files by
+'step generate-assets --analytics'.
+
+Examples:
+  edu-stats analyze proficiency
+  edu-stats analyze graduation --window 10 --confidence 0.9
+  edu-stats analyze enrollment --all-series --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAnalyze,
+}
+
+func init() {
+	analyzeCmd.Flags().IntVar(&analyzeWindow, "window", analytics.DefaultOptions.Window, "Years covered by each trailing average")
+	analyzeCmd.Flags().Float64Var(&analyzeConfidence, "confidence", analytics.DefaultOptions.Confidence, "Confidence level of the trend slope's interval")
+	analyzeCmd.Flags().Float64Var(&analyzeAlpha, "alpha", analytics.DefaultOptions.Alpha, "Significance level a change point must reach")
+	analyzeCmd.Flags().BoolVar(&analyzeAllSeries, "all-series", false, "Also analyze every breakdown series")
+	analyzeCmd.Flags().StringVar(&analyzeFormat, "format", "table", "Output format: table or json")
+}
+
+// seriesAnalysis is the JSON layout of one analyzed series.
+type seriesAnalysis struct {
+	Key      string              `json:"key"`
+	Label    string              `json:"label"`
+	Analysis *analytics.Analysis `json:"analysis"`
+	data     []generators.DataPoint
+}
+
+func runAnalyze(cmd *cobra.Command, args []string) error {
+	m, ok := catalog.Get(args[0])
+	if !ok || m.QueryOnly {
+		ids := make([]string, len(catalog.Published))
+		for i, metric := range catalog.Published {
+			ids[i] = metric.ID
+		}
+		return fmt.Errorf("unknown stat %q (one of %s)", args[0], strings.Join(ids, ", "))
+	}
+	switch analyzeFormat {
+	case "table", "json":
+	default:
+		return fmt.Errorf("unknown format %q, use table or json", analyzeFormat)
+	}
+	switch {
+	case analyzeWindow < 1:
+		return fmt.Errorf("--window must be at least 1")
+	case analyzeConfidence <= 0 || analyzeConfidence >= 1:
+		return fmt.Errorf("--confidence must be between 0 and 1")
+	case analyzeAlpha <= 0 || analyzeAlpha >= 1:
+		return fmt.Errorf("--alpha must be between 0 and 1")
+	}
+	opts := analytics.DefaultOptions
+	opts.Window, opts.Confidence, opts.Alpha = analyzeWindow, analyzeConfidence, analyzeAlpha
+
+	db, err := database.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	stats, err := generators.NewHugoGenerator(db).Stats()
+	if err != nil {
+		return fmt.Errorf("failed to load stats: %w", err)
+	}
+	data, ok := stats[m.ID]
+	if !ok {
+		return fmt.Errorf("%s has no data; load it with 'edu-stats all' or 'edu-stats import'", m.Title)
+	}
+
+	series := []seriesAnalysis{{Key: "headline", Label: "Headline series " + data.DefaultSeries, data: data.Years}}
+	if analyzeAllSeries {
+		for _, s := range data.Series {
+			series = append(series, seriesAnalysis{Key: s.Key, Label: s.Key, data: s.Data})
+		}
+	}
+	for i := range series {
+		series[i].Analysis = generators.Analyze(series[i].data, opts)
+	}
+
+	if analyzeFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Stat   string           `json:"stat"`
+			Series []seriesAnalysis `json:"series"`
+		}{m.ID, series})
+	}
+	fmt.Printf("%s (%s)\n", m.Title, m.ID)
+	for _, s := range series {
+		fmt.Println()
+		printAnalysis(os.Stdout, m, s)
+	}
+	return nil
+}
+
+// perYear names the unit of a change per year in m's unit.
+func perYear(m catalog.Metric) string {
+	switch m.Unit {
+	case "percent":
+		return "percentage points per year"
+	case "scale_score":
+		return "points per year"
+	}
+	return "per year"
+}
+
+func printAnalysis(w io.Writer, m catalog.Metric, s seriesAnalysis) {
+	fmt.Fprintln(w, s.Label)
+	a := s.Analysis
+	if a == nil {
+		fmt.Fprintln(w, "  No measured points")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "  Points\t%d (%d–%d)\n", a.Points, a.FirstYear, a.LastYear)
+	if a.CAGR != nil {
+		fmt.Fprintf(tw, "  Compound change\t%+.2f%% per year\n", *a.CAGR)
+	} else {
+		fmt.Fprintf(tw, "  Compound change\tn/a\n")
+	}
+	if t := a.Trend; t != nil {
+		line := fmt.Sprintf("%+.3f %s", t.Slope, perYear(m))
+		if t.SlopeLow != nil {
+			line += fmt.Sprintf(" (%.0f%% CI %+.3f to %+.3f)", t.Confidence*100, *t.SlopeLow, *t.SlopeHigh)
+		}
+		fmt.Fprintf(tw, "  Trend\t%s, R² %.2f\n", line, t.RSquared)
+	}
+	if len(a.ChangePoints) == 0 {
+		fmt.Fprintf(tw, "  Change points\tnone\n")
+	}
+	for i, cp := range a.ChangePoints {
+		label := ""
+		if i == 0 {
+			label = "Change points"
+		}
+		fmt.Fprintf(tw, "  %s\t%d (after %d): slope %+.3f → %+.3f per year, jump %+.2f, p=%.4f\n",
+			label, cp.Year, cp.PreviousYear, cp.SlopeBefore, cp.SlopeAfter, cp.Shift, cp.PValue)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "  YEAR\tVALUE\t%d-YR AVG\tTREND\t\n", a.RollingWindow)
+	rolling := make(map[int]analytics.Average, len(a.Rolling))
+	for _, avg := range a.Rolling {
+		rolling[avg.Year] = avg
+	}
+	for _, dp := range s.data {
+		if dp.Imputed {
+			continue
+		}
+		trend := ""
+		if a.Trend != nil {
+			trend = fmt.Sprintf("%.1f", a.Trend.At(dp.Year))
+		}
+		fmt.Fprintf(tw, "  %d\t%.1f%s\t%.1f\t%s\t\n", dp.Year, dp.Value, m.UnitSuffix, rolling[dp.Year].Value, trend)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	changesSource string
+	changesSince  string
+	changesRun    string
+)
+
+var changesCmd = &cobra.Command{
+	Use:   "changes",
+	Short: "List values revised by previous downloads",
+	Long: `List stored values that a download changed or removed.
+
+Every download records the old and new value of each revised observation,
+tagged with the run that made the change. Use this to review upstream
+revisions (e.g. a re-released ACS estimate) or to spot a bad parse.
+
+Examples:
+  edu-stats changes
+  edu-stats changes --source census_attainment
+  edu-stats changes --since 2026-01-01
+  edu-stats changes --run census_attainment-20260105T120000.000Z`,
+	RunE: runChanges,
+}
+
+func init() {
+	changesCmd.Flags().StringVar(&changesSource, "source", "", "Only show revisions from this source (e.g. nces_digest)")
+	changesCmd.Flags().StringVar(&changesSince, "since", "", "Only show revisions recorded on or after this date (YYYY-MM-DD)")
+	changesCmd.Flags().StringVar(&changesRun, "run", "", "Only show revisions made by this run (the RUN column)")
+}
+
+func runChanges(cmd *cobra.Command, args []string) error {
+	var since time.Time
+	if changesSince != "" {
+		t, err := time.Parse("2006-01-02", changesSince)
+		if err != nil {
+			return fmt.Errorf("invalid --since date, use YYYY-MM-DD: %w", err)
+		}
+		since = t
+	}
+
+	db, err := database.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	revisions, err := database.GetRevisions(db, changesSource, changesRun, since)
+	if err != nil {
+		return fmt.Errorf("failed to read revision history: %w", err)
+	}
+
+	if len(revisions) == 0 {
+		fmt.Println("No revisions recorded.")
+		return nil
+	}
+
+	fmt.Printf("%-19s  %-22s  %-34s  %4s  %-28s  %10s  %10s  %s\n",
+		"RECORDED", "SOURCE", "TABLE", "YEAR", "SERIES", "OLD", "NEW", "RUN")
+	runs := make(map[string]bool)
+	for _, r := range revisions {
+		series := r.SeriesKey
+		if series == "" {
+			series = "-"
+		}
+		fmt.Printf("%-19s  %-22s  %-34s  %4d  %-28s  %10s  %10s  %s\n",
+			r.RecordedAt.Format("2006-01-02 15:04:05"), r.Source, r.Table+"."+r.Column, r.Year,
+			series, formatRevisionValue(r.OldValue), formatRevisionValue(r.NewValue), r.RunID)
+		runs[r.RunID] = true
+	}
+	fmt.Printf("\n%d revisions across %d runs\n", len(revisions), len(runs))
+	return nil
+}
+
+func formatRevisionValue(v sql.NullFloat64) string {
+	if !v.Valid {
+		return "(none)"
+	}
+	return fmt.Sprintf("%.2f", v.Float64)
+}
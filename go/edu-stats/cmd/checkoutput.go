@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aallbrig/proficiency-comparison/internal/generators"
+	"github.com/spf13/cobra"
+)
+
+var checkOutputCmd = &cobra.Command{
+	Use:   "check-output [dir]",
+	Short: "Validate generated JSON files against their published schemas",
+	Long: `Check every stat file, index.json, cohorts.json and generations.json in the
+Hugo data directory (or dir) against the JSON Schema of the schemaVersion it
+declares. Files without a schemaVersion are checked as version 1.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runCheckOutput,
+}
+
+func runCheckOutput(cmd *cobra.Command, args []string) error {
+	dir := generators.OutputDir()
+	if len(args) == 1 {
+		dir = args[0]
+	}
+
+	checks, err := generators.CheckOutput(dir)
+	if err != nil {
+		return err
+	}
+	if len(checks) == 0 {
+		return fmt.Errorf("no generated files in %s", dir)
+	}
+
+	failed := 0
+	for _, c := range checks {
+		if c.Err != nil {
+			failed++
+			fmt.Printf("  ❌ %s (%s v%d): %v\n", c.File, c.Kind, c.Version, c.Err)
+			continue
+		}
+		fmt.Printf("  ✓ %s (%s v%d)\n", c.File, c.Kind, c.Version)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files do not match their schema", failed, len(checks))
+	}
+	return nil
+}
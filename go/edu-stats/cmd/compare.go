@@ -0,0 +1,430 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/cohorts"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/generators"
+	"github.com/spf13/cobra"
+)
+
+var (
+	compareGenerations []string
+	compareStats       []string
+	compareFormat      string
+	compareChart       string
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare [birth-year birth-year]",
+	Short: "Compare two birth years or generations across every stat",
+	Long: `Compare birth cohorts without opening the Hugo site.
+
+With two birth years, each stat is looked up at its life-stage offset for
+each cohort (e.g. NAEP Grade 8 at age ~14) using the same mapping written to
+cohorts.json. The output lists the measured year used for each cohort and
+the absolute and relative difference (second minus first). When both
+measurements publish a standard error, the difference gets its 95% margin
+and the p-value of a z-test that it is zero; differences below p=0.05 are
+starred. A difference between years on either side of a series break in the
+catalog (e.g. NAEP Long-Term Trend to Main NAEP) is marked † and the break
+is named below the table. --chart also draws the comparison to an SVG or PNG
+file.
+
+With --generation, each generation's cohorts are summarised per stat as the
+mean, minimum, maximum, least-squares trend per year and number of distinct
+observations. These are the figures in generations.json. Generations can be
+given by key, name or short name (e.g. millennial, z, "Gen X").
+
+Examples:
+  edu-stats compare 1965 1995
+  edu-stats compare 1965 1995 --stats literacy,proficiency --format csv
+  edu-stats compare 1965 1995 --chart comparison.svg
+  edu-stats compare --generation millennial --generation z`,
+	RunE: runCompare,
+}
+
+func init() {
+	compareCmd.Flags().StringArrayVar(&compareGenerations, "generation", nil, "Generation to compare (repeatable)")
+	compareCmd.Flags().StringSliceVar(&compareStats, "stats", nil, "Only compare these stats (e.g. literacy,attainment)")
+	compareCmd.Flags().StringVar(&compareFormat, "format", "table", "Output format: table, json or csv")
+	compareCmd.Flags().StringVar(&compareChart, "chart", "", "Also draw a birth-year comparison to this .svg or .png file")
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	switch {
+	case len(compareGenerations) > 0 && len(args) > 0:
+		return fmt.Errorf("give either two birth years or --generation, not both")
+	case len(compareGenerations) == 0 && len(args) != 2:
+		return fmt.Errorf("compare needs two birth years (e.g. compare 1965 1995) or --generation")
+	case compareChart != "" && len(compareGenerations) > 0:
+		return fmt.Errorf("--chart draws birth-year comparisons; for generations use 'step generate-assets --charts'")
+	}
+	for _, stat := range compareStats {
+		if _, ok := cohorts.Stages[stat]; !ok {
+			return fmt.Errorf("unknown stat %q", stat)
+		}
+	}
+	switch compareFormat {
+	case "table", "json", "csv":
+	default:
+		return fmt.Errorf("unknown format %q, use table, json or csv", compareFormat)
+	}
+
+	db, err := database.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	stats, err := generators.NewHugoGenerator(db).Stats()
+	if err != nil {
+		return fmt.Errorf("failed to load stats: %w", err)
+	}
+	if len(compareStats) > 0 {
+		filtered := make(map[string]generators.StatData)
+		for _, stat := range compareStats {
+			if data, ok := stats[stat]; ok {
+				filtered[stat] = data
+			}
+		}
+		stats = filtered
+	}
+
+	if len(compareGenerations) > 0 {
+		return compareGenerationSummaries(os.Stdout, stats)
+	}
+	return compareBirthYears(os.Stdout, stats, args)
+}
+
+// cohortComparison is the JSON layout of a birth-year comparison.
+type cohortComparison struct {
+	BirthYears  [2]int                   `json:"birthYears"`
+	Generations [2]string                `json:"generations"`
+	Stats       []cohorts.StatComparison `json:"stats"`
+	// Breaks lists, per stat, the series breaks between the two measured
+	// years.
+	Breaks map[string][]catalog.Break `json:"seriesBreaks,omitempty"`
+}
+
+// seriesBreaks returns the catalog breaks of stat that separate values
+// measured in years a and b, in either order.
+func seriesBreaks(stat string, a, b int) []catalog.Break {
+	m, ok := catalog.Get(stat)
+	if !ok {
+		return nil
+	}
+	return m.BreaksBetween(min(a, b), max(a, b))
+}
+
+// breakNotes lists breaks as the lines printed under a comparison table.
+func breakNotes(stat string, breaks []catalog.Break) []string {
+	var notes []string
+	for _, br := range breaks {
+		notes = append(notes, fmt.Sprintf("† %s: series break in %d (%s).", stat, br.Year, br.Label))
+	}
+	return notes
+}
+
+func compareBirthYears(w io.Writer, stats map[string]generators.StatData, args []string) error {
+	var years [2]int
+	for i, arg := range args {
+		year, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid birth year %q", arg)
+		}
+		years[i] = year
+	}
+
+	built := generators.BuildCohorts(stats)
+	if len(stats) > 0 {
+		first, last := built[0].BirthYear, built[len(built)-1].BirthYear
+		for _, year := range years {
+			if year < first || year > last {
+				return fmt.Errorf("birth year %d is outside the cohorts with data (%d-%d)", year, first, last)
+			}
+		}
+	}
+	var pair [2]cohorts.Cohort
+	for i, year := range years {
+		pair[i] = cohorts.Cohort{BirthYear: year}
+		for _, c := range built {
+			if c.BirthYear == year {
+				pair[i] = c
+			}
+		}
+	}
+
+	result := cohortComparison{
+		BirthYears:  years,
+		Generations: [2]string{pair[0].Generation, pair[1].Generation},
+		Stats:       cohorts.Compare(pair[0], pair[1], compareStats),
+	}
+	for _, sc := range result.Stats {
+		if sc.A == nil || sc.B == nil {
+			continue
+		}
+		if breaks := seriesBreaks(sc.Stat, sc.A.Year, sc.B.Year); len(breaks) > 0 {
+			if result.Breaks == nil {
+				result.Breaks = make(map[string][]catalog.Break)
+			}
+			result.Breaks[sc.Stat] = breaks
+		}
+	}
+	if compareChart != "" {
+		if err := generators.ComparisonChart(years, result.Stats).WriteFile(compareChart); err != nil {
+			return err
+		}
+	}
+
+	switch compareFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	case "csv":
+		return writeComparisonCSV(w, result)
+	}
+
+	if len(result.Stats) == 0 {
+		fmt.Fprintln(w, "No observations loaded.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "STAT\tAGE\t%d\tYEAR\t%d\tYEAR\tDIFF\tDIFF %%\tP\n", years[0], years[1])
+	tested := false
+	var notes []string
+	for _, sc := range result.Stats {
+		a, aYear := formatMeasurement(sc.A)
+		b, bYear := formatMeasurement(sc.B)
+		diff := formatDiff(sc.Absolute, "%+.1f")
+		if sc.StandardError != nil {
+			diff += fmt.Sprintf(" ±%.1f", database.Z95*(*sc.StandardError))
+			tested = true
+		}
+		if breaks := result.Breaks[sc.Stat]; len(breaks) > 0 {
+			diff += " †"
+			notes = append(notes, breakNotes(sc.Stat, breaks)...)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			sc.Stat, sc.Label, a, aYear, b, bYear, diff, formatDiff(sc.Relative, "%+.1f%%"), formatPValue(sc))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if tested {
+		fmt.Fprintf(w, "\n± is the 95%% margin of the difference; * marks p < %.2f (z-test on the published standard errors).\n",
+			cohorts.SignificanceLevel)
+	}
+	if len(notes) > 0 {
+		fmt.Fprintln(w)
+		for _, note := range notes {
+			fmt.Fprintln(w, note)
+		}
+	}
+	return nil
+}
+
+// formatPValue shows a comparison's p-value, starred when significant.
+func formatPValue(sc cohorts.StatComparison) string {
+	if sc.PValue == nil {
+		return "-"
+	}
+	p := fmt.Sprintf("%.3f", *sc.PValue)
+	if *sc.PValue < 0.001 {
+		p = "<0.001"
+	}
+	if sc.Significant {
+		p += " *"
+	}
+	return p
+}
+
+func formatMeasurement(m *cohorts.Measurement) (string, string) {
+	if m == nil {
+		return "-", "-"
+	}
+	value := fmt.Sprintf("%.1f", m.Value)
+	if m.Imputed {
+		value += " est."
+	}
+	return value, strconv.Itoa(m.Year)
+}
+
+func formatDiff(v *float64, format string) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf(format, *v)
+}
+
+func writeComparisonCSV(w io.Writer, result cohortComparison) error {
+	cw := csv.NewWriter(w)
+	a, b := strconv.Itoa(result.BirthYears[0]), strconv.Itoa(result.BirthYears[1])
+	header := []string{"stat", "offset", "value_" + a, "year_" + a, "value_" + b, "year_" + b, "absolute_diff", "relative_diff_pct",
+		"se_" + a, "se_" + b, "diff_se", "p_value", "series_breaks"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, sc := range result.Stats {
+		row := []string{sc.Stat, strconv.Itoa(cohorts.Stages[sc.Stat].Offset)}
+		for _, m := range []*cohorts.Measurement{sc.A, sc.B} {
+			if m == nil {
+				row = append(row, "", "")
+				continue
+			}
+			row = append(row, strconv.FormatFloat(m.Value, 'f', -1, 64), strconv.Itoa(m.Year))
+		}
+		for _, v := range []*float64{sc.Absolute, sc.Relative} {
+			if v == nil {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, strconv.FormatFloat(*v, 'f', 4, 64))
+		}
+		for _, m := range []*cohorts.Measurement{sc.A, sc.B} {
+			if m == nil || m.StandardError == nil {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, strconv.FormatFloat(*m.StandardError, 'f', -1, 64))
+		}
+		for _, v := range []*float64{sc.StandardError, sc.PValue} {
+			if v == nil {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, strconv.FormatFloat(*v, 'f', 4, 64))
+		}
+		var breaks []string
+		for _, br := range result.Breaks[sc.Stat] {
+			breaks = append(breaks, strconv.Itoa(br.Year))
+		}
+		row = append(row, strings.Join(breaks, ";"))
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func compareGenerationSummaries(w io.Writer, stats map[string]generators.StatData) error {
+	var wanted []cohorts.Generation
+	for _, name := range compareGenerations {
+		g, ok := cohorts.FindGeneration(name)
+		if !ok {
+			return fmt.Errorf("unknown generation %q", name)
+		}
+		wanted = append(wanted, g)
+	}
+
+	summaries := make(map[string]cohorts.GenerationSummary)
+	for _, s := range cohorts.Summarize(generators.BuildCohorts(stats)) {
+		summaries[s.Key] = s
+	}
+
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	switch compareFormat {
+	case "json":
+		out := make([]cohorts.GenerationSummary, 0, len(wanted))
+		for _, g := range wanted {
+			s, ok := summaries[g.Key]
+			if !ok {
+				s = cohorts.GenerationSummary{Generation: g, Stats: map[string]cohorts.GenerationStat{}}
+			}
+			out = append(out, s)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"stat", "generation", "mean", "min", "max", "slope", "observations", "first_year", "last_year"}); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			for _, g := range wanted {
+				s, ok := summaries[g.Key].Stats[key]
+				if !ok {
+					continue
+				}
+				slope := ""
+				if s.Slope != nil {
+					slope = strconv.FormatFloat(*s.Slope, 'f', 4, 64)
+				}
+				if err := cw.Write([]string{key, g.Key,
+					strconv.FormatFloat(s.Mean, 'f', 4, 64),
+					strconv.FormatFloat(s.Min, 'f', -1, 64),
+					strconv.FormatFloat(s.Max, 'f', -1, 64),
+					slope, strconv.Itoa(s.Observations),
+					strconv.Itoa(s.FirstYear), strconv.Itoa(s.LastYear)}); err != nil {
+					return err
+				}
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	if len(keys) == 0 {
+		fmt.Fprintln(w, "No observations loaded.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STAT\tGENERATION\tMEAN\tMIN\tMAX\tTREND/YR\tN\tYEARS")
+	var notes []string
+	for _, key := range keys {
+		label := fmt.Sprintf("%s (%s)", key, cohorts.Stages[key].Label)
+		var means []float64
+		var measured []cohorts.GenerationStat
+		for _, g := range wanted {
+			s, ok := summaries[g.Key].Stats[key]
+			if !ok {
+				fmt.Fprintf(tw, "%s\t%s\t-\t-\t-\t-\t0\t-\n", label, g.Name)
+				label = ""
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%.1f\t%.1f\t%.1f\t%s\t%d\t%d-%d\n",
+				label, g.Name, s.Mean, s.Min, s.Max, formatDiff(s.Slope, "%+.2f"), s.Observations, s.FirstYear, s.LastYear)
+			label = ""
+			means = append(means, s.Mean)
+			measured = append(measured, s)
+		}
+		if len(wanted) == 2 && len(means) == 2 {
+			delta := fmt.Sprintf("%+.1f", means[1]-means[0])
+			// Either generation's years may reach across a break.
+			a, b := measured[0], measured[1]
+			if breaks := seriesBreaks(key, min(a.FirstYear, b.FirstYear), max(a.LastYear, b.LastYear)); len(breaks) > 0 {
+				delta += " †"
+				notes = append(notes, breakNotes(key, breaks)...)
+			}
+			fmt.Fprintf(tw, "\tΔ %s − %s\t%s\t\t\t\t\t\n", wanted[1].Short, wanted[0].Short, delta)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(notes) > 0 {
+		fmt.Fprintln(w)
+		for _, note := range notes {
+			fmt.Fprintln(w, note)
+		}
+	}
+	return nil
+}
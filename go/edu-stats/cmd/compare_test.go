@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aallbrig/proficiency-comparison/internal/generators"
+)
+
+func TestCompareBirthYearsOutsideData(t *testing.T) {
+	stats := map[string]generators.StatData{
+		"literacy": {Years: []generators.DataPoint{{Year: 1990, Value: 97}, {Year: 2010, Value: 99}}},
+	}
+	for _, args := range [][]string{{"1900", "1990"}, {"1965", "2011"}} {
+		err := compareBirthYears(new(bytes.Buffer), stats, args)
+		if err == nil || !strings.Contains(err.Error(), "1928-2010") {
+			t.Errorf("compare %v: error = %v, want one naming 1928-2010", args, err)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := compareBirthYears(buf, stats, []string{"1970", "2010"}); err != nil {
+		t.Fatalf("compare inside the range: %v", err)
+	}
+	if !strings.Contains(buf.String(), "literacy") {
+		t.Errorf("comparison should list literacy:\n%s", buf.String())
+	}
+}
+
+func TestCompareMarksSeriesBreaks(t *testing.T) {
+	stats := map[string]generators.StatData{
+		"proficiency": {Years: []generators.DataPoint{{Year: 1999, Value: 259}, {Year: 2009, Value: 264}}},
+	}
+	buf := new(bytes.Buffer)
+	if err := compareBirthYears(buf, stats, []string{"1985", "1995"}); err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "+5.0 †") || !strings.Contains(out, "† proficiency: series break in 2002") {
+		t.Errorf("a comparison across the 2002 NAEP break should be marked:\n%s", out)
+	}
+
+	buf.Reset()
+	if err := compareBirthYears(buf, stats, []string{"1985", "1986"}); err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+	if strings.Contains(buf.String(), "†") {
+		t.Errorf("a comparison within one series should not be marked:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/aallbrig/proficiency-comparison/internal/analytics"
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/generators"
+	"github.com/aallbrig/proficiency-comparison/internal/schema"
+	"github.com/spf13/cobra"
+)
+
+var (
+	correlateLag    int
+	correlateScan   int
+	correlateCohort bool
+	correlateMatrix bool
+	correlateFormat string
+)
+
+var correlateCmd = &cobra.Command{
+	Use:   "correlate <stat> <stat>",
+	Short: "Correlate two stats by calendar year or birth cohort, with lag scans",
+	Long: `Relate two stats' headline series.
+
+By default values measured in the same calendar year are paired. With
+--cohort each stat's years are first shifted back by its life-stage offset,
+so values of the same birth cohort are paired (e.g. graduation at ~18 with
+NAEP Grade 8 at ~14 for the same birth year). --lag N pairs the first stat
+in year Y with the second in year Y+N; --scan N correlates every lag from
+-N to N and marks the strongest.
+
+Pearson's r (with its two-sided p-value) and Spearman's rank correlation
+are given with the number of overlapping years. Fewer than 5 overlapping
+years trigger a warning; fewer than 3 give no coefficients. Only measured
+years are used.
+
+--matrix prints every pair of stats under both alignments as JSON, the
+contents of correlations.json written by 'step generate-assets'.
+
+Examples:
+  edu-stats correlate graduation attainment
+  edu-stats correlate proficiency graduation --cohort
+  edu-stats correlate enrollment graduation --scan 8
+  edu-stats correlate --matrix`,
+	RunE: runCorrelate,
+}
+
+func init() {
+	correlateCmd.Flags().IntVar(&correlateLag, "lag", 0, "Years the second stat is measured after the first")
+	correlateCmd.Flags().IntVar(&correlateScan, "scan", 0, "Also correlate every lag from -N to N")
+	correlateCmd.Flags().BoolVar(&correlateCohort, "cohort", false, "Align by birth cohort (each stat's life-stage offset) instead of calendar year")
+	correlateCmd.Flags().BoolVar(&correlateMatrix, "matrix", false, "Print every pair of stats as JSON")
+	correlateCmd.Flags().StringVar(&correlateFormat, "format", "table", "Output format: table or json")
+}
+
+func runCorrelate(cmd *cobra.Command, args []string) error {
+	switch {
+	case correlateMatrix && len(args) > 0:
+		return fmt.Errorf("--matrix covers every stat; give no stats with it")
+	case !correlateMatrix && len(args) != 2:
+		return fmt.Errorf("correlate needs two stats (e.g. correlate graduation attainment) or --matrix")
+	case correlateScan < 0:
+		return fmt.Errorf("--scan must not be negative")
+	}
+	switch correlateFormat {
+	case "table", "json":
+	default:
+		return fmt.Errorf("unknown format %q, use table or json", correlateFormat)
+	}
+	var metrics [2]catalog.Metric
+	for i, arg := range args {
+		m, ok := catalog.Get(arg)
+		if !ok || m.QueryOnly {
+			return fmt.Errorf("unknown stat %q", arg)
+		}
+		metrics[i] = m
+	}
+
+	db, err := database.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	stats, err := generators.NewHugoGenerator(db).Stats()
+	if err != nil {
+		return fmt.Errorf("failed to load stats: %w", err)
+	}
+
+	if correlateMatrix {
+		out := generators.CorrelationMatrix(stats)
+		out.SchemaVersion = schema.Current
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	for _, m := range metrics {
+		if _, ok := stats[m.ID]; !ok {
+			return fmt.Errorf("%s has no data", m.Title)
+		}
+	}
+	alignment := generators.AlignYear
+	if correlateCohort {
+		alignment = generators.AlignCohort
+	}
+	a, b := metrics[0], metrics[1]
+	result := generators.CorrelateStats(stats, a, b, alignment, correlateLag, correlateScan)
+	var scan []analytics.Correlation
+	if correlateScan > 0 {
+		scan = analytics.ScanLags(
+			generators.CorrelationPoints(stats[a.ID], a, alignment),
+			generators.CorrelationPoints(stats[b.ID], b, alignment),
+			correlateScan)
+	}
+
+	if correlateFormat == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			generators.StatCorrelation
+			Scan []analytics.Correlation `json:"scan,omitempty"`
+		}{result, scan})
+	}
+	printCorrelation(os.Stdout, a, b, result, scan)
+	if result.LowOverlap {
+		fmt.Fprintf(os.Stderr, "⚠ Only %d overlapping years; correlations need at least %d to be meaningful\n",
+			result.Overlap, analytics.MinOverlap)
+	}
+	return nil
+}
+
+// lagText describes a lag, e.g. "2 years later".
+func lagText(lag int) string {
+	when := "later"
+	if lag < 0 {
+		lag, when = -lag, "earlier"
+	}
+	if lag == 1 {
+		return "1 year " + when
+	}
+	return fmt.Sprintf("%d years %s", lag, when)
+}
+
+// coefficient formats an optional coefficient.
+func coefficient(v *float64) string {
+	if v == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.3f", *v)
+}
+
+func printCorrelation(w io.Writer, a, b catalog.Metric, c generators.StatCorrelation, scan []analytics.Correlation) {
+	by := "calendar year"
+	if c.Alignment == generators.AlignCohort {
+		by = fmt.Sprintf("birth cohort (%s %s, %s %s)", a.ShortTitle, a.Cohort.Label, b.ShortTitle, b.Cohort.Label)
+	}
+	fmt.Fprintf(w, "%s vs %s by %s", a.Title, b.Title, by)
+	if c.Lag != 0 {
+		fmt.Fprintf(w, ", %s measured %s", b.ShortTitle, lagText(c.Lag))
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if c.Overlap > 0 {
+		fmt.Fprintf(tw, "  Overlap\t%d years (%d–%d)\n", c.Overlap, c.FirstYear, c.LastYear)
+	} else {
+		fmt.Fprintf(tw, "  Overlap\tnone\n")
+	}
+	pearson := coefficient(c.Pearson)
+	if c.PValue != nil {
+		pearson += fmt.Sprintf(" (p=%.4f)", *c.PValue)
+	}
+	fmt.Fprintf(tw, "  Pearson\t%s\n", pearson)
+	fmt.Fprintf(tw, "  Spearman\t%s\n", coefficient(c.Spearman))
+	tw.Flush()
+
+	if len(scan) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "  LAG\tOVERLAP\tPEARSON\tSPEARMAN\tP\t\t\n")
+	for _, s := range scan {
+		p, mark := "", ""
+		if s.PValue != nil {
+			p = fmt.Sprintf("%.4f", *s.PValue)
+		}
+		if c.Strongest != nil && s.Lag == c.Strongest.Lag {
+			mark = "← strongest"
+		}
+		if s.LowOverlap && s.Overlap > 0 {
+			mark = "few years"
+		}
+		fmt.Fprintf(tw, "  %+d\t%d\t%s\t%s\t%s\t%s\t\n", s.Lag, s.Overlap, coefficient(s.Pearson), coefficient(s.Spearman), p, mark)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aallbrig/proficiency-comparison/internal/coverage"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	coverageFormat string
+	coverageSource string
+)
+
+var coverageCmd = &cobra.Command{
+	Use:   "coverage",
+	Short: "Show which years each metric actually has data for",
+	Long: `Build a year-by-metric matrix from the observation tables and flag gaps.
+
+Unlike the years shown by 'status', which reflect the --years range each
+download was run with, coverage reports the years actually loaded. It also
+stores the real first year, last year and year count for every source in
+source_metadata.
+
+Examples:
+  edu-stats coverage
+  edu-stats coverage --source nces_digest
+  edu-stats coverage --format csv > coverage.csv`,
+	RunE: runCoverage,
+}
+
+func init() {
+	coverageCmd.Flags().StringVar(&coverageFormat, "format", "table", "Output format: table, csv or json")
+	coverageCmd.Flags().StringVar(&coverageSource, "source", "", "Only include this source (e.g. nces_digest)")
+}
+
+func runCoverage(cmd *cobra.Command, args []string) error {
+	db, err := database.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	report, err := coverage.Build(db, coverageSource)
+	if err != nil {
+		return fmt.Errorf("failed to build coverage report: %w", err)
+	}
+
+	sources, err := database.GetSourceMetadata(db)
+	if err != nil {
+		return fmt.Errorf("failed to read source metadata: %w", err)
+	}
+	for _, s := range sources {
+		if _, err := database.RefreshSourceCoverage(db, s.Name); err != nil {
+			return fmt.Errorf("failed to update coverage for %s: %w", s.Name, err)
+		}
+	}
+
+	switch coverageFormat {
+	case "table":
+		report.WriteTable(os.Stdout)
+		return nil
+	case "csv":
+		return report.WriteCSV(os.Stdout)
+	case "json":
+		return report.WriteJSON(os.Stdout)
+	default:
+		return fmt.Errorf("unknown format %q, use table, csv or json", coverageFormat)
+	}
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/export"
+	"github.com/aallbrig/proficiency-comparison/internal/query"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat string
+	exportOut    string
+)
+
+// exportExtensions maps each export format to its file extension.
+var exportExtensions = map[string]string{
+	"csv":      ".csv",
+	"jsonl":    ".jsonl",
+	"parquet":  ".parquet",
+	"sdmx-csv": ".sdmx.csv",
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export every observation table with dimensions and provenance",
+	Long: `Write the full observation tables for use in R, pandas or spreadsheets.
+
+Unlike the Hugo JSON files, which chart one default series per stat and
+list each other source and dimension combination as a series of year and
+value, the export keeps every row with all dimension columns, the source it
+came from and when it was first loaded. Alongside one file per table it writes:
+
+  data_dictionary.csv  type, role, unit and description of every column
+  sources.csv          each source's last download, status and loaded years
+
+Rows are ordered by year, source, dimensions and id, so exporting the same
+database twice produces identical files that can be diffed and versioned.
+
+SDMX-CSV writes one observation per value: empty dimensions become _T (all),
+and tables with several value columns gain a MEASURE dimension.
+
+Examples:
+  edu-stats export --out export/
+  edu-stats export --format parquet --out export/
+  edu-stats export --format sdmx-csv --out sdmx/`,
+	RunE: runExport,
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportFormat, "format", "csv", "Output format: csv, jsonl, parquet or sdmx-csv")
+	exportCmd.Flags().StringVar(&exportOut, "out", "export", "Directory to write the export to")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	ext, ok := exportExtensions[exportFormat]
+	if !ok {
+		return fmt.Errorf("unknown format %q, use csv, jsonl, parquet or sdmx-csv", exportFormat)
+	}
+
+	db, err := database.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if err := os.MkdirAll(exportOut, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", exportOut, err)
+	}
+
+	for _, t := range database.ObservationTables {
+		table, err := query.Dump(db, t)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", t.Name, err)
+		}
+		path := filepath.Join(exportOut, t.Name+ext)
+		err = writeFile(path, func(w io.Writer) error {
+			switch exportFormat {
+			case "jsonl":
+				return export.WriteJSONLines(w, table)
+			case "parquet":
+				return export.WriteParquet(w, table)
+			case "sdmx-csv":
+				return export.WriteSDMXCSV(w, table, sdmxLayout(t))
+			default:
+				return export.WriteCSV(w, table)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		fmt.Printf("✓ %s (%d rows)\n", path, len(table.Rows))
+	}
+
+	dictionary := filepath.Join(exportOut, "data_dictionary.csv")
+	if err := writeFile(dictionary, func(w io.Writer) error { return export.WriteCSV(w, query.Dictionary()) }); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dictionary, err)
+	}
+	fmt.Printf("✓ %s\n", dictionary)
+
+	sources, err := query.Sources(db)
+	if err != nil {
+		return fmt.Errorf("failed to read source metadata: %w", err)
+	}
+	sourcesPath := filepath.Join(exportOut, "sources.csv")
+	if err := writeFile(sourcesPath, func(w io.Writer) error { return export.WriteCSV(w, sources) }); err != nil {
+		return fmt.Errorf("failed to write %s: %w", sourcesPath, err)
+	}
+	fmt.Printf("✓ %s\n", sourcesPath)
+	return nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sdmxLayout uses the table's key columns as SDMX dimensions and its value
+// columns as measures.
+func sdmxLayout(t database.ObservationTable) export.SDMXLayout {
+	units := make(map[string]string)
+	for _, c := range query.TableColumns(t) {
+		if c.Role == query.RoleMeasure {
+			units[c.Name] = c.Unit
+		}
+	}
+	return export.SDMXLayout{
+		Dataflow:   fmt.Sprintf("EDUSTATS:%s(1.0)", strings.ToUpper(t.Name)),
+		Dimensions: t.KeyColumns,
+		TimePeriod: "year",
+		Measures:   t.ValueColumns,
+		Units:      units,
+		Attributes: []string{"source"},
+	}
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/export"
+	"github.com/aallbrig/proficiency-comparison/internal/importer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importSource string
+	importMap    []string
+)
+
+// builtinSources are the source names the downloaders own. Importing under
+// one would let the next download overwrite the imported rows.
+var builtinSources = []string{
+	"census_attainment",
+	"ecls_early_childhood",
+	"naep_proficiency",
+	"nces_digest",
+	"world_bank_literacy",
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <table> <file.csv>",
+	Short: "Load observations from a CSV file",
+	Long: `Load your own observations into one of the observation tables so they
+appear alongside the downloaded series.
+
+The CSV needs a header row. Headers matching table columns are used as-is;
+--map renames a header to a column, and --map 'Header=' ignores it. The file
+must have a year column and at least one value column. Values are checked
+against the schema (types, required columns, education_level, level,
+subject and grade values) and every problem is reported with its line.
+Optional standard_error, ci_low and ci_high columns give the sampling error
+of the table's first value column; a standard error alone gets a 95%
+confidence interval.
+
+Like a download, the import replaces the source's rows for the years the
+file covers, records revisions, and updates source_metadata.
+
+Tables: ` + tableNames() + `
+
+Examples:
+  edu-stats import graduation_rates district.csv --source district_grad --dry-run
+  edu-stats import graduation_rates district.csv --source district_grad \
+    --map "Grad Rate=rate" --map "School Year=year" --map "Notes="`,
+	Args: cobra.ExactArgs(2),
+	RunE: runImport,
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importSource, "source", "", "Source name to store the rows under (required)")
+	importCmd.Flags().StringArrayVar(&importMap, "map", nil, "Map a CSV header to a table column: 'Header=column' (repeatable)")
+	importCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Validate and show what would change without writing")
+	importCmd.Flags().IntVar(&maxRevisions, "max-revisions", 0, "Fail if the import would revise more than N stored values (0 = no limit)")
+	importCmd.MarkFlagRequired("source")
+}
+
+func tableNames() string {
+	names := make([]string, len(database.ObservationTables))
+	for i, t := range database.ObservationTables {
+		names[i] = t.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	table, ok := importer.Table(args[0])
+	if !ok {
+		return fmt.Errorf("unknown table %q, use one of: %s", args[0], tableNames())
+	}
+	for _, s := range builtinSources {
+		if importSource == s {
+			return fmt.Errorf("source %q is used by a downloader; choose another name", importSource)
+		}
+	}
+
+	mapping := make(map[string]string)
+	for _, m := range importMap {
+		from, to, ok := strings.Cut(m, "=")
+		if !ok {
+			return fmt.Errorf("invalid --map %q, use 'Header=column'", m)
+		}
+		mapping[strings.TrimSpace(from)] = strings.TrimSpace(to)
+	}
+
+	f, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	parsed, err := importer.Parse(f, table, mapping)
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[1], err)
+	}
+	if len(parsed.Errors) > 0 {
+		for _, e := range parsed.Errors {
+			fmt.Printf("  ✗ %s\n", e)
+		}
+		return fmt.Errorf("%s has %d invalid rows; nothing was imported", args[1], len(parsed.Errors))
+	}
+	if len(parsed.Rows) == 0 {
+		return fmt.Errorf("%s has no rows", args[1])
+	}
+
+	fmt.Printf("Read %d rows for %s, %d-%d\n", len(parsed.Rows), table.Name, parsed.StartYear, parsed.EndYear)
+	mapped := make([]string, 0, len(parsed.Columns))
+	for col := range parsed.Columns {
+		mapped = append(mapped, col)
+	}
+	sort.Strings(mapped)
+	for _, col := range mapped {
+		if header := parsed.Columns[col]; header != col {
+			fmt.Printf("  %s ← %q\n", col, header)
+		}
+	}
+
+	db, err := database.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	report, err := database.ReplaceWindow(db, database.WindowLoad{
+		Table:        table,
+		Source:       importSource,
+		StartYear:    parsed.StartYear,
+		EndYear:      parsed.EndYear,
+		Rows:         parsed.Rows,
+		MaxRevisions: maxRevisions,
+		DryRun:       dryRun,
+	})
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] would apply %s\n\n", report)
+		return export.WriteTable(os.Stdout, observationPreview(table, parsed.Rows, 10))
+	}
+	fmt.Printf("✓ %s\n", report)
+
+	coverage, err := database.GetSourceCoverage(db, importSource)
+	if err != nil {
+		return fmt.Errorf("failed to count %s rows: %w", importSource, err)
+	}
+	yearsRange := fmt.Sprintf("%d-%d", parsed.StartYear, parsed.EndYear)
+	if err := database.UpdateSourceMetadata(db, importSource, yearsRange, coverage.RowCount, "success",
+		fmt.Sprintf("Imported from %s: %s", args[1], report)); err != nil {
+		return fmt.Errorf("failed to update source metadata: %w", err)
+	}
+	if _, err := database.RefreshSourceCoverage(db, importSource); err != nil {
+		return fmt.Errorf("failed to update coverage for %s: %w", importSource, err)
+	}
+	return nil
+}
+
+// observationPreview lays out the first limit rows as they will be stored.
+func observationPreview(t database.ObservationTable, rows []database.Observation, limit int) export.Table {
+	cols := append([]string{"year"}, t.KeyColumns...)
+	cols = append(cols, t.ValueColumns...)
+	table := export.Table{}
+	for _, c := range cols {
+		table.Columns = append(table.Columns, export.Column{Name: c})
+	}
+	for i, obs := range rows {
+		if i == limit {
+			break
+		}
+		row := []interface{}{int64(obs.Year)}
+		for _, k := range obs.Key {
+			if k == nil {
+				row = append(row, nil)
+				continue
+			}
+			row = append(row, fmt.Sprint(k))
+		}
+		for _, v := range obs.Values {
+			if !v.Valid {
+				row = append(row, nil)
+				continue
+			}
+			row = append(row, v.Float64)
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	if len(rows) > limit {
+		fmt.Printf("First %d of %d rows:\n", limit, len(rows))
+	}
+	return table
+}
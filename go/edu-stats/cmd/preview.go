@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/generators"
+	"github.com/aallbrig/proficiency-comparison/internal/preview"
+	"github.com/aallbrig/proficiency-comparison/internal/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	previewAddr     string
+	previewInterval time.Duration
+	previewNoHugo   bool
+)
+
+var previewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Serve the Hugo site with live reload, regenerating data as the database changes",
+	Long: `Serve the site from the Go binary while you work on the data.
+
+preview generates the Hugo JSON assets, serves hugo/site/static (including
+the generated data) and, when hugo is installed, the pages of a hugo build.
+It then watches:
+
+  the SQLite database   any write (a pipeline step, import or reset in
+                        another terminal) regenerates the stats whose
+                        tables changed, as 'step generate-assets' would,
+                        validating the changed data first
+  hugo/site             changes to static files, layouts or content
+                        rebuild the pages
+
+and tells open browser tabs to reload over server-sent events.
+
+Examples:
+  edu-stats preview
+  edu-stats preview --addr localhost:8000 --interval 500ms`,
+	Args: cobra.NoArgs,
+	RunE: runPreview,
+}
+
+func init() {
+	previewCmd.Flags().StringVar(&previewAddr, "addr", "localhost:1313", "Address to listen on")
+	previewCmd.Flags().DurationVar(&previewInterval, "interval", time.Second, "How often to check for changes")
+	previewCmd.Flags().BoolVar(&previewNoHugo, "no-hugo", false, "Serve static files only, without building pages with hugo")
+	previewCmd.Flags().BoolVar(&skipValidation, "skip-validation", false, "Generate even if the last validation run found errors")
+}
+
+func runPreview(cmd *cobra.Command, args []string) error {
+	db, err := database.Open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	dataDir := generators.OutputDir()
+	staticDir := filepath.Dir(dataDir)
+	siteDir := filepath.Dir(staticDir)
+	generator := generators.NewHugoGenerator(db)
+	if err := revalidate(db); err != nil {
+		return err
+	}
+	if err := generateValidated(db, generator); err != nil {
+		return err
+	}
+
+	roots := []string{staticDir}
+	var site *hugoBuild
+	if !previewNoHugo {
+		if site, err = newHugoBuild(siteDir); err != nil {
+			fmt.Printf("  ℹ %v; serving static files only\n", err)
+		} else {
+			defer site.Close()
+			roots = append(roots, site.dir)
+		}
+	}
+	server := preview.New(roots...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	dbFiles := []string{database.DatabaseFile, database.DatabaseFile + "-wal"}
+	go preview.Watch(ctx, previewInterval,
+		func() (string, error) { return preview.Fingerprint(dbFiles) },
+		func() {
+			files, err := regenerate(db, generator, dataDir)
+			if err != nil {
+				fmt.Printf("  ✗ Regenerating assets failed: %v\n", err)
+				return
+			}
+			if len(files) == 0 {
+				return
+			}
+			payload, _ := json.Marshal(map[string]interface{}{"files": files})
+			fmt.Printf("  ↻ %d file(s) changed, reloading %d browser(s)\n", len(files), server.Clients())
+			server.Notify(preview.Event{Name: "data", Data: string(payload)})
+		},
+		func(err error) { fmt.Printf("  Warning: could not check the database: %v\n", err) },
+	)
+
+	sitePaths := []string{staticDir, filepath.Join(siteDir, "layouts"), filepath.Join(siteDir, "content"), filepath.Join(siteDir, "hugo.toml")}
+	go preview.Watch(ctx, previewInterval,
+		// Generated data has its own watcher; the staging directory
+		// generate-assets swaps in is skipped by name below.
+		func() (string, error) { return siteFingerprint(sitePaths, dataDir) },
+		func() {
+			if site != nil {
+				if err := site.Build(); err != nil {
+					fmt.Printf("  ✗ hugo build failed: %v\n", err)
+					return
+				}
+			}
+			fmt.Printf("  ↻ Site changed, reloading %d browser(s)\n", server.Clients())
+			server.Notify(preview.Event{Name: "reload", Data: "site"})
+		},
+		func(err error) { fmt.Printf("  Warning: could not check the site: %v\n", err) },
+	)
+
+	httpServer := &http.Server{Addr: previewAddr, Handler: server, ReadHeaderTimeout: 10 * time.Second}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+	fmt.Printf("\nPreview at http://%s (watching %s)\n", displayAddr(previewAddr), database.GetDatabasePath())
+	fmt.Println("Press Ctrl+C to stop")
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	fmt.Println("\nShutting down...")
+	server.Close()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// siteFingerprint fingerprints the site sources, leaving out the data
+// directory and the staging directories generate-assets creates beside it
+// and in the content directory. Regenerated content pages do count, so
+// the pages are rebuilt after the data changes.
+func siteFingerprint(paths []string, dataDir string) (string, error) {
+	skip := []string{dataDir}
+	staging, _ := filepath.Glob(filepath.Join(filepath.Dir(dataDir), "."+filepath.Base(dataDir)+"-*"))
+	contentStaging, _ := filepath.Glob(filepath.Join(generators.ContentDir(dataDir), ".edu-stats-*"))
+	skip = append(skip, staging...)
+	return preview.Fingerprint(paths, append(skip, contentStaging...)...)
+}
+
+// regenerate runs an incremental generate-assets and returns the files
+// whose contents changed, from the manifests before and after. The
+// changed data is validated first, and like generate-assets it stops when
+// that finds errors.
+func regenerate(db *sql.DB, generator *generators.HugoGenerator, dataDir string) ([]string, error) {
+	before := readManifest(dataDir)
+	if err := revalidate(db); err != nil {
+		return nil, err
+	}
+	if err := generateValidated(db, generator); err != nil {
+		return nil, err
+	}
+	after := readManifest(dataDir)
+	if before.RunID != "" && before.RunID == after.RunID {
+		return nil, nil
+	}
+	old := make(map[string]string, len(before.Files))
+	for _, f := range before.Files {
+		old[f.Path] = f.SHA256
+	}
+	var changed []string
+	for _, f := range after.Files {
+		if old[f.Path] != f.SHA256 {
+			changed = append(changed, f.Path)
+		}
+		delete(old, f.Path)
+	}
+	for p := range old {
+		changed = append(changed, p)
+	}
+	return changed, nil
+}
+
+// revalidate runs the validate step when the data changed after the latest
+// validation run, or was never validated, so the generate-assets gate judges
+// the data preview is about to publish. Writing the results changes the
+// database once more, but the next pass finds the run current.
+func revalidate(db *sql.DB) error {
+	if skipValidation {
+		return nil
+	}
+	runID, _, err := validate.LatestRun(db)
+	if err != nil {
+		return fmt.Errorf("could not read validation results: %w", err)
+	}
+	if runID != "" {
+		current, err := validate.Current(db, runID)
+		if err != nil {
+			return fmt.Errorf("could not compare validation run %s with the data: %w", runID, err)
+		}
+		if current {
+			return nil
+		}
+	}
+	summary, err := validate.Run(db, validate.DefaultRules)
+	if err != nil {
+		return fmt.Errorf("validating the changed data: %w", err)
+	}
+	fmt.Printf("  ℹ Validated the changed data: %d errors, %d warnings (run %s)\n", summary.Errors, summary.Warnings, summary.RunID)
+	return nil
+}
+
+func readManifest(dir string) generators.Manifest {
+	var m generators.Manifest
+	if data, err := os.ReadFile(filepath.Join(dir, "manifest.json")); err == nil {
+		json.Unmarshal(data, &m)
+	}
+	return m
+}
+
+// hugoBuild renders the site's pages into a temporary directory.
+type hugoBuild struct {
+	siteDir string
+	dir     string
+}
+
+func newHugoBuild(siteDir string) (*hugoBuild, error) {
+	if _, err := exec.LookPath("hugo"); err != nil {
+		return nil, fmt.Errorf("hugo is not installed, so pages are not built")
+	}
+	dir, err := os.MkdirTemp("", "edu-stats-preview-")
+	if err != nil {
+		return nil, err
+	}
+	b := &hugoBuild{siteDir: siteDir, dir: dir}
+	if err := b.Build(); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *hugoBuild) Build() error {
+	out, err := exec.Command("hugo", "--source", b.siteDir, "--destination", b.dir, "--baseURL", "/", "--quiet").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("hugo: %v\n%s", err, out)
+	}
+	return nil
+}
+
+func (b *hugoBuild) Close() {
+	os.RemoveAll(b.dir)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/generators"
+	"github.com/aallbrig/proficiency-comparison/internal/validate"
+)
+
+func TestRegenerateStopsOnValidationErrors(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`
+		CREATE TABLE validation_results (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			run_id TEXT NOT NULL, rule TEXT NOT NULL, severity TEXT NOT NULL, data_version INTEGER
+		);
+		CREATE TABLE data_versions (table_name TEXT PRIMARY KEY, version INTEGER NOT NULL DEFAULT 0);
+		INSERT INTO validation_results (run_id, rule, severity, data_version) VALUES ('run-1', 'range', 'error', 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// The generator has no tables to read, so getting past the gate fails
+	// differently.
+	files, err := regenerate(db, generators.NewHugoGenerator(db), t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "last validation run run-1 found 1 errors") {
+		t.Errorf("regenerate = (%v, %v), want the validation error", files, err)
+	}
+}
+
+func TestRevalidateOnlyWhenDataChanged(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := database.ApplySchema(db); err != nil {
+		t.Skipf("schema.sql not found: %v", err)
+	}
+	lastID := func() int64 {
+		t.Helper()
+		var id int64
+		if err := db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM validation_results`).Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	current := func() bool {
+		t.Helper()
+		runID, _, err := validate.LatestRun(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := validate.Current(db, runID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	// Never validated: preview validates before generating.
+	if err := revalidate(db); err != nil {
+		t.Fatalf("revalidate: %v", err)
+	}
+	if lastID() == 0 || !current() {
+		t.Fatal("revalidate did not record a current validation run")
+	}
+
+	// Nothing changed since: no new run.
+	before := lastID()
+	if err := revalidate(db); err != nil {
+		t.Fatalf("revalidate: %v", err)
+	}
+	if lastID() != before {
+		t.Error("revalidate re-ran validation although the data had not changed")
+	}
+
+	// A load in another terminal makes the run stale until preview
+	// validates again.
+	if _, err := db.Exec(`INSERT INTO graduation_rates (year, rate, source) VALUES (2020, 86.5, 'test')`); err != nil {
+		t.Fatal(err)
+	}
+	if current() {
+		t.Fatal("run still current after a load")
+	}
+	if err := revalidate(db); err != nil {
+		t.Fatalf("revalidate: %v", err)
+	}
+	if lastID() == before || !current() {
+		t.Error("revalidate did not validate the loaded data")
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/export"
+	"github.com/aallbrig/proficiency-comparison/internal/query"
+	"github.com/spf13/cobra"
+)
+
+var (
+	queryYears  string
+	querySource string
+	queryFormat string
+	queryDims   = map[string]*string{
+		"state":   new(string),
+		"gender":  new(string),
+		"race":    new(string),
+		"grade":   new(string),
+		"subject": new(string),
+	}
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query <metric>",
+	Short: "Extract observations for one metric with filters",
+	Long: `Print the stored observations for a metric without writing SQL.
+
+Every metric is returned with the same columns: metric, year, its
+dimensions under canonical names, value, unit and source. Units are percent
+(0–100), scale_score (NAEP 0–500) or value (early childhood measures, whose
+unit depends on the measure column).
+
+Metrics: ` + strings.Join(query.IDs(), ", ") + `
+
+--gender and --race filter the demographic group column on tables that have
+no dedicated gender or race column; those tables accept only one of the two.
+Filtering on a dimension the metric does not have is an error.
+
+Examples:
+  edu-stats query graduation --years 2010-2020
+  edu-stats query proficiency --subject reading --grade 8 --format csv
+  edu-stats query attainment --source census_attainment --format json
+  edu-stats query enrollment --format parquet > enrollment.parquet`,
+	Args: cobra.ExactArgs(1),
+	RunE: runQuery,
+}
+
+func init() {
+	queryCmd.Flags().StringVar(&queryYears, "years", "", "Year or year range (YYYY or YYYY-YYYY)")
+	queryCmd.Flags().StringVar(&querySource, "source", "", "Only include this source (e.g. nces_digest)")
+	queryCmd.Flags().StringVar(&queryFormat, "format", "table", "Output format: "+strings.Join(export.Formats, ", "))
+	queryCmd.Flags().StringVar(queryDims["state"], "state", "", "State (e.g. CA)")
+	queryCmd.Flags().StringVar(queryDims["gender"], "gender", "", "Gender")
+	queryCmd.Flags().StringVar(queryDims["race"], "race", "", "Race/ethnicity")
+	queryCmd.Flags().StringVar(queryDims["grade"], "grade", "", "Grade (4, 8 or 12)")
+	queryCmd.Flags().StringVar(queryDims["subject"], "subject", "", "Subject (e.g. reading, mathematics)")
+}
+
+func runQuery(cmd *cobra.Command, args []string) error {
+	metric, ok := query.Lookup(args[0])
+	if !ok {
+		return fmt.Errorf("unknown metric %q, use one of: %s", args[0], strings.Join(query.IDs(), ", "))
+	}
+
+	filter := query.Filter{Source: querySource, Dimensions: make(map[string]string)}
+	if queryYears != "" {
+		var err error
+		if strings.Contains(queryYears, "-") {
+			filter.FromYear, filter.ToYear, err = parseYears(queryYears)
+		} else {
+			_, err = fmt.Sscanf(queryYears, "%d", &filter.FromYear)
+			filter.ToYear = filter.FromYear
+		}
+		if err != nil {
+			return fmt.Errorf("invalid --years: %w", err)
+		}
+	}
+	for name, value := range queryDims {
+		if *value != "" {
+			filter.Dimensions[name] = *value
+		}
+	}
+
+	if queryFormat == "parquet" {
+		if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			return fmt.Errorf("refusing to write parquet to a terminal; redirect output to a file")
+		}
+	}
+
+	db, err := database.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	table, err := query.Run(db, metric, filter)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	return export.Write(os.Stdout, queryFormat, table)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/generators"
+	"github.com/aallbrig/proficiency-comparison/internal/report"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportFormat        string
+	reportOutput        string
+	reportDefaultSeries []string
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Write a narrative summary of every stat as Markdown or HTML",
+	Long: `Write a document summarising every stat for readers who won't open JSON.
+
+Each stat gets a short narrative and a table with its latest value, the
+change over 10 and 30 years (to the nearest measured year within the stat's
+search window), record high and low, coverage and missing years, series
+breaks, data-quality findings from the latest validation run and the source
+citation. Changes that span a series break are called out. Years filled in
+by --resample are never used; all figures are measured values.
+
+The figures come from the same queries as 'step generate-assets', so pass the
+same --default-series overrides to match the site. The HTML report is a
+single self-contained page with each stat's chart inlined as SVG.
+
+Examples:
+  edu-stats report > report.md
+  edu-stats report --format html -o report.html
+  edu-stats report --default-series proficiency:subject=math,grade=8`,
+	RunE: runReport,
+}
+
+func init() {
+	reportCmd.Flags().StringVar(&reportFormat, "format", report.FormatMarkdown, "Output format: md or html")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "Write the report to this file instead of stdout")
+	reportCmd.Flags().StringArrayVar(&reportDefaultSeries, "default-series", nil, "Headline series for a stat: stat:col=value,... (repeatable)")
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	switch reportFormat {
+	case report.FormatMarkdown, report.FormatHTML:
+	default:
+		return fmt.Errorf("unknown format %q, use md or html", reportFormat)
+	}
+
+	db, err := database.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	generator := generators.NewHugoGenerator(db)
+	generator.DefaultSeries = make(map[string]generators.SeriesSelector)
+	for _, s := range reportDefaultSeries {
+		stat, selector, err := generators.ParseSeriesSelector(s)
+		if err != nil {
+			return err
+		}
+		generator.DefaultSeries[stat] = selector
+	}
+
+	r, err := report.Build(db, generator)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if reportOutput != "" {
+		f, err := os.Create(reportOutput)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := report.Write(w, r, reportFormat); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if reportOutput != "" {
+		fmt.Fprintf(os.Stderr, "✓ Report written to %s\n", reportOutput)
+	}
+	return nil
+}
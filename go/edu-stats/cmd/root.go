@@ -34,4 +34,15 @@ func init() {
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(syncCmd)
 	rootCmd.AddCommand(resetCmd)
+	rootCmd.AddCommand(changesCmd)
+	rootCmd.AddCommand(coverageCmd)
+	rootCmd.AddCommand(compareCmd)
+	rootCmd.AddCommand(queryCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(previewCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(analyzeCmd)
+	rootCmd.AddCommand(correlateCmd)
 }
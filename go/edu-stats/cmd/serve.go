@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aallbrig/proficiency-comparison/internal/api"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr    string
+	serveOrigins []string
+	serveQuiet   bool
+	serveMaxCost int
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the database as a read-only JSON API",
+	Long: `Expose the SQLite database over HTTP so the Hugo site can run against
+live data in development and other tools can query it directly.
+
+Endpoints:
+  GET /metrics                      every metric with its dimensions and coverage
+  GET /metrics/{id}                 one metric
+  GET /metrics/{id}/series          observations, filtered with ?from=&to=&source=
+                                    and any dimension (?state=CA&grade=8), paged
+                                    with ?limit= (default 1000) and ?offset=
+  GET /cohorts/{birthYear}          the cohort born that year, as in cohorts.json
+  GET /sources                      each source's last download and loaded years
+  GET|POST /graphql                 GraphQL over the same data: one field per metric
+                                    with its dimensions as arguments, plus metrics,
+                                    sources and cohorts
+  GET /graphql/schema               the GraphQL schema (SDL)
+
+Responses carry an ETag; send it back in If-None-Match to get 304 Not Modified
+until the data changes. The database is opened read-only. GraphQL queries
+whose estimated cost (fields times page sizes, plus 100 per metric queried)
+is above --max-complexity are rejected before they touch the database.
+GraphQL introspection (__schema, __type) is supported.
+
+Examples:
+  edu-stats serve
+  edu-stats serve --addr 127.0.0.1:9000 --cors-origin http://localhost:1313
+  curl 'localhost:8080/metrics/proficiency/series?state=CA&grade=8'
+  curl localhost:8080/graphql -H 'Content-Type: application/json' \
+    -d '{"query":"{ proficiency(state: \"CA\", limit: 20) { year value source { name } } }"}'`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", ":8080", "Address to listen on")
+	serveCmd.Flags().StringSliceVar(&serveOrigins, "cors-origin", []string{"*"}, "Origins allowed to call the API from a browser (repeatable; empty disables CORS)")
+	serveCmd.Flags().BoolVar(&serveQuiet, "quiet", false, "Do not log requests")
+	serveCmd.Flags().IntVar(&serveMaxCost, "max-complexity", api.DefaultMaxComplexity, "Largest estimated cost of a GraphQL query")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	db, err := database.OpenReadOnly()
+	if err != nil {
+		return fmt.Errorf("%w (run 'edu-stats init' first)", err)
+	}
+	defer db.Close()
+
+	opts := api.Options{MaxComplexity: serveMaxCost}
+	for _, o := range serveOrigins {
+		if o = strings.TrimSpace(o); o != "" {
+			opts.AllowOrigins = append(opts.AllowOrigins, o)
+		}
+	}
+	if !serveQuiet {
+		opts.Log = os.Stdout
+	}
+
+	server := &http.Server{
+		Addr:              serveAddr,
+		Handler:           api.New(db, opts),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+	fmt.Printf("Serving %s on http://%s\n", database.GetDatabasePath(), displayAddr(serveAddr))
+	fmt.Println("Press Ctrl+C to stop")
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Println("\nShutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// displayAddr turns a listen address such as ":8080" into one a browser can
+// open.
+func displayAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
@@ -21,9 +21,12 @@ func init() {
 	stepCmd.AddCommand(downloadNCESCmd)
 	stepCmd.AddCommand(downloadNAEPCmd)
 	stepCmd.AddCommand(downloadECLSCmd)
+	stepCmd.AddCommand(validateCmd)
 	stepCmd.AddCommand(generateAssetsCmd)
+	stepCmd.AddCommand(checkOutputCmd)
 	
 	// Add flags that steps might need
 	stepCmd.PersistentFlags().StringVar(&years, "years", "1970-2025", "Year range to download (format: YYYY-YYYY)")
 	stepCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Simulate without downloading data")
+	stepCmd.PersistentFlags().IntVar(&maxRevisions, "max-revisions", 0, "Fail a download that would revise more than N stored values (0 = no limit)")
 }
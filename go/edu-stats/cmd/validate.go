@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/validate"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Run data quality checks on loaded observations",
+	Long: `Check every observation table against its declared data quality rules:
+value ranges, units, year coverage, year-over-year change limits, required
+years and duplicate logical keys.
+
+Findings are written to the validation_results table. Any error-level finding
+fails the step, and generate-assets refuses to run until a clean validation
+of the current data: data loaded after the latest run must be validated again.`,
+	RunE: runValidate,
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	db, err := database.Open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	fmt.Println("  Validating observation tables...")
+	summary, err := validate.Run(db, validate.DefaultRules)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range summary.Results {
+		icon := "⚠"
+		if r.Severity == validate.SeverityError {
+			icon = "❌"
+		}
+		year := ""
+		if r.Year != 0 {
+			year = fmt.Sprintf(" %d", r.Year)
+		}
+		fmt.Printf("    %s [%s] %s%s: %s\n", icon, r.Rule, r.Series, year, r.Message)
+	}
+
+	fmt.Printf("  %d errors, %d warnings (run %s)\n", summary.Errors, summary.Warnings, summary.RunID)
+	if summary.Errors > 0 {
+		return fmt.Errorf("validation found %d errors", summary.Errors)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+// Package analytics describes how a year/value series moves: its compound
+// annual change, trailing averages, least-squares trend with a confidence
+// interval for the slope, and structural change points where the trend
+// shifts (for example NAEP reading falling after 2017).
+//
+// Every figure is computed from measured points only; callers leave out
+// years filled in by resampling.
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+// Point is one measured value.
+type Point struct {
+	Year  int
+	Value float64
+}
+
+// Options tunes Analyze.
+type Options struct {
+	// Window is the span, in years, of each trailing average.
+	Window int
+	// Confidence is the level of the slope's confidence interval.
+	Confidence float64
+	// Alpha is the significance level a change point must reach.
+	Alpha float64
+	// MinSegment is the fewest points either side of a change point.
+	MinSegment int
+}
+
+// DefaultOptions are used for generated JSON and by analyze unless
+// overridden.
+var DefaultOptions = Options{Window: 5, Confidence: 0.95, Alpha: 0.01, MinSegment: 2}
+
+// Trend is an ordinary least-squares line through a series, with the
+// slope in units per year.
+type Trend struct {
+	Slope     float64 `json:"slope"`
+	Intercept float64 `json:"intercept"`
+	RSquared  float64 `json:"rSquared"`
+	// SlopeStdErr, SlopeLow and SlopeHigh are omitted for fewer than three
+	// points, where the slope has no residual variance to estimate from.
+	SlopeStdErr *float64 `json:"slopeStdErr,omitempty"`
+	SlopeLow    *float64 `json:"slopeLow,omitempty"`
+	SlopeHigh   *float64 `json:"slopeHigh,omitempty"`
+	Confidence  float64  `json:"confidence"`
+	Points      int      `json:"points"`
+	FirstYear   int      `json:"firstYear"`
+	LastYear    int      `json:"lastYear"`
+}
+
+// At returns the trend's value in year.
+func (t Trend) At(year int) float64 {
+	return t.Intercept + t.Slope*float64(year)
+}
+
+// Average is the trailing average ending at one measured year.
+type Average struct {
+	Year  int     `json:"year"`
+	Value float64 `json:"value"`
+	// Points is how many measurements fall within the window.
+	Points int `json:"points"`
+}
+
+// ChangePoint is where a series' trend breaks: separate lines before and
+// after it fit significantly better than one line through both.
+type ChangePoint struct {
+	// Year is the first measured year of the new trend and PreviousYear
+	// the last of the old one.
+	Year         int     `json:"year"`
+	PreviousYear int     `json:"previousYear"`
+	SlopeBefore  float64 `json:"slopeBefore"`
+	SlopeAfter   float64 `json:"slopeAfter"`
+	// Shift is the jump at Year between the line after and the line
+	// before, extended.
+	Shift float64 `json:"shift"`
+	// PValue is from a Chow test of the two lines against one.
+	PValue float64 `json:"pValue"`
+}
+
+// Analysis is everything Analyze reports about a series.
+type Analysis struct {
+	Points    int `json:"points"`
+	FirstYear int `json:"firstYear,omitempty"`
+	LastYear  int `json:"lastYear,omitempty"`
+	// CAGR is the compound annual change from the first to the last point,
+	// in percent per year; omitted when either value is not positive.
+	CAGR          *float64      `json:"cagr,omitempty"`
+	Trend         *Trend        `json:"trend,omitempty"`
+	RollingWindow int           `json:"rollingWindow"`
+	Rolling       []Average     `json:"rolling,omitempty"`
+	ChangePoints  []ChangePoint `json:"changePoints,omitempty"`
+}
+
+// Analyze computes every figure for points, which need not be sorted. Years
+// appearing more than once keep their last value.
+func Analyze(points []Point, opts Options) Analysis {
+	if opts.Window <= 0 {
+		opts.Window = DefaultOptions.Window
+	}
+	if opts.Confidence <= 0 || opts.Confidence >= 1 {
+		opts.Confidence = DefaultOptions.Confidence
+	}
+	if opts.Alpha <= 0 || opts.Alpha >= 1 {
+		opts.Alpha = DefaultOptions.Alpha
+	}
+	if opts.MinSegment < 2 {
+		opts.MinSegment = DefaultOptions.MinSegment
+	}
+
+	pts := normalize(points)
+	a := Analysis{Points: len(pts), RollingWindow: opts.Window}
+	if len(pts) == 0 {
+		return a
+	}
+	first, last := pts[0], pts[len(pts)-1]
+	a.FirstYear, a.LastYear = first.Year, last.Year
+	a.CAGR = CAGR(first, last)
+	if t, ok := Fit(pts, opts.Confidence); ok {
+		a.Trend = &t
+	}
+	a.Rolling = Rolling(pts, opts.Window)
+	a.ChangePoints = ChangePoints(pts, opts.Alpha, opts.MinSegment)
+	return a
+}
+
+// normalize sorts points by year, keeping the last value given for a year.
+func normalize(points []Point) []Point {
+	byYear := make(map[int]float64, len(points))
+	for _, p := range points {
+		byYear[p.Year] = p.Value
+	}
+	out := make([]Point, 0, len(byYear))
+	for year, value := range byYear {
+		out = append(out, Point{year, value})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Year < out[j].Year })
+	return out
+}
+
+// CAGR returns the compound annual change from first to last in percent
+// per year, or nil when the years are equal or either value is not
+// positive.
+func CAGR(first, last Point) *float64 {
+	years := last.Year - first.Year
+	if years <= 0 || first.Value <= 0 || last.Value <= 0 {
+		return nil
+	}
+	rate := (math.Pow(last.Value/first.Value, 1/float64(years)) - 1) * 100
+	return &rate
+}
+
+// Rolling returns, for each point of a sorted series, the mean of the
+// points measured within the window years ending at it.
+func Rolling(pts []Point, window int) []Average {
+	out := make([]Average, len(pts))
+	start := 0
+	sum := 0.0
+	for i, p := range pts {
+		sum += p.Value
+		for pts[start].Year <= p.Year-window {
+			sum -= pts[start].Value
+			start++
+		}
+		n := i - start + 1
+		out[i] = Average{Year: p.Year, Value: sum / float64(n), Points: n}
+	}
+	return out
+}
+
+// line is a least-squares fit and its residual sum of squares.
+type line struct {
+	slope, intercept, sse, sxx, syy float64
+}
+
+// ols fits a line through pts, which must hold at least two distinct years.
+func ols(pts []Point) line {
+	n := float64(len(pts))
+	var mx, my float64
+	for _, p := range pts {
+		mx += float64(p.Year)
+		my += p.Value
+	}
+	mx /= n
+	my /= n
+	var sxx, sxy, syy float64
+	for _, p := range pts {
+		dx, dy := float64(p.Year)-mx, p.Value-my
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	l := line{sxx: sxx, syy: syy}
+	l.slope = sxy / sxx
+	l.intercept = my - l.slope*mx
+	for _, p := range pts {
+		r := p.Value - (l.intercept + l.slope*float64(p.Year))
+		l.sse += r * r
+	}
+	return l
+}
+
+// Fit returns the least-squares trend through a sorted series with a
+// confidence interval for its slope at the given level. It reports false
+// for fewer than two points.
+func Fit(pts []Point, confidence float64) (Trend, bool) {
+	if len(pts) < 2 {
+		return Trend{}, false
+	}
+	l := ols(pts)
+	t := Trend{
+		Slope:      l.slope,
+		Intercept:  l.intercept,
+		RSquared:   1,
+		Confidence: confidence,
+		Points:     len(pts),
+		FirstYear:  pts[0].Year,
+		LastYear:   pts[len(pts)-1].Year,
+	}
+	if l.syy > 0 {
+		t.RSquared = 1 - l.sse/l.syy
+	}
+	if df := len(pts) - 2; df > 0 {
+		se := math.Sqrt(l.sse / float64(df) / l.sxx)
+		margin := studentTQuantile(1-(1-confidence)/2, float64(df)) * se
+		low, high := l.slope-margin, l.slope+margin
+		t.SlopeStdErr, t.SlopeLow, t.SlopeHigh = &se, &low, &high
+	}
+	return t, true
+}
+
+// ChangePoints finds trend breaks in a sorted series by binary
+// segmentation: the split whose two separate lines leave the least
+// residual is kept if a Chow test rejects one common line at alpha, and
+// each side is then searched again. Each segment keeps at least minSegment
+// points.
+func ChangePoints(pts []Point, alpha float64, minSegment int) []ChangePoint {
+	var out []ChangePoint
+	var search func(seg []Point)
+	search = func(seg []Point) {
+		n := len(seg)
+		// Two lines use four parameters; the test needs residual degrees
+		// of freedom left over.
+		if n < 2*minSegment || n <= 4 {
+			return
+		}
+		best, bestSSE := -1, math.Inf(1)
+		var before, after line
+		for k := minSegment; k <= n-minSegment; k++ {
+			l1, l2 := ols(seg[:k]), ols(seg[k:])
+			if sse := l1.sse + l2.sse; sse < bestSSE {
+				best, bestSSE, before, after = k, sse, l1, l2
+			}
+		}
+		whole := ols(seg)
+		df := float64(n - 4)
+		var p float64
+		switch {
+		case whole.sse <= bestSSE:
+			return
+		case bestSSE == 0:
+			p = 0
+		default:
+			f := ((whole.sse - bestSSE) / 2) / (bestSSE / df)
+			p = fSurvival(f, 2, df)
+		}
+		if p >= alpha {
+			return
+		}
+		year := float64(seg[best].Year)
+		out = append(out, ChangePoint{
+			Year:         seg[best].Year,
+			PreviousYear: seg[best-1].Year,
+			SlopeBefore:  before.slope,
+			SlopeAfter:   after.slope,
+			Shift:        (after.intercept + after.slope*year) - (before.intercept + before.slope*year),
+			PValue:       p,
+		})
+		search(seg[:best])
+		search(seg[best:])
+	}
+	search(pts)
+	sort.Slice(out, func(i, j int) bool { return out[i].Year < out[j].Year })
+	return out
+}
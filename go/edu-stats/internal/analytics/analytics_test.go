@@ -0,0 +1,191 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+func TestDistributions(t *testing.T) {
+	for _, c := range []struct {
+		p, df, want float64
+	}{
+		{0.975, 10, 2.2281},
+		{0.975, 1, 12.7062},
+		{0.95, 30, 1.6973},
+		{0.5, 5, 0},
+	} {
+		if got := studentTQuantile(c.p, c.df); !near(got, c.want, 1e-3) {
+			t.Errorf("studentTQuantile(%v, %v) = %.4f, want %.4f", c.p, c.df, got, c.want)
+		}
+	}
+	// F(2, 10) critical value at 5% is 4.103.
+	if got := fSurvival(4.1028, 2, 10); !near(got, 0.05, 1e-3) {
+		t.Errorf("fSurvival(4.1028, 2, 10) = %.4f, want 0.05", got)
+	}
+	if got := fSurvival(0, 2, 10); got != 1 {
+		t.Errorf("fSurvival(0) = %v, want 1", got)
+	}
+}
+
+func TestCAGRAndRolling(t *testing.T) {
+	got := CAGR(Point{2000, 100}, Point{2010, 200})
+	if got == nil || !near(*got, 7.1773, 1e-3) {
+		t.Errorf("CAGR doubling over 10 years = %v, want 7.18%%", got)
+	}
+	if CAGR(Point{2000, 0}, Point{2010, 5}) != nil || CAGR(Point{2000, 5}, Point{2000, 6}) != nil {
+		t.Error("CAGR should be nil from zero or over no years")
+	}
+
+	pts := []Point{{2000, 10}, {2001, 20}, {2004, 30}, {2010, 40}}
+	rolling := Rolling(pts, 5)
+	want := []Average{{2000, 10, 1}, {2001, 15, 2}, {2004, 20, 3}, {2010, 40, 1}}
+	for i, w := range want {
+		if rolling[i].Year != w.Year || !near(rolling[i].Value, w.Value, 1e-9) || rolling[i].Points != w.Points {
+			t.Errorf("rolling[%d] = %+v, want %+v", i, rolling[i], w)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	// y = 2x - 3990 plus alternating noise.
+	var pts []Point
+	for i, year := range []int{2000, 2001, 2002, 2003, 2004, 2005} {
+		noise := 0.5
+		if i%2 == 1 {
+			noise = -0.5
+		}
+		pts = append(pts, Point{year, 2*float64(year) - 3990 + noise})
+	}
+	trend, ok := Fit(pts, 0.95)
+	if !ok {
+		t.Fatal("Fit reported no trend")
+	}
+	if !near(trend.Slope, 2, 0.2) || trend.RSquared < 0.95 {
+		t.Errorf("trend = %+v, want slope ~2 with a tight fit", trend)
+	}
+	if trend.SlopeLow == nil || *trend.SlopeLow >= trend.Slope || *trend.SlopeHigh <= trend.Slope {
+		t.Errorf("confidence interval %v-%v should bracket the slope %v", trend.SlopeLow, trend.SlopeHigh, trend.Slope)
+	}
+	if !near(trend.At(2010), 30, 1) {
+		t.Errorf("At(2010) = %v, want ~30", trend.At(2010))
+	}
+
+	two, ok := Fit(pts[:2], 0.95)
+	if !ok || two.SlopeStdErr != nil || two.SlopeLow != nil {
+		t.Errorf("two points should fit a line without an interval: %+v", two)
+	}
+	if _, ok := Fit(pts[:1], 0.95); ok {
+		t.Error("one point should not fit a trend")
+	}
+}
+
+// naepReading is the NAEP long-term trend and main reading average for
+// 13-year-olds / Grade 8, rising for decades and falling after 2017.
+var naepReading = []Point{
+	{1971, 255}, {1975, 256}, {1980, 259}, {1984, 257}, {1988, 258}, {1990, 257},
+	{1992, 260}, {1994, 260}, {1996, 259}, {1999, 259}, {2002, 264}, {2003, 263},
+	{2005, 262}, {2007, 263}, {2009, 264}, {2011, 265}, {2013, 266}, {2015, 265},
+	{2017, 267}, {2019, 263}, {2022, 260},
+}
+
+func TestChangePointsFindsNAEPDrop(t *testing.T) {
+	a := Analyze(naepReading, DefaultOptions)
+	if a.Points != 21 || a.FirstYear != 1971 || a.LastYear != 2022 {
+		t.Errorf("analysis covers %d points %d-%d", a.Points, a.FirstYear, a.LastYear)
+	}
+	if a.Trend == nil || a.Trend.Slope <= 0 {
+		t.Errorf("overall trend should be rising: %+v", a.Trend)
+	}
+	// The falling segment may start at the 2017 peak or just after it.
+	var drop *ChangePoint
+	for i, cp := range a.ChangePoints {
+		if cp.Year >= 2017 && cp.Year <= 2019 {
+			drop = &a.ChangePoints[i]
+		}
+	}
+	if drop == nil {
+		t.Fatalf("change points %+v should include the fall after 2017", a.ChangePoints)
+	}
+	if drop.SlopeBefore <= 0 || drop.SlopeAfter >= 0 || drop.PValue >= DefaultOptions.Alpha {
+		t.Errorf("drop = %+v, want a significant turn from rising to falling", *drop)
+	}
+}
+
+func TestChangePointsIgnoresSteadySeries(t *testing.T) {
+	var pts []Point
+	for i := 0; i < 20; i++ {
+		noise := []float64{0.3, -0.2, 0.1, -0.4, 0.2}[i%5]
+		pts = append(pts, Point{1990 + i, 50 + 0.5*float64(i) + noise})
+	}
+	if cps := ChangePoints(pts, 0.01, 2); len(cps) != 0 {
+		t.Errorf("a steady trend should have no change points, got %+v", cps)
+	}
+	if cps := ChangePoints(pts[:4], 0.01, 2); len(cps) != 0 {
+		t.Errorf("too few points to test, got %+v", cps)
+	}
+}
+
+func TestAnalyzeEmptyAndDuplicates(t *testing.T) {
+	if a := Analyze(nil, Options{}); a.Points != 0 || a.Trend != nil || a.RollingWindow != DefaultOptions.Window {
+		t.Errorf("empty analysis = %+v", a)
+	}
+	a := Analyze([]Point{{2001, 5}, {2000, 4}, {2001, 6}}, DefaultOptions)
+	if a.Points != 2 || a.Trend == nil || a.Trend.Slope != 2 {
+		t.Errorf("duplicates should keep the last value: %+v", a)
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	a := []Point{{2000, 1}, {2001, 2}, {2002, 3}, {2003, 4}, {2004, 5}, {2005, 6}}
+	// b rises with a two years later, but not linearly.
+	b := []Point{{2002, 1}, {2003, 4}, {2004, 9}, {2005, 16}, {2006, 25}, {2007, 36}, {2010, 0}}
+
+	c := Correlate(a, b, 2)
+	if c.Overlap != 6 || c.FirstYear != 2000 || c.LastYear != 2005 || c.LowOverlap {
+		t.Errorf("lag 2 overlap = %+v, want 6 years 2000-2005", c)
+	}
+	if c.Spearman == nil || *c.Spearman != 1 {
+		t.Errorf("Spearman = %v, want 1 for a monotonic relationship", c.Spearman)
+	}
+	if c.Pearson == nil || *c.Pearson >= 1 || *c.Pearson < 0.95 || c.PValue == nil || *c.PValue > 0.01 {
+		t.Errorf("Pearson = %v (p %v), want strong but below 1", c.Pearson, c.PValue)
+	}
+
+	sparse := Correlate(a, b, 0)
+	if sparse.Overlap != 4 || !sparse.LowOverlap {
+		t.Errorf("lag 0 = %+v, want 4 overlapping years flagged as low", sparse)
+	}
+	if none := Correlate(a, b, -10); none.Overlap != 0 || none.Pearson != nil {
+		t.Errorf("no overlap = %+v", none)
+	}
+	// A constant series has no correlation.
+	if flat := Correlate(a, []Point{{2000, 3}, {2001, 3}, {2002, 3}}, 0); flat.Pearson != nil || flat.Spearman != nil {
+		t.Errorf("constant series = %+v, want no coefficients", flat)
+	}
+
+	// noisy is mirrored exactly two years later by echo.
+	noisy := []Point{{2000, 1}, {2001, 3}, {2002, 2}, {2003, 5}, {2004, 4}, {2005, 6}}
+	echo := []Point{{2002, 2}, {2003, 6}, {2004, 4}, {2005, 10}, {2006, 8}, {2007, 12}}
+	scan := ScanLags(noisy, echo, 3)
+	if len(scan) != 7 || scan[0].Lag != -3 || scan[6].Lag != 3 {
+		t.Fatalf("scan lags = %+v", scan)
+	}
+	if best, ok := Strongest(scan); !ok || best.Lag != 2 || *best.Pearson != 1 {
+		t.Errorf("strongest = %+v, want lag 2", best)
+	}
+}
+
+func TestRanksAverageTies(t *testing.T) {
+	got := ranks([]float64{10, 30, 20, 30})
+	want := []float64{1, 3.5, 2, 3.5}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ranks = %v, want %v", got, want)
+			break
+		}
+	}
+}
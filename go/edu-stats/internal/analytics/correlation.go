@@ -0,0 +1,165 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+// MinOverlap is the fewest paired years a correlation is considered
+// reliable with; fewer are reported with LowOverlap set.
+const MinOverlap = 5
+
+// minPairs is the fewest pairs any coefficient is computed from.
+const minPairs = 3
+
+// Pair is one year's values of two aligned series.
+type Pair struct {
+	// Year is the year of the first series' value; the second's was
+	// measured Lag years later.
+	Year int
+	A, B float64
+}
+
+// Align pairs each point of a with the point of b measured lag years
+// later. Both series should be sorted and hold one point per year, as
+// after Analyze's normalization; years without a match are dropped.
+func Align(a, b []Point, lag int) []Pair {
+	byYear := make(map[int]float64, len(b))
+	for _, p := range b {
+		byYear[p.Year] = p.Value
+	}
+	var pairs []Pair
+	for _, p := range normalize(a) {
+		if v, ok := byYear[p.Year+lag]; ok {
+			pairs = append(pairs, Pair{Year: p.Year, A: p.Value, B: v})
+		}
+	}
+	return pairs
+}
+
+// Correlation relates two series at one lag.
+type Correlation struct {
+	Lag       int `json:"lag"`
+	Overlap   int `json:"overlap"`
+	FirstYear int `json:"firstYear,omitempty"`
+	LastYear  int `json:"lastYear,omitempty"`
+	// Pearson, Spearman and PValue are omitted when fewer than three years
+	// overlap or either series is constant over them. PValue is the
+	// two-sided p-value of Pearson's r against no correlation.
+	Pearson  *float64 `json:"pearson,omitempty"`
+	Spearman *float64 `json:"spearman,omitempty"`
+	PValue   *float64 `json:"pValue,omitempty"`
+	// LowOverlap is set when fewer than MinOverlap years overlap.
+	LowOverlap bool `json:"lowOverlap,omitempty"`
+}
+
+// Correlate aligns a and b at lag and computes both coefficients.
+func Correlate(a, b []Point, lag int) Correlation {
+	pairs := Align(a, b, lag)
+	c := Correlation{Lag: lag, Overlap: len(pairs), LowOverlap: len(pairs) < MinOverlap}
+	if len(pairs) == 0 {
+		return c
+	}
+	c.FirstYear, c.LastYear = pairs[0].Year, pairs[len(pairs)-1].Year
+	if len(pairs) < minPairs {
+		return c
+	}
+	xs, ys := make([]float64, len(pairs)), make([]float64, len(pairs))
+	for i, p := range pairs {
+		xs[i], ys[i] = p.A, p.B
+	}
+	if r, ok := pearson(xs, ys); ok {
+		c.Pearson = &r
+		p := pearsonPValue(r, len(pairs))
+		c.PValue = &p
+	}
+	if rho, ok := pearson(ranks(xs), ranks(ys)); ok {
+		c.Spearman = &rho
+	}
+	return c
+}
+
+// ScanLags correlates a and b at every lag from -maxLag to maxLag.
+func ScanLags(a, b []Point, maxLag int) []Correlation {
+	if maxLag < 0 {
+		maxLag = -maxLag
+	}
+	out := make([]Correlation, 0, 2*maxLag+1)
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		out = append(out, Correlate(a, b, lag))
+	}
+	return out
+}
+
+// Strongest returns the scanned correlation with the largest |Pearson|
+// among those with at least MinOverlap years, or false if none has one.
+func Strongest(scan []Correlation) (Correlation, bool) {
+	best, found := Correlation{}, false
+	for _, c := range scan {
+		if c.Pearson == nil || c.LowOverlap {
+			continue
+		}
+		if !found || math.Abs(*c.Pearson) > math.Abs(*best.Pearson) {
+			best, found = c, true
+		}
+	}
+	return best, found
+}
+
+// pearson is the sample correlation of xs and ys; it reports false when
+// either has no variance.
+func pearson(xs, ys []float64) (float64, bool) {
+	n := float64(len(xs))
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx /= n
+	my /= n
+	var sxy, sxx, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, false
+	}
+	r := sxy / math.Sqrt(sxx*syy)
+	return math.Max(-1, math.Min(1, r)), true
+}
+
+// pearsonPValue is the two-sided p-value of r from n pairs under the null
+// hypothesis of no correlation.
+func pearsonPValue(r float64, n int) float64 {
+	df := float64(n - 2)
+	if math.Abs(r) >= 1 {
+		return 0
+	}
+	t := math.Abs(r) * math.Sqrt(df/(1-r*r))
+	return 2 * (1 - studentTCDF(t, df))
+}
+
+// ranks replaces each value by its rank, averaging tied ranks.
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+	out := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			out[order[k]] = rank
+		}
+		i = j + 1
+	}
+	return out
+}
@@ -0,0 +1,467 @@
+// Package api serves the observation database as a read-only JSON REST API:
+// the metric list, a metric's observations filtered by dimension, one birth
+// year's cohort measurements and the loaded sources. The same data is
+// available through a GraphQL schema generated from the metrics (see
+// graphql.go).
+//
+// Every response carries an ETag of its body, so clients that send
+// If-None-Match get 304 Not Modified until the data changes. Series
+// responses are paginated with limit and offset and link to the next page.
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/cohorts"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/export"
+	"github.com/aallbrig/proficiency-comparison/internal/generators"
+	"github.com/aallbrig/proficiency-comparison/internal/graphql"
+	"github.com/aallbrig/proficiency-comparison/internal/query"
+)
+
+// Page sizes for series responses.
+const (
+	DefaultLimit = 1000
+	MaxLimit     = 10000
+)
+
+// Options configures a Server.
+type Options struct {
+	// AllowOrigins lists the origins allowed to call the API from a
+	// browser; "*" allows any. Empty disables CORS headers.
+	AllowOrigins []string
+	// Log receives one line per request when set.
+	Log io.Writer
+	// MaxComplexity limits the estimated cost of a GraphQL query; 0 uses
+	// DefaultMaxComplexity.
+	MaxComplexity int
+}
+
+// Server answers API requests from one database.
+type Server struct {
+	db     *sql.DB
+	opts   Options
+	mux    *http.ServeMux
+	schema *graphql.Schema
+
+	// cohorts caches the birth-year mapping until the data version
+	// fingerprint changes.
+	mu           sync.Mutex
+	cohortsKey   string
+	cohortsByAge map[int]cohorts.Cohort
+}
+
+// New returns a Server reading from db.
+func New(db *sql.DB, opts Options) *Server {
+	s := &Server{db: db, opts: opts, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /{$}", s.handleIndex)
+	s.mux.HandleFunc("GET /metrics", s.handleMetrics)
+	s.mux.HandleFunc("GET /metrics/{id}", s.handleMetric)
+	s.mux.HandleFunc("GET /metrics/{id}/series", s.handleSeries)
+	s.mux.HandleFunc("GET /cohorts/{birthYear}", s.handleCohort)
+	s.mux.HandleFunc("GET /sources", s.handleSources)
+	s.mux.HandleFunc("GET /graphql", s.handleGraphQL)
+	s.mux.HandleFunc("POST /graphql", s.handleGraphQL)
+	s.mux.HandleFunc("GET /graphql/schema", s.handleGraphQLSchema)
+	s.schema = s.graphQLSchema()
+	return s
+}
+
+// ServeHTTP applies CORS and logging around the routes.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	s.cors(rec, r)
+	if r.Method == http.MethodOptions {
+		rec.WriteHeader(http.StatusNoContent)
+	} else {
+		s.mux.ServeHTTP(rec, r)
+	}
+	if s.opts.Log != nil {
+		fmt.Fprintf(s.opts.Log, "  %s %s %d %s\n", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Microsecond))
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (s *Server) cors(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	allowed := ""
+	for _, o := range s.opts.AllowOrigins {
+		if o == "*" {
+			allowed = "*"
+			break
+		}
+		if origin != "" && o == origin {
+			allowed = origin
+		}
+	}
+	if allowed == "" {
+		return
+	}
+	h := w.Header()
+	h.Set("Access-Control-Allow-Origin", allowed)
+	if allowed != "*" {
+		h.Add("Vary", "Origin")
+	}
+	h.Set("Access-Control-Expose-Headers", "ETag, Link")
+	if r.Method == http.MethodOptions {
+		h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		h.Set("Access-Control-Allow-Headers", "Content-Type, If-None-Match")
+		h.Set("Access-Control-Max-Age", "600")
+	}
+}
+
+// writeJSON sends v with an ETag of its encoding, or 304 when the client
+// already has it.
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	writeJSONStatus(w, r, http.StatusOK, v)
+}
+
+// writeJSONStatus sends v with the given status; only 200 responses get an
+// ETag.
+func writeJSONStatus(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	body := buf.Bytes()
+	h := w.Header()
+	if status != http.StatusOK {
+		h.Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "no-cache")
+	if matchesETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func matchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, map[string]interface{}{
+		"endpoints": []string{
+			"/metrics",
+			"/metrics/{id}",
+			"/metrics/{id}/series?from=&to=&source=&{dimension}=&limit=&offset=",
+			"/cohorts/{birthYear}",
+			"/sources",
+			"/graphql",
+			"/graphql/schema",
+		},
+	})
+}
+
+// Dimension is a metric dimension as listed by /metrics.
+type Dimension struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// MetricInfo is one entry of /metrics.
+type MetricInfo struct {
+	ID           string      `json:"id"`
+	Title        string      `json:"title,omitempty"`
+	Description  string      `json:"description"`
+	Unit         string      `json:"unit"`
+	Table        string      `json:"table"`
+	Dimensions   []Dimension `json:"dimensions"`
+	YearMin      int         `json:"yearMin,omitempty"`
+	YearMax      int         `json:"yearMax,omitempty"`
+	Observations int         `json:"observations"`
+	Series       string      `json:"series"`
+}
+
+func (s *Server) metricInfo(m query.Metric) (MetricInfo, error) {
+	info := MetricInfo{
+		ID:          m.ID,
+		Description: m.Description,
+		Unit:        m.Unit,
+		Table:       m.Table.Name,
+		Dimensions:  make([]Dimension, len(m.Dimensions)),
+		Series:      "/metrics/" + m.ID + "/series",
+	}
+	if c, ok := catalog.Get(m.ID); ok {
+		info.Title = c.Title
+	}
+	for i, d := range m.Dimensions {
+		info.Dimensions[i] = Dimension{Name: d.Name, Type: typeName(d.Type)}
+	}
+	c, err := query.MetricCoverage(s.db, m)
+	if err != nil {
+		return info, err
+	}
+	info.YearMin, info.YearMax, info.Observations = c.YearMin, c.YearMax, c.Observations
+	return info, nil
+}
+
+func typeName(t export.ColumnType) string {
+	switch t {
+	case export.Int:
+		return "integer"
+	case export.Float:
+		return "number"
+	}
+	return "string"
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics := make([]MetricInfo, 0, len(query.Metrics))
+	for _, m := range query.Metrics {
+		info, err := s.metricInfo(m)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		metrics = append(metrics, info)
+	}
+	writeJSON(w, r, map[string]interface{}{"metrics": metrics})
+}
+
+func (s *Server) lookupMetric(w http.ResponseWriter, r *http.Request) (query.Metric, bool) {
+	id := r.PathValue("id")
+	m, ok := query.Lookup(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown metric %q; see /metrics", id))
+	}
+	return m, ok
+}
+
+func (s *Server) handleMetric(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.lookupMetric(w, r)
+	if !ok {
+		return
+	}
+	info, err := s.metricInfo(m)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, r, info)
+}
+
+// SeriesPage is a /metrics/{id}/series response.
+type SeriesPage struct {
+	Metric string          `json:"metric"`
+	Unit   string          `json:"unit"`
+	Total  int             `json:"total"`
+	Offset int             `json:"offset"`
+	Limit  int             `json:"limit"`
+	Next   string          `json:"next,omitempty"`
+	Data   []export.Object `json:"data"`
+}
+
+func (s *Server) handleSeries(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.lookupMetric(w, r)
+	if !ok {
+		return
+	}
+	params := r.URL.Query()
+	filter, limit, offset, err := parseSeriesParams(m, params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	table, err := query.Run(s.db, m, filter)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	table = table.Without("metric", "unit")
+
+	page := SeriesPage{Metric: m.ID, Unit: m.Unit, Total: len(table.Rows), Offset: offset, Limit: limit}
+	end := offset + limit
+	if end > len(table.Rows) {
+		end = len(table.Rows)
+	}
+	if offset < len(table.Rows) {
+		table.Rows = table.Rows[offset:end]
+	} else {
+		table.Rows = nil
+	}
+	page.Data = table.Objects()
+	if end < page.Total {
+		next := url.Values{}
+		for k, v := range params {
+			next[k] = v
+		}
+		next.Set("offset", strconv.Itoa(end))
+		next.Set("limit", strconv.Itoa(limit))
+		page.Next = r.URL.Path + "?" + next.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", page.Next))
+	}
+	writeJSON(w, r, page)
+}
+
+// parseSeriesParams turns series query parameters into a query filter and
+// page bounds.
+func parseSeriesParams(m query.Metric, params url.Values) (query.Filter, int, int, error) {
+	filter := query.Filter{Dimensions: make(map[string]string)}
+	limit, offset := DefaultLimit, 0
+
+	dims := make(map[string]bool)
+	for _, d := range m.Dimensions {
+		dims[d.Name] = true
+	}
+	if dims["group"] {
+		dims["gender"], dims["race"] = true, true
+	}
+
+	intParam := func(name string, min, max int) (int, error) {
+		n, err := strconv.Atoi(params.Get(name))
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("%s must be a number from %d to %d", name, min, max)
+		}
+		return n, nil
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var err error
+		switch {
+		case k == "from":
+			filter.FromYear, err = intParam(k, 0, 9999)
+		case k == "to":
+			filter.ToYear, err = intParam(k, 0, 9999)
+		case k == "source":
+			filter.Source = params.Get(k)
+		case k == "limit":
+			limit, err = intParam(k, 1, MaxLimit)
+		case k == "offset":
+			offset, err = intParam(k, 0, int(^uint(0)>>1))
+		case dims[k]:
+			filter.Dimensions[k] = params.Get(k)
+		default:
+			names := make([]string, 0, len(dims))
+			for d := range dims {
+				names = append(names, d)
+			}
+			sort.Strings(names)
+			err = fmt.Errorf("unknown parameter %q; use from, to, source, limit, offset or a dimension of %s: %s",
+				k, m.ID, strings.Join(names, ", "))
+		}
+		if err != nil {
+			return filter, 0, 0, err
+		}
+	}
+	return filter, limit, offset, nil
+}
+
+func (s *Server) handleCohort(w http.ResponseWriter, r *http.Request) {
+	birthYear, err := strconv.Atoi(r.PathValue("birthYear"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "birth year must be a number")
+		return
+	}
+	byYear, err := s.cohorts()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c, ok := byYear[birthYear]
+	if !ok || len(c.Stats) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no cohort data for birth year %d", birthYear))
+		return
+	}
+	writeJSON(w, r, c)
+}
+
+// cohorts returns every birth year's measurements, rebuilding them only
+// when the data versions have changed since the last call.
+func (s *Server) cohorts() (map[int]cohorts.Cohort, error) {
+	versions, err := database.DataVersions(s.db)
+	if err != nil {
+		return nil, err
+	}
+	key := ""
+	if versions != nil {
+		tables := make([]string, 0, len(versions))
+		for t := range versions {
+			tables = append(tables, t)
+		}
+		sort.Strings(tables)
+		for _, t := range tables {
+			key += fmt.Sprintf("%s=%d;", t, versions[t])
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if versions != nil && s.cohortsByAge != nil && key == s.cohortsKey {
+		return s.cohortsByAge, nil
+	}
+	stats, err := generators.NewHugoGenerator(s.db).Stats()
+	if err != nil {
+		return nil, err
+	}
+	byYear := make(map[int]cohorts.Cohort)
+	for _, c := range generators.BuildCohorts(stats) {
+		byYear[c.BirthYear] = c
+	}
+	s.cohortsKey, s.cohortsByAge = key, byYear
+	return byYear, nil
+}
+
+func (s *Server) handleSources(w http.ResponseWriter, r *http.Request) {
+	table, err := query.Sources(s.db)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, r, map[string]interface{}{"sources": table.Objects()})
+}
@@ -0,0 +1,309 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func setupAPITestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	schema := `
+	CREATE TABLE educational_attainment (id INTEGER PRIMARY KEY, year INTEGER, age_group TEXT, education_level TEXT,
+		percentage REAL, gender TEXT, race TEXT, source TEXT, created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+	CREATE TABLE literacy_rates (id INTEGER PRIMARY KEY, year INTEGER, age_group TEXT, rate REAL, gender TEXT,
+		source TEXT, created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+	CREATE TABLE graduation_rates (id INTEGER PRIMARY KEY, year INTEGER, rate REAL, cohort_year INTEGER, state TEXT,
+		demographics TEXT, source TEXT, created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+	CREATE TABLE enrollment_rates (id INTEGER PRIMARY KEY, year INTEGER, age_group TEXT, enrollment_rate REAL,
+		level TEXT, state TEXT, demographics TEXT, source TEXT, created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+	CREATE TABLE test_proficiency (id INTEGER PRIMARY KEY, year INTEGER, subject TEXT, grade INTEGER, avg_score REAL,
+		proficiency_level TEXT, percentage_proficient REAL, state TEXT, demographics TEXT, source TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+	CREATE TABLE early_childhood (id INTEGER PRIMARY KEY, year INTEGER, cohort_year INTEGER, metric_name TEXT,
+		metric_value REAL, age_months INTEGER, demographics TEXT, source TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+	CREATE TABLE source_metadata (id INTEGER PRIMARY KEY, source_name TEXT UNIQUE, last_download DATETIME,
+		years_available TEXT, row_count INTEGER DEFAULT 0, status TEXT);
+	INSERT INTO educational_attainment (year, age_group, education_level, percentage, source) VALUES
+		(2020, '25plus', 'bachelors_plus', 37.5, 'census');
+	INSERT INTO test_proficiency (year, subject, grade, avg_score, state, source) VALUES
+		(2019, 'reading', 8, 263, NULL, 'naep'),
+		(2019, 'reading', 4, 220, NULL, 'naep'),
+		(2022, 'reading', 8, 260, NULL, 'naep'),
+		(2022, 'mathematics', 8, 274, 'CA', 'naep');
+	INSERT INTO source_metadata (source_name, last_download, years_available, status) VALUES
+		('naep', '2024-05-01 12:00:00', '2019,2022', 'success');`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	return db
+}
+
+func get(t *testing.T, h http.Handler, path string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body.String(), err)
+	}
+}
+
+func TestMetricsListsCoverage(t *testing.T) {
+	db := setupAPITestDB(t)
+	defer db.Close()
+	h := New(db, Options{})
+
+	rec := get(t, h, "/metrics", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Metrics []MetricInfo `json:"metrics"`
+	}
+	decode(t, rec, &body)
+	var found bool
+	for _, m := range body.Metrics {
+		if m.ID == "proficiency" {
+			found = true
+			if m.YearMin != 2019 || m.YearMax != 2022 || m.Observations != 4 {
+				t.Errorf("unexpected proficiency coverage %+v", m)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("proficiency missing from %s", rec.Body)
+	}
+
+	if rec := get(t, h, "/metrics/nope", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown metric: status %d", rec.Code)
+	}
+}
+
+func TestSeriesFiltersAndPages(t *testing.T) {
+	db := setupAPITestDB(t)
+	defer db.Close()
+	h := New(db, Options{})
+
+	rec := get(t, h, "/metrics/proficiency/series?grade=8&limit=2", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var page struct {
+		Total int                      `json:"total"`
+		Next  string                   `json:"next"`
+		Data  []map[string]interface{} `json:"data"`
+	}
+	decode(t, rec, &page)
+	if page.Total != 3 || len(page.Data) != 2 {
+		t.Fatalf("expected 2 of 3 grade 8 rows, got total %d, %v", page.Total, page.Data)
+	}
+	if page.Data[0]["year"] != 2019.0 || page.Data[0]["value"] != 263.0 {
+		t.Errorf("unexpected first row %v", page.Data[0])
+	}
+	if _, ok := page.Data[0]["metric"]; ok {
+		t.Errorf("rows should not repeat the metric: %v", page.Data[0])
+	}
+	if !strings.Contains(page.Next, "offset=2") || !strings.Contains(rec.Header().Get("Link"), `rel="next"`) {
+		t.Errorf("expected a next link, got %q / %q", page.Next, rec.Header().Get("Link"))
+	}
+
+	rec = get(t, h, page.Next, nil)
+	page.Next = ""
+	decode(t, rec, &page)
+	if len(page.Data) != 1 || page.Next != "" || page.Data[0]["subject"] != "reading" {
+		t.Errorf("unexpected last page %s", rec.Body)
+	}
+
+	for _, bad := range []string{"?colour=red", "?limit=0", "?grade=eighth"} {
+		if rec := get(t, h, "/metrics/proficiency/series"+bad, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", bad, rec.Code)
+		}
+	}
+}
+
+func TestETagAndCORS(t *testing.T) {
+	db := setupAPITestDB(t)
+	defer db.Close()
+	h := New(db, Options{AllowOrigins: []string{"http://localhost:1313"}})
+
+	rec := get(t, h, "/sources", map[string]string{"Origin": "http://localhost:1313"})
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status %d, etag %q", rec.Code, etag)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:1313" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), `"last_download": "2024-05-01T12:00:00Z"`) {
+		t.Errorf("unexpected sources %s", rec.Body)
+	}
+
+	rec = get(t, h, "/sources", map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("expected an empty 304, got %d %q", rec.Code, rec.Body)
+	}
+
+	rec = get(t, h, "/sources", map[string]string{"Origin": "http://evil.example"})
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unlisted origin was allowed: %q", got)
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/metrics", nil)
+	req.Header.Set("Origin", "http://localhost:1313")
+	pre := httptest.NewRecorder()
+	h.ServeHTTP(pre, req)
+	if pre.Code != http.StatusNoContent || !strings.Contains(pre.Header().Get("Access-Control-Allow-Methods"), "GET") {
+		t.Errorf("preflight: %d %v", pre.Code, pre.Header())
+	}
+}
+
+func TestCohortByBirthYear(t *testing.T) {
+	db := setupAPITestDB(t)
+	defer db.Close()
+	h := New(db, Options{})
+
+	// Attainment is measured at age 26, so 2020 data belongs to 1994.
+	rec := get(t, h, "/cohorts/1994", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var c struct {
+		BirthYear int                    `json:"birthYear"`
+		Stats     map[string]interface{} `json:"stats"`
+	}
+	decode(t, rec, &c)
+	if c.BirthYear != 1994 || c.Stats["attainment"] == nil {
+		t.Errorf("unexpected cohort %s", rec.Body)
+	}
+
+	if rec := get(t, h, "/cohorts/1850", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("empty cohort: status %d", rec.Code)
+	}
+	if rec := get(t, h, "/cohorts/ninety", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad birth year: status %d", rec.Code)
+	}
+}
+
+func TestGraphQLAcrossMetrics(t *testing.T) {
+	db := setupAPITestDB(t)
+	defer db.Close()
+	h := New(db, Options{MaxComplexity: 500})
+
+	body := `{"query":"query ($grade: Int) { naep: proficiency(grade: $grade, state: \"CA\", limit: 10) { year subject value source { name status } metric { unit } } attainment(limit: 10) { year value } cohort(birthYear: 1994) { generation attainment { year value } } }","variables":{"grade":8}}`
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Data struct {
+			NAEP []struct {
+				Year    int
+				Subject string
+				Value   float64
+				Source  struct{ Name, Status string }
+				Metric  struct{ Unit string }
+			}
+			Attainment []struct{ Year int }
+			Cohort     struct {
+				Generation string
+				Attainment struct{ Year int }
+			}
+		}
+		Errors []interface{}
+	}
+	decode(t, rec, &resp)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("errors: %v", resp.Errors)
+	}
+	naep := resp.Data.NAEP
+	if len(naep) != 1 || naep[0].Subject != "mathematics" || naep[0].Source.Status != "success" || naep[0].Metric.Unit != "scale_score" {
+		t.Errorf("unexpected proficiency rows %+v", naep)
+	}
+	if len(resp.Data.Attainment) != 1 || resp.Data.Cohort.Attainment.Year != 2020 {
+		t.Errorf("unexpected attainment or cohort: %s", rec.Body)
+	}
+
+	// The default page of 1000 rows is over this server's limit.
+	rec = get(t, h, "/graphql?query="+url.QueryEscape("{ proficiency { year value } }"), nil)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "complexity") {
+		t.Errorf("expected the complexity limit, got %d %s", rec.Code, rec.Body)
+	}
+
+	rec = get(t, h, "/graphql/schema", nil)
+	if !strings.Contains(rec.Body.String(), "proficiency(from: Int, to: Int, source: String, subject: String, grade: Int,") {
+		t.Errorf("schema does not list proficiency's dimensions:\n%s", rec.Body)
+	}
+}
+
+func TestGraphQLDefaultLimitsAndIntrospection(t *testing.T) {
+	db := setupAPITestDB(t)
+	defer db.Close()
+	h := New(db, Options{})
+
+	graphql := func(query string) *httptest.ResponseRecorder {
+		return get(t, h, "/graphql?query="+url.QueryEscape(query), nil)
+	}
+	// One full page of two fields fits; aliasing more pages does not, even
+	// of a single field or a single row.
+	if rec := graphql(`{ proficiency(limit: 10000) { year value } }`); rec.Code != http.StatusOK {
+		t.Errorf("a full page was rejected: %d %s", rec.Code, rec.Body)
+	}
+	if rec := graphql(`{ a: proficiency(limit: 10000) { year } b: attainment(limit: 10000) { year } c: literacy(limit: 10000) { year } }`); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "complexity") {
+		t.Errorf("three aliased full pages: got %d %s", rec.Code, rec.Body)
+	}
+	var aliases strings.Builder
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&aliases, "a%d: proficiency(limit: 1) { year } ", i)
+	}
+	if rec := graphql("{ " + aliases.String() + "}"); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "complexity") {
+		t.Errorf("300 aliased table queries: got %d", rec.Code)
+	}
+
+	rec := graphql(`{ __schema { queryType { name } } __type(name: "ProficiencyObservation") { fields { name type { kind ofType { name } } } } }`)
+	var resp struct {
+		Data struct {
+			Schema struct{ QueryType struct{ Name string } } `json:"__schema"`
+			Type   struct {
+				Fields []struct {
+					Name string
+					Type struct {
+						Kind   string
+						OfType struct{ Name string }
+					}
+				}
+			} `json:"__type"`
+		}
+	}
+	decode(t, rec, &resp)
+	if resp.Data.Schema.QueryType.Name != "Query" || len(resp.Data.Type.Fields) == 0 {
+		t.Fatalf("unexpected introspection result: %s", rec.Body)
+	}
+	if f := resp.Data.Type.Fields[0]; f.Name != "year" || f.Type.Kind != "NON_NULL" || f.Type.OfType.Name != "Int" {
+		t.Errorf("first ProficiencyObservation field = %+v, want year: Int!", f)
+	}
+}
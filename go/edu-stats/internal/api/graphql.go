@@ -0,0 +1,509 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/cohorts"
+	"github.com/aallbrig/proficiency-comparison/internal/export"
+	"github.com/aallbrig/proficiency-comparison/internal/graphql"
+	"github.com/aallbrig/proficiency-comparison/internal/query"
+)
+
+// GraphQL limits. A field costs one, times the page size of every list
+// above it, and each observation field adds observationCost for its table
+// query. The default complexity allows one full page (MaxLimit rows) of two
+// fields per request, so aliases cannot multiply the rows one request reads.
+const (
+	DefaultMaxComplexity = 25000
+	MaxDepth             = 8
+	maxQueryBytes        = 1 << 20
+	observationCost      = 100
+)
+
+// requestState caches lookups shared by every row of one GraphQL request.
+type requestState struct {
+	server *Server
+
+	sourcesOnce sync.Once
+	sources     map[string]map[string]interface{}
+	sourcesErr  error
+
+	mu      sync.Mutex
+	metrics map[string]map[string]interface{}
+}
+
+type stateKey struct{}
+
+func state(ctx context.Context) *requestState {
+	return ctx.Value(stateKey{}).(*requestState)
+}
+
+// source returns the provenance record for a source name. Sources without
+// metadata, such as rows loaded before it was recorded, get just a name and
+// found is false.
+func (st *requestState) source(name string) (src map[string]interface{}, found bool, err error) {
+	st.sourcesOnce.Do(func() {
+		table, err := query.Sources(st.server.db)
+		if err != nil {
+			st.sourcesErr = err
+			return
+		}
+		st.sources = make(map[string]map[string]interface{}, len(table.Rows))
+		for _, row := range table.Rows {
+			src := sourceValue(row)
+			st.sources[src["name"].(string)] = src
+		}
+	})
+	if st.sourcesErr != nil {
+		return nil, false, st.sourcesErr
+	}
+	if src, ok := st.sources[name]; ok {
+		return src, true, nil
+	}
+	return map[string]interface{}{"name": name}, false, nil
+}
+
+// sourceValue maps a query.Sources row to the Source type's fields.
+func sourceValue(row []interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":         row[0],
+		"lastDownload": row[1],
+		"status":       row[2],
+		"yearMin":      row[3],
+		"yearMax":      row[4],
+		"yearCount":    row[5],
+		"rowCount":     row[6],
+	}
+}
+
+func (st *requestState) metric(id string) (map[string]interface{}, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if v, ok := st.metrics[id]; ok {
+		return v, nil
+	}
+	m, ok := query.Lookup(id)
+	if !ok {
+		return nil, nil
+	}
+	info, err := st.server.metricInfo(m)
+	if err != nil {
+		return nil, err
+	}
+	v := metricValue(info)
+	st.metrics[id] = v
+	return v, nil
+}
+
+func metricValue(info MetricInfo) map[string]interface{} {
+	dims := make([]string, len(info.Dimensions))
+	for i, d := range info.Dimensions {
+		dims[i] = d.Name
+	}
+	v := map[string]interface{}{
+		"id":           info.ID,
+		"description":  info.Description,
+		"unit":         info.Unit,
+		"table":        info.Table,
+		"dimensions":   dims,
+		"observations": info.Observations,
+	}
+	if info.Title != "" {
+		v["title"] = info.Title
+	}
+	if info.Observations > 0 {
+		v["yearMin"], v["yearMax"] = info.YearMin, info.YearMax
+	}
+	return v
+}
+
+// graphQLSchema builds the schema from the observation metrics and the
+// metric catalog: one root field per metric with its dimensions as
+// arguments, plus metrics, sources and cohorts.
+func (s *Server) graphQLSchema() *graphql.Schema {
+	nonNull := func(t graphql.Type) graphql.Type { return &graphql.NonNull{Of: t} }
+	listOf := func(t graphql.Type) graphql.Type { return nonNull(&graphql.List{Of: nonNull(t)}) }
+
+	metricType := &graphql.Object{
+		Name:        "Metric",
+		Description: "A value column of an observation table.",
+		Fields: []*graphql.Field{
+			{Name: "id", Type: nonNull(graphql.String)},
+			{Name: "title", Type: graphql.String},
+			{Name: "description", Type: nonNull(graphql.String)},
+			{Name: "unit", Type: nonNull(graphql.String)},
+			{Name: "table", Type: nonNull(graphql.String)},
+			{Name: "dimensions", Type: listOf(graphql.String)},
+			{Name: "yearMin", Type: graphql.Int},
+			{Name: "yearMax", Type: graphql.Int},
+			{Name: "observations", Type: nonNull(graphql.Int)},
+		},
+	}
+	sourceType := &graphql.Object{
+		Name:        "Source",
+		Description: "Where observations came from and when they were last downloaded.",
+		Fields: []*graphql.Field{
+			{Name: "name", Type: nonNull(graphql.String)},
+			{Name: "lastDownload", Type: graphql.String, Description: "RFC 3339 time of the last download"},
+			{Name: "status", Type: graphql.String},
+			{Name: "yearMin", Type: graphql.Int},
+			{Name: "yearMax", Type: graphql.Int},
+			{Name: "yearCount", Type: graphql.Int},
+			{Name: "rowCount", Type: graphql.Int},
+		},
+	}
+	metricField := func(id string) *graphql.Field {
+		return &graphql.Field{Name: "metric", Type: nonNull(metricType), Resolve: func(p graphql.Params) (interface{}, error) {
+			return state(p.Context).metric(id)
+		}}
+	}
+
+	root := &graphql.Object{Name: "Query"}
+	root.Fields = append(root.Fields,
+		&graphql.Field{
+			Name: "metrics", Type: listOf(metricType), Description: "Every metric with its coverage.",
+			Items: func(map[string]interface{}) int { return len(query.Metrics) },
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				out := make([]map[string]interface{}, 0, len(query.Metrics))
+				for _, m := range query.Metrics {
+					v, err := state(p.Context).metric(m.ID)
+					if err != nil {
+						return nil, err
+					}
+					out = append(out, v)
+				}
+				return out, nil
+			},
+		},
+		&graphql.Field{
+			Name: "metric", Type: metricType,
+			Args: []*graphql.Argument{{Name: "id", Type: nonNull(graphql.String)}},
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				return state(p.Context).metric(p.Args["id"].(string))
+			},
+		},
+	)
+
+	for _, m := range query.Metrics {
+		root.Fields = append(root.Fields, s.observationField(m, sourceType, metricField(m.ID)))
+	}
+
+	root.Fields = append(root.Fields,
+		&graphql.Field{
+			Name: "sources", Type: listOf(sourceType),
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				table, err := query.Sources(s.db)
+				if err != nil {
+					return nil, err
+				}
+				out := make([]map[string]interface{}, len(table.Rows))
+				for i, row := range table.Rows {
+					out[i] = sourceValue(row)
+				}
+				return out, nil
+			},
+		},
+		&graphql.Field{
+			Name: "source", Type: sourceType,
+			Args: []*graphql.Argument{{Name: "name", Type: nonNull(graphql.String)}},
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				src, found, err := state(p.Context).source(p.Args["name"].(string))
+				if err != nil || !found {
+					return nil, err
+				}
+				return src, nil
+			},
+		},
+	)
+
+	cohortType := s.cohortType(metricField)
+	root.Fields = append(root.Fields,
+		&graphql.Field{
+			Name: "cohort", Type: cohortType, Description: "Each stat measured at its life stage for one birth year.",
+			Args: []*graphql.Argument{{Name: "birthYear", Type: nonNull(graphql.Int)}},
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				byYear, err := s.cohorts()
+				if err != nil {
+					return nil, err
+				}
+				c, ok := byYear[p.Args["birthYear"].(int)]
+				if !ok || len(c.Stats) == 0 {
+					return nil, nil
+				}
+				return cohortValue(c), nil
+			},
+		},
+		&graphql.Field{
+			Name: "cohorts", Type: listOf(cohortType), Description: "Birth years from..to that have data.",
+			Args: []*graphql.Argument{
+				{Name: "from", Type: nonNull(graphql.Int)},
+				{Name: "to", Type: nonNull(graphql.Int)},
+			},
+			Items: func(args map[string]interface{}) int {
+				if n := args["to"].(int) - args["from"].(int) + 1; n > 0 {
+					return n
+				}
+				return 0
+			},
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				byYear, err := s.cohorts()
+				if err != nil {
+					return nil, err
+				}
+				out := []map[string]interface{}{}
+				for year := p.Args["from"].(int); year <= p.Args["to"].(int); year++ {
+					if c, ok := byYear[year]; ok && len(c.Stats) > 0 {
+						out = append(out, cohortValue(c))
+					}
+				}
+				return out, nil
+			},
+		},
+	)
+
+	maxComplexity := s.opts.MaxComplexity
+	if maxComplexity == 0 {
+		maxComplexity = DefaultMaxComplexity
+	}
+	return &graphql.Schema{Query: root, MaxDepth: MaxDepth, MaxComplexity: maxComplexity}
+}
+
+// observationField is the root field listing m's observations, filtered by
+// its dimensions and paged like /metrics/{id}/series.
+func (s *Server) observationField(m query.Metric, sourceType *graphql.Object, metricField *graphql.Field) *graphql.Field {
+	nonNull := func(t graphql.Type) graphql.Type { return &graphql.NonNull{Of: t} }
+
+	obs := &graphql.Object{
+		Name:        objectName(m.ID) + "Observation",
+		Description: m.Description + " (" + m.Table.Name + "." + m.Column + ")",
+		Fields:      []*graphql.Field{{Name: "year", Type: nonNull(graphql.Int)}},
+	}
+	args := []*graphql.Argument{
+		{Name: "from", Type: graphql.Int, Description: "First year"},
+		{Name: "to", Type: graphql.Int, Description: "Last year"},
+		{Name: "source", Type: graphql.String},
+	}
+	for _, d := range m.Dimensions {
+		t := graphql.String
+		if d.Type == export.Int {
+			t = graphql.Int
+		}
+		obs.Fields = append(obs.Fields, &graphql.Field{Name: d.Name, Type: t})
+		args = append(args, &graphql.Argument{Name: d.Name, Type: t})
+		if d.Name == "group" {
+			args = append(args,
+				&graphql.Argument{Name: "gender", Type: graphql.String, Description: "Filters on group"},
+				&graphql.Argument{Name: "race", Type: graphql.String, Description: "Filters on group"},
+			)
+		}
+	}
+	obs.Fields = append(obs.Fields,
+		&graphql.Field{Name: "value", Type: nonNull(graphql.Float)},
+		&graphql.Field{Name: "unit", Type: nonNull(graphql.String)},
+		&graphql.Field{Name: "source", Type: nonNull(sourceType), Resolve: func(p graphql.Params) (interface{}, error) {
+			src, _, err := state(p.Context).source(p.Source.(map[string]interface{})["source"].(string))
+			return src, err
+		}},
+		metricField,
+	)
+	args = append(args,
+		&graphql.Argument{Name: "limit", Type: graphql.Int, Default: DefaultLimit},
+		&graphql.Argument{Name: "offset", Type: graphql.Int, Default: 0},
+	)
+
+	return &graphql.Field{
+		Name:        m.ID,
+		Type:        nonNull(&graphql.List{Of: nonNull(obs)}),
+		Description: m.Description,
+		Args:        args,
+		Items:       func(args map[string]interface{}) int { return intArg(args, "limit", DefaultLimit) },
+		Cost:        observationCost,
+		Resolve: func(p graphql.Params) (interface{}, error) {
+			limit, offset := intArg(p.Args, "limit", DefaultLimit), intArg(p.Args, "offset", 0)
+			if limit < 1 || limit > MaxLimit {
+				return nil, fmt.Errorf("limit must be a number from 1 to %d", MaxLimit)
+			}
+			if offset < 0 {
+				return nil, fmt.Errorf("offset must not be negative")
+			}
+			filter := query.Filter{Dimensions: make(map[string]string)}
+			for name, v := range p.Args {
+				switch name {
+				case "from":
+					filter.FromYear = v.(int)
+				case "to":
+					filter.ToYear = v.(int)
+				case "source":
+					filter.Source = v.(string)
+				case "limit", "offset":
+				default:
+					if v != nil {
+						filter.Dimensions[name] = fmt.Sprint(v)
+					}
+				}
+			}
+			table, err := query.Run(s.db, m, filter)
+			if err != nil {
+				return nil, err
+			}
+			rows := table.Rows
+			if offset >= len(rows) {
+				rows = nil
+			} else if end := offset + limit; end < len(rows) {
+				rows = rows[offset:end]
+			} else {
+				rows = rows[offset:]
+			}
+			out := make([]map[string]interface{}, len(rows))
+			for i, row := range rows {
+				v := make(map[string]interface{}, len(row))
+				for j, c := range table.Columns {
+					v[c.Name] = row[j]
+				}
+				out[i] = v
+			}
+			return out, nil
+		},
+	}
+}
+
+// intArg returns an optional Int argument, or def when it is null.
+func intArg(args map[string]interface{}, name string, def int) int {
+	if n, ok := args[name].(int); ok {
+		return n
+	}
+	return def
+}
+
+// cohortType has one field per catalog stat with a life stage.
+func (s *Server) cohortType(metricField func(id string) *graphql.Field) *graphql.Object {
+	nonNull := func(t graphql.Type) graphql.Type { return &graphql.NonNull{Of: t} }
+	c := &graphql.Object{
+		Name: "Cohort",
+		Fields: []*graphql.Field{
+			{Name: "birthYear", Type: nonNull(graphql.Int)},
+			{Name: "generation", Type: graphql.String},
+		},
+	}
+	for _, m := range catalog.Published {
+		stage, ok := cohorts.Stages[m.ID]
+		if !ok {
+			continue
+		}
+		measurement := &graphql.Object{
+			Name:        objectName(m.ID) + "CohortMeasurement",
+			Description: fmt.Sprintf("%s %s.", m.Title, stage.Label),
+			Fields: []*graphql.Field{
+				{Name: "offset", Type: nonNull(graphql.Int), Description: "Age at measurement"},
+				{Name: "targetYear", Type: nonNull(graphql.Int)},
+				{Name: "year", Type: nonNull(graphql.Int), Description: "Year the value was measured"},
+				{Name: "value", Type: nonNull(graphql.Float)},
+				{Name: "distance", Type: nonNull(graphql.Int), Description: "Years between targetYear and year"},
+				{Name: "imputed", Type: nonNull(graphql.Boolean)},
+				metricField(m.ID),
+			},
+		}
+		c.Fields = append(c.Fields, &graphql.Field{Name: m.ID, Type: measurement, Description: m.Title + " " + stage.Label})
+	}
+	return c
+}
+
+func cohortValue(c cohorts.Cohort) map[string]interface{} {
+	v := map[string]interface{}{"birthYear": c.BirthYear}
+	if c.Generation != "" {
+		v["generation"] = c.Generation
+	}
+	for id, m := range c.Stats {
+		v[id] = map[string]interface{}{
+			"offset":     m.Offset,
+			"targetYear": m.TargetYear,
+			"year":       m.Year,
+			"value":      m.Value,
+			"distance":   m.Distance,
+			"imputed":    m.Imputed,
+		}
+	}
+	return v
+}
+
+// objectName turns a metric ID such as proficiency_pct into ProficiencyPct.
+func objectName(id string) string {
+	parts := strings.Split(id, "_")
+	for i, p := range parts {
+		if p != "" {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// handleGraphQL runs a query sent as GET ?query=&variables=&operationName=
+// or as a POST body of application/json or application/graphql.
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	if r.Method == http.MethodGet {
+		params := r.URL.Query()
+		req.Query = params.Get("query")
+		req.OperationName = params.Get("operationName")
+		if vars := params.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				writeError(w, http.StatusBadRequest, "variables must be a JSON object")
+				return
+			}
+		}
+	} else {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxQueryBytes+1))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(body) > maxQueryBytes {
+			writeError(w, http.StatusRequestEntityTooLarge, "query is too large")
+			return
+		}
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/graphql":
+			req.Query = string(body)
+		case "application/json", "":
+			if err := json.Unmarshal(body, &req); err != nil {
+				writeError(w, http.StatusBadRequest, "body must be a JSON object with a query")
+				return
+			}
+		default:
+			writeError(w, http.StatusUnsupportedMediaType, "use application/json or application/graphql")
+			return
+		}
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeError(w, http.StatusBadRequest, "missing query; the schema is at /graphql/schema")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), stateKey{}, &requestState{
+		server:  s,
+		metrics: make(map[string]map[string]interface{}),
+	})
+	resp := s.schema.Execute(ctx, req)
+	status := http.StatusOK
+	if resp.Rejected() {
+		status = http.StatusBadRequest
+	}
+	writeJSONStatus(w, r, status, resp)
+}
+
+func (s *Server) handleGraphQLSchema(w http.ResponseWriter, r *http.Request) {
+	sdl := s.schema.SDL()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(sdl)))
+	io.WriteString(w, sdl)
+}
@@ -0,0 +1,191 @@
+// Package catalog is the single definition of every stat: its title, unit,
+// where its values come from, the dimensions it varies by, its default
+// series, its cohort life stage and its citation. The generator, index.json,
+// query, the API and the site's JavaScript all read it instead of keeping
+// their own copies.
+package catalog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+)
+
+//go:embed metrics.json
+var metricsJSON []byte
+
+// Cohort is the life stage at which a metric is measured for a birth cohort.
+type Cohort struct {
+	Offset       int    `json:"offset"`
+	Label        string `json:"label"`
+	SearchWindow int    `json:"searchWindow"`
+}
+
+// Dimension is a key column a metric varies by, under the canonical name
+// query, the API and exports use for it. Column defaults to Name.
+type Dimension struct {
+	Name   string `json:"name"`
+	Column string `json:"column,omitempty"`
+}
+
+// Break is a change of method or source in a metric's headline series:
+// values from Year on are not directly comparable with those before it.
+type Break struct {
+	Year  int    `json:"year"`
+	Label string `json:"label"`
+}
+
+// Metric is one stat. QueryOnly metrics are served by query and the API but
+// have no stat file, page or chart.
+type Metric struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	ShortTitle  string `json:"shortTitle"`
+	Description string `json:"description"`
+	// Unit is percent, scale_score or value; UnitSuffix is how the site
+	// prints it after a number.
+	Unit       string `json:"unit"`
+	UnitSuffix string `json:"unitSuffix"`
+	// Table and Column select the values; DefaultSeries picks the headline
+	// breakdown (dimensions not listed must be empty).
+	Table         string            `json:"table"`
+	Column        string            `json:"column"`
+	Dimensions    []Dimension       `json:"dimensions"`
+	DefaultSeries map[string]string `json:"defaultSeries"`
+	Cohort        Cohort            `json:"cohort"`
+	// Breaks lists the years the headline series changes method, oldest
+	// first; charts draw them so the change is not read as a trend.
+	Breaks    []Break `json:"breaks,omitempty"`
+	Source    string  `json:"source"`
+	Citation  string  `json:"citation"`
+	Filename  string  `json:"filename,omitempty"`
+	QueryOnly bool    `json:"queryOnly,omitempty"`
+}
+
+// ObservationTable returns the table the metric reads from.
+func (m Metric) ObservationTable() database.ObservationTable {
+	for _, t := range database.ObservationTables {
+		if t.Name == m.Table {
+			return t
+		}
+	}
+	return database.ObservationTable{}
+}
+
+// BreaksBetween returns the breaks after first up to and including last:
+// those that separate a value measured in first from one measured in last.
+func (m Metric) BreaksBetween(first, last int) []Break {
+	var out []Break
+	for _, b := range m.Breaks {
+		if b.Year > first && b.Year <= last {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// Metrics is the catalog in publication order.
+var Metrics = mustLoad(metricsJSON)
+
+// Published is Metrics without the QueryOnly entries: the stats the
+// generator writes files, pages and charts for.
+var Published = published(Metrics)
+
+func published(metrics []Metric) []Metric {
+	var out []Metric
+	for _, m := range metrics {
+		if !m.QueryOnly {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Get returns the metric with the given ID.
+func Get(id string) (Metric, bool) {
+	for _, m := range Metrics {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Metric{}, false
+}
+
+func mustLoad(data []byte) []Metric {
+	metrics, err := load(data)
+	if err != nil {
+		panic(fmt.Sprintf("catalog: %v", err))
+	}
+	return metrics
+}
+
+// load parses and checks a catalog: IDs must be unique and every metric
+// must name an observation table, one of its value columns, key columns of
+// that table as dimensions and, unless it is QueryOnly, a file. Dimension
+// columns left empty are filled in from their names.
+func load(data []byte) ([]Metric, error) {
+	var metrics []Metric
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for i := range metrics {
+		m := &metrics[i]
+		if m.ID == "" || seen[m.ID] {
+			return nil, fmt.Errorf("missing or duplicate metric id %q", m.ID)
+		}
+		seen[m.ID] = true
+
+		t := m.ObservationTable()
+		if t.Name == "" {
+			return nil, fmt.Errorf("%s: unknown table %q", m.ID, m.Table)
+		}
+		if !contains(t.ValueColumns, m.Column) {
+			return nil, fmt.Errorf("%s: %s has no value column %q", m.ID, m.Table, m.Column)
+		}
+		for dim := range m.DefaultSeries {
+			if !contains(t.KeyColumns, dim) {
+				return nil, fmt.Errorf("%s: default series dimension %q is not a key column of %s", m.ID, dim, m.Table)
+			}
+		}
+		names := make(map[string]bool)
+		for j, d := range m.Dimensions {
+			if d.Column == "" {
+				m.Dimensions[j].Column = d.Name
+			}
+			if d.Name == "" || names[d.Name] {
+				return nil, fmt.Errorf("%s: missing or duplicate dimension name %q", m.ID, d.Name)
+			}
+			names[d.Name] = true
+			if !contains(t.KeyColumns, m.Dimensions[j].Column) {
+				return nil, fmt.Errorf("%s: dimension %s is not a key column of %s", m.ID, d.Name, m.Table)
+			}
+		}
+		switch {
+		case m.QueryOnly && m.Filename != "":
+			return nil, fmt.Errorf("%s: query-only metrics have no file", m.ID)
+		case !m.QueryOnly && m.Filename == "":
+			return nil, fmt.Errorf("%s: no filename", m.ID)
+		}
+		for i, b := range m.Breaks {
+			if b.Year <= 0 || b.Label == "" {
+				return nil, fmt.Errorf("%s: series break %d needs a year and a label", m.ID, i+1)
+			}
+			if i > 0 && b.Year <= m.Breaks[i-1].Year {
+				return nil, fmt.Errorf("%s: series breaks must be in year order", m.ID)
+			}
+		}
+	}
+	return metrics, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
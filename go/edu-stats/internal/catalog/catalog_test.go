@@ -0,0 +1,65 @@
+package catalog
+
+import "testing"
+
+func TestCatalogLoads(t *testing.T) {
+	if len(Metrics) != 7 || len(Published) != 6 {
+		t.Fatalf("expected 7 metrics, 6 published; got %d and %d", len(Metrics), len(Published))
+	}
+	if pct, ok := Get("proficiency_pct"); !ok || !pct.QueryOnly || pct.Unit != "percent" {
+		t.Errorf("proficiency_pct should be a query-only percentage: %+v", pct)
+	}
+	for _, m := range Published {
+		if m.QueryOnly {
+			t.Errorf("%s is query-only but published", m.ID)
+		}
+	}
+	m, ok := Get("proficiency")
+	if !ok {
+		t.Fatal("proficiency missing")
+	}
+	if m.Cohort.Offset != 14 || m.Unit != "scale_score" || m.DefaultSeries["grade"] != "8" {
+		t.Errorf("unexpected proficiency entry %+v", m)
+	}
+	if g, _ := Get("graduation"); len(g.Breaks) != 1 || g.Breaks[0].Year != 2011 {
+		t.Errorf("graduation breaks = %+v, want the 2011 ACGR break", g.Breaks)
+	}
+	if len(m.Breaks) != 1 || m.Breaks[0].Year != 2002 {
+		t.Errorf("proficiency breaks = %+v, want the 2002 switch to Main NAEP", m.Breaks)
+	}
+	if a, _ := Get("attainment"); len(a.Breaks) != 1 || a.Breaks[0].Year != 2010 {
+		t.Errorf("attainment breaks = %+v, want the 2010 switch to ACS", a.Breaks)
+	}
+	if m.ObservationTable().Name != "test_proficiency" {
+		t.Errorf("proficiency table = %q", m.ObservationTable().Name)
+	}
+	if e, _ := Get("early_childhood"); e.Dimensions[1] != (Dimension{Name: "measure", Column: "metric_name"}) ||
+		e.Dimensions[2] != (Dimension{Name: "age_months", Column: "age_months"}) {
+		t.Errorf("early_childhood dimensions = %+v, want columns filled in from names", e.Dimensions)
+	}
+	for _, m := range Published {
+		if m.Citation == "" || m.Cohort.Label == "" {
+			t.Errorf("%s is missing a citation or cohort label", m.ID)
+		}
+	}
+}
+
+func TestLoadRejectsBadEntries(t *testing.T) {
+	tests := map[string]string{
+		"duplicate id":        `[{"id":"a","table":"literacy_rates","column":"rate","filename":"a.json"},{"id":"a","table":"literacy_rates","column":"rate","filename":"b.json"}]`,
+		"unknown table":       `[{"id":"a","table":"nope","column":"rate","filename":"a.json"}]`,
+		"unknown column":      `[{"id":"a","table":"literacy_rates","column":"score","filename":"a.json"}]`,
+		"bad dimension":       `[{"id":"a","table":"literacy_rates","column":"rate","defaultSeries":{"grade":"8"},"filename":"a.json"}]`,
+		"no filename":         `[{"id":"a","table":"literacy_rates","column":"rate"}]`,
+		"query-only file":     `[{"id":"a","table":"literacy_rates","column":"rate","filename":"a.json","queryOnly":true}]`,
+		"unknown dimension":   `[{"id":"a","table":"literacy_rates","column":"rate","filename":"a.json","dimensions":[{"name":"race"}]}]`,
+		"duplicate dimension": `[{"id":"a","table":"literacy_rates","column":"rate","filename":"a.json","dimensions":[{"name":"gender"},{"name":"gender"}]}]`,
+		"unlabeled break":     `[{"id":"a","table":"literacy_rates","column":"rate","filename":"a.json","breaks":[{"year":2000}]}]`,
+		"breaks out of order": `[{"id":"a","table":"literacy_rates","column":"rate","filename":"a.json","breaks":[{"year":2000,"label":"b"},{"year":1990,"label":"a"}]}]`,
+	}
+	for name, data := range tests {
+		if _, err := load([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
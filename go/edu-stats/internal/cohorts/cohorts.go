@@ -0,0 +1,158 @@
+package cohorts
+
+import (
+	"sort"
+
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+)
+
+// Generation is a named span of birth years.
+type Generation struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Short string `json:"short"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Generations are the bands drawn on the timeline, oldest first. Keys match
+// the CSS classes used by the site.
+var Generations = []Generation{
+	{Key: "silent", Name: "Silent Generation", Short: "Silent", Start: 1928, End: 1945},
+	{Key: "baby-boomer", Name: "Baby Boomer", Short: "Boomer", Start: 1946, End: 1964},
+	{Key: "x", Name: "Generation X", Short: "Gen X", Start: 1965, End: 1980},
+	{Key: "millennial", Name: "Millennial", Short: "Millennial", Start: 1981, End: 1996},
+	{Key: "z", Name: "Generation Z", Short: "Gen Z", Start: 1997, End: 2012},
+	{Key: "alpha", Name: "Generation Alpha", Short: "Gen Alpha", Start: 2013, End: 2030},
+}
+
+// GenerationFor returns the generation containing birthYear.
+func GenerationFor(birthYear int) (Generation, bool) {
+	for _, g := range Generations {
+		if birthYear >= g.Start && birthYear <= g.End {
+			return g, true
+		}
+	}
+	return Generation{}, false
+}
+
+// Stage is the age at which a stat is measured for a birth cohort.
+type Stage struct {
+	Offset int    `json:"offset"`
+	Label  string `json:"label"`
+	// SearchWindow is how many years either side of birth year + Offset a
+	// measurement may come from when the series has no point for that year.
+	SearchWindow int `json:"searchWindow"`
+}
+
+// Stages holds the life-stage mapping for every stat in the metric catalog,
+// keyed like index.json: a birth cohort's value for a stat is the one
+// measured Offset years after birth (e.g. NAEP Grade 8 reading at age ~14).
+var Stages = stagesFromCatalog()
+
+func stagesFromCatalog() map[string]Stage {
+	stages := make(map[string]Stage, len(catalog.Published))
+	for _, m := range catalog.Published {
+		stages[m.ID] = Stage{Offset: m.Cohort.Offset, Label: m.Cohort.Label, SearchWindow: m.Cohort.SearchWindow}
+	}
+	return stages
+}
+
+// Point is one observation of a stat's series.
+type Point struct {
+	Year    int
+	Value   float64
+	Imputed bool
+	// StandardError is the published sampling error of Value, if any.
+	StandardError *float64
+}
+
+// Nearest returns the point closest to target within window years. Ties go
+// to the earlier year so results do not depend on input order.
+func Nearest(points []Point, target, window int) (Point, bool) {
+	var best Point
+	bestDist := -1
+	for _, p := range points {
+		dist := p.Year - target
+		if dist < 0 {
+			dist = -dist
+		}
+		if dist > window {
+			continue
+		}
+		if bestDist < 0 || dist < bestDist || (dist == bestDist && p.Year < best.Year) {
+			best, bestDist = p, dist
+		}
+	}
+	return best, bestDist >= 0
+}
+
+// Measurement is the value a stat recorded for one birth cohort.
+type Measurement struct {
+	Offset     int     `json:"offset"`
+	TargetYear int     `json:"targetYear"`
+	Year       int     `json:"year"`
+	Value      float64 `json:"value"`
+	// Distance is how many years the observation is from TargetYear.
+	Distance int  `json:"distance"`
+	Imputed  bool `json:"imputed,omitempty"`
+	// StandardError is the sampling error the source publishes for Value.
+	StandardError *float64 `json:"standardError,omitempty"`
+}
+
+// Lookup finds the measurement of a stat for birthYear using stage.
+func Lookup(points []Point, stage Stage, birthYear int) (Measurement, bool) {
+	target := birthYear + stage.Offset
+	p, ok := Nearest(points, target, stage.SearchWindow)
+	if !ok {
+		return Measurement{}, false
+	}
+	dist := p.Year - target
+	if dist < 0 {
+		dist = -dist
+	}
+	return Measurement{
+		Offset:        stage.Offset,
+		TargetYear:    target,
+		Year:          p.Year,
+		Value:         p.Value,
+		Distance:      dist,
+		Imputed:       p.Imputed,
+		StandardError: p.StandardError,
+	}, true
+}
+
+// Cohort holds every stat's measurement for one birth year.
+type Cohort struct {
+	BirthYear  int                    `json:"birthYear"`
+	Generation string                 `json:"generation,omitempty"`
+	Stats      map[string]Measurement `json:"stats"`
+}
+
+// Build maps each birth year from first to last to the matching measurement
+// of every stat in series. Stats without a Stage, and stats with no
+// observation in range for a year, are left out of that cohort.
+func Build(series map[string][]Point, first, last int) []Cohort {
+	stats := make([]string, 0, len(series))
+	for stat := range series {
+		if _, ok := Stages[stat]; ok {
+			stats = append(stats, stat)
+		}
+	}
+	sort.Strings(stats)
+
+	cohorts := make([]Cohort, 0, last-first+1)
+	for year := first; year <= last; year++ {
+		c := Cohort{BirthYear: year, Stats: make(map[string]Measurement)}
+		if g, ok := GenerationFor(year); ok {
+			c.Generation = g.Key
+		}
+		for _, stat := range stats {
+			if m, ok := Lookup(series[stat], Stages[stat], year); ok {
+				c.Stats[stat] = m
+			}
+		}
+		cohorts = append(cohorts, c)
+	}
+	return cohorts
+}
@@ -0,0 +1,202 @@
+package cohorts
+
+import (
+	"math"
+	"testing"
+)
+
+var naepReading = []Point{
+	{Year: 1971, Value: 255}, {Year: 1975, Value: 256}, {Year: 1980, Value: 259},
+	{Year: 1984, Value: 257}, {Year: 1988, Value: 258}, {Year: 1992, Value: 260},
+	{Year: 2004, Value: 264}, {Year: 2019, Value: 263},
+}
+
+func TestGenerationFor(t *testing.T) {
+	tests := []struct {
+		year int
+		key  string
+	}{
+		{1928, "silent"}, {1945, "silent"}, {1946, "baby-boomer"}, {1964, "baby-boomer"},
+		{1965, "x"}, {1980, "x"}, {1981, "millennial"}, {1996, "millennial"},
+		{1997, "z"}, {2012, "z"}, {2013, "alpha"},
+	}
+	for _, tt := range tests {
+		g, ok := GenerationFor(tt.year)
+		if !ok || g.Key != tt.key {
+			t.Errorf("GenerationFor(%d) = %q, want %q", tt.year, g.Key, tt.key)
+		}
+	}
+	if _, ok := GenerationFor(1900); ok {
+		t.Error("1900 should not belong to any generation")
+	}
+}
+
+func TestNearest(t *testing.T) {
+	if p, ok := Nearest(naepReading, 1984, 4); !ok || p.Year != 1984 {
+		t.Errorf("exact match: got %+v, %v", p, ok)
+	}
+	// 1986 is equidistant from 1984 and 1988; the earlier year wins.
+	if p, ok := Nearest(naepReading, 1986, 4); !ok || p.Year != 1984 {
+		t.Errorf("tie: got %+v, want 1984", p)
+	}
+	if _, ok := Nearest(naepReading, 1960, 4); ok {
+		t.Error("1960 has no point within 4 years")
+	}
+}
+
+func TestLookupUsesLifeStageOffset(t *testing.T) {
+	stage := Stages["proficiency"]
+	tests := []struct {
+		birthYear int
+		year      int
+		value     float64
+	}{
+		{1957, 1971, 255}, // boomer
+		{1970, 1984, 257}, // gen X
+		{1990, 2004, 264}, // millennial
+		{2005, 2019, 263}, // gen Z
+	}
+	for _, tt := range tests {
+		m, ok := Lookup(naepReading, stage, tt.birthYear)
+		if !ok {
+			t.Errorf("born %d: no measurement", tt.birthYear)
+			continue
+		}
+		if m.Year != tt.year || m.Value != tt.value || m.Offset != 14 {
+			t.Errorf("born %d: got %+v, want year %d value %.0f", tt.birthYear, m, tt.year, tt.value)
+		}
+	}
+
+	m, _ := Lookup(naepReading, stage, 1972) // target 1986, nearest 1984
+	if m.TargetYear != 1986 || m.Distance != 2 {
+		t.Errorf("born 1972: target %d distance %d, want 1986 and 2", m.TargetYear, m.Distance)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	series := map[string][]Point{
+		"proficiency": naepReading,
+		"unknown":     {{Year: 2000, Value: 1}},
+	}
+	built := Build(series, 1957, 1960)
+	if len(built) != 4 {
+		t.Fatalf("expected 4 cohorts, got %d", len(built))
+	}
+	first := built[0]
+	if first.BirthYear != 1957 || first.Generation != "baby-boomer" {
+		t.Errorf("unexpected first cohort %+v", first)
+	}
+	if _, ok := first.Stats["unknown"]; ok {
+		t.Error("stats without a Stage should be skipped")
+	}
+	if m := first.Stats["proficiency"]; m.Year != 1971 {
+		t.Errorf("1957 proficiency from %d, want 1971", m.Year)
+	}
+}
+
+func TestFindGeneration(t *testing.T) {
+	for _, name := range []string{"z", "Gen Z", "generation z"} {
+		if g, ok := FindGeneration(name); !ok || g.Key != "z" {
+			t.Errorf("FindGeneration(%q) = %q, %v", name, g.Key, ok)
+		}
+	}
+	if _, ok := FindGeneration("lost"); ok {
+		t.Error("unknown generation should not match")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	series := map[string][]Point{"proficiency": naepReading}
+	summaries := Summarize(Build(series, 1965, 1980))
+	if len(summaries) != 1 || summaries[0].Key != "x" {
+		t.Fatalf("expected only Gen X, got %+v", summaries)
+	}
+
+	// Gen X (1965–1980) at age 14 reaches 1979–1994: observations 1980, 1984, 1988, 1992.
+	s := summaries[0].Stats["proficiency"]
+	if s.Observations != 4 {
+		t.Errorf("observations = %d, want 4", s.Observations)
+	}
+	if s.Min != 257 || s.Max != 260 || s.Mean != 258.5 {
+		t.Errorf("unexpected aggregate %+v", s)
+	}
+	if s.Slope == nil || *s.Slope <= 0 {
+		t.Errorf("expected positive slope, got %v", s.Slope)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	series := map[string][]Point{
+		"proficiency": naepReading,
+		"literacy":    {{Year: 2015, Value: 99}},
+	}
+	built := Build(series, 1965, 1990)
+	a, b := built[0], built[len(built)-1]
+
+	got := Compare(a, b, nil)
+	if len(got) != 2 || got[0].Stat != "literacy" || got[1].Stat != "proficiency" {
+		t.Fatalf("unexpected stats %+v", got)
+	}
+
+	lit := got[0]
+	if lit.A != nil || lit.B == nil || lit.Absolute != nil {
+		t.Errorf("literacy only measured for 1990 cohort, got %+v", lit)
+	}
+
+	prof := got[1]
+	if prof.A == nil || prof.B == nil || prof.Absolute == nil || prof.Relative == nil {
+		t.Fatalf("proficiency should be measured for both cohorts, got %+v", prof)
+	}
+	want := prof.B.Value - prof.A.Value
+	if *prof.Absolute != want {
+		t.Errorf("absolute = %v, want %v", *prof.Absolute, want)
+	}
+	if rel := want / prof.A.Value * 100; *prof.Relative != rel {
+		t.Errorf("relative = %v, want %v", *prof.Relative, rel)
+	}
+
+	only := Compare(a, b, []string{"proficiency"})
+	if len(only) != 1 || only[0].Stat != "proficiency" {
+		t.Errorf("stats filter not applied: %+v", only)
+	}
+}
+
+func TestCompareSignificance(t *testing.T) {
+	se := func(v float64) *float64 { return &v }
+	measured := func(year int, value float64, stdErr *float64) Measurement {
+		return Measurement{Year: year, Value: value, StandardError: stdErr}
+	}
+	a := Cohort{Stats: map[string]Measurement{
+		"proficiency": measured(1990, 257, se(0.8)),
+		"attainment":  measured(2015, 32.5, se(0.1)),
+		"graduation":  measured(2010, 78.2, nil),
+	}}
+	b := Cohort{Stats: map[string]Measurement{
+		"proficiency": measured(1992, 258, se(1.2)),
+		"attainment":  measured(2019, 33.1, se(0.1)),
+		"graduation":  measured(2014, 82.3, se(0.5)),
+	}}
+
+	got := make(map[string]StatComparison)
+	for _, sc := range Compare(a, b, nil) {
+		got[sc.Stat] = sc
+	}
+	// 1 point apart with SE sqrt(0.8² + 1.2²) = 1.44: z 0.69, p 0.49.
+	if p := got["proficiency"]; p.StandardError == nil || math.Abs(*p.StandardError-1.4422) > 1e-3 ||
+		p.PValue == nil || math.Abs(*p.PValue-0.488) > 1e-3 || p.Significant {
+		t.Errorf("proficiency = %+v, want an insignificant difference", p)
+	}
+	// 0.6 points apart with SE 0.14: z 4.2.
+	if at := got["attainment"]; at.PValue == nil || *at.PValue > 0.001 || !at.Significant {
+		t.Errorf("attainment = %+v, want a significant difference", at)
+	}
+	if g := got["graduation"]; g.StandardError != nil || g.PValue != nil {
+		t.Errorf("graduation = %+v, want no test without both standard errors", g)
+	}
+
+	// Two cohorts matched to the same measurement are not tested.
+	same := Compare(a, a, []string{"proficiency"})[0]
+	if same.PValue != nil {
+		t.Errorf("same measurement compared with itself: %+v", same)
+	}
+}
@@ -0,0 +1,87 @@
+package cohorts
+
+import (
+	"math"
+	"sort"
+)
+
+// SignificanceLevel is the p-value below which a difference between two
+// cohorts is reported as significant.
+const SignificanceLevel = 0.05
+
+// StatComparison is one stat measured for two birth cohorts. A or B is nil
+// when that cohort has no observation within the stat's search window, in
+// which case the differences are nil too.
+type StatComparison struct {
+	Stat  string       `json:"stat"`
+	Label string       `json:"label"`
+	A     *Measurement `json:"a"`
+	B     *Measurement `json:"b"`
+	// Absolute is B minus A in the stat's unit.
+	Absolute *float64 `json:"absolute"`
+	// Relative is Absolute as a percentage of A; nil when A is zero.
+	Relative *float64 `json:"relative"`
+	// StandardError is the standard error of Absolute and PValue the
+	// two-sided p-value of a z-test that it is zero, treating A and B as
+	// independent samples. Both are nil unless A and B are different
+	// measured years that each publish a standard error.
+	StandardError *float64 `json:"standardError,omitempty"`
+	PValue        *float64 `json:"pValue,omitempty"`
+	// Significant is set when PValue is below SignificanceLevel.
+	Significant bool `json:"significant,omitempty"`
+}
+
+// Compare lines up every stat of two cohorts, sorted by stat. When stats is
+// non-empty only those stats are compared, in the given order.
+func Compare(a, b Cohort, stats []string) []StatComparison {
+	if len(stats) == 0 {
+		seen := make(map[string]bool)
+		for _, c := range []Cohort{a, b} {
+			for stat := range c.Stats {
+				if !seen[stat] {
+					seen[stat] = true
+					stats = append(stats, stat)
+				}
+			}
+		}
+		sort.Strings(stats)
+	}
+
+	out := make([]StatComparison, 0, len(stats))
+	for _, stat := range stats {
+		sc := StatComparison{Stat: stat, Label: Stages[stat].Label}
+		if m, ok := a.Stats[stat]; ok {
+			sc.A = &m
+		}
+		if m, ok := b.Stats[stat]; ok {
+			sc.B = &m
+		}
+		if sc.A != nil && sc.B != nil {
+			abs := sc.B.Value - sc.A.Value
+			sc.Absolute = &abs
+			if sc.A.Value != 0 {
+				rel := abs / sc.A.Value * 100
+				sc.Relative = &rel
+			}
+			sc.testDifference()
+		}
+		out = append(out, sc)
+	}
+	return out
+}
+
+// testDifference fills in the significance of Absolute from the two
+// measurements' standard errors.
+func (sc *StatComparison) testDifference() {
+	a, b := sc.A, sc.B
+	if a.StandardError == nil || b.StandardError == nil || a.Year == b.Year {
+		return
+	}
+	se := math.Hypot(*a.StandardError, *b.StandardError)
+	if se == 0 {
+		return
+	}
+	p := math.Erfc(math.Abs(*sc.Absolute) / se / math.Sqrt2)
+	sc.StandardError, sc.PValue = &se, &p
+	sc.Significant = p < SignificanceLevel
+}
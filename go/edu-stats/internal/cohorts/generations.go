@@ -0,0 +1,114 @@
+package cohorts
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// FindGeneration looks a generation up by key, name or short name, ignoring
+// case, so "z", "Gen Z" and "Generation Z" all match.
+func FindGeneration(name string) (Generation, bool) {
+	want := strings.ToLower(strings.TrimSpace(name))
+	for _, g := range Generations {
+		for _, candidate := range []string{g.Key, g.Name, g.Short} {
+			if strings.ToLower(candidate) == want {
+				return g, true
+			}
+		}
+	}
+	return Generation{}, false
+}
+
+// GenerationStat aggregates one stat over a generation's birth years.
+// Birth years that map to the same measured year count once, so a sparse
+// series is not weighted toward the observations many cohorts share.
+type GenerationStat struct {
+	Mean float64 `json:"mean"`
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	// Slope is the least-squares trend in units per measured year; nil when
+	// fewer than two observations fall within the generation.
+	Slope        *float64 `json:"slope"`
+	Observations int      `json:"observations"`
+	FirstYear    int      `json:"firstYear"`
+	LastYear     int      `json:"lastYear"`
+}
+
+// GenerationSummary is every stat's aggregate for one generation.
+type GenerationSummary struct {
+	Generation
+	Stats map[string]GenerationStat `json:"stats"`
+}
+
+// Summarize aggregates built cohorts by generation. Generations with no
+// cohorts in the input are omitted.
+func Summarize(built []Cohort) []GenerationSummary {
+	var summaries []GenerationSummary
+	for _, g := range Generations {
+		// stat -> measured year -> value
+		observed := make(map[string]map[int]float64)
+		members := 0
+		for _, c := range built {
+			if c.BirthYear < g.Start || c.BirthYear > g.End {
+				continue
+			}
+			members++
+			for stat, m := range c.Stats {
+				if observed[stat] == nil {
+					observed[stat] = make(map[int]float64)
+				}
+				observed[stat][m.Year] = m.Value
+			}
+		}
+		if members == 0 {
+			continue
+		}
+
+		summary := GenerationSummary{Generation: g, Stats: make(map[string]GenerationStat)}
+		for stat, byYear := range observed {
+			summary.Stats[stat] = aggregate(byYear)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+func aggregate(byYear map[int]float64) GenerationStat {
+	years := make([]int, 0, len(byYear))
+	for y := range byYear {
+		years = append(years, y)
+	}
+	sort.Ints(years)
+
+	s := GenerationStat{
+		Min:          math.Inf(1),
+		Max:          math.Inf(-1),
+		Observations: len(years),
+		FirstYear:    years[0],
+		LastYear:     years[len(years)-1],
+	}
+	var sumX, sumY float64
+	for _, y := range years {
+		v := byYear[y]
+		sumX += float64(y)
+		sumY += v
+		s.Min = math.Min(s.Min, v)
+		s.Max = math.Max(s.Max, v)
+	}
+	n := float64(len(years))
+	s.Mean = sumY / n
+
+	if len(years) >= 2 {
+		meanX := sumX / n
+		var sxy, sxx float64
+		for _, y := range years {
+			dx := float64(y) - meanX
+			sxy += dx * (byYear[y] - s.Mean)
+			sxx += dx * dx
+		}
+		slope := sxy / sxx
+		s.Slope = &slope
+	}
+	return s
+}
@@ -0,0 +1,197 @@
+package coverage
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+)
+
+// Gap is a run of missing years between two loaded years of a series.
+type Gap struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// Years is the number of missing years in the gap.
+func (g Gap) Years() int {
+	return g.To - g.From + 1
+}
+
+// Metric is the coverage of one observation series.
+type Metric struct {
+	Label   string `json:"label"`
+	Table   string `json:"table"`
+	Column  string `json:"column"`
+	Source  string `json:"source"`
+	Series  string `json:"series,omitempty"`
+	YearMin int    `json:"yearMin"`
+	YearMax int    `json:"yearMax"`
+	Count   int    `json:"count"`
+	Years   []int  `json:"years"`
+	Gaps    []Gap  `json:"gaps,omitempty"`
+}
+
+// Has reports whether the metric has a value for year.
+func (m Metric) Has(year int) bool {
+	i := sort.SearchInts(m.Years, year)
+	return i < len(m.Years) && m.Years[i] == year
+}
+
+// Report is the year-by-metric coverage matrix.
+type Report struct {
+	YearMin int      `json:"yearMin"`
+	YearMax int      `json:"yearMax"`
+	Metrics []Metric `json:"metrics"`
+}
+
+// Build reads every value column of every observation table and returns
+// which years each series covers. An empty source includes all sources.
+func Build(db *sql.DB, source string) (Report, error) {
+	var report Report
+	for _, t := range database.ObservationTables {
+		for _, col := range t.ValueColumns {
+			series, err := database.LoadSeries(db, t, col)
+			if err != nil {
+				return report, fmt.Errorf("%s.%s: %w", t.Name, col, err)
+			}
+			for _, s := range series {
+				if source != "" && s.Source != source {
+					continue
+				}
+				report.Metrics = append(report.Metrics, newMetric(s))
+			}
+		}
+	}
+
+	for i, m := range report.Metrics {
+		if i == 0 || m.YearMin < report.YearMin {
+			report.YearMin = m.YearMin
+		}
+		if i == 0 || m.YearMax > report.YearMax {
+			report.YearMax = m.YearMax
+		}
+	}
+	return report, nil
+}
+
+func newMetric(s database.Series) Metric {
+	m := Metric{
+		Label:  s.Label(),
+		Table:  s.Table.Name,
+		Column: s.Column,
+		Source: s.Source,
+		Series: database.SeriesKey(s.Table, s.Key),
+	}
+	for _, p := range s.Points {
+		if n := len(m.Years); n > 0 && m.Years[n-1] == p.Year {
+			continue
+		}
+		m.Years = append(m.Years, p.Year)
+	}
+	if len(m.Years) == 0 {
+		return m
+	}
+	m.YearMin = m.Years[0]
+	m.YearMax = m.Years[len(m.Years)-1]
+	m.Count = len(m.Years)
+	m.Gaps = FindGaps(m.Years)
+	return m
+}
+
+// FindGaps returns the runs of missing years between sorted, distinct
+// years.
+func FindGaps(years []int) []Gap {
+	var gaps []Gap
+	for i := 1; i < len(years); i++ {
+		if years[i]-years[i-1] > 1 {
+			gaps = append(gaps, Gap{From: years[i-1] + 1, To: years[i] - 1})
+		}
+	}
+	return gaps
+}
+
+// WriteTable prints the matrix with one row per year and one column per
+// metric, followed by a legend and the gaps found in each metric.
+func (r Report) WriteTable(w io.Writer) {
+	if len(r.Metrics) == 0 {
+		fmt.Fprintln(w, "No observations loaded.")
+		return
+	}
+
+	fmt.Fprintf(w, "%-6s", "YEAR")
+	for i := range r.Metrics {
+		fmt.Fprintf(w, " %3d", i+1)
+	}
+	fmt.Fprintln(w)
+	for year := r.YearMin; year <= r.YearMax; year++ {
+		fmt.Fprintf(w, "%-6d", year)
+		for _, m := range r.Metrics {
+			mark := "  ·"
+			if m.Has(year) {
+				mark = "  ●"
+			} else if year < m.YearMin || year > m.YearMax {
+				mark = "   "
+			}
+			fmt.Fprintf(w, " %s", mark)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w)
+	for i, m := range r.Metrics {
+		fmt.Fprintf(w, "%3d  %s: %d years, %d-%d\n", i+1, m.Label, m.Count, m.YearMin, m.YearMax)
+		if len(m.Gaps) == 0 {
+			continue
+		}
+		gaps := make([]string, len(m.Gaps))
+		for j, g := range m.Gaps {
+			if g.From == g.To {
+				gaps[j] = strconv.Itoa(g.From)
+			} else {
+				gaps[j] = fmt.Sprintf("%d-%d", g.From, g.To)
+			}
+		}
+		fmt.Fprintf(w, "     ⚠ %d gaps: %s\n", len(m.Gaps), strings.Join(gaps, ", "))
+	}
+}
+
+// WriteCSV writes the matrix as year rows with a 1/0 column per metric.
+func (r Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"year"}
+	for _, m := range r.Metrics {
+		header = append(header, m.Label)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for year := r.YearMin; year <= r.YearMax && len(r.Metrics) > 0; year++ {
+		row := []string{strconv.Itoa(year)}
+		for _, m := range r.Metrics {
+			if m.Has(year) {
+				row = append(row, "1")
+			} else {
+				row = append(row, "0")
+			}
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,95 @@
+package coverage
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func setupCoverageTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	schema := `
+	CREATE TABLE literacy_rates (id INTEGER PRIMARY KEY, year INTEGER, age_group TEXT, rate REAL, gender TEXT, source TEXT);
+	CREATE TABLE educational_attainment (id INTEGER PRIMARY KEY, year INTEGER, age_group TEXT, education_level TEXT,
+		percentage REAL, gender TEXT, race TEXT, source TEXT);
+	CREATE TABLE graduation_rates (id INTEGER PRIMARY KEY, year INTEGER, rate REAL, cohort_year INTEGER,
+		state TEXT, demographics TEXT, source TEXT);
+	CREATE TABLE enrollment_rates (id INTEGER PRIMARY KEY, year INTEGER, age_group TEXT, enrollment_rate REAL,
+		level TEXT, state TEXT, demographics TEXT, source TEXT);
+	CREATE TABLE test_proficiency (id INTEGER PRIMARY KEY, year INTEGER, subject TEXT, grade INTEGER, avg_score REAL,
+		proficiency_level TEXT, percentage_proficient REAL, state TEXT, demographics TEXT, source TEXT);
+	CREATE TABLE early_childhood (id INTEGER PRIMARY KEY, year INTEGER, cohort_year INTEGER, metric_name TEXT,
+		metric_value REAL, age_months INTEGER, demographics TEXT, source TEXT);`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	return db
+}
+
+func TestBuildFlagsGaps(t *testing.T) {
+	db := setupCoverageTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO graduation_rates (year, rate, source) VALUES
+		(1960, 69.5, 'nces'), (1965, 76.5, 'nces'), (1970, 76.9, 'nces'), (1971, 77.0, 'nces')
+	`)
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	report, err := Build(db, "")
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if len(report.Metrics) != 1 {
+		t.Fatalf("expected 1 metric, got %d", len(report.Metrics))
+	}
+	m := report.Metrics[0]
+	if m.Count != 4 || m.YearMin != 1960 || m.YearMax != 1971 {
+		t.Errorf("unexpected coverage %+v", m)
+	}
+	want := []Gap{{1961, 1964}, {1966, 1969}}
+	if len(m.Gaps) != len(want) || m.Gaps[0] != want[0] || m.Gaps[1] != want[1] {
+		t.Errorf("gaps = %+v, want %+v", m.Gaps, want)
+	}
+	if !m.Has(1965) || m.Has(1966) {
+		t.Error("Has should report loaded years only")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	db := setupCoverageTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO literacy_rates (year, age_group, rate, source) VALUES (2000, 'adult', 99, 'wb'), (2002, 'adult', 99, 'wb');
+		INSERT INTO test_proficiency (year, subject, grade, avg_score, source) VALUES (2001, 'reading', 8, 263, 'naep');
+	`)
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	report, err := Build(db, "")
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header + 3 years, got %d lines:\n%s", len(lines), buf.String())
+	}
+	if lines[2] != "2001,0,1" {
+		t.Errorf("2001 row = %q, want 2001,0,1", lines[2])
+	}
+}
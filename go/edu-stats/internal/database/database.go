@@ -61,6 +61,23 @@ func Open() (*sql.DB, error) {
 	return db, nil
 }
 
+// OpenReadOnly opens the database so that any write fails, for serving it
+// to clients.
+func OpenReadOnly() (*sql.DB, error) {
+	if _, err := os.Stat(DatabaseFile); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db, err := sql.Open("sqlite3", "file:"+DatabaseFile+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
 func ApplySchema(db *sql.DB) error {
 	schemaPath := "schema.sql"
 	
@@ -102,6 +119,14 @@ func ApplySchema(db *sql.DB) error {
 		return fmt.Errorf("failed to apply schema from %s: %w", foundLocation, err)
 	}
 
+	if err := migrateColumns(db); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	if err := TrackDataVersions(db); err != nil {
+		return fmt.Errorf("failed to install data version triggers: %w", err)
+	}
+
 	fmt.Printf("✓ Database schema applied successfully (from %s)\n", foundLocation)
 	return nil
 }
@@ -143,6 +168,81 @@ type SourceMetadata struct {
 	Status         string
 }
 
+// SourceCoverage is the span of years a source actually has rows for, across
+// every observation table.
+type SourceCoverage struct {
+	Source    string
+	YearMin   int
+	YearMax   int
+	YearCount int
+	RowCount  int
+}
+
+// GetSourceCoverage measures what source has loaded in the observation
+// tables, independent of the --years range it was downloaded with.
+func GetSourceCoverage(db *sql.DB, source string) (SourceCoverage, error) {
+	c := SourceCoverage{Source: source}
+	years := make(map[int]bool)
+	for _, t := range ObservationTables {
+		rows, err := db.Query(fmt.Sprintf("SELECT year, COUNT(*) FROM %s WHERE source = ? GROUP BY year", t.Name), source)
+		if err != nil {
+			return c, err
+		}
+		for rows.Next() {
+			var year, n int
+			if err := rows.Scan(&year, &n); err != nil {
+				rows.Close()
+				return c, err
+			}
+			years[year] = true
+			c.RowCount += n
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return c, err
+		}
+	}
+
+	for y := range years {
+		if c.YearCount == 0 || y < c.YearMin {
+			c.YearMin = y
+		}
+		if c.YearCount == 0 || y > c.YearMax {
+			c.YearMax = y
+		}
+		c.YearCount++
+	}
+	return c, nil
+}
+
+// RefreshSourceCoverage stores the loaded year span and row count for
+// source in source_metadata, replacing the requested --years range in
+// years_available with the years actually present.
+func RefreshSourceCoverage(db *sql.DB, source string) (SourceCoverage, error) {
+	c, err := GetSourceCoverage(db, source)
+	if err != nil {
+		return c, err
+	}
+
+	yearsAvailable := ""
+	if c.YearCount > 0 {
+		yearsAvailable = fmt.Sprintf("%d-%d", c.YearMin, c.YearMax)
+	}
+	_, err = db.Exec(`
+		UPDATE source_metadata
+		SET year_min = ?, year_max = ?, year_count = ?, row_count = ?, years_available = ?
+		WHERE source_name = ?
+	`, nullInt(c.YearMin, c.YearCount), nullInt(c.YearMax, c.YearCount), c.YearCount, c.RowCount, yearsAvailable, source)
+	return c, err
+}
+
+func nullInt(v, count int) interface{} {
+	if count == 0 {
+		return nil
+	}
+	return v
+}
+
 func GetSourceMetadata(db *sql.DB) ([]SourceMetadata, error) {
 	rows, err := db.Query(`
 		SELECT source_name, last_download, years_available, row_count, status 
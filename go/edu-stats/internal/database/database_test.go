@@ -2,8 +2,12 @@ package database
 
 import (
 	"database/sql"
+	"errors"
+	"math"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func setupTestDB(t *testing.T) *sql.DB {
@@ -44,6 +48,20 @@ func setupTestDB(t *testing.T) *sql.DB {
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			UNIQUE(year, age_group, gender, source)
 		);
+
+		CREATE TABLE revision_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			run_id TEXT NOT NULL,
+			source TEXT NOT NULL,
+			table_name TEXT NOT NULL,
+			year INTEGER NOT NULL,
+			series_key TEXT,
+			column_name TEXT NOT NULL,
+			old_value REAL,
+			new_value REAL,
+			change_type TEXT NOT NULL,
+			recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
 	`
 
 	if _, err := db.Exec(schemaSQL); err != nil {
@@ -144,3 +162,338 @@ func TestApplySchema(t *testing.T) {
 	// Note: ApplySchema looks for schema.sql in specific locations
 	// This test would need to be adjusted based on actual file structure
 }
+
+func TestReplaceWindowReport(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	load := WindowLoad{
+		Table:     LiteracyRates,
+		Source:    "test",
+		StartYear: 2000,
+		EndYear:   2010,
+		Rows: []Observation{
+			{Year: 2000, Key: []interface{}{"adult", nil}, Values: []sql.NullFloat64{Float(98.0)}},
+			{Year: 2005, Key: []interface{}{"adult", nil}, Values: []sql.NullFloat64{Float(98.5)}},
+		},
+	}
+	report, err := ReplaceWindow(db, load)
+	if err != nil {
+		t.Fatalf("first load: %v", err)
+	}
+	if report.Inserted != 2 {
+		t.Errorf("first load: want 2 inserted, got %+v", report)
+	}
+
+	// Row outside the window must survive the second load.
+	if _, err := db.Exec(`INSERT INTO literacy_rates (year, age_group, rate, source) VALUES (1990, 'adult', 97.0, 'test')`); err != nil {
+		t.Fatalf("insert outside row: %v", err)
+	}
+
+	load.Rows = []Observation{
+		{Year: 2000, Key: []interface{}{"adult", nil}, Values: []sql.NullFloat64{Float(98.2)}},
+		{Year: 2010, Key: []interface{}{"adult", nil}, Values: []sql.NullFloat64{Float(99.0)}},
+	}
+	report, err = ReplaceWindow(db, load)
+	if err != nil {
+		t.Fatalf("second load: %v", err)
+	}
+	if report.Inserted != 1 || report.Updated != 1 || report.Unchanged != 0 || report.Removed != 1 {
+		t.Errorf("second load report = %+v, want 1 inserted, 1 updated, 0 unchanged, 1 removed", report)
+	}
+	if report.Revisions != 2 {
+		t.Errorf("expected 2 revisions (2000 updated, 2005 removed), got %d", report.Revisions)
+	}
+
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM literacy_rates WHERE source = 'test'`).Scan(&n)
+	if n != 3 {
+		t.Errorf("expected 3 rows (1990, 2000, 2010), got %d", n)
+	}
+}
+
+func TestReplaceWindowRecordsRevisions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	load := WindowLoad{
+		Table:     LiteracyRates,
+		Source:    "test",
+		StartYear: 2000,
+		EndYear:   2000,
+		Rows: []Observation{
+			{Year: 2000, Key: []interface{}{"adult", nil}, Values: []sql.NullFloat64{Float(98.0)}},
+		},
+	}
+	if _, err := ReplaceWindow(db, load); err != nil {
+		t.Fatalf("first load: %v", err)
+	}
+
+	load.RunID = "run-2"
+	load.Rows[0].Values = []sql.NullFloat64{Float(97.5)}
+	if _, err := ReplaceWindow(db, load); err != nil {
+		t.Fatalf("revising load: %v", err)
+	}
+
+	revisions, err := GetRevisions(db, "test", "", time.Time{})
+	if err != nil {
+		t.Fatalf("GetRevisions: %v", err)
+	}
+	if len(revisions) != 1 {
+		t.Fatalf("expected 1 revision, got %d", len(revisions))
+	}
+	r := revisions[0]
+	if r.RunID != "run-2" || r.OldValue.Float64 != 98.0 || r.NewValue.Float64 != 97.5 {
+		t.Errorf("unexpected revision %+v", r)
+	}
+	if r.SeriesKey != "age_group=adult" {
+		t.Errorf("series key = %q, want age_group=adult", r.SeriesKey)
+	}
+	if byRun, _ := GetRevisions(db, "", "run-2", time.Time{}); len(byRun) != 1 {
+		t.Errorf("revisions for run-2 = %d, want 1", len(byRun))
+	}
+	if other, _ := GetRevisions(db, "", "run-1", time.Time{}); len(other) != 0 {
+		t.Errorf("revisions for run-1 = %+v, want none", other)
+	}
+}
+
+func TestReplaceWindowMaxRevisions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	load := WindowLoad{
+		Table:     LiteracyRates,
+		Source:    "test",
+		StartYear: 2000,
+		EndYear:   2001,
+		Rows: []Observation{
+			{Year: 2000, Key: []interface{}{"adult", nil}, Values: []sql.NullFloat64{Float(98.0)}},
+			{Year: 2001, Key: []interface{}{"adult", nil}, Values: []sql.NullFloat64{Float(98.1)}},
+		},
+	}
+	if _, err := ReplaceWindow(db, load); err != nil {
+		t.Fatalf("first load: %v", err)
+	}
+
+	load.MaxRevisions = 1
+	load.Rows = nil
+	_, err := ReplaceWindow(db, load)
+	if !errors.Is(err, ErrTooManyRevisions) {
+		t.Fatalf("expected ErrTooManyRevisions, got %v", err)
+	}
+
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM literacy_rates`).Scan(&n)
+	if n != 2 {
+		t.Errorf("rejected load should leave rows untouched, got %d rows", n)
+	}
+}
+
+func TestReplaceWindowsMaxRevisionsCoversRun(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	row := func(year int, rate float64) Observation {
+		return Observation{Year: year, Key: []interface{}{"adult", nil}, Values: []sql.NullFloat64{Float(rate)}}
+	}
+	early := WindowLoad{Table: LiteracyRates, Source: "test", StartYear: 2000, EndYear: 2000,
+		Rows: []Observation{row(2000, 98.0)}}
+	late := WindowLoad{Table: LiteracyRates, Source: "test", StartYear: 2010, EndYear: 2010,
+		Rows: []Observation{row(2010, 99.0)}}
+	if _, err := ReplaceWindows(db, early, late); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+
+	// Each load revises one value, within the limit on its own but not
+	// together.
+	early.Rows, late.Rows = []Observation{row(2000, 97.0)}, []Observation{row(2010, 98.0)}
+	early.MaxRevisions, late.MaxRevisions = 1, 1
+	early.RunID, late.RunID = "run-2", "run-2"
+	reports, err := ReplaceWindows(db, early, late)
+	if !errors.Is(err, ErrTooManyRevisions) {
+		t.Fatalf("expected ErrTooManyRevisions, got %v", err)
+	}
+	if len(reports) != 2 || reports[0].Revisions != 1 || reports[1].Revisions != 1 {
+		t.Errorf("reports = %+v, want one revision per load", reports)
+	}
+
+	var rate float64
+	db.QueryRow(`SELECT rate FROM literacy_rates WHERE year = 2000`).Scan(&rate)
+	if rate != 98.0 {
+		t.Errorf("the first load of a rejected run was kept: rate = %v", rate)
+	}
+	if revisions, _ := GetRevisions(db, "test", "", time.Time{}); len(revisions) != 0 {
+		t.Errorf("a rejected run recorded %d revisions", len(revisions))
+	}
+
+	early.MaxRevisions, late.MaxRevisions = 2, 2
+	if _, err := ReplaceWindows(db, early, late); err != nil {
+		t.Fatalf("run within the limit: %v", err)
+	}
+	revisions, _ := GetRevisions(db, "test", "", time.Time{})
+	if len(revisions) != 2 || revisions[0].RunID != "run-2" || revisions[1].RunID != "run-2" {
+		t.Errorf("revisions = %+v, want two under run-2", revisions)
+	}
+}
+
+func TestReplaceWindowDryRun(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO literacy_rates (year, age_group, rate, source) VALUES (2000, 'adult', 97.0, 'test')`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	report, err := ReplaceWindow(db, WindowLoad{
+		Table:     LiteracyRates,
+		Source:    "test",
+		StartYear: 2000,
+		EndYear:   2005,
+		DryRun:    true,
+		Rows: []Observation{
+			{Year: 2000, Key: []interface{}{"adult", nil}, Values: []sql.NullFloat64{Float(98.0)}},
+			{Year: 2005, Key: []interface{}{"adult", nil}, Values: []sql.NullFloat64{Float(98.5)}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if report.Inserted != 1 || report.Updated != 1 {
+		t.Errorf("dry run report = %+v, want 1 inserted, 1 updated", report)
+	}
+
+	var count int
+	var rate float64
+	db.QueryRow(`SELECT COUNT(*), MAX(rate) FROM literacy_rates`).Scan(&count, &rate)
+	if count != 1 || rate != 97.0 {
+		t.Errorf("dry run changed the table: %d rows, rate %v", count, rate)
+	}
+	db.QueryRow(`SELECT COUNT(*) FROM revision_history`).Scan(&count)
+	if count != 0 {
+		t.Errorf("dry run recorded %d revisions", count)
+	}
+}
+
+func TestDataVersionsAndGenerationState(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	versions, err := DataVersions(db)
+	if err != nil || versions != nil {
+		t.Fatalf("DataVersions without the table = %v, %v; want nil, nil", versions, err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE data_versions (table_name TEXT PRIMARY KEY, version INTEGER NOT NULL DEFAULT 0, changed_at DATETIME);
+		CREATE TABLE generation_state (output_dir TEXT NOT NULL, table_name TEXT NOT NULL, data_version INTEGER NOT NULL,
+			settings TEXT NOT NULL, run_id TEXT NOT NULL, generated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (output_dir, table_name));
+		CREATE TABLE educational_attainment (id INTEGER PRIMARY KEY);
+		CREATE TABLE graduation_rates (id INTEGER PRIMARY KEY);
+		CREATE TABLE enrollment_rates (id INTEGER PRIMARY KEY);
+		CREATE TABLE test_proficiency (id INTEGER PRIMARY KEY);
+		CREATE TABLE early_childhood (id INTEGER PRIMARY KEY);
+	`)
+	if err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+	if err := TrackDataVersions(db); err != nil {
+		t.Fatalf("TrackDataVersions: %v", err)
+	}
+	// Installing twice is a no-op.
+	if err := TrackDataVersions(db); err != nil {
+		t.Fatalf("TrackDataVersions again: %v", err)
+	}
+
+	if _, err := db.Exec(`INSERT INTO literacy_rates (year, age_group, rate, source) VALUES (2000, 'adult', 99, 'wb'), (2001, 'adult', 99, 'wb')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE literacy_rates SET rate = 98 WHERE year = 2000`); err != nil {
+		t.Fatal(err)
+	}
+	versions, err = DataVersions(db)
+	if err != nil {
+		t.Fatalf("DataVersions: %v", err)
+	}
+	if versions["literacy_rates"] != 3 || versions["graduation_rates"] != 0 {
+		t.Errorf("versions = %v, want literacy_rates 3 and nothing else", versions)
+	}
+
+	if _, found, err := LoadGenerationState(db, "/out"); err != nil || found {
+		t.Fatalf("LoadGenerationState before save = %v, %v", found, err)
+	}
+	state := GenerationState{RunID: "assets-1", Settings: "s", Versions: versions}
+	if err := SaveGenerationState(db, "/out", state); err != nil {
+		t.Fatalf("SaveGenerationState: %v", err)
+	}
+	state.RunID = "assets-2"
+	if err := SaveGenerationState(db, "/out", state); err != nil {
+		t.Fatalf("SaveGenerationState again: %v", err)
+	}
+	got, found, err := LoadGenerationState(db, "/out")
+	if err != nil || !found {
+		t.Fatalf("LoadGenerationState = %v, %v", found, err)
+	}
+	if got.RunID != "assets-2" || got.Settings != "s" || got.Versions["literacy_rates"] != 3 || len(got.Versions) != len(ObservationTables) {
+		t.Errorf("state = %+v", got)
+	}
+}
+
+func TestReplaceWindowUncertainty(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	load := WindowLoad{
+		Table:     LiteracyRates,
+		Source:    "test",
+		StartYear: 2000,
+		EndYear:   2001,
+		Rows: []Observation{
+			{Year: 2000, Key: []interface{}{"adult", nil}, Values: []sql.NullFloat64{Float(98.0)}, Uncertainty: StandardError(98.0, 0.5)},
+			{Year: 2001, Key: []interface{}{"adult", nil}, Values: []sql.NullFloat64{Float(98.4)}},
+		},
+	}
+	// A table from before the uncertainty columns refuses rows with one.
+	if _, err := ReplaceWindow(db, load); err == nil || !strings.Contains(err.Error(), "check-schema") {
+		t.Fatalf("load without the columns: err = %v, want a check-schema hint", err)
+	}
+	for _, c := range UncertaintyColumns {
+		if _, err := db.Exec("ALTER TABLE literacy_rates ADD COLUMN " + c + " REAL"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ReplaceWindow(db, load); err != nil {
+		t.Fatalf("first load: %v", err)
+	}
+
+	series, err := LoadSeries(db, LiteracyRates, "rate")
+	if err != nil || len(series) != 1 || len(series[0].Points) != 2 {
+		t.Fatalf("LoadSeries = %+v, %v", series, err)
+	}
+	u := series[0].Points[0].Uncertainty
+	if u.StandardError.Float64 != 0.5 || math.Abs(u.CILow.Float64-97.02) > 0.01 || math.Abs(u.CIHigh.Float64-98.98) > 0.01 {
+		t.Errorf("2000 uncertainty = %+v, want SE 0.5 and 95%% interval 97.02-98.98", u)
+	}
+	if !series[0].Points[1].Uncertainty.IsZero() {
+		t.Errorf("2001 has no uncertainty, got %+v", series[0].Points[1].Uncertainty)
+	}
+
+	// A revised standard error is a revision like any other value.
+	load.RunID = "run-2"
+	load.Rows[0].Uncertainty = StandardError(98.0, 0.4)
+	report, err := ReplaceWindow(db, load)
+	if err != nil {
+		t.Fatalf("revising load: %v", err)
+	}
+	if report.Updated != 1 || report.Unchanged != 1 || report.Revisions != 3 {
+		t.Errorf("report = %+v, want the 2000 row updated with 3 revised columns", report)
+	}
+	revisions, _ := GetRevisions(db, "test", "", time.Time{})
+	columns := make(map[string]bool)
+	for _, r := range revisions {
+		columns[r.Column] = true
+	}
+	if !columns["standard_error"] || !columns["ci_low"] || columns["rate"] {
+		t.Errorf("revised columns = %v, want the uncertainty only", columns)
+	}
+}
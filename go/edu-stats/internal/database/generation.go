@@ -0,0 +1,110 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DataVersions returns the change counter of every observation table that
+// has changed since data_versions was installed; tables never changed are
+// absent (version 0). It returns nil when the database predates
+// data_versions, so callers can fall back to treating everything as changed.
+func DataVersions(db *sql.DB) (map[string]int64, error) {
+	var name string
+	err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'data_versions'`).Scan(&name)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT table_name, version FROM data_versions`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[string]int64)
+	for rows.Next() {
+		var table string
+		var version int64
+		if err := rows.Scan(&table, &version); err != nil {
+			return nil, err
+		}
+		versions[table] = version
+	}
+	return versions, rows.Err()
+}
+
+// DataVersion sums the change counters of every observation table, so it
+// moves whenever any observation row is inserted, updated or deleted. ok is
+// false when the database predates data_versions.
+func DataVersion(db *sql.DB) (version int64, ok bool, err error) {
+	versions, err := DataVersions(db)
+	if err != nil || versions == nil {
+		return 0, false, err
+	}
+	for _, v := range versions {
+		version += v
+	}
+	return version, true, nil
+}
+
+// GenerationState is what the last generate-assets run into one output
+// directory was built from.
+type GenerationState struct {
+	RunID string
+	// Settings fingerprints the generator options, so a run with different
+	// options does not reuse files built with the old ones.
+	Settings string
+	Versions map[string]int64
+}
+
+// LoadGenerationState returns the state recorded for outputDir, and false
+// when nothing has been recorded.
+func LoadGenerationState(db *sql.DB, outputDir string) (GenerationState, bool, error) {
+	rows, err := db.Query(`
+		SELECT table_name, data_version, settings, run_id
+		FROM generation_state WHERE output_dir = ?
+	`, outputDir)
+	if err != nil {
+		return GenerationState{}, false, err
+	}
+	defer rows.Close()
+
+	state := GenerationState{Versions: make(map[string]int64)}
+	found := false
+	for rows.Next() {
+		var table string
+		var version int64
+		if err := rows.Scan(&table, &version, &state.Settings, &state.RunID); err != nil {
+			return GenerationState{}, false, err
+		}
+		state.Versions[table] = version
+		found = true
+	}
+	return state, found, rows.Err()
+}
+
+// SaveGenerationState replaces the state recorded for outputDir.
+func SaveGenerationState(db *sql.DB, outputDir string, state GenerationState) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM generation_state WHERE output_dir = ?`, outputDir); err != nil {
+		return err
+	}
+	for _, t := range ObservationTables {
+		if _, err := tx.Exec(`
+			INSERT INTO generation_state (output_dir, table_name, data_version, settings, run_id)
+			VALUES (?, ?, ?, ?, ?)
+		`, outputDir, t.Name, state.Versions[t.Name], state.Settings, state.RunID); err != nil {
+			return fmt.Errorf("record generation state: %w", err)
+		}
+	}
+	return tx.Commit()
+}
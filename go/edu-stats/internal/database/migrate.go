@@ -0,0 +1,102 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// addedColumn is a column added to schema.sql after its table was first
+// created.
+type addedColumn struct {
+	table      string
+	column     string
+	definition string
+}
+
+// addedColumns lists the columns added to existing tables. CREATE TABLE IF
+// NOT EXISTS leaves existing tables alone, so ApplySchema adds any of these
+// that an older database is missing.
+var addedColumns = append([]addedColumn{
+	{"source_metadata", "year_min", "INTEGER"},
+	{"source_metadata", "year_max", "INTEGER"},
+	{"source_metadata", "year_count", "INTEGER DEFAULT 0"},
+	{"validation_results", "data_version", "INTEGER"},
+}, uncertaintyColumns()...)
+
+// uncertaintyColumns adds UncertaintyColumns to every observation table.
+func uncertaintyColumns() []addedColumn {
+	var cols []addedColumn
+	for _, t := range ObservationTables {
+		for _, c := range UncertaintyColumns {
+			cols = append(cols, addedColumn{t.Name, c, "REAL"})
+		}
+	}
+	return cols
+}
+
+func migrateColumns(db *sql.DB) error {
+	for _, c := range addedColumns {
+		exists, err := columnExists(db, c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+			return fmt.Errorf("failed to add %s.%s: %w", c.table, c.column, err)
+		}
+		fmt.Printf("✓ Added column %s.%s\n", c.table, c.column)
+	}
+	return nil
+}
+
+// HasUncertainty reports whether t has UncertaintyColumns, which databases
+// created before they existed lack until ApplySchema adds them.
+func HasUncertainty(db *sql.DB, t ObservationTable) (bool, error) {
+	return columnExists(db, t.Name, UncertaintyColumns[0])
+}
+
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// TrackDataVersions installs insert, update and delete triggers on every
+// observation table that bump its row in data_versions, so readers can tell
+// which tables changed since they last looked.
+func TrackDataVersions(db *sql.DB) error {
+	for _, t := range ObservationTables {
+		for _, event := range []string{"INSERT", "UPDATE", "DELETE"} {
+			trigger := fmt.Sprintf(`
+				CREATE TRIGGER IF NOT EXISTS %s_%s_version AFTER %s ON %s
+				BEGIN
+					INSERT INTO data_versions (table_name, version, changed_at)
+					VALUES ('%s', 1, CURRENT_TIMESTAMP)
+					ON CONFLICT(table_name) DO UPDATE SET
+						version = version + 1, changed_at = CURRENT_TIMESTAMP;
+				END`, t.Name, strings.ToLower(event), event, t.Name, t.Name)
+			if _, err := db.Exec(trigger); err != nil {
+				return fmt.Errorf("%s %s trigger: %w", t.Name, strings.ToLower(event), err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,665 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ObservationTable describes one of the per-stat observation tables: its
+// logical key (the UNIQUE constraint columns other than year and source) and
+// the measured value columns.
+type ObservationTable struct {
+	Name         string
+	KeyColumns   []string
+	ValueColumns []string
+	// IntegerKeys are the key columns stored as INTEGER; the rest are TEXT.
+	IntegerKeys []string
+	// NotNull and Allowed mirror the NOT NULL and CHECK(col IN (...))
+	// constraints schema.sql declares on key columns.
+	NotNull []string
+	Allowed map[string][]string
+}
+
+// IsIntegerKey reports whether col is one of the table's INTEGER key columns.
+func (t ObservationTable) IsIntegerKey(col string) bool {
+	for _, c := range t.IntegerKeys {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	LiteracyRates = ObservationTable{
+		Name:         "literacy_rates",
+		KeyColumns:   []string{"age_group", "gender"},
+		ValueColumns: []string{"rate"},
+		NotNull:      []string{"age_group"},
+	}
+	EducationalAttainment = ObservationTable{
+		Name:         "educational_attainment",
+		KeyColumns:   []string{"age_group", "education_level", "gender", "race"},
+		ValueColumns: []string{"percentage"},
+		NotNull:      []string{"age_group", "education_level"},
+		Allowed: map[string][]string{
+			"education_level": {"high_school", "bachelors_plus", "associates", "graduate"},
+		},
+	}
+	GraduationRates = ObservationTable{
+		Name:         "graduation_rates",
+		KeyColumns:   []string{"cohort_year", "state", "demographics"},
+		ValueColumns: []string{"rate"},
+		IntegerKeys:  []string{"cohort_year"},
+	}
+	EnrollmentRates = ObservationTable{
+		Name:         "enrollment_rates",
+		KeyColumns:   []string{"age_group", "level", "state", "demographics"},
+		ValueColumns: []string{"enrollment_rate"},
+		NotNull:      []string{"age_group"},
+		Allowed: map[string][]string{
+			"level": {"elementary", "secondary", "postsecondary"},
+		},
+	}
+	TestProficiency = ObservationTable{
+		Name:         "test_proficiency",
+		KeyColumns:   []string{"subject", "grade", "proficiency_level", "state", "demographics"},
+		ValueColumns: []string{"avg_score", "percentage_proficient"},
+		IntegerKeys:  []string{"grade"},
+		NotNull:      []string{"subject", "grade"},
+		Allowed: map[string][]string{
+			"subject": {"reading", "mathematics", "science", "writing"},
+			"grade":   {"4", "8", "12"},
+		},
+	}
+	EarlyChildhood = ObservationTable{
+		Name:         "early_childhood",
+		KeyColumns:   []string{"cohort_year", "metric_name", "age_months", "demographics"},
+		ValueColumns: []string{"metric_value"},
+		IntegerKeys:  []string{"cohort_year", "age_months"},
+		NotNull:      []string{"metric_name"},
+	}
+)
+
+// ObservationTables lists every observation table in schema order.
+var ObservationTables = []ObservationTable{
+	LiteracyRates,
+	EducationalAttainment,
+	GraduationRates,
+	EnrollmentRates,
+	TestProficiency,
+	EarlyChildhood,
+}
+
+// Observation is a single row to be loaded into an ObservationTable. Key and
+// Values line up with the table's KeyColumns and ValueColumns; a nil key
+// entry is stored as NULL.
+type Observation struct {
+	Year   int
+	Key    []interface{}
+	Values []sql.NullFloat64
+	// Uncertainty is the published sampling error of Values[0].
+	Uncertainty Uncertainty
+}
+
+// Float wraps v as a non-NULL value column.
+func Float(v float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: v, Valid: true}
+}
+
+// UncertaintyColumns are the columns every observation table stores
+// Uncertainty in, in order.
+var UncertaintyColumns = []string{"standard_error", "ci_low", "ci_high"}
+
+// Uncertainty is the sampling error a source publishes with a row's first
+// value column: its standard error and 95% confidence interval. The zero
+// value means the source publishes none.
+type Uncertainty struct {
+	StandardError sql.NullFloat64
+	CILow         sql.NullFloat64
+	CIHigh        sql.NullFloat64
+}
+
+// Z95 is the standard normal quantile of a two-sided 95% interval.
+const Z95 = 1.959963984540054
+
+// StandardError returns the uncertainty of value given its standard error,
+// with a normal 95% confidence interval.
+func StandardError(value, se float64) Uncertainty {
+	return Uncertainty{
+		StandardError: Float(se),
+		CILow:         Float(value - Z95*se),
+		CIHigh:        Float(value + Z95*se),
+	}
+}
+
+// MarginOfError converts a margin of error published at the confidence
+// level whose normal quantile is z (1.645 for the 90% margins of the ACS)
+// into a standard error and 95% interval.
+func MarginOfError(value, moe, z float64) Uncertainty {
+	return StandardError(value, moe/z)
+}
+
+// IsZero reports whether u holds nothing.
+func (u Uncertainty) IsZero() bool {
+	return !u.StandardError.Valid && !u.CILow.Valid && !u.CIHigh.Valid
+}
+
+// values lines u up with UncertaintyColumns.
+func (u Uncertainty) values() []sql.NullFloat64 {
+	return []sql.NullFloat64{u.StandardError, u.CILow, u.CIHigh}
+}
+
+// WindowLoad is a source's complete set of rows for a year window. Applying
+// it replaces only that window; rows for the source outside the window are
+// left untouched.
+type WindowLoad struct {
+	Table     ObservationTable
+	Source    string
+	StartYear int
+	EndYear   int
+	// Preserve lists years inside the window whose existing rows must be
+	// kept even though Rows has nothing for them, e.g. an API year that
+	// failed to fetch.
+	Preserve map[int]bool
+	Rows     []Observation
+	// RunID tags the revision_history entries written by this load.
+	RunID string
+	// MaxRevisions aborts the load, leaving the table untouched, when more
+	// than this many stored values would change across its run (see
+	// ReplaceWindows). Zero means no limit.
+	MaxRevisions int
+	// DryRun computes the report and then rolls the load back.
+	DryRun bool
+}
+
+// ErrTooManyRevisions is returned by ReplaceWindows when a run would revise
+// more stored values than its MaxRevisions allows.
+var ErrTooManyRevisions = errors.New("too many revisions")
+
+// ChangeReport summarizes what a WindowLoad did to its table.
+type ChangeReport struct {
+	Source    string
+	Table     string
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Removed   int
+	// Revisions counts the stored values that were changed or removed.
+	Revisions int
+	RunID     string
+}
+
+func (r ChangeReport) String() string {
+	return fmt.Sprintf("%s/%s: %d inserted, %d updated, %d unchanged, %d removed",
+		r.Source, r.Table, r.Inserted, r.Updated, r.Unchanged, r.Removed)
+}
+
+// NewRunID returns an identifier for one load of source, used to group its
+// revision_history entries.
+func NewRunID(source string) string {
+	return fmt.Sprintf("%s-%s", source, time.Now().UTC().Format("20060102T150405.000Z"))
+}
+
+// ReplaceWindow upserts load.Rows keyed on the table's UNIQUE columns and
+// deletes rows for the source inside the window that are no longer present.
+// Keys are matched with IS so NULL dimensions compare equal, which SQLite's
+// UNIQUE constraints (and therefore ON CONFLICT) do not do. Every changed or
+// removed value, including its uncertainty, is recorded in revision_history
+// under load.RunID.
+func ReplaceWindow(db *sql.DB, load WindowLoad) (ChangeReport, error) {
+	reports, err := ReplaceWindows(db, load)
+	if len(reports) == 0 {
+		return ChangeReport{Source: load.Source, Table: load.Table.Name, RunID: load.RunID}, err
+	}
+	return reports[0], err
+}
+
+// ReplaceWindows applies the loads of one run, such as a downloader that
+// fills several tables, in a single transaction: either every table is
+// updated or none is. MaxRevisions is checked against the revisions of the
+// whole run, and a DryRun on any load rolls the whole run back. Loads
+// without a RunID share a new one.
+func ReplaceWindows(db *sql.DB, loads ...WindowLoad) ([]ChangeReport, error) {
+	runID := ""
+	for _, load := range loads {
+		if load.RunID != "" {
+			runID = load.RunID
+			break
+		}
+	}
+	if runID == "" && len(loads) > 0 {
+		runID = NewRunID(loads[0].Source)
+	}
+
+	// Databases from before UncertaintyColumns existed can still take rows
+	// without any.
+	hasUncertainty := make([]bool, len(loads))
+	for i := range loads {
+		if loads[i].RunID == "" {
+			loads[i].RunID = runID
+		}
+		has, err := HasUncertainty(db, loads[i].Table)
+		if err != nil {
+			return nil, err
+		}
+		hasUncertainty[i] = has
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	reports := make([]ChangeReport, len(loads))
+	stale := make([][]int64, len(loads))
+	var revisions []Revision
+	total, limit, dryRun := 0, 0, false
+	for i, load := range loads {
+		report, ids, revised, err := upsertWindow(tx, load, hasUncertainty[i])
+		reports[i] = report
+		if err != nil {
+			return reports[:i+1], err
+		}
+		stale[i] = ids
+		revisions = append(revisions, revised...)
+		total += report.Revisions
+		if load.MaxRevisions > 0 && (limit == 0 || load.MaxRevisions < limit) {
+			limit = load.MaxRevisions
+		}
+		dryRun = dryRun || load.DryRun
+	}
+
+	if limit > 0 && total > limit {
+		var tables []string
+		for _, r := range reports {
+			tables = append(tables, fmt.Sprintf("%s/%s", r.Source, r.Table))
+		}
+		return reports, fmt.Errorf("%s would revise %d stored values (limit %d): %w",
+			strings.Join(tables, ", "), total, limit, ErrTooManyRevisions)
+	}
+
+	for i, load := range loads {
+		for _, id := range stale[i] {
+			if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", load.Table.Name), id); err != nil {
+				return reports, fmt.Errorf("delete stale %s row: %w", load.Table.Name, err)
+			}
+			reports[i].Removed++
+		}
+	}
+
+	for _, r := range revisions {
+		if _, err := tx.Exec(`
+			INSERT INTO revision_history (run_id, source, table_name, year, series_key, column_name, old_value, new_value, change_type)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, r.RunID, r.Source, r.Table, r.Year, r.SeriesKey, r.Column, r.OldValue, r.NewValue, r.ChangeType); err != nil {
+			return reports, fmt.Errorf("record revision: %w", err)
+		}
+	}
+
+	if dryRun {
+		return reports, nil
+	}
+	return reports, tx.Commit()
+}
+
+// upsertWindow inserts and updates load.Rows inside tx and returns the ids
+// of the source's rows in the window that the load no longer has, along
+// with every revision the load would record. Stale rows are left for the
+// caller to delete once the run is within its revision limit.
+func upsertWindow(tx *sql.Tx, load WindowLoad, hasUncertainty bool) (ChangeReport, []int64, []Revision, error) {
+	report := ChangeReport{Source: load.Source, Table: load.Table.Name, RunID: load.RunID}
+	t := load.Table
+	var revisions []Revision
+
+	columns := t.ValueColumns
+	if hasUncertainty {
+		columns = append(append([]string{}, t.ValueColumns...), UncertaintyColumns...)
+	}
+
+	where := []string{"source = ?", "year IS ?"}
+	for _, col := range t.KeyColumns {
+		where = append(where, col+" IS ?")
+	}
+	selectSQL := fmt.Sprintf("SELECT id, %s FROM %s WHERE %s",
+		strings.Join(columns, ", "), t.Name, strings.Join(where, " AND "))
+
+	insertCols := append([]string{"year", "source"}, t.KeyColumns...)
+	insertCols = append(insertCols, columns...)
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		t.Name, strings.Join(insertCols, ", "), placeholders(len(insertCols)))
+
+	sets := make([]string, len(columns))
+	for i, col := range columns {
+		sets[i] = col + " = ?"
+	}
+	updateSQL := fmt.Sprintf("UPDATE %s SET %s WHERE id = ?", t.Name, strings.Join(sets, ", "))
+
+	kept := make(map[int64]bool)
+	for _, obs := range load.Rows {
+		if len(obs.Key) != len(t.KeyColumns) || len(obs.Values) != len(t.ValueColumns) {
+			return report, nil, nil, fmt.Errorf("%s: observation for %d has %d key and %d value columns, want %d and %d",
+				t.Name, obs.Year, len(obs.Key), len(obs.Values), len(t.KeyColumns), len(t.ValueColumns))
+		}
+		stored := obs.Values
+		if hasUncertainty {
+			stored = append(append([]sql.NullFloat64{}, obs.Values...), obs.Uncertainty.values()...)
+		} else if !obs.Uncertainty.IsZero() {
+			return report, nil, nil, fmt.Errorf("%s has no %s column; run 'edu-stats step check-schema' to add it",
+				t.Name, UncertaintyColumns[0])
+		}
+
+		args := append([]interface{}{load.Source, obs.Year}, obs.Key...)
+		existing := make([]sql.NullFloat64, len(columns))
+		dest := []interface{}{new(int64)}
+		for i := range existing {
+			dest = append(dest, &existing[i])
+		}
+
+		err := tx.QueryRow(selectSQL, args...).Scan(dest...)
+		switch {
+		case err == sql.ErrNoRows:
+			insertArgs := append([]interface{}{obs.Year, load.Source}, obs.Key...)
+			for _, v := range stored {
+				insertArgs = append(insertArgs, v)
+			}
+			res, err := tx.Exec(insertSQL, insertArgs...)
+			if err != nil {
+				return report, nil, nil, fmt.Errorf("insert %s year %d: %w", t.Name, obs.Year, err)
+			}
+			id, _ := res.LastInsertId()
+			kept[id] = true
+			report.Inserted++
+		case err != nil:
+			return report, nil, nil, fmt.Errorf("look up %s year %d: %w", t.Name, obs.Year, err)
+		default:
+			id := *dest[0].(*int64)
+			kept[id] = true
+			if valuesEqual(existing, stored) {
+				report.Unchanged++
+				continue
+			}
+			updateArgs := make([]interface{}, 0, len(stored)+1)
+			for _, v := range stored {
+				updateArgs = append(updateArgs, v)
+			}
+			updateArgs = append(updateArgs, id)
+			if _, err := tx.Exec(updateSQL, updateArgs...); err != nil {
+				return report, nil, nil, fmt.Errorf("update %s year %d: %w", t.Name, obs.Year, err)
+			}
+			report.Updated++
+			revisions = append(revisions, diffRevisions(load, columns, obs.Year, obs.Key, existing, stored, "updated")...)
+		}
+	}
+
+	staleSQL := fmt.Sprintf("SELECT id, year, %s, %s FROM %s WHERE source = ? AND year >= ? AND year <= ?",
+		strings.Join(t.KeyColumns, ", "), strings.Join(columns, ", "), t.Name)
+	rows, err := tx.Query(staleSQL, load.Source, load.StartYear, load.EndYear)
+	if err != nil {
+		return report, nil, nil, err
+	}
+	var stale []int64
+	for rows.Next() {
+		var id int64
+		var year int
+		key := make([]interface{}, len(t.KeyColumns))
+		values := make([]sql.NullFloat64, len(columns))
+		dest := []interface{}{&id, &year}
+		for i := range key {
+			dest = append(dest, &key[i])
+		}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return report, nil, nil, err
+		}
+		if kept[id] || load.Preserve[year] {
+			continue
+		}
+		stale = append(stale, id)
+		revisions = append(revisions, diffRevisions(load, columns, year, key, values, make([]sql.NullFloat64, len(values)), "removed")...)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, nil, nil, err
+	}
+
+	report.Revisions = len(revisions)
+	return report, stale, revisions, nil
+}
+
+// Revision is one stored value that a load changed or removed.
+type Revision struct {
+	RecordedAt time.Time
+	RunID      string
+	Source     string
+	Table      string
+	Year       int
+	SeriesKey  string
+	Column     string
+	OldValue   sql.NullFloat64
+	NewValue   sql.NullFloat64
+	ChangeType string
+}
+
+// GetRevisions lists recorded revisions, newest first. An empty source or
+// runID matches every source or run; a zero since matches every date.
+func GetRevisions(db *sql.DB, source, runID string, since time.Time) ([]Revision, error) {
+	query := `
+		SELECT recorded_at, run_id, source, table_name, year, series_key, column_name, old_value, new_value, change_type
+		FROM revision_history
+		WHERE 1 = 1`
+	var args []interface{}
+	if source != "" {
+		query += " AND source = ?"
+		args = append(args, source)
+	}
+	if runID != "" {
+		query += " AND run_id = ?"
+		args = append(args, runID)
+	}
+	if !since.IsZero() {
+		query += " AND recorded_at >= ?"
+		args = append(args, since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	query += " ORDER BY recorded_at DESC, id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+	for rows.Next() {
+		var r Revision
+		if err := rows.Scan(&r.RecordedAt, &r.RunID, &r.Source, &r.Table, &r.Year, &r.SeriesKey,
+			&r.Column, &r.OldValue, &r.NewValue, &r.ChangeType); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+// diffRevisions returns a Revision for every one of columns that differs
+// between old and new.
+func diffRevisions(load WindowLoad, columns []string, year int, key []interface{}, old, updated []sql.NullFloat64, changeType string) []Revision {
+	var revisions []Revision
+	for i, col := range columns {
+		if valuesEqual(old[i:i+1], updated[i:i+1]) {
+			continue
+		}
+		revisions = append(revisions, Revision{
+			RunID:      load.RunID,
+			Source:     load.Source,
+			Table:      load.Table.Name,
+			Year:       year,
+			SeriesKey:  SeriesKey(load.Table, key),
+			Column:     col,
+			OldValue:   old[i],
+			NewValue:   updated[i],
+			ChangeType: changeType,
+		})
+	}
+	return revisions
+}
+
+// SeriesKey renders a row's key columns as "col=value" pairs, leaving out
+// NULL columns, so rows of the same series share one readable label.
+func SeriesKey(table ObservationTable, key []interface{}) string {
+	var parts []string
+	for i, col := range table.KeyColumns {
+		if key[i] == nil {
+			continue
+		}
+		v := key[i]
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		parts = append(parts, fmt.Sprintf("%s=%v", col, v))
+	}
+	return strings.Join(parts, ",")
+}
+
+// CountSourceRows returns how many rows source has in table across all years.
+func CountSourceRows(db *sql.DB, table ObservationTable, source string) (int, error) {
+	var n int
+	err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE source = ?", table.Name), source).Scan(&n)
+	return n, err
+}
+
+func valuesEqual(a, b []sql.NullFloat64) bool {
+	for i := range a {
+		if a[i].Valid != b[i].Valid {
+			return false
+		}
+		if a[i].Valid && math.Abs(a[i].Float64-b[i].Float64) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// Point is one year's value in a Series.
+type Point struct {
+	Year  int
+	Value float64
+	// Uncertainty is only read for a table's first value column.
+	Uncertainty Uncertainty
+}
+
+// Series is every non-NULL value of one column for a single source and key
+// combination, ordered by year.
+type Series struct {
+	Table  ObservationTable
+	Column string
+	Source string
+	Key    []interface{}
+	Points []Point
+}
+
+// Label identifies the series as "table.column[source;key]" for reports.
+func (s Series) Label() string {
+	label := fmt.Sprintf("%s.%s[%s", s.Table.Name, s.Column, s.Source)
+	if key := SeriesKey(s.Table, s.Key); key != "" {
+		label += ";" + key
+	}
+	return label + "]"
+}
+
+// LoadSeries reads column from table and splits it into one Series per
+// source and key combination. Points of the table's first value column
+// carry their Uncertainty.
+func LoadSeries(db *sql.DB, table ObservationTable, column string) ([]Series, error) {
+	withUncertainty := false
+	if column == table.ValueColumns[0] {
+		var err error
+		if withUncertainty, err = HasUncertainty(db, table); err != nil {
+			return nil, err
+		}
+	}
+	cols := append([]string{"source"}, table.KeyColumns...)
+	values := column
+	if withUncertainty {
+		values += ", " + strings.Join(UncertaintyColumns, ", ")
+	}
+	query := fmt.Sprintf("SELECT %s, year, %s FROM %s WHERE %s IS NOT NULL ORDER BY %s, year",
+		strings.Join(cols, ", "), values, table.Name, column, strings.Join(cols, ", "))
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []Series
+	lastID := ""
+	for rows.Next() {
+		var source string
+		key := make([]interface{}, len(table.KeyColumns))
+		var p Point
+		dest := []interface{}{&source}
+		for i := range key {
+			dest = append(dest, &key[i])
+		}
+		dest = append(dest, &p.Year, &p.Value)
+		if withUncertainty {
+			u := &p.Uncertainty
+			dest = append(dest, &u.StandardError, &u.CILow, &u.CIHigh)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, v := range key {
+			if b, ok := v.([]byte); ok {
+				key[i] = string(b)
+			}
+		}
+
+		id := source + "\x00" + SeriesKey(table, key)
+		if id != lastID {
+			series = append(series, Series{Table: table, Column: column, Source: source, Key: key})
+			lastID = id
+		}
+		last := &series[len(series)-1]
+		last.Points = append(last.Points, p)
+	}
+	return series, rows.Err()
+}
+
+// LastDataChange returns when observation data last changed: the newest row
+// inserted into an observation table or revision recorded against one. It
+// is the zero time for an empty database.
+func LastDataChange(db *sql.DB) (time.Time, error) {
+	parts := make([]string, 0, len(ObservationTables)+1)
+	for _, t := range ObservationTables {
+		parts = append(parts, fmt.Sprintf("SELECT MAX(created_at) AS changed FROM %s", t.Name))
+	}
+	parts = append(parts, "SELECT MAX(recorded_at) AS changed FROM revision_history")
+
+	var latest sql.NullString
+	query := fmt.Sprintf("SELECT MAX(changed) FROM (%s)", strings.Join(parts, " UNION ALL "))
+	if err := db.QueryRow(query).Scan(&latest); err != nil {
+		return time.Time{}, err
+	}
+	if !latest.Valid {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04:05.999999999-07:00", time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, latest.String); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", latest.String)
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 
@@ -12,6 +13,9 @@ import (
 
 type CensusDownloader struct {
 	db *sql.DB
+	// MaxRevisions fails the download instead of overwriting more than this
+	// many previously stored values. Zero means no limit.
+	MaxRevisions int
 }
 
 func NewCensusDownloader(db *sql.DB) *CensusDownloader {
@@ -43,50 +47,46 @@ func (c *CensusDownloader) Download(startYear, endYear int, dryRun bool) error {
 		return nil
 	}
 
-	fmt.Println("  Downloading Census educational attainment data...")
+	runID := database.NewRunID(sourceName)
 
-	// Clear existing data for this source to avoid duplicates on re-run.
-	if _, err := c.db.Exec(`DELETE FROM educational_attainment WHERE source = ?`, sourceName); err != nil {
-		return fmt.Errorf("failed to clear existing attainment data: %w", err)
-	}
+	fmt.Println("  Downloading Census educational attainment data...")
 
-	totalRows := 0
+	var rows []database.Observation
+	// Years whose API fetch failed keep whatever is already stored for them.
+	preserve := make(map[int]bool)
 
 	// Seed historical data (pre-2010) from embedded Census CPS series.
+	historicalRows := 0
 	for _, h := range historicalAttainment {
 		if h.year < startYear || h.year > endYear {
 			continue
 		}
-		_, err := c.db.Exec(`
-			INSERT INTO educational_attainment (year, age_group, education_level, percentage, source)
-			VALUES (?, ?, ?, ?, ?)
-		`, h.year, "25plus", "bachelors_plus", h.pct, sourceName)
-		if err != nil {
-			fmt.Printf("    Warning: failed to insert historical year %d: %v\n", h.year, err)
-			continue
-		}
-		totalRows++
+		rows = append(rows, attainmentObservation(h.year, h.pct, database.Uncertainty{}))
+		historicalRows++
 	}
-	fmt.Printf("    ✓ Inserted %d historical attainment rows (1940–2009)\n", totalRows)
+	fmt.Printf("    ✓ Prepared %d historical attainment rows (1940–2009)\n", historicalRows)
 
 	// Fetch live ACS 1-year estimates for 2010–present.
 	apiRows := 0
 	for year := max(2010, startYear); year <= min(endYear, 2024); year++ {
-		// B15003_022E = Bachelor's degree count, B15003_001E = Total population 25+
+		// B15003_022E = Bachelor's degree count, B15003_001E = Total population 25+,
+		// and their margins of error (the M variables).
 		url := fmt.Sprintf(
-			"https://api.census.gov/data/%d/acs/acs1?get=NAME,B15003_022E,B15003_001E&for=us:*",
+			"https://api.census.gov/data/%d/acs/acs1?get=NAME,B15003_022E,B15003_022M,B15003_001E,B15003_001M&for=us:*",
 			year,
 		)
 
 		resp, err := http.Get(url)
 		if err != nil {
 			fmt.Printf("    ⚠ Failed to fetch year %d: %v\n", year, err)
+			preserve[year] = true
 			continue
 		}
 
 		if resp.StatusCode != 200 {
 			resp.Body.Close()
 			fmt.Printf("    ⚠ Year %d unavailable (HTTP %d)\n", year, resp.StatusCode)
+			preserve[year] = true
 			continue
 		}
 
@@ -94,6 +94,7 @@ func (c *CensusDownloader) Download(startYear, endYear int, dryRun bool) error {
 		if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
 			resp.Body.Close()
 			fmt.Printf("    ⚠ Failed to parse year %d: %v\n", year, err)
+			preserve[year] = true
 			continue
 		}
 		resp.Body.Close()
@@ -103,56 +104,116 @@ func (c *CensusDownloader) Download(startYear, endYear int, dryRun bool) error {
 			continue
 		}
 
-		row := data[1]
-		if len(row) < 3 {
-			continue
-		}
-
-		var bachelors, total float64
-		switch v := row[1].(type) {
-		case float64:
-			bachelors = v
-		case string:
-			bachelors, _ = strconv.ParseFloat(v, 64)
-		}
-		switch v := row[2].(type) {
-		case float64:
-			total = v
-		case string:
-			total, _ = strconv.ParseFloat(v, 64)
-		}
-
-		if total == 0 {
-			continue
-		}
-
-		percentage := (bachelors / total) * 100
-		_, err = c.db.Exec(`
-			INSERT INTO educational_attainment (year, age_group, education_level, percentage, source)
-			VALUES (?, ?, ?, ?, ?)
-		`, year, "25plus", "bachelors_plus", percentage, sourceName)
-		if err != nil {
-			fmt.Printf("    Warning: failed to insert year %d: %v\n", year, err)
+		percentage, uncertainty, ok := acsAttainment(data[1])
+		if !ok {
 			continue
 		}
+		rows = append(rows, attainmentObservation(year, percentage, uncertainty))
 		apiRows++
-		totalRows++
-		fmt.Printf("    ✓ Year %d: %.1f%%\n", year, percentage)
+		if uncertainty.StandardError.Valid {
+			fmt.Printf("    ✓ Year %d: %.1f%% (SE %.2f)\n", year, percentage, uncertainty.StandardError.Float64)
+		} else {
+			fmt.Printf("    ✓ Year %d: %.1f%%\n", year, percentage)
+		}
 	}
 	fmt.Printf("    ✓ Fetched %d years from Census ACS API (2010–present)\n", apiRows)
 
+	report, err := database.ReplaceWindow(c.db, database.WindowLoad{
+		Table:        database.EducationalAttainment,
+		Source:       sourceName,
+		StartYear:    startYear,
+		EndYear:      endYear,
+		Preserve:     preserve,
+		RunID:        runID,
+		MaxRevisions: c.MaxRevisions,
+		Rows:         rows,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load attainment data: %w", err)
+	}
+	fmt.Printf("    ✓ %s\n", report)
+
+	totalRows, _ := database.CountSourceRows(c.db, database.EducationalAttainment, sourceName)
 	yearsRange := fmt.Sprintf("%d-%d", startYear, endYear)
 	status := "success"
-	if totalRows == 0 {
+	if len(rows) == 0 || len(preserve) > 0 {
 		status = "partial"
 	}
 	database.UpdateSourceMetadata(c.db, sourceName, yearsRange, totalRows, status,
-		fmt.Sprintf("Historical (1940–2009) + Census ACS API (2010+): %d total rows", totalRows))
+		fmt.Sprintf("Historical (1940–2009) + Census ACS API (2010+): %s", report))
+	database.RefreshSourceCoverage(c.db, sourceName)
 
-	fmt.Printf("  ✓ Census download complete: %d rows\n", totalRows)
+	fmt.Printf("  ✓ Census download complete: %d rows in %d-%d\n", len(rows), startYear, endYear)
 	return nil
 }
 
+// attainmentObservation builds the national bachelor's-or-higher row for the
+// 25+ population, the only attainment breakdown this downloader produces.
+func attainmentObservation(year int, pct float64, u database.Uncertainty) database.Observation {
+	return database.Observation{
+		Year:        year,
+		Key:         []interface{}{"25plus", "bachelors_plus", nil, nil},
+		Values:      []sql.NullFloat64{database.Float(pct)},
+		Uncertainty: u,
+	}
+}
+
+// acsZ is the normal quantile of the 90% level ACS margins of error are
+// published at.
+const acsZ = 1.645
+
+// acsControlled is the annotation the ACS API returns in place of a margin
+// of error for an estimate controlled to the population totals, whose
+// margin is zero. Other negative annotations mean no margin is available.
+const acsControlled = -555555555
+
+// acsAttainment computes the bachelor's degree share from an ACS row of
+// NAME, B15003_022E, B15003_022M, B15003_001E and B15003_001M. Its
+// uncertainty follows the Census Bureau's formula for the margin of error
+// of a proportion, and is left empty when a margin is unavailable. ok is
+// false when the row has no usable total.
+func acsAttainment(row []interface{}) (pct float64, u database.Uncertainty, ok bool) {
+	if len(row) < 5 {
+		return 0, u, false
+	}
+	bachelors, _ := acsNumber(row[1])
+	bachelorsMOE, hasBachelorsMOE := acsNumber(row[2])
+	total, _ := acsNumber(row[3])
+	totalMOE, hasTotalMOE := acsNumber(row[4])
+	if total <= 0 {
+		return 0, u, false
+	}
+
+	p := bachelors / total
+	pct = p * 100
+	if totalMOE == acsControlled {
+		totalMOE = 0
+	}
+	if !hasBachelorsMOE || !hasTotalMOE || bachelorsMOE < 0 || totalMOE < 0 {
+		return pct, u, true
+	}
+	// When the difference under the root is negative the Bureau uses the
+	// ratio formula, which adds the terms instead.
+	radicand := bachelorsMOE*bachelorsMOE - p*p*totalMOE*totalMOE
+	if radicand < 0 {
+		radicand = bachelorsMOE*bachelorsMOE + p*p*totalMOE*totalMOE
+	}
+	moe := math.Sqrt(radicand) / total * 100
+	return pct, database.MarginOfError(pct, moe, acsZ), true
+}
+
+// acsNumber reads an ACS API cell, which may be a JSON number or a string.
+func acsNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -2,6 +2,7 @@ package downloaders
 
 import (
 	"database/sql"
+	"math"
 	"testing"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -47,6 +48,13 @@ func setupDownloaderTestDB(t *testing.T) *sql.DB {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		source_name TEXT UNIQUE NOT NULL, years_range TEXT,
 		rows_downloaded INTEGER, status TEXT, notes TEXT, last_run DATETIME
+	);
+	CREATE TABLE revision_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id TEXT NOT NULL, source TEXT NOT NULL, table_name TEXT NOT NULL,
+		year INTEGER NOT NULL, series_key TEXT, column_name TEXT NOT NULL,
+		old_value REAL, new_value REAL, change_type TEXT NOT NULL,
+		recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);`
 	if _, err := db.Exec(schema); err != nil {
 		t.Fatalf("create schema: %v", err)
@@ -317,3 +325,76 @@ func TestWorldBankDownloaderIdempotent(t *testing.T) {
 		t.Errorf("re-run changed row count: %d → %d (not idempotent)", n1, n2)
 	}
 }
+
+// --- Incremental window loads ---
+
+func TestNCESDownloaderPreservesRowsOutsideWindow(t *testing.T) {
+	db := setupDownloaderTestDB(t)
+	defer db.Close()
+	d := NewNCESDownloader(db)
+	if err := d.Download(1960, 2020, false); err != nil {
+		t.Fatalf("full run: %v", err)
+	}
+	full := countRows(t, db, "graduation_rates")
+
+	if err := d.Download(2010, 2020, false); err != nil {
+		t.Fatalf("window run: %v", err)
+	}
+	if n := countRows(t, db, "graduation_rates"); n != full {
+		t.Errorf("window reload changed row count: %d → %d", full, n)
+	}
+	var rate float64
+	if err := db.QueryRow(`SELECT rate FROM graduation_rates WHERE year = 1960`).Scan(&rate); err != nil {
+		t.Fatalf("1960 graduation lost after 2010-2020 reload: %v", err)
+	}
+}
+
+func TestNAEPDownloaderReplacesOnlyWindow(t *testing.T) {
+	db := setupDownloaderTestDB(t)
+	defer db.Close()
+	// A stale row inside the window and a foreign-source row must be treated differently.
+	if _, err := db.Exec(`
+		INSERT INTO test_proficiency (year, subject, grade, avg_score, source)
+		VALUES (2021, 'reading', 8, 999, 'naep_proficiency'),
+		       (2021, 'reading', 8, 262, 'other_source')
+	`); err != nil {
+		t.Fatalf("seed rows: %v", err)
+	}
+	d := NewNAEPDownloader(db)
+	if err := d.Download(2019, 2022, false); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	var stale int
+	db.QueryRow(`SELECT COUNT(*) FROM test_proficiency WHERE year = 2021 AND source = 'naep_proficiency'`).Scan(&stale)
+	if stale != 0 {
+		t.Errorf("stale in-window row should be removed, found %d", stale)
+	}
+	var other int
+	db.QueryRow(`SELECT COUNT(*) FROM test_proficiency WHERE source = 'other_source'`).Scan(&other)
+	if other != 1 {
+		t.Errorf("other source rows should be untouched, found %d", other)
+	}
+}
+
+func TestACSAttainmentMarginOfError(t *testing.T) {
+	// The total is controlled, so only the bachelor's count's margin counts:
+	// 200,000 / 220,000,000 is a 90% margin of 0.0909 points.
+	pct, u, ok := acsAttainment([]interface{}{"United States", "50000000", "200000", float64(220000000), "-555555555"})
+	if !ok || math.Abs(pct-22.7273) > 1e-3 {
+		t.Fatalf("acsAttainment = %v, %v, want 22.73%%", pct, ok)
+	}
+	if se := u.StandardError.Float64; math.Abs(se-0.0909/1.645) > 1e-3 {
+		t.Errorf("standard error = %v, want %v", se, 0.0909/1.645)
+	}
+	if !u.CILow.Valid || u.CILow.Float64 >= pct || u.CIHigh.Float64 <= pct {
+		t.Errorf("interval %+v should bracket %v", u, pct)
+	}
+
+	// A margin the API does not have leaves the share without one.
+	if pct, u, ok := acsAttainment([]interface{}{"United States", "50000000", "-666666666", "220000000", "-555555555"}); !ok || pct == 0 || !u.IsZero() {
+		t.Errorf("unavailable margin: %v, %+v, %v", pct, u, ok)
+	}
+	if _, _, ok := acsAttainment([]interface{}{"United States", "0", "0", "0", "0"}); ok {
+		t.Error("a row without a total should be skipped")
+	}
+}
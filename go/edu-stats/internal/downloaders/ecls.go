@@ -35,6 +35,7 @@ func (e *ECLSDownloader) Download(startYear, endYear int, dryRun bool) error {
 	yearsRange := fmt.Sprintf("%d-%d", startYear, endYear)
 	database.UpdateSourceMetadata(e.db, sourceName, yearsRange, 0, "partial", 
 		"ECLS data requires restricted-use license application")
+	database.RefreshSourceCoverage(e.db, sourceName)
 	
 	fmt.Printf("  ℹ ECLS download: 0 rows (restricted data - manual access required)\n")
 	return nil
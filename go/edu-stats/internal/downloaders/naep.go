@@ -9,6 +9,9 @@ import (
 
 type NAEPDownloader struct {
 	db *sql.DB
+	// MaxRevisions fails the download instead of overwriting more than this
+	// many previously stored values. Zero means no limit.
+	MaxRevisions int
 }
 
 func NewNAEPDownloader(db *sql.DB) *NAEPDownloader {
@@ -47,34 +50,43 @@ func (n *NAEPDownloader) Download(startYear, endYear int, dryRun bool) error {
 		return nil
 	}
 
+	runID := database.NewRunID(sourceName)
+
 	fmt.Println("  Seeding NAEP reading proficiency data...")
 	fmt.Println("    ℹ NAEP LTT Age 13 (1971–1999) + Main NAEP Grade 8 (2002–2022)")
 
-	// Clear existing data for this source to avoid duplicates on re-run.
-	if _, err := n.db.Exec(`DELETE FROM test_proficiency WHERE source = ?`, sourceName); err != nil {
-		return fmt.Errorf("failed to clear existing proficiency data: %w", err)
-	}
-
-	totalRows := 0
+	var rows []database.Observation
 	for _, row := range naepReadingGrade8 {
 		if row.year < startYear || row.year > endYear {
 			continue
 		}
-		_, err := n.db.Exec(`
-			INSERT INTO test_proficiency (year, subject, grade, avg_score, source)
-			VALUES (?, ?, ?, ?, ?)
-		`, row.year, "reading", 8, row.score, sourceName)
-		if err != nil {
-			fmt.Printf("    Warning: failed to insert NAEP year %d: %v\n", row.year, err)
-			continue
-		}
-		totalRows++
+		rows = append(rows, database.Observation{
+			Year:   row.year,
+			Key:    []interface{}{"reading", 8, nil, nil, nil},
+			Values: []sql.NullFloat64{database.Float(row.score), {}},
+		})
+	}
+
+	report, err := database.ReplaceWindow(n.db, database.WindowLoad{
+		Table:        database.TestProficiency,
+		Source:       sourceName,
+		StartYear:    startYear,
+		EndYear:      endYear,
+		RunID:        runID,
+		MaxRevisions: n.MaxRevisions,
+		Rows:         rows,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load proficiency data: %w", err)
 	}
+	fmt.Printf("    ✓ %s\n", report)
 
+	totalRows, _ := database.CountSourceRows(n.db, database.TestProficiency, sourceName)
 	yearsRange := fmt.Sprintf("%d-%d", startYear, endYear)
 	database.UpdateSourceMetadata(n.db, sourceName, yearsRange, totalRows, "success",
-		fmt.Sprintf("NAEP LTT Age 13 (1971–1999) + Main Grade 8 (2002–2022): %d rows", totalRows))
+		fmt.Sprintf("NAEP LTT Age 13 (1971–1999) + Main Grade 8 (2002–2022): %s", report))
+	database.RefreshSourceCoverage(n.db, sourceName)
 
-	fmt.Printf("  ✓ NAEP data seeded: %d rows\n", totalRows)
+	fmt.Printf("  ✓ NAEP data seeded: %d rows in %d-%d\n", len(rows), startYear, endYear)
 	return nil
 }
@@ -9,6 +9,9 @@ import (
 
 type NCESDownloader struct {
 	db *sql.DB
+	// MaxRevisions fails the download instead of overwriting more than this
+	// many previously stored values. Zero means no limit.
+	MaxRevisions int
 }
 
 func NewNCESDownloader(db *sql.DB) *NCESDownloader {
@@ -75,60 +78,75 @@ func (n *NCESDownloader) Download(startYear, endYear int, dryRun bool) error {
 		return nil
 	}
 
+	runID := database.NewRunID(sourceName)
+
 	fmt.Println("  Seeding NCES graduation and enrollment data...")
 	fmt.Println("    ℹ AFGR series (1960–2010) + ACGR series (2011–2020)")
 
-	// Clear existing data to avoid duplicates on re-run.
-	if _, err := n.db.Exec(`DELETE FROM graduation_rates WHERE source = ?`, sourceName); err != nil {
-		return fmt.Errorf("failed to clear existing graduation data: %w", err)
-	}
-	if _, err := n.db.Exec(`DELETE FROM enrollment_rates WHERE source = ?`, sourceName); err != nil {
-		return fmt.Errorf("failed to clear existing enrollment data: %w", err)
-	}
-
-	gradRows := 0
+	var gradRows []database.Observation
 	for _, row := range historicalGraduation {
 		if row.year < startYear || row.year > endYear {
 			continue
 		}
-		_, err := n.db.Exec(`
-			INSERT INTO graduation_rates (year, rate, source)
-			VALUES (?, ?, ?)
-		`, row.year, row.rate, sourceName)
-		if err != nil {
-			fmt.Printf("    Warning: failed to insert graduation year %d: %v\n", row.year, err)
-			continue
-		}
-		gradRows++
+		gradRows = append(gradRows, database.Observation{
+			Year:   row.year,
+			Key:    []interface{}{nil, nil, nil},
+			Values: []sql.NullFloat64{database.Float(row.rate)},
+		})
 	}
-	fmt.Printf("    ✓ Inserted %d graduation rate rows (1960–2020)\n", gradRows)
-
-	enrollRows := 0
+	var enrollRows []database.Observation
 	for _, row := range historicalEnrollment {
 		if row.year < startYear || row.year > endYear {
 			continue
 		}
-		_, err := n.db.Exec(`
-			INSERT INTO enrollment_rates (year, age_group, enrollment_rate, source)
-			VALUES (?, ?, ?, ?)
-		`, row.year, "5_to_17", row.rate, sourceName)
-		if err != nil {
-			fmt.Printf("    Warning: failed to insert enrollment year %d: %v\n", row.year, err)
-			continue
-		}
-		enrollRows++
+		enrollRows = append(enrollRows, database.Observation{
+			Year:   row.year,
+			Key:    []interface{}{"5_to_17", nil, nil, nil},
+			Values: []sql.NullFloat64{database.Float(row.rate)},
+		})
+	}
+
+	// Both tables are one run, so a revision limit hit by either leaves both
+	// untouched.
+	reports, err := database.ReplaceWindows(n.db,
+		database.WindowLoad{
+			Table:        database.GraduationRates,
+			Source:       sourceName,
+			StartYear:    startYear,
+			EndYear:      endYear,
+			RunID:        runID,
+			MaxRevisions: n.MaxRevisions,
+			Rows:         gradRows,
+		},
+		database.WindowLoad{
+			Table:        database.EnrollmentRates,
+			Source:       sourceName,
+			StartYear:    startYear,
+			EndYear:      endYear,
+			RunID:        runID,
+			MaxRevisions: n.MaxRevisions,
+			Rows:         enrollRows,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to load graduation and enrollment data: %w", err)
 	}
-	fmt.Printf("    ✓ Inserted %d enrollment rate rows (1950–2020)\n", enrollRows)
+	gradReport, enrollReport := reports[0], reports[1]
+	fmt.Printf("    ✓ %s\n", gradReport)
+	fmt.Printf("    ✓ %s\n", enrollReport)
 
-	totalRows := gradRows + enrollRows
+	gradTotal, _ := database.CountSourceRows(n.db, database.GraduationRates, sourceName)
+	enrollTotal, _ := database.CountSourceRows(n.db, database.EnrollmentRates, sourceName)
+	totalRows := gradTotal + enrollTotal
 	yearsRange := fmt.Sprintf("%d-%d", startYear, endYear)
 	status := "success"
 	if totalRows == 0 {
 		status = "partial"
 	}
 	database.UpdateSourceMetadata(n.db, sourceName, yearsRange, totalRows, status,
-		fmt.Sprintf("NCES historical series: %d graduation + %d enrollment rows", gradRows, enrollRows))
+		fmt.Sprintf("NCES historical series: %d graduation + %d enrollment rows; %s; %s",
+			gradTotal, enrollTotal, gradReport, enrollReport))
+	database.RefreshSourceCoverage(n.db, sourceName)
 
-	fmt.Printf("  ✓ NCES data seeded: %d rows\n", totalRows)
+	fmt.Printf("  ✓ NCES data seeded: %d rows in %d-%d\n", len(gradRows)+len(enrollRows), startYear, endYear)
 	return nil
 }
@@ -17,6 +17,9 @@ import (
 // https://nces.ed.gov/programs/digest/d23/tables/dt23_603.10.asp
 type WorldBankDownloader struct {
 	db *sql.DB
+	// MaxRevisions fails the download instead of overwriting more than this
+	// many previously stored values. Zero means no limit.
+	MaxRevisions int
 }
 
 func NewWorldBankDownloader(db *sql.DB) *WorldBankDownloader {
@@ -56,35 +59,44 @@ func (w *WorldBankDownloader) Download(startYear, endYear int, dryRun bool) erro
 		return nil
 	}
 
+	runID := database.NewRunID(sourceName)
+
 	fmt.Println("  Seeding US literacy data (NCES Digest historical series)...")
 	fmt.Println("    ℹ World Bank does not collect literacy data for USA")
 	fmt.Println("    ℹ Using NCES Digest Table 603.10 historical series instead")
 
-	// Clear existing data for this source to avoid duplicates on re-run.
-	if _, err := w.db.Exec(`DELETE FROM literacy_rates WHERE source = ?`, sourceName); err != nil {
-		return fmt.Errorf("failed to clear existing literacy data: %w", err)
-	}
-
-	totalRows := 0
+	var rows []database.Observation
 	for _, h := range historicalLiteracy {
 		if h.year < startYear || h.year > endYear {
 			continue
 		}
-		_, err := w.db.Exec(`
-			INSERT INTO literacy_rates (year, age_group, rate, source)
-			VALUES (?, ?, ?, ?)
-		`, h.year, h.ageGroup, h.rate, sourceName)
-		if err != nil {
-			fmt.Printf("    Warning: failed to insert literacy year %d: %v\n", h.year, err)
-			continue
-		}
-		totalRows++
+		rows = append(rows, database.Observation{
+			Year:   h.year,
+			Key:    []interface{}{h.ageGroup, nil},
+			Values: []sql.NullFloat64{database.Float(h.rate)},
+		})
+	}
+
+	report, err := database.ReplaceWindow(w.db, database.WindowLoad{
+		Table:        database.LiteracyRates,
+		Source:       sourceName,
+		StartYear:    startYear,
+		EndYear:      endYear,
+		RunID:        runID,
+		MaxRevisions: w.MaxRevisions,
+		Rows:         rows,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load literacy data: %w", err)
 	}
+	fmt.Printf("    ✓ %s\n", report)
 
+	totalRows, _ := database.CountSourceRows(w.db, database.LiteracyRates, sourceName)
 	yearsRange := fmt.Sprintf("%d-%d", startYear, endYear)
 	database.UpdateSourceMetadata(w.db, sourceName, yearsRange, totalRows, "success",
-		fmt.Sprintf("NCES historical US literacy series: %d rows", totalRows))
+		fmt.Sprintf("NCES historical US literacy series: %s", report))
+	database.RefreshSourceCoverage(w.db, sourceName)
 
-	fmt.Printf("  ✓ Literacy data seeded: %d rows\n", totalRows)
+	fmt.Printf("  ✓ Literacy data seeded: %d rows in %d-%d\n", len(rows), startYear, endYear)
 	return nil
 }
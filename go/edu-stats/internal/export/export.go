@@ -0,0 +1,230 @@
+// Package export writes tabular observation data as an aligned table, CSV,
+// JSON or Parquet.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// ColumnType is the type of every value in a column. Nil values are allowed
+// in any column and written as empty, null or a Parquet null.
+type ColumnType int
+
+const (
+	String ColumnType = iota
+	Int
+	Float
+)
+
+// Column is one named, typed column of a Table.
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Table is a set of rows in column order. Cells hold string, int64, float64
+// or nil to match their column's type.
+type Table struct {
+	Columns []Column
+	Rows    [][]interface{}
+}
+
+// Formats lists the formats accepted by Write.
+var Formats = []string{"table", "csv", "json", "parquet"}
+
+// Write writes t in the named format.
+func Write(w io.Writer, format string, t Table) error {
+	switch format {
+	case "table":
+		return WriteTable(w, t)
+	case "csv":
+		return WriteCSV(w, t)
+	case "json":
+		return WriteJSON(w, t)
+	case "parquet":
+		return WriteParquet(w, t)
+	default:
+		return fmt.Errorf("unknown format %q, use %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Text formats a cell for the table and CSV outputs.
+func Text(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// WriteTable writes t as space-aligned columns with an upper-case header.
+func WriteTable(w io.Writer, t Table) error {
+	if len(t.Rows) == 0 {
+		_, err := fmt.Fprintln(w, "No observations match.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = strings.ToUpper(c.Name)
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range t.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = Text(v)
+			if v == nil {
+				cells[i] = "-"
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// WriteCSV writes t with a header row of column names.
+func WriteCSV(w io.Writer, t Table) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = c.Name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range t.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = Text(v)
+		}
+		if err := cw.Write(cells); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSONLines writes one JSON object per row, keyed by column name in
+// column order.
+func WriteJSONLines(w io.Writer, t Table) error {
+	for _, row := range t.Rows {
+		line, err := jsonObject(t.Columns, row)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonObject encodes row as a JSON object, keeping column order and writing
+// nil cells as null.
+func jsonObject(cols []Column, row []interface{}) (string, error) {
+	var b strings.Builder
+	b.WriteString("{")
+	for i, c := range cols {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		name, _ := json.Marshal(c.Name)
+		value, err := json.Marshal(row[i])
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", c.Name, err)
+		}
+		b.Write(name)
+		b.WriteString(": ")
+		b.Write(value)
+	}
+	b.WriteString("}")
+	return b.String(), nil
+}
+
+// WriteJSON writes t as an array of objects keyed by column name, keeping
+// column order and writing nil cells as null.
+func WriteJSON(w io.Writer, t Table) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for r, row := range t.Rows {
+		obj, err := jsonObject(t.Columns, row)
+		if err != nil {
+			return err
+		}
+		sep := "\n  "
+		if r > 0 {
+			sep = ",\n  "
+		}
+		if _, err := io.WriteString(w, sep+obj); err != nil {
+			return err
+		}
+	}
+	if len(t.Rows) > 0 {
+		_, err := io.WriteString(w, "\n]\n")
+		return err
+	}
+	_, err := io.WriteString(w, "]\n")
+	return err
+}
+
+// Object is one row that marshals as a JSON object keyed by column name in
+// column order, like WriteJSON.
+type Object struct {
+	Columns []Column
+	Values  []interface{}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o Object) MarshalJSON() ([]byte, error) {
+	s, err := jsonObject(o.Columns, o.Values)
+	return []byte(s), err
+}
+
+// Objects returns t's rows as Objects.
+func (t Table) Objects() []Object {
+	objects := make([]Object, len(t.Rows))
+	for i, row := range t.Rows {
+		objects[i] = Object{Columns: t.Columns, Values: row}
+	}
+	return objects
+}
+
+// Without returns t without the named columns.
+func (t Table) Without(names ...string) Table {
+	drop := make(map[string]bool, len(names))
+	for _, n := range names {
+		drop[n] = true
+	}
+	var keep []int
+	out := Table{}
+	for i, c := range t.Columns {
+		if !drop[c.Name] {
+			keep = append(keep, i)
+			out.Columns = append(out.Columns, c)
+		}
+	}
+	out.Rows = make([][]interface{}, len(t.Rows))
+	for r, row := range t.Rows {
+		cells := make([]interface{}, len(keep))
+		for i, k := range keep {
+			cells[i] = row[k]
+		}
+		out.Rows[r] = cells
+	}
+	return out
+}
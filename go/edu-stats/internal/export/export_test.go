@@ -0,0 +1,413 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var sample = Table{
+	Columns: []Column{
+		{Name: "metric", Type: String},
+		{Name: "year", Type: Int},
+		{Name: "state", Type: String},
+		{Name: "value", Type: Float},
+	},
+	Rows: [][]interface{}{
+		{"graduation", int64(2019), nil, 85.8},
+		{"graduation", int64(2020), "CA", 87.5},
+	},
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, sample); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	want := "metric,year,state,value\ngraduation,2019,,85.8\ngraduation,2020,CA,87.5\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, sample); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rows); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if len(rows) != 2 || rows[0]["state"] != nil || rows[1]["year"] != float64(2020) {
+		t.Errorf("unexpected rows %v", rows)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(strings.Split(buf.String(), "\n")[1]), `{"metric"`) {
+		t.Errorf("columns should keep table order: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, Table{Columns: sample.Columns}); err != nil || buf.String() != "[]\n" {
+		t.Errorf("empty table = %q, %v", buf.String(), err)
+	}
+}
+
+func TestObjectsWithout(t *testing.T) {
+	data, err := json.Marshal(sample.Without("metric").Objects())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `[{"year":2019,"state":null,"value":85.8},{"year":2020,"state":"CA","value":87.5}]`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+	if len(sample.Columns) != 4 {
+		t.Error("Without should not modify the table")
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, sample); err != nil {
+		t.Fatalf("WriteParquet: %v", err)
+	}
+	got := readParquet(t, buf.Bytes())
+	if !reflect.DeepEqual(got, sample) {
+		t.Errorf("round trip:\ngot  %+v\nwant %+v", got, sample)
+	}
+
+	// Fifteen or more columns use the long form of Thrift list headers, and
+	// long runs of levels need multi-byte varints.
+	wide := Table{}
+	for i := 0; i < 16; i++ {
+		wide.Columns = append(wide.Columns, Column{Name: fmt.Sprintf("c%02d", i), Type: ColumnType(i % 3)})
+	}
+	for r := 0; r < 300; r++ {
+		row := make([]interface{}, len(wide.Columns))
+		for i, col := range wide.Columns {
+			if r%7 == i%7 {
+				continue
+			}
+			switch col.Type {
+			case Int:
+				row[i] = int64(r * i)
+			case Float:
+				row[i] = float64(r) / 4
+			default:
+				row[i] = fmt.Sprintf("r%d", r)
+			}
+		}
+		wide.Rows = append(wide.Rows, row)
+	}
+	buf.Reset()
+	if err := WriteParquet(&buf, wide); err != nil {
+		t.Fatalf("WriteParquet wide: %v", err)
+	}
+	if got := readParquet(t, buf.Bytes()); !reflect.DeepEqual(got, wide) {
+		t.Error("wide table did not round-trip")
+	}
+
+	bad := Table{Columns: []Column{{Name: "year", Type: Int}}, Rows: [][]interface{}{{"2019"}}}
+	if err := WriteParquet(&bytes.Buffer{}, bad); err == nil {
+		t.Error("expected an error for a string in an Int column")
+	}
+}
+
+// readParquet decodes a file written by WriteParquet following
+// parquet.thrift and the Parquet encodings spec rather than the writer's
+// helpers, checking the magic bytes, footer metadata and every page.
+func readParquet(t *testing.T, data []byte) Table {
+	t.Helper()
+	if len(data) < 12 || string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		t.Fatal("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if footerLen <= 0 || footerLen > len(data)-12 {
+		t.Fatalf("footer length %d out of range", footerLen)
+	}
+	footerStart := len(data) - 8 - footerLen
+	r := &compactReader{data: data[footerStart : len(data)-8]}
+	meta := r.readStruct()
+	if r.err != nil || r.pos != footerLen {
+		t.Fatalf("footer: %v, read %d of %d bytes", r.err, r.pos, footerLen)
+	}
+
+	if meta[1] != int64(1) {
+		t.Errorf("version = %v, want 1", meta[1])
+	}
+	schema := meta[2].([]interface{})
+	root := schema[0].(map[int16]interface{})
+	if int(root[5].(int64)) != len(schema)-1 {
+		t.Fatalf("root has %v children, schema has %d columns", root[5], len(schema)-1)
+	}
+	numRows := int(meta[3].(int64))
+	groups := meta[4].([]interface{})
+	if len(groups) != 1 {
+		t.Fatalf("%d row groups, want 1", len(groups))
+	}
+	group := groups[0].(map[int16]interface{})
+	chunks := group[1].([]interface{})
+	if int(group[3].(int64)) != numRows || len(chunks) != len(schema)-1 {
+		t.Fatalf("row group has %v rows and %d chunks", group[3], len(chunks))
+	}
+
+	var table Table
+	physical := map[int64]ColumnType{2: Int, 5: Float, 6: String}
+	var total int64
+	for i, el := range schema[1:] {
+		el := el.(map[int16]interface{})
+		typ, ok := physical[el[1].(int64)]
+		if !ok || el[3] != int64(1) {
+			t.Fatalf("column %v: physical type %v, repetition %v", el[4], el[1], el[3])
+		}
+		if (typ == String) != (el[6] == int64(0)) {
+			t.Errorf("column %v: converted type %v", el[4], el[6])
+		}
+		name := string(el[4].([]byte))
+		table.Columns = append(table.Columns, Column{Name: name, Type: typ})
+
+		cm := chunks[i].(map[int16]interface{})[3].(map[int16]interface{})
+		path := cm[3].([]interface{})
+		if cm[1] != el[1] || len(path) != 1 || string(path[0].([]byte)) != name || cm[4] != int64(0) {
+			t.Errorf("column %s: chunk metadata %v does not match the schema", name, cm)
+		}
+		if int(cm[5].(int64)) != numRows {
+			t.Errorf("column %s: %v values, want %d", name, cm[5], numRows)
+		}
+		total += cm[6].(int64)
+
+		offset := int(cm[9].(int64))
+		pr := &compactReader{data: data[offset:footerStart]}
+		header := pr.readStruct()
+		dph := header[5].(map[int16]interface{})
+		if pr.err != nil || header[1] != int64(0) || header[2] != header[3] || dph[2] != int64(0) || dph[3] != int64(3) {
+			t.Fatalf("column %s: page header %v (%v)", name, header, pr.err)
+		}
+		if int64(pr.pos)+header[3].(int64) != cm[6].(int64) {
+			t.Errorf("column %s: page is %v bytes, chunk is %v", name, header[3], cm[6])
+		}
+		page := data[offset+pr.pos : offset+pr.pos+int(header[3].(int64))]
+		column := decodePage(t, page, typ, numRows)
+		for r, v := range column {
+			if i == 0 {
+				table.Rows = append(table.Rows, make([]interface{}, len(schema)-1))
+			}
+			table.Rows[r][i] = v
+		}
+	}
+	if group[2] != total {
+		t.Errorf("row group total_byte_size = %v, want %d", group[2], total)
+	}
+	return table
+}
+
+// decodePage reads a data page of definition levels (RLE/bit-packed hybrid,
+// bit width 1, with a 4-byte length) followed by PLAIN values.
+func decodePage(t *testing.T, page []byte, typ ColumnType, n int) []interface{} {
+	t.Helper()
+	size := int(binary.LittleEndian.Uint32(page))
+	levels, rest := page[4:4+size], page[4+size:]
+	var defined []bool
+	for len(levels) > 0 {
+		header, k := binary.Uvarint(levels)
+		levels = levels[k:]
+		if header&1 == 0 {
+			for j := uint64(0); j < header>>1; j++ {
+				defined = append(defined, levels[0] == 1)
+			}
+			levels = levels[1:]
+			continue
+		}
+		for j := 0; j < int(header>>1)*8; j++ {
+			defined = append(defined, levels[j/8]>>(j%8)&1 == 1)
+		}
+		levels = levels[header>>1:]
+	}
+	if len(defined) < n {
+		t.Fatalf("%d definition levels, want %d", len(defined), n)
+	}
+
+	values := make([]interface{}, n)
+	for r := 0; r < n; r++ {
+		if !defined[r] {
+			continue
+		}
+		switch typ {
+		case Int:
+			values[r] = int64(binary.LittleEndian.Uint64(rest))
+			rest = rest[8:]
+		case Float:
+			values[r] = math.Float64frombits(binary.LittleEndian.Uint64(rest))
+			rest = rest[8:]
+		default:
+			l := int(binary.LittleEndian.Uint32(rest))
+			values[r] = string(rest[4 : 4+l])
+			rest = rest[4+l:]
+		}
+	}
+	if len(rest) != 0 {
+		t.Errorf("%d bytes left after the values", len(rest))
+	}
+	return values
+}
+
+// compactReader decodes the Thrift compact protocol into field-ID maps,
+// lists and scalars (bool, int64, float64, []byte).
+type compactReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *compactReader) byte() byte {
+	if r.pos >= len(r.data) {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *compactReader) uvarint() uint64 {
+	var v uint64
+	for shift := 0; shift < 64; shift += 7 {
+		b := r.byte()
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			break
+		}
+	}
+	return v
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) readStruct() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var id int16
+	for r.err == nil {
+		b := r.byte()
+		if b == 0 {
+			break
+		}
+		if delta := int16(b >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.readValue(b & 0x0f)
+	}
+	return fields
+}
+
+func (r *compactReader) readValue(typ byte) interface{} {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		if r.pos+8 > len(r.data) {
+			r.err = io.ErrUnexpectedEOF
+			return nil
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(r.data[r.pos:]))
+		r.pos += 8
+		return f
+	case 8:
+		n := int(r.uvarint())
+		if r.pos+n > len(r.data) {
+			r.err = io.ErrUnexpectedEOF
+			return nil
+		}
+		b := r.data[r.pos : r.pos+n]
+		r.pos += n
+		return b
+	case 9, 10:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			elem := header & 0x0f
+			if elem == 1 || elem == 2 {
+				list[i] = r.byte() == 1
+				continue
+			}
+			list[i] = r.readValue(elem)
+		}
+		return list
+	case 12:
+		return r.readStruct()
+	}
+	r.err = fmt.Errorf("unsupported compact type %d at byte %d", typ, r.pos)
+	return nil
+}
+
+func TestRLELevels(t *testing.T) {
+	got := rleLevels([]byte{1, 1, 1, 0, 1})
+	want := []byte{3 << 1, 1, 1 << 1, 0, 1 << 1, 1}
+	if !bytes.Equal(got, want) {
+		t.Errorf("rleLevels = %v, want %v", got, want)
+	}
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSONLines(&buf, sample); err != nil {
+		t.Fatalf("WriteJSONLines: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 || lines[0] != `{"metric": "graduation", "year": 2019, "state": null, "value": 85.8}` {
+		t.Errorf("unexpected lines %q", lines)
+	}
+}
+
+func TestWriteSDMXCSV(t *testing.T) {
+	table := Table{
+		Columns: []Column{
+			{Name: "year", Type: Int}, {Name: "subject", Type: String}, {Name: "state", Type: String},
+			{Name: "avg_score", Type: Float}, {Name: "percentage_proficient", Type: Float}, {Name: "source", Type: String},
+		},
+		Rows: [][]interface{}{
+			{int64(2022), "reading", nil, 260.0, 31.0, "naep"},
+			{int64(2022), "mathematics", "CA", 274.0, nil, "naep"},
+		},
+	}
+	layout := SDMXLayout{
+		Dataflow:   "EDUSTATS:TEST_PROFICIENCY(1.0)",
+		Dimensions: []string{"subject", "state"},
+		TimePeriod: "year",
+		Measures:   []string{"avg_score", "percentage_proficient"},
+		Units:      map[string]string{"avg_score": "scale_score", "percentage_proficient": "percent"},
+		Attributes: []string{"source"},
+	}
+	var buf bytes.Buffer
+	if err := WriteSDMXCSV(&buf, table, layout); err != nil {
+		t.Fatalf("WriteSDMXCSV: %v", err)
+	}
+	want := `DATAFLOW,SUBJECT,STATE,MEASURE,TIME_PERIOD,OBS_VALUE,UNIT_MEASURE,SOURCE
+EDUSTATS:TEST_PROFICIENCY(1.0),reading,_T,AVG_SCORE,2022,260,SCALE_SCORE,naep
+EDUSTATS:TEST_PROFICIENCY(1.0),reading,_T,PERCENTAGE_PROFICIENT,2022,31,PERCENT,naep
+EDUSTATS:TEST_PROFICIENCY(1.0),mathematics,CA,AVG_SCORE,2022,274,SCALE_SCORE,naep
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
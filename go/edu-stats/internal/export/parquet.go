@@ -0,0 +1,292 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WriteParquet writes t as a Parquet file with a single row group. Every
+// column is OPTIONAL and stored uncompressed in one PLAIN-encoded data page:
+// strings as UTF8 BYTE_ARRAY, ints as INT64 and floats as DOUBLE. This is
+// the simplest layout every Parquet reader understands, and the files this
+// CLI produces are small enough that compression is not worth a dependency.
+func WriteParquet(w io.Writer, t Table) error {
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+
+	chunks := make([]parquetChunk, len(t.Columns))
+	for i, col := range t.Columns {
+		page, err := parquetPage(t, i)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		header := parquetPageHeader(len(t.Rows), len(page))
+		chunks[i] = parquetChunk{
+			offset: int64(file.Len()),
+			size:   int64(len(header) + len(page)),
+		}
+		file.Write(header)
+		file.Write(page)
+	}
+
+	footer := parquetFooter(t, chunks)
+	file.Write(footer)
+	binary.Write(&file, binary.LittleEndian, uint32(len(footer)))
+	file.WriteString("PAR1")
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+type parquetChunk struct {
+	offset int64
+	size   int64
+}
+
+// Parquet physical types, repetition types and enum values from
+// parquet.thrift.
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetOptional = 1
+	parquetUTF8     = 0
+	parquetPlain    = 0
+	parquetRLE      = 3
+	parquetDataPage = 0
+)
+
+func parquetType(t ColumnType) int32 {
+	switch t {
+	case Int:
+		return parquetInt64
+	case Float:
+		return parquetDouble
+	default:
+		return parquetByteArray
+	}
+}
+
+// parquetPage encodes column i as definition levels followed by the PLAIN
+// values of its non-null cells.
+func parquetPage(t Table, i int) ([]byte, error) {
+	levels := make([]byte, len(t.Rows))
+	var values bytes.Buffer
+	for r, row := range t.Rows {
+		v := row[i]
+		if v == nil {
+			continue
+		}
+		levels[r] = 1
+		switch t.Columns[i].Type {
+		case Int:
+			n, ok := v.(int64)
+			if !ok {
+				return nil, fmt.Errorf("row %d: want int64, got %T", r, v)
+			}
+			binary.Write(&values, binary.LittleEndian, n)
+		case Float:
+			f, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("row %d: want float64, got %T", r, v)
+			}
+			binary.Write(&values, binary.LittleEndian, math.Float64bits(f))
+		default:
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("row %d: want string, got %T", r, v)
+			}
+			binary.Write(&values, binary.LittleEndian, uint32(len(s)))
+			values.WriteString(s)
+		}
+	}
+
+	rle := rleLevels(levels)
+	var page bytes.Buffer
+	binary.Write(&page, binary.LittleEndian, uint32(len(rle)))
+	page.Write(rle)
+	page.Write(values.Bytes())
+	return page.Bytes(), nil
+}
+
+// rleLevels encodes bit-width-1 levels with the RLE half of Parquet's
+// RLE/bit-packing hybrid: each run is a varint (length << 1) and one byte.
+func rleLevels(levels []byte) []byte {
+	var out []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		out = append(out, levels[i])
+		i = j
+	}
+	return out
+}
+
+func parquetPageHeader(numValues, size int) []byte {
+	var e thriftWriter
+	e.i32(1, parquetDataPage)
+	e.i32(2, int32(size))
+	e.i32(3, int32(size))
+	e.beginStruct(5)
+	e.i32(1, int32(numValues))
+	e.i32(2, parquetPlain)
+	e.i32(3, parquetRLE)
+	e.i32(4, parquetRLE)
+	e.endStruct()
+	e.stop()
+	return e.buf.Bytes()
+}
+
+func parquetFooter(t Table, chunks []parquetChunk) []byte {
+	var e thriftWriter
+	e.i32(1, 1)
+
+	e.beginList(2, thriftStruct, len(t.Columns)+1)
+	e.beginElement()
+	e.binary(4, "schema")
+	e.i32(5, int32(len(t.Columns)))
+	e.endStruct()
+	for _, col := range t.Columns {
+		e.beginElement()
+		e.i32(1, parquetType(col.Type))
+		e.i32(3, parquetOptional)
+		e.binary(4, col.Name)
+		if col.Type == String {
+			e.i32(6, parquetUTF8)
+		}
+		e.endStruct()
+	}
+
+	e.i64(3, int64(len(t.Rows)))
+
+	var total int64
+	for _, c := range chunks {
+		total += c.size
+	}
+	e.beginList(4, thriftStruct, 1)
+	e.beginElement()
+	e.beginList(1, thriftStruct, len(chunks))
+	for i, c := range chunks {
+		col := t.Columns[i]
+		e.beginElement()
+		e.i64(2, c.offset)
+		e.beginStruct(3)
+		e.i32(1, parquetType(col.Type))
+		e.beginList(2, thriftI32, 2)
+		e.listI32(parquetPlain)
+		e.listI32(parquetRLE)
+		e.beginList(3, thriftBinary, 1)
+		e.listBinary(col.Name)
+		e.i32(4, 0) // UNCOMPRESSED
+		e.i64(5, int64(len(t.Rows)))
+		e.i64(6, c.size)
+		e.i64(7, c.size)
+		e.i64(9, c.offset)
+		e.endStruct()
+		e.endStruct()
+	}
+	e.i64(2, total)
+	e.i64(3, int64(len(t.Rows)))
+	e.endStruct()
+
+	e.binary(6, "edu-stats")
+	e.stop()
+	return e.buf.Bytes()
+}
+
+// Thrift compact protocol type IDs.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter emits the subset of the Thrift compact protocol the Parquet
+// footer needs. Field IDs are delta-encoded against the enclosing struct.
+type thriftWriter struct {
+	buf   bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+func (e *thriftWriter) field(id int16, typ byte) {
+	if delta := id - e.last; delta > 0 && delta <= 15 {
+		e.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		e.buf.WriteByte(typ)
+		e.varint(int64(id))
+	}
+	e.last = id
+}
+
+func (e *thriftWriter) varint(v int64) {
+	e.buf.Write(binary.AppendVarint(nil, v))
+}
+
+func (e *thriftWriter) uvarint(v uint64) {
+	e.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (e *thriftWriter) i32(id int16, v int32) {
+	e.field(id, thriftI32)
+	e.varint(int64(v))
+}
+
+func (e *thriftWriter) i64(id int16, v int64) {
+	e.field(id, thriftI64)
+	e.varint(v)
+}
+
+func (e *thriftWriter) binary(id int16, s string) {
+	e.field(id, thriftBinary)
+	e.listBinary(s)
+}
+
+func (e *thriftWriter) beginStruct(id int16) {
+	e.field(id, thriftStruct)
+	e.beginElement()
+}
+
+// beginElement opens a struct that is a list element rather than a field.
+func (e *thriftWriter) beginElement() {
+	e.stack = append(e.stack, e.last)
+	e.last = 0
+}
+
+func (e *thriftWriter) endStruct() {
+	e.stop()
+	e.last = e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+}
+
+func (e *thriftWriter) beginList(id int16, elem byte, size int) {
+	e.field(id, thriftList)
+	if size < 15 {
+		e.buf.WriteByte(byte(size)<<4 | elem)
+		return
+	}
+	e.buf.WriteByte(0xf0 | elem)
+	e.uvarint(uint64(size))
+}
+
+func (e *thriftWriter) listI32(v int32) {
+	e.varint(int64(v))
+}
+
+func (e *thriftWriter) listBinary(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf.WriteString(s)
+}
+
+func (e *thriftWriter) stop() {
+	e.buf.WriteByte(0)
+}
@@ -0,0 +1,110 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SDMXLayout maps the columns of a Table onto SDMX-CSV (version 1).
+type SDMXLayout struct {
+	// Dataflow identifies the dataset, e.g. "EDUSTATS:GRADUATION_RATES(1.0)".
+	Dataflow string
+	// Dimensions are the column names that identify an observation, apart
+	// from the time period.
+	Dimensions []string
+	TimePeriod string
+	// Measures are the value columns. Each non-null measure becomes its own
+	// observation; with more than one, a MEASURE dimension says which.
+	Measures []string
+	// Units gives the UNIT_MEASURE attribute of each measure.
+	Units map[string]string
+	// Attributes are copied to every observation of their row.
+	Attributes []string
+}
+
+// sdmxTotal is written for empty dimension values, which in these tables
+// mean the figure covers every member of the dimension.
+const sdmxTotal = "_T"
+
+// WriteSDMXCSV writes t as SDMX-CSV with upper-case column IDs: DATAFLOW,
+// the dimensions, TIME_PERIOD, OBS_VALUE, UNIT_MEASURE and the attributes.
+func WriteSDMXCSV(w io.Writer, t Table, l SDMXLayout) error {
+	index := make(map[string]int, len(t.Columns))
+	for i, c := range t.Columns {
+		index[c.Name] = i
+	}
+	lookup := func(names []string) ([]int, error) {
+		out := make([]int, len(names))
+		for i, n := range names {
+			j, ok := index[n]
+			if !ok {
+				return nil, fmt.Errorf("no column %q", n)
+			}
+			out[i] = j
+		}
+		return out, nil
+	}
+	dims, err := lookup(l.Dimensions)
+	if err != nil {
+		return err
+	}
+	measures, err := lookup(l.Measures)
+	if err != nil {
+		return err
+	}
+	attrs, err := lookup(l.Attributes)
+	if err != nil {
+		return err
+	}
+	period, err := lookup([]string{l.TimePeriod})
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	header := []string{"DATAFLOW"}
+	for _, d := range l.Dimensions {
+		header = append(header, strings.ToUpper(d))
+	}
+	multi := len(l.Measures) > 1
+	if multi {
+		header = append(header, "MEASURE")
+	}
+	header = append(header, "TIME_PERIOD", "OBS_VALUE", "UNIT_MEASURE")
+	for _, a := range l.Attributes {
+		header = append(header, strings.ToUpper(a))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range t.Rows {
+		for m, mi := range measures {
+			if row[mi] == nil {
+				continue
+			}
+			record := []string{l.Dataflow}
+			for _, di := range dims {
+				v := Text(row[di])
+				if v == "" {
+					v = sdmxTotal
+				}
+				record = append(record, v)
+			}
+			if multi {
+				record = append(record, strings.ToUpper(l.Measures[m]))
+			}
+			record = append(record, Text(row[period[0]]), Text(row[mi]), strings.ToUpper(l.Units[l.Measures[m]]))
+			for _, ai := range attrs {
+				record = append(record, Text(row[ai]))
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,455 @@
+package generators
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/cohorts"
+)
+
+// ChartDir is the directory, inside the output directory, charts are
+// written to.
+const ChartDir = "charts"
+
+// Chart formats generate-assets can write.
+const (
+	ChartSVG = "svg"
+	ChartPNG = "png"
+)
+
+// ParseChartFormats parses a --charts value such as "svg" or "svg,png".
+func ParseChartFormats(s string) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(s, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		switch f {
+		case "":
+		case ChartSVG, ChartPNG:
+			seen[f] = true
+		default:
+			return nil, fmt.Errorf("unknown chart format %q, use svg, png or svg,png", f)
+		}
+	}
+	var formats []string
+	for _, f := range []string{ChartSVG, ChartPNG} {
+		if seen[f] {
+			formats = append(formats, f)
+		}
+	}
+	return formats, nil
+}
+
+// Chart size and palette. 1200x630 is the Open Graph card size, and reads
+// well scaled down in reports and READMEs.
+const (
+	chartWidth  = 1200
+	chartHeight = 630
+	chartMargin = 56.0
+
+	colorText    = "#212529"
+	colorMuted   = "#6c757d"
+	colorGrid    = "#dee2e6"
+	colorLine    = "#0d6efd"
+	colorCompare = "#adb5bd"
+	colorBreak   = "#dc3545"
+	colorPaper   = "#ffffff"
+)
+
+// frame is the plot area of a chart, below its title and above its
+// footnotes.
+type frame struct {
+	left, top, right, bottom float64
+}
+
+// newChart starts a drawing with a title, subtitle and footnotes, and
+// returns the area left for the plot.
+func newChart(title, subtitle string, footnotes []string) (*Drawing, frame) {
+	d := &Drawing{Width: chartWidth, Height: chartHeight, Title: title}
+	d.rect(0, 0, chartWidth, chartHeight, colorPaper)
+	d.text(chartMargin, 64, title, 34, "start", colorText, true)
+	if subtitle != "" {
+		d.text(chartMargin, 100, subtitle, 20, "start", colorMuted, false)
+	}
+
+	var lines []string
+	for _, f := range footnotes {
+		lines = append(lines, wrapText(f, 15, chartWidth-2*chartMargin)...)
+	}
+	for i, line := range lines {
+		d.text(chartMargin, chartHeight-24-float64(len(lines)-1-i)*20, line, 15, "start", colorMuted, false)
+	}
+	return d, frame{
+		left:   chartMargin + 64,
+		top:    140,
+		right:  chartWidth - chartMargin,
+		bottom: chartHeight - 88 - float64(len(lines))*20,
+	}
+}
+
+// niceTicks returns about n round tick values covering [lo, hi].
+func niceTicks(lo, hi float64, n int) []float64 {
+	if hi <= lo {
+		lo, hi = lo-1, hi+1
+	}
+	raw := (hi - lo) / float64(n)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := mag * 10
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if raw <= m*mag {
+			step = m * mag
+			break
+		}
+	}
+	var ticks []float64
+	for v := math.Floor(lo/step) * step; v <= hi+step*1e-9; v += step {
+		ticks = append(ticks, math.Round(v/step)*step)
+	}
+	if ticks[len(ticks)-1] < hi {
+		ticks = append(ticks, ticks[len(ticks)-1]+step)
+	}
+	return ticks
+}
+
+// formatValue prints v with as many decimals as step needs, plus suffix.
+func formatValue(v, step float64, suffix string) string {
+	decimals := 0
+	for scaled := step; decimals < 3 && math.Abs(scaled-math.Round(scaled)) > 1e-9; scaled *= 10 {
+		decimals++
+	}
+	return strconv.FormatFloat(v, 'f', decimals, 64) + suffix
+}
+
+// linear maps [d0, d1] onto [r0, r1].
+func linear(d0, d1, r0, r1 float64) func(float64) float64 {
+	if d1 == d0 {
+		return func(float64) float64 { return (r0 + r1) / 2 }
+	}
+	return func(v float64) float64 { return r0 + (v-d0)/(d1-d0)*(r1-r0) }
+}
+
+// yAxis draws horizontal grid lines and labels for ticks.
+func (d *Drawing) yAxis(f frame, ticks []float64, y func(float64) float64, suffix string) {
+	step := ticks[1] - ticks[0]
+	for _, t := range ticks {
+		d.line(colorGrid, 1, nil, point{f.left, y(t)}, point{f.right, y(t)})
+		d.text(f.left-12, y(t)+5, formatValue(t, step, suffix), 15, "end", colorMuted, false)
+	}
+}
+
+// sourceNote is the footnote citing m.
+func sourceNote(m catalog.Metric) string {
+	if m.Citation != "" {
+		return "Source: " + m.Citation
+	}
+	return "Source: " + m.Source
+}
+
+// StatChart draws a stat's headline series as a line over the years.
+// Imputed points are hollow, points with a confidence interval have a bar
+// spanning it, and each of the metric's series breaks is a dashed line the
+// series is not joined across.
+func StatChart(data StatData, m catalog.Metric) *Drawing {
+	points := append([]DataPoint(nil), data.Years...)
+	sort.Slice(points, func(i, j int) bool { return points[i].Year < points[j].Year })
+
+	var breaks []catalog.Break
+	imputed, intervals := false, false
+	if len(points) > 0 {
+		for _, b := range m.Breaks {
+			if b.Year > points[0].Year && b.Year <= points[len(points)-1].Year {
+				breaks = append(breaks, b)
+			}
+		}
+		for _, p := range points {
+			imputed = imputed || p.Imputed
+			intervals = intervals || (p.CILow != nil && p.CIHigh != nil)
+		}
+	}
+
+	var notes []string
+	if len(breaks) > 0 {
+		notes = append(notes, "Dashed lines mark series breaks: values either side are not directly comparable.")
+	}
+	if imputed {
+		notes = append(notes, fmt.Sprintf("Hollow points are estimated (%s) between measured years.", data.Resampled))
+	}
+	if intervals {
+		notes = append(notes, "Grey bars span the published 95% confidence interval.")
+	}
+	notes = append(notes, sourceNote(m))
+
+	subtitle := data.Description
+	if len(points) > 0 {
+		subtitle += fmt.Sprintf(", %d-%d", points[0].Year, points[len(points)-1].Year)
+	}
+	d, f := newChart(data.Name, subtitle, notes)
+	if len(points) == 0 {
+		d.text((f.left+f.right)/2, (f.top+f.bottom)/2, "No data available", 24, "middle", colorMuted, false)
+		return d
+	}
+
+	lo, hi := points[0].Value, points[0].Value
+	for _, p := range points {
+		lo, hi = math.Min(lo, p.Value), math.Max(hi, p.Value)
+		if p.CILow != nil && p.CIHigh != nil {
+			lo, hi = math.Min(lo, *p.CILow), math.Max(hi, *p.CIHigh)
+		}
+	}
+	ticks := niceTicks(lo, hi, 5)
+	y := linear(ticks[0], ticks[len(ticks)-1], f.bottom, f.top)
+	first, last := points[0].Year, points[len(points)-1].Year
+	x := linear(float64(first), float64(last), f.left+12, f.right-12)
+	d.yAxis(f, ticks, y, m.UnitSuffix)
+
+	for _, year := range niceTicks(float64(first), float64(last), 8) {
+		if year < float64(first) || year > float64(last) || year != math.Trunc(year) {
+			continue
+		}
+		d.line(colorGrid, 1, nil, point{x(year), f.bottom}, point{x(year), f.bottom + 6})
+		d.text(x(year), f.bottom+28, strconv.Itoa(int(year)), 15, "middle", colorMuted, false)
+	}
+
+	for _, b := range breaks {
+		bx := x(float64(b.Year) - 0.5)
+		d.line(colorBreak, 2, []float64{8, 6}, point{bx, f.top}, point{bx, f.bottom})
+		label := fmt.Sprintf("%d: %s", b.Year, b.Label)
+		if bx+8+textWidth(label, 15) > f.right {
+			d.text(bx-8, f.top+16, label, 15, "end", colorBreak, false)
+		} else {
+			d.text(bx+8, f.top+16, label, 15, "start", colorBreak, false)
+		}
+	}
+
+	for _, p := range points {
+		if p.CILow != nil && p.CIHigh != nil {
+			px := x(float64(p.Year))
+			d.line(colorCompare, 3, nil, point{px, y(*p.CILow)}, point{px, y(*p.CIHigh)})
+		}
+	}
+
+	// One line per stretch between breaks.
+	var run []point
+	next := 0
+	for _, p := range points {
+		if next < len(breaks) && p.Year >= breaks[next].Year {
+			d.line(colorLine, 3, nil, run...)
+			run = nil
+			for next < len(breaks) && p.Year >= breaks[next].Year {
+				next++
+			}
+		}
+		run = append(run, point{x(float64(p.Year)), y(p.Value)})
+	}
+	d.line(colorLine, 3, nil, run...)
+
+	if len(points) <= 80 {
+		for _, p := range points {
+			if p.Imputed {
+				d.circle(point{x(float64(p.Year)), y(p.Value)}, 4, colorPaper, colorLine, 2)
+			} else {
+				d.circle(point{x(float64(p.Year)), y(p.Value)}, 4.5, colorLine, "", 0)
+			}
+		}
+	}
+	latest := points[len(points)-1]
+	d.text(x(float64(latest.Year)), y(latest.Value)-14, formatValue(latest.Value, 0.1, m.UnitSuffix), 18, "end", colorText, true)
+	return d
+}
+
+// GenerationChart compares the generations on one stat: a dot at the mean
+// of each generation's birth cohorts, with a line from the lowest to the
+// highest.
+func GenerationChart(m catalog.Metric, summaries []cohorts.GenerationSummary) *Drawing {
+	type entry struct {
+		gen  cohorts.Generation
+		stat cohorts.GenerationStat
+	}
+	var entries []entry
+	for _, s := range summaries {
+		if st, ok := s.Stats[m.ID]; ok {
+			entries = append(entries, entry{s.Generation, st})
+		}
+	}
+
+	d, f := newChart(m.ShortTitle+" by generation",
+		"Mean of each generation's birth cohorts, measured "+m.Cohort.Label,
+		[]string{"Lines span the lowest and highest cohort value; n is the number of distinct measured years.", sourceNote(m)})
+	if len(entries) == 0 {
+		d.text((f.left+f.right)/2, (f.top+f.bottom)/2, "No data available", 24, "middle", colorMuted, false)
+		return d
+	}
+
+	lo, hi := entries[0].stat.Min, entries[0].stat.Max
+	for _, e := range entries {
+		lo, hi = math.Min(lo, e.stat.Min), math.Max(hi, e.stat.Max)
+	}
+	ticks := niceTicks(lo, hi, 5)
+	y := linear(ticks[0], ticks[len(ticks)-1], f.bottom, f.top)
+	d.yAxis(f, ticks, y, m.UnitSuffix)
+
+	band := (f.right - f.left) / float64(len(entries))
+	for i, e := range entries {
+		cx := f.left + band*(float64(i)+0.5)
+		d.line(colorLine, 3, nil, point{cx, y(e.stat.Min)}, point{cx, y(e.stat.Max)})
+		d.circle(point{cx, y(e.stat.Mean)}, 8, colorLine, colorPaper, 2)
+		d.text(cx+14, y(e.stat.Mean)+6, formatValue(e.stat.Mean, 0.1, m.UnitSuffix), 17, "start", colorText, true)
+		d.text(cx, f.bottom+28, e.gen.Short, 17, "middle", colorText, false)
+		d.text(cx, f.bottom+48, fmt.Sprintf("%d-%d, n=%d", e.gen.Start, e.gen.End, e.stat.Observations), 14, "middle", colorMuted, false)
+	}
+	return d
+}
+
+// comparisonDomain is the scale a stat is drawn on when two cohorts are
+// compared: the full range for percentages and NAEP scale scores, so rows
+// are not exaggerated, and from zero otherwise.
+func comparisonDomain(m catalog.Metric, values ...float64) (float64, float64) {
+	switch m.Unit {
+	case "percent":
+		return 0, 100
+	case "scale_score":
+		return 0, 500
+	}
+	lo, hi := 0.0, 0.0
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	ticks := niceTicks(lo, hi, 4)
+	return ticks[0], ticks[len(ticks)-1]
+}
+
+// ComparisonChart draws two birth cohorts' value of each stat as a pair of
+// dots joined by a line, one row per stat, with the difference at the end
+// of the row. The stats are those of cohorts.Compare.
+func ComparisonChart(birthYears [2]int, stats []cohorts.StatComparison) *Drawing {
+	title := fmt.Sprintf("Born %d vs born %d", birthYears[0], birthYears[1])
+	notes := []string{"Each stat is measured at the age shown, the same mapping as cohorts.json; percentages are drawn on 0-100%, NAEP scores on 0-500."}
+	var cited []string
+	for _, sc := range stats {
+		if m, ok := catalog.Get(sc.Stat); ok {
+			cited = append(cited, m.Source)
+		}
+	}
+	if len(cited) > 0 {
+		notes = append(notes, "Sources: "+strings.Join(uniqueStrings(cited), "; "))
+	}
+	d, f := newChart(title, "", notes)
+
+	// Legend in place of a subtitle.
+	legend := []struct {
+		color string
+		year  int
+	}{{colorCompare, birthYears[0]}, {colorLine, birthYears[1]}}
+	lx := chartMargin
+	for _, l := range legend {
+		d.circle(point{lx + 8, 94}, 8, l.color, "", 0)
+		label := fmt.Sprintf("Born %d", l.year)
+		d.text(lx+24, 100, label, 18, "start", colorText, false)
+		lx += 24 + textWidth(label, 18) + 32
+	}
+	if len(stats) == 0 {
+		d.text((f.left+f.right)/2, (f.top+f.bottom)/2, "No data available", 24, "middle", colorMuted, false)
+		return d
+	}
+
+	labelWidth := 280.0
+	diffWidth := 120.0
+	rowLeft, rowRight := chartMargin+labelWidth, chartWidth-chartMargin-diffWidth
+	row := (f.bottom - f.top) / float64(len(stats))
+	for i, sc := range stats {
+		m, _ := catalog.Get(sc.Stat)
+		cy := f.top + row*(float64(i)+0.5)
+		name := m.ShortTitle
+		if name == "" {
+			name = sc.Stat
+		}
+		d.text(chartMargin, cy, name, 18, "start", colorText, true)
+		d.text(chartMargin, cy+20, sc.Label, 14, "start", colorMuted, false)
+
+		var values []float64
+		for _, ms := range []*cohorts.Measurement{sc.A, sc.B} {
+			if ms != nil {
+				values = append(values, ms.Value)
+			}
+		}
+		lo, hi := comparisonDomain(m, values...)
+		x := linear(lo, hi, rowLeft, rowRight)
+		d.line(colorGrid, 2, nil, point{rowLeft, cy}, point{rowRight, cy})
+		if sc.A != nil && sc.B != nil {
+			d.line(colorMuted, 3, nil, point{x(sc.A.Value), cy}, point{x(sc.B.Value), cy})
+		}
+		for j, ms := range []*cohorts.Measurement{sc.A, sc.B} {
+			if ms == nil {
+				continue
+			}
+			d.circle(point{x(ms.Value), cy}, 9, legend[j].color, colorPaper, 2)
+			label := fmt.Sprintf("%s (%d)", formatValue(ms.Value, 0.1, m.UnitSuffix), ms.Year)
+			if ms.Imputed {
+				label += " est."
+			}
+			// Keep the two labels apart: the first above, the second below.
+			ly := cy - 16
+			if j == 1 {
+				ly = cy + 28
+			}
+			d.text(x(ms.Value), ly, label, 14, "middle", colorMuted, false)
+		}
+		diff := "n/a"
+		if sc.Absolute != nil {
+			diff = formatValue(*sc.Absolute, 0.1, m.UnitSuffix)
+			if *sc.Absolute >= 0 {
+				diff = "+" + diff
+			}
+		}
+		d.text(chartWidth-chartMargin, cy+6, diff, 20, "end", colorText, true)
+	}
+	return d
+}
+
+func uniqueStrings(list []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// writeCharts draws every generated stat, and the generations' comparison
+// of it, into dir/charts in each of h.Charts' formats.
+func (h *HugoGenerator) writeCharts(dir string, generated map[string]StatData) error {
+	chartDir := filepath.Join(dir, ChartDir)
+	if err := os.MkdirAll(chartDir, 0755); err != nil {
+		return err
+	}
+	summaries := cohorts.Summarize(BuildCohorts(generated))
+	count := 0
+	for _, m := range catalog.Published {
+		data, ok := generated[m.ID]
+		if !ok {
+			continue
+		}
+		drawings := map[string]*Drawing{
+			m.ID:                  StatChart(data, m),
+			"generations-" + m.ID: GenerationChart(m, summaries),
+		}
+		for name, drawing := range drawings {
+			for _, format := range h.Charts {
+				if err := drawing.WriteFile(filepath.Join(chartDir, name+"."+format)); err != nil {
+					return fmt.Errorf("failed to write chart %s.%s: %w", name, format, err)
+				}
+				count++
+			}
+		}
+	}
+	fmt.Printf("    ✓ Generated %d charts in %s/\n", count, ChartDir)
+	return nil
+}
@@ -0,0 +1,391 @@
+package generators
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/cohorts"
+)
+
+// Content sections written under the Hugo site's content directory. Each is
+// owned by the generator and replaced whole on every run.
+const (
+	StatsSection       = "stats"
+	CohortsSection     = "cohorts"
+	GenerationsSection = "generations"
+)
+
+// contentSections lists the generated sections in the order they are
+// written.
+var contentSections = []string{StatsSection, CohortsSection, GenerationsSection}
+
+// ComparisonGap is how many years before a birth year its page compares
+// against: roughly one generation, the parents of that cohort.
+const ComparisonGap = 30
+
+// generatedNotice heads every generated page's front matter.
+const generatedNotice = "# Generated by edu-stats generate-assets; edits are overwritten."
+
+// ContentDir returns the Hugo content directory beside the site's static
+// data directory outputDir.
+func ContentDir(outputDir string) string {
+	return filepath.Join(filepath.Dir(filepath.Dir(outputDir)), "content")
+}
+
+// contentCurrent reports whether every generated section exists, so an
+// up-to-date run need not rewrite them.
+func (h *HugoGenerator) contentCurrent() bool {
+	if h.contentDir == "" {
+		return true
+	}
+	for _, section := range contentSections {
+		if _, err := os.Stat(filepath.Join(h.contentDir, section, "_index.md")); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// writeContent writes a Markdown page per stat, per birth year with data
+// and per generation, so Hugo renders the figures as crawlable pages. Each
+// section is built in a staging directory and swapped into place like the
+// data directory.
+func (h *HugoGenerator) writeContent(generated map[string]StatData) error {
+	if err := os.MkdirAll(h.contentDir, 0755); err != nil {
+		return fmt.Errorf("failed to create content directory %s: %w", h.contentDir, err)
+	}
+	stage, err := os.MkdirTemp(h.contentDir, ".edu-stats-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stage)
+
+	built := BuildCohorts(generated)
+	pages := map[string]map[string]string{
+		StatsSection:       h.statPages(generated),
+		CohortsSection:     cohortPages(built),
+		GenerationsSection: generationPages(built),
+	}
+	for _, section := range contentSections {
+		dir := filepath.Join(stage, section)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		for name, page := range pages[section] {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(page), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	for _, section := range contentSections {
+		if err := swapDir(filepath.Join(stage, section), filepath.Join(h.contentDir, section)); err != nil {
+			return fmt.Errorf("failed to replace content/%s: %w", section, err)
+		}
+	}
+	fmt.Printf("    ✓ Generated %d stat, %d birth year and %d generation pages in %s\n",
+		len(pages[StatsSection])-1, len(pages[CohortsSection])-1, len(pages[GenerationsSection])-1, h.contentDir)
+	return nil
+}
+
+// frontMatter renders YAML front matter from alternating keys and values.
+// Strings are double-quoted; other values are written as is.
+func frontMatter(fields ...interface{}) string {
+	var b strings.Builder
+	b.WriteString("---\n" + generatedNotice + "\n")
+	for i := 0; i+1 < len(fields); i += 2 {
+		switch v := fields[i+1].(type) {
+		case string:
+			fmt.Fprintf(&b, "%s: %q\n", fields[i], v)
+		case float64:
+			fmt.Fprintf(&b, "%s: %s\n", fields[i], strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			fmt.Fprintf(&b, "%s: %v\n", fields[i], v)
+		}
+	}
+	b.WriteString("---\n\n")
+	return b.String()
+}
+
+// cell escapes text for a Markdown table cell.
+func cell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+// valueText formats v in m's unit, e.g. "86.5%".
+func valueText(m catalog.Metric, v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64) + m.UnitSuffix
+}
+
+// differenceText formats a change in m's unit, e.g. "+7.3 percentage points
+// (+9.3%)"; relative may be nil.
+func differenceText(m catalog.Metric, absolute float64, relative *float64) string {
+	s := fmt.Sprintf("%+.1f", absolute)
+	switch m.Unit {
+	case "percent":
+		s += " percentage points"
+	case "scale_score":
+		s += " points"
+	}
+	if relative != nil {
+		s += fmt.Sprintf(" (%+.1f%%)", *relative)
+	}
+	return s
+}
+
+// acrossBreaks notes on a comparison of values measured from first to last
+// the series breaks between them, where the difference partly reflects the
+// change of method.
+func acrossBreaks(m catalog.Metric, diff string, first, last int) string {
+	for _, br := range m.BreaksBetween(first, last) {
+		diff += fmt.Sprintf(", across the %d series break", br.Year)
+	}
+	return diff
+}
+
+// statPages renders content/stats: a page per stat with data, keyed by file
+// name.
+func (h *HugoGenerator) statPages(generated map[string]StatData) map[string]string {
+	pages := map[string]string{
+		"_index.md": frontMatter("title", "Statistics") +
+			"Every education statistic on this site, with its full series and source.\n",
+	}
+	for i, m := range catalog.Published {
+		data, ok := generated[m.ID]
+		if !ok {
+			continue
+		}
+		points := append([]DataPoint(nil), data.Years...)
+		sort.Slice(points, func(i, j int) bool { return points[i].Year < points[j].Year })
+		latest, measured := points[len(points)-1], 0
+		for _, p := range points {
+			if !p.Imputed {
+				latest = p
+				measured++
+			}
+		}
+
+		fields := []interface{}{
+			"title", m.Title,
+			"linkTitle", m.ShortTitle,
+			"description", m.Description,
+			"weight", i + 1,
+			"statId", m.ID,
+			"source", m.Source,
+			"citation", m.Citation,
+			"unit", m.Unit,
+			"yearMin", points[0].Year,
+			"yearMax", points[len(points)-1].Year,
+			"dataPoints", len(points),
+			"latestYear", latest.Year,
+			"latestValue", latest.Value,
+			"latestLabel", valueText(m, latest.Value),
+			"dataFile", "/data/" + m.Filename,
+		}
+		for _, format := range h.Charts {
+			if format == ChartSVG {
+				fields = append(fields, "chart", "/data/"+ChartDir+"/"+m.ID+".svg")
+			}
+		}
+
+		var b strings.Builder
+		b.WriteString(frontMatter(fields...))
+		fmt.Fprintf(&b, "%s.\n\n", m.Description)
+		fmt.Fprintf(&b, "The latest measurement is **%s** in %d. The series covers %d–%d with %d measured years",
+			valueText(m, latest.Value), latest.Year, points[0].Year, points[len(points)-1].Year, measured)
+		if imputed := len(points) - measured; imputed > 0 {
+			fmt.Fprintf(&b, " and %d years filled in by %s resampling", imputed, data.Resampled)
+		}
+		fmt.Fprintf(&b, ". For a birth cohort it is read %s.\n", m.Cohort.Label)
+
+		if len(m.Breaks) > 0 {
+			b.WriteString("\n## Series breaks\n\n")
+			for _, br := range m.Breaks {
+				fmt.Fprintf(&b, "- **%d:** %s\n", br.Year, br.Label)
+			}
+		}
+
+		b.WriteString("\n## Data\n\n| Year | Value |\n|------|-------|\n")
+		for _, p := range points {
+			value := valueText(m, p.Value)
+			if p.Imputed {
+				value += " (imputed)"
+			}
+			fmt.Fprintf(&b, "| %d | %s |\n", p.Year, value)
+		}
+		fmt.Fprintf(&b, "\nSource: %s\n", m.Citation)
+		pages[m.ID+".md"] = b.String()
+	}
+	return pages
+}
+
+// measurementText formats a cohort's measurement of m with the year it
+// was taken.
+func measurementText(m catalog.Metric, ms *cohorts.Measurement) string {
+	if ms == nil {
+		return "no data"
+	}
+	s := fmt.Sprintf("%s (%d)", valueText(m, ms.Value), ms.Year)
+	if ms.Imputed {
+		s += ", imputed"
+	}
+	return s
+}
+
+// cohortPages renders content/cohorts: a page per birth year with at least
+// one measurement, comparing it with the cohort born ComparisonGap years
+// earlier.
+func cohortPages(built []cohorts.Cohort) map[string]string {
+	pages := map[string]string{
+		"_index.md": frontMatter("title", "Birth Years") +
+			fmt.Sprintf("Each birth year's statistics at the life stage they were measured, compared with the cohort born %d years earlier.\n", ComparisonGap),
+	}
+	byYear := make(map[int]cohorts.Cohort, len(built))
+	for _, c := range built {
+		byYear[c.BirthYear] = c
+	}
+	for _, c := range built {
+		if len(c.Stats) == 0 {
+			continue
+		}
+		earlier := byYear[c.BirthYear-ComparisonGap]
+		earlier.BirthYear = c.BirthYear - ComparisonGap
+
+		fields := []interface{}{
+			"title", fmt.Sprintf("Born in %d", c.BirthYear),
+			"description", fmt.Sprintf("US education statistics for people born in %d, compared with those born in %d.", c.BirthYear, earlier.BirthYear),
+			"weight", c.BirthYear,
+			"birthYear", c.BirthYear,
+		}
+		g, hasGeneration := cohorts.GenerationFor(c.BirthYear)
+		if hasGeneration {
+			fields = append(fields, "generation", g.Name, "generationKey", g.Key)
+		}
+		fields = append(fields, "comparedWith", earlier.BirthYear)
+
+		var b strings.Builder
+		b.WriteString(frontMatter(fields...))
+		if hasGeneration {
+			fmt.Fprintf(&b, "Generation: [%s](../../%s/%s/), born %d–%d.\n\n",
+				g.Name, GenerationsSection, g.Key, g.Start, g.End)
+		}
+		fmt.Fprintf(&b, "Each statistic is the value measured at the life stage shown, compared with people born in %d.\n\n", earlier.BirthYear)
+		fmt.Fprintf(&b, "| Statistic | Life stage | Born %d | Born %d | Difference |\n", c.BirthYear, earlier.BirthYear)
+		b.WriteString("|-----------|------------|------|------|------------|\n")
+		for _, m := range catalog.Published {
+			if _, ok := c.Stats[m.ID]; !ok {
+				continue
+			}
+			sc := cohorts.Compare(earlier, c, []string{m.ID})[0]
+			diff := "n/a"
+			if sc.Absolute != nil {
+				diff = acrossBreaks(m, differenceText(m, *sc.Absolute, sc.Relative),
+					min(sc.A.Year, sc.B.Year), max(sc.A.Year, sc.B.Year))
+			}
+			fmt.Fprintf(&b, "| [%s](../../%s/%s/) | %s | %s | %s | %s |\n",
+				cell(m.ShortTitle), StatsSection, m.ID, cell(m.Cohort.Label),
+				measurementText(m, sc.B), measurementText(m, sc.A), diff)
+		}
+		pages[strconv.Itoa(c.BirthYear)+".md"] = b.String()
+	}
+	return pages
+}
+
+// generationPages renders content/generations: a page per generation with
+// cohorts, summarising each stat and comparing its mean with the previous
+// generation's.
+func generationPages(built []cohorts.Cohort) map[string]string {
+	pages := map[string]string{
+		"_index.md": frontMatter("title", "Generations") +
+			"Each generation's statistics aggregated over its birth years, compared with the generation before.\n",
+	}
+	summaries := cohorts.Summarize(built)
+	previous := make(map[string]cohorts.GenerationSummary)
+	for i, g := range cohorts.Generations {
+		if i > 0 {
+			for _, s := range summaries {
+				if s.Key == cohorts.Generations[i-1].Key {
+					previous[g.Key] = s
+				}
+			}
+		}
+	}
+	withData := make(map[int]bool)
+	for _, c := range built {
+		if len(c.Stats) > 0 {
+			withData[c.BirthYear] = true
+		}
+	}
+
+	for i, s := range summaries {
+		var b strings.Builder
+		b.WriteString(frontMatter(
+			"title", s.Name,
+			"linkTitle", s.Short,
+			"description", fmt.Sprintf("US education statistics for people born %d–%d (%s).", s.Start, s.End, s.Name),
+			"weight", i+1,
+			"generationKey", s.Key,
+			"startYear", s.Start,
+			"endYear", s.End,
+		))
+		prev, hasPrev := previous[s.Key]
+		fmt.Fprintf(&b, "Born %d–%d. Each statistic is aggregated over the years its birth cohorts were measured; a year shared by several cohorts counts once.", s.Start, s.End)
+		if hasPrev {
+			fmt.Fprintf(&b, " Means are compared with the previous generation, %s.", prev.Name)
+		}
+		b.WriteString("\n\n| Statistic | Mean | Range | Trend per year | Years measured |")
+		if hasPrev {
+			fmt.Fprintf(&b, " vs %s |", cell(prev.Short))
+		}
+		b.WriteString("\n|-----------|------|-------|----------------|----------------|")
+		if hasPrev {
+			b.WriteString("------|")
+		}
+		b.WriteString("\n")
+		for _, m := range catalog.Published {
+			st, ok := s.Stats[m.ID]
+			if !ok {
+				continue
+			}
+			trend := "n/a"
+			if st.Slope != nil {
+				trend = fmt.Sprintf("%+.2f", *st.Slope)
+			}
+			fmt.Fprintf(&b, "| [%s](../../%s/%s/) | %s | %s – %s | %s | %d (%d–%d) |",
+				cell(m.ShortTitle), StatsSection, m.ID, valueText(m, st.Mean),
+				valueText(m, st.Min), valueText(m, st.Max), trend, st.Observations, st.FirstYear, st.LastYear)
+			if hasPrev {
+				diff := "n/a"
+				if p, ok := prev.Stats[m.ID]; ok {
+					abs := st.Mean - p.Mean
+					var rel *float64
+					if p.Mean != 0 {
+						r := abs / p.Mean * 100
+						rel = &r
+					}
+					// Either generation's years may reach across a break.
+					diff = acrossBreaks(m, differenceText(m, abs, rel),
+						min(p.FirstYear, st.FirstYear), max(p.LastYear, st.LastYear))
+				}
+				fmt.Fprintf(&b, " %s |", diff)
+			}
+			b.WriteString("\n")
+		}
+
+		var years []string
+		for year := s.Start; year <= s.End; year++ {
+			if withData[year] {
+				years = append(years, fmt.Sprintf("[%d](../../%s/%d/)", year, CohortsSection, year))
+			}
+		}
+		if len(years) > 0 {
+			fmt.Fprintf(&b, "\n## Birth years\n\n%s\n", strings.Join(years, " · "))
+		}
+		pages[s.Key+".md"] = b.String()
+	}
+	return pages
+}
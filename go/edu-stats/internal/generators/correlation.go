@@ -0,0 +1,116 @@
+package generators
+
+import (
+	"fmt"
+
+	"github.com/aallbrig/proficiency-comparison/internal/analytics"
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/schema"
+)
+
+// Ways two stats' series can be lined up for correlation.
+const (
+	// AlignYear pairs values measured in the same calendar year.
+	AlignYear = "year"
+	// AlignCohort pairs values of the same birth cohort: each stat's years
+	// are shifted back by its life-stage offset.
+	AlignCohort = "cohort"
+)
+
+// CorrelationMaxLag is how many years either way correlations.json scans
+// for the strongest lagged relationship.
+const CorrelationMaxLag = 5
+
+// CorrelationPoints returns a stat's measured headline points for
+// correlation, keyed by calendar year or, with AlignCohort, by birth year
+// (measured year minus the stat's cohort offset).
+func CorrelationPoints(data StatData, m catalog.Metric, alignment string) []analytics.Point {
+	offset := 0
+	if alignment == AlignCohort {
+		offset = m.Cohort.Offset
+	}
+	var points []analytics.Point
+	for _, dp := range data.Years {
+		if !dp.Imputed {
+			points = append(points, analytics.Point{Year: dp.Year - offset, Value: dp.Value})
+		}
+	}
+	return points
+}
+
+// StatCorrelation relates two stats at lag zero, with the strongest lag
+// found within CorrelationMaxLag.
+type StatCorrelation struct {
+	A         string `json:"a"`
+	B         string `json:"b"`
+	Alignment string `json:"alignment"`
+	analytics.Correlation
+	Strongest *analytics.Correlation `json:"strongest,omitempty"`
+}
+
+// CorrelationsFile is the layout of correlations.json: every pair of
+// stats with data, by calendar year and by birth cohort.
+type CorrelationsFile struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MinOverlap    int               `json:"minOverlap"`
+	MaxLag        int               `json:"maxLag"`
+	Stats         []string          `json:"stats"`
+	Pairs         []StatCorrelation `json:"pairs"`
+}
+
+// CorrelationMatrix correlates every pair of stats in stats, in catalog
+// order, under both alignments.
+func CorrelationMatrix(stats map[string]StatData) CorrelationsFile {
+	out := CorrelationsFile{
+		MinOverlap: analytics.MinOverlap,
+		MaxLag:     CorrelationMaxLag,
+		Stats:      []string{},
+		Pairs:      []StatCorrelation{},
+	}
+	var metrics []catalog.Metric
+	for _, m := range catalog.Published {
+		if _, ok := stats[m.ID]; ok {
+			metrics = append(metrics, m)
+			out.Stats = append(out.Stats, m.ID)
+		}
+	}
+	for _, alignment := range []string{AlignYear, AlignCohort} {
+		for i, a := range metrics {
+			for _, b := range metrics[i+1:] {
+				out.Pairs = append(out.Pairs, CorrelateStats(stats, a, b, alignment, 0, CorrelationMaxLag))
+			}
+		}
+	}
+	return out
+}
+
+// CorrelateStats correlates a with b at lag and, when maxLag is positive,
+// records the strongest lag within ±maxLag.
+func CorrelateStats(stats map[string]StatData, a, b catalog.Metric, alignment string, lag, maxLag int) StatCorrelation {
+	pa := CorrelationPoints(stats[a.ID], a, alignment)
+	pb := CorrelationPoints(stats[b.ID], b, alignment)
+	sc := StatCorrelation{
+		A:           a.ID,
+		B:           b.ID,
+		Alignment:   alignment,
+		Correlation: analytics.Correlate(pa, pb, lag),
+	}
+	if maxLag > 0 {
+		if best, ok := analytics.Strongest(analytics.ScanLags(pa, pb, maxLag)); ok {
+			sc.Strongest = &best
+		}
+	}
+	return sc
+}
+
+// generateCorrelations writes correlations.json.
+func (h *HugoGenerator) generateCorrelations(outputDir string, generated map[string]StatData) error {
+	version := h.schemaVersion()
+	out := CorrelationMatrix(generated)
+	out.SchemaVersion = version
+	if err := writeOutput(outputDir, "correlations.json", schema.KindCorrelations, version, out); err != nil {
+		return err
+	}
+	fmt.Printf("    ✓ Generated correlations.json (%d stat pairs)\n", len(out.Pairs)/2)
+	return nil
+}
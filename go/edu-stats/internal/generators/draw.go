@@ -0,0 +1,367 @@
+package generators
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Drawing is a chart as a list of shapes in pixel coordinates. The SVG and
+// PNG renderers draw the same list, so both formats show the same chart.
+type Drawing struct {
+	Width  int
+	Height int
+	// Title is the accessible name of the SVG.
+	Title  string
+	shapes []shape
+}
+
+type point struct{ X, Y float64 }
+
+// shape is one element of a Drawing. Colors are "#rrggbb"; an empty fill
+// or stroke is not drawn.
+type shape interface {
+	svg(w *bytes.Buffer)
+	raster(r *raster)
+}
+
+type rectShape struct {
+	x, y, w, h float64
+	fill       string
+}
+
+type lineShape struct {
+	points []point
+	stroke string
+	width  float64
+	// dash is the on and off lengths of a dashed line; nil is solid.
+	dash []float64
+}
+
+type circleShape struct {
+	center       point
+	r            float64
+	fill, stroke string
+	width        float64
+}
+
+type textShape struct {
+	at     point // baseline
+	text   string
+	size   float64
+	anchor string // start, middle or end
+	fill   string
+	bold   bool
+}
+
+func (d *Drawing) rect(x, y, w, h float64, fill string) {
+	d.shapes = append(d.shapes, rectShape{x, y, w, h, fill})
+}
+
+func (d *Drawing) line(stroke string, width float64, dash []float64, points ...point) {
+	d.shapes = append(d.shapes, lineShape{points, stroke, width, dash})
+}
+
+func (d *Drawing) circle(center point, r float64, fill, stroke string, width float64) {
+	d.shapes = append(d.shapes, circleShape{center, r, fill, stroke, width})
+}
+
+func (d *Drawing) text(x, y float64, s string, size float64, anchor, fill string, bold bool) {
+	d.shapes = append(d.shapes, textShape{point{x, y}, s, size, anchor, fill, bold})
+}
+
+// textWidth estimates the rendered width of s, used to wrap and place text.
+// It is exact for the PNG font and close for common sans-serif fonts.
+func textWidth(s string, size float64) float64 {
+	return float64(len([]rune(s))) * size * 0.6
+}
+
+// wrapText breaks s into lines no wider than width at size.
+func wrapText(s string, size, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && textWidth(line+" "+word, size) > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// num formats a coordinate with at most two decimals.
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// SVG renders the drawing as a standalone SVG document.
+func (d *Drawing) SVG() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img" aria-label="%s" font-family="system-ui, -apple-system, 'Segoe UI', Roboto, Helvetica, Arial, sans-serif">`+"\n",
+		d.Width, d.Height, d.Width, d.Height, escapeXML(d.Title))
+	fmt.Fprintf(&b, "<title>%s</title>\n", escapeXML(d.Title))
+	for _, s := range d.shapes {
+		s.svg(&b)
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func (s rectShape) svg(w *bytes.Buffer) {
+	fmt.Fprintf(w, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n", num(s.x), num(s.y), num(s.w), num(s.h), s.fill)
+}
+
+func (s lineShape) svg(w *bytes.Buffer) {
+	pts := make([]string, len(s.points))
+	for i, p := range s.points {
+		pts[i] = num(p.X) + "," + num(p.Y)
+	}
+	fmt.Fprintf(w, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%s" stroke-linejoin="round" stroke-linecap="round"`,
+		strings.Join(pts, " "), s.stroke, num(s.width))
+	if len(s.dash) > 0 {
+		dash := make([]string, len(s.dash))
+		for i, v := range s.dash {
+			dash[i] = num(v)
+		}
+		fmt.Fprintf(w, ` stroke-dasharray="%s"`, strings.Join(dash, " "))
+	}
+	w.WriteString("/>\n")
+}
+
+func (s circleShape) svg(w *bytes.Buffer) {
+	fill := s.fill
+	if fill == "" {
+		fill = "none"
+	}
+	fmt.Fprintf(w, `<circle cx="%s" cy="%s" r="%s" fill="%s"`, num(s.center.X), num(s.center.Y), num(s.r), fill)
+	if s.stroke != "" {
+		fmt.Fprintf(w, ` stroke="%s" stroke-width="%s"`, s.stroke, num(s.width))
+	}
+	w.WriteString("/>\n")
+}
+
+func (s textShape) svg(w *bytes.Buffer) {
+	fmt.Fprintf(w, `<text x="%s" y="%s" font-size="%s" fill="%s"`, num(s.at.X), num(s.at.Y), num(s.size), s.fill)
+	if s.anchor != "" && s.anchor != "start" {
+		fmt.Fprintf(w, ` text-anchor="%s"`, s.anchor)
+	}
+	if s.bold {
+		w.WriteString(` font-weight="bold"`)
+	}
+	fmt.Fprintf(w, ">%s</text>\n", escapeXML(s.text))
+}
+
+// supersample is how many pixels per side the PNG renderer draws for each
+// output pixel before averaging them, to smooth lines and text.
+const supersample = 2
+
+// raster draws shapes into an image scaled by supersample.
+type raster struct {
+	img *image.RGBA
+}
+
+// PNG renders the drawing as a PNG image.
+func (d *Drawing) PNG(w io.Writer) error {
+	r := &raster{img: image.NewRGBA(image.Rect(0, 0, d.Width*supersample, d.Height*supersample))}
+	for _, s := range d.shapes {
+		s.raster(r)
+	}
+	return png.Encode(w, downsample(r.img, d.Width, d.Height))
+}
+
+// WriteFile writes the drawing as SVG or PNG, chosen by path's extension.
+func (d *Drawing) WriteFile(path string) error {
+	var b bytes.Buffer
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".svg":
+		b.Write(d.SVG())
+	case ".png":
+		if err := d.PNG(&b); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown chart format %q, use .svg or .png", filepath.Ext(path))
+	}
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+func downsample(src *image.RGBA, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	n := uint32(supersample * supersample)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var r, g, b, a uint32
+			for dy := 0; dy < supersample; dy++ {
+				for dx := 0; dx < supersample; dx++ {
+					c := src.RGBAAt(x*supersample+dx, y*supersample+dy)
+					r, g, b, a = r+uint32(c.R), g+uint32(c.G), b+uint32(c.B), a+uint32(c.A)
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(b / n), uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+func parseColor(s string) (color.RGBA, bool) {
+	if len(s) != 7 || s[0] != '#' {
+		return color.RGBA{}, false
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{}, false
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, true
+}
+
+// fill sets every pixel whose center lies in [x0,x1)x[y0,y1), given in
+// drawing coordinates, and for which inside (if given) is true.
+func (r *raster) fill(x0, y0, x1, y1 float64, c color.RGBA, inside func(x, y float64) bool) {
+	bounds := r.img.Bounds()
+	px0 := max(int(math.Floor(x0*supersample)), bounds.Min.X)
+	py0 := max(int(math.Floor(y0*supersample)), bounds.Min.Y)
+	px1 := min(int(math.Ceil(x1*supersample)), bounds.Max.X)
+	py1 := min(int(math.Ceil(y1*supersample)), bounds.Max.Y)
+	for py := py0; py < py1; py++ {
+		y := (float64(py) + 0.5) / supersample
+		if y < y0 || y >= y1 {
+			continue
+		}
+		for px := px0; px < px1; px++ {
+			x := (float64(px) + 0.5) / supersample
+			if x < x0 || x >= x1 || (inside != nil && !inside(x, y)) {
+				continue
+			}
+			r.img.SetRGBA(px, py, c)
+		}
+	}
+}
+
+func (s rectShape) raster(r *raster) {
+	if c, ok := parseColor(s.fill); ok {
+		r.fill(s.x, s.y, s.x+s.w, s.y+s.h, c, nil)
+	}
+}
+
+// segment draws a line from a to b with round caps.
+func (r *raster) segment(a, b point, width float64, c color.RGBA) {
+	half := width / 2
+	dx, dy := b.X-a.X, b.Y-a.Y
+	length2 := dx*dx + dy*dy
+	r.fill(math.Min(a.X, b.X)-half, math.Min(a.Y, b.Y)-half, math.Max(a.X, b.X)+half, math.Max(a.Y, b.Y)+half, c,
+		func(x, y float64) bool {
+			t := 0.0
+			if length2 > 0 {
+				t = math.Max(0, math.Min(1, ((x-a.X)*dx+(y-a.Y)*dy)/length2))
+			}
+			ex, ey := x-(a.X+t*dx), y-(a.Y+t*dy)
+			return ex*ex+ey*ey <= half*half
+		})
+}
+
+func (s lineShape) raster(r *raster) {
+	c, ok := parseColor(s.stroke)
+	if !ok {
+		return
+	}
+	if len(s.dash) == 0 {
+		for i := 1; i < len(s.points); i++ {
+			r.segment(s.points[i-1], s.points[i], s.width, c)
+		}
+		return
+	}
+	// Walk the line, drawing the "on" parts of the dash pattern.
+	dashIndex, left := 0, s.dash[0]
+	for i := 1; i < len(s.points); i++ {
+		a, b := s.points[i-1], s.points[i]
+		length := math.Hypot(b.X-a.X, b.Y-a.Y)
+		for pos := 0.0; pos < length; {
+			step := math.Min(left, length-pos)
+			if dashIndex%2 == 0 {
+				from, to := pos/length, (pos+step)/length
+				r.segment(point{a.X + (b.X-a.X)*from, a.Y + (b.Y-a.Y)*from}, point{a.X + (b.X-a.X)*to, a.Y + (b.Y-a.Y)*to}, s.width, c)
+			}
+			pos += step
+			if left -= step; left <= 0 {
+				dashIndex = (dashIndex + 1) % len(s.dash)
+				left = s.dash[dashIndex]
+			}
+		}
+	}
+}
+
+func (s circleShape) raster(r *raster) {
+	// ring fills the pixels between radii inner and outer.
+	ring := func(inner, outer float64, c color.RGBA) {
+		r.fill(s.center.X-outer, s.center.Y-outer, s.center.X+outer, s.center.Y+outer, c, func(x, y float64) bool {
+			d := math.Hypot(x-s.center.X, y-s.center.Y)
+			return d >= inner && d <= outer
+		})
+	}
+	inner := s.r
+	if c, ok := parseColor(s.stroke); ok {
+		inner -= s.width / 2
+		ring(inner, s.r+s.width/2, c)
+	}
+	if c, ok := parseColor(s.fill); ok {
+		ring(0, inner, c)
+	}
+}
+
+// raster draws text in the bitmap font, one font pixel being a tenth of
+// the font size, so a glyph advances 0.6 of the size as textWidth assumes.
+func (s textShape) raster(r *raster) {
+	c, ok := parseColor(s.fill)
+	if !ok {
+		return
+	}
+	dot := s.size / 10
+	x := s.at.X
+	switch s.anchor {
+	case "middle":
+		x -= textWidth(s.text, s.size) / 2
+	case "end":
+		x -= textWidth(s.text, s.size)
+	}
+	top := s.at.Y - 7*dot
+	for _, ch := range s.text {
+		rows := glyph(ch)
+		for row, bits := range rows {
+			for col := 0; col < 5; col++ {
+				if bits&(0x10>>col) == 0 {
+					continue
+				}
+				x0, y0 := x+float64(col)*dot, top+float64(row)*dot
+				x1 := x0 + dot
+				if s.bold {
+					x1 += dot / 2
+				}
+				r.fill(x0, y0, x1, y0+dot, c, nil)
+			}
+		}
+		x += 6 * dot
+	}
+}
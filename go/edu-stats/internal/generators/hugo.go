@@ -6,11 +6,38 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"time"
+
+	"github.com/aallbrig/proficiency-comparison/internal/analytics"
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/cohorts"
+	"github.com/aallbrig/proficiency-comparison/internal/schema"
 )
 
 type HugoGenerator struct {
 	db *sql.DB
+	// Resample expands each series to annual points, flagging the filled
+	// years as imputed. The zero value leaves series as measured.
+	Resample ResampleMethod
+	// DefaultSeries overrides the headline series of individual stats;
+	// stats not listed use the package DefaultSeries.
+	DefaultSeries map[string]SeriesSelector
+	// SchemaVersion selects the output contract (see package schema); zero
+	// means schema.Current.
+	SchemaVersion int
+	// Full regenerates every stat even when its tables have not changed
+	// since the last run into the same directory.
+	Full bool
+	// Charts lists the formats (ChartSVG, ChartPNG) to draw each stat's
+	// chart in, into the charts directory beside the JSON. Empty draws none.
+	Charts []string
+	// Analytics adds each series' trend analysis (see package analytics) to
+	// the stat files.
+	Analytics bool
+	// NoContent skips the Hugo content pages GenerateAll otherwise writes
+	// beside the data (see ContentDir).
+	NoContent bool
+	// contentDir is where the content pages are written; empty writes none.
+	contentDir string
 }
 
 func NewHugoGenerator(db *sql.DB) *HugoGenerator {
@@ -18,21 +45,55 @@ func NewHugoGenerator(db *sql.DB) *HugoGenerator {
 }
 
 type DataPoint struct {
-	Year  int     `json:"year"`
-	Value float64 `json:"value"`
-	Label string  `json:"label,omitempty"`
+	Year    int     `json:"year"`
+	Value   float64 `json:"value"`
+	Label   string  `json:"label,omitempty"`
+	Imputed bool    `json:"imputed,omitempty"`
+	Method  string  `json:"method,omitempty"`
+	// StandardError, CILow and CIHigh are the sampling error and 95%
+	// confidence interval of a measured value whose source publishes them.
+	StandardError *float64 `json:"standardError,omitempty"`
+	CILow         *float64 `json:"ciLow,omitempty"`
+	CIHigh        *float64 `json:"ciHigh,omitempty"`
 }
 
+// StatData is one stat's JSON file. Data is the headline series named by
+// DefaultSeries; Series holds every breakdown by dimension and source.
 type StatData struct {
-	Name        string      `json:"name"`
-	Description string      `json:"description"`
-	Source      string      `json:"source"`
-	Years       []DataPoint `json:"data"`
+	SchemaVersion int         `json:"schemaVersion,omitempty"`
+	ID            string      `json:"id,omitempty"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	Source        string      `json:"source"`
+	Unit          string      `json:"unit,omitempty"`
+	Citation      string      `json:"citation,omitempty"`
+	Resampled     string      `json:"resampled,omitempty"`
+	DefaultSeries string      `json:"defaultSeries,omitempty"`
+	Years         []DataPoint `json:"data"`
+	Series        []Series    `json:"series,omitempty"`
+	// Analysis describes the headline series when HugoGenerator.Analytics
+	// is set.
+	Analysis *analytics.Analysis `json:"analysis,omitempty"`
 }
 
 func (h *HugoGenerator) GenerateAll() error {
 	fmt.Println("  Generating Hugo JSON assets...")
 
+	outputDir := OutputDir()
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
+	}
+
+	fmt.Printf("    Output directory: %s\n", outputDir)
+	if !h.NoContent {
+		h.contentDir = ContentDir(outputDir)
+	}
+	return h.generateToDir(outputDir)
+}
+
+// OutputDir returns the Hugo site's static data directory, looked up from
+// the repo root, go/edu-stats or go/edu-stats/cmd.
+func OutputDir() string {
 	// Try multiple locations for Hugo site
 	hugoOutputLocations := []string{
 		filepath.Join("hugo", "site", "static", "data"),                    // From repo root
@@ -62,329 +123,381 @@ func (h *HugoGenerator) GenerateAll() error {
 		// Fall back to first option and create it
 		outputDir = hugoOutputLocations[0]
 	}
-	
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
-	}
-
-	fmt.Printf("    Output directory: %s\n", outputDir)
-	return h.generateToDir(outputDir)
+	return outputDir
 }
 
-// generateToDir writes all stat JSON files and index.json into outputDir.
-// It is separated from GenerateAll to allow testing with a temp directory.
-func (h *HugoGenerator) generateToDir(outputDir string) error {
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
+// generateStat reads one catalog metric with its series and applies the
+// configured resampling.
+func (h *HugoGenerator) generateStat(m catalog.Metric) (StatData, error) {
+	data := StatData{
+		ID:          m.ID,
+		Name:        m.Title,
+		Description: m.Description,
+		Source:      m.Source,
+		Unit:        m.Unit,
+		Citation:    m.Citation,
 	}
-
-	generators := []struct {
-		name     string
-		filename string
-		fn       func() (StatData, error)
-	}{
-		{"Literacy Rates", "literacy.json", h.generateLiteracyData},
-		{"Educational Attainment", "attainment.json", h.generateAttainmentData},
-		{"Graduation Rates", "graduation.json", h.generateGraduationData},
-		{"Enrollment Rates", "enrollment.json", h.generateEnrollmentData},
-		{"Test Proficiency", "proficiency.json", h.generateProficiencyData},
-		{"Early Childhood", "early_childhood.json", h.generateEarlyChildhoodData},
+	data, err := h.withSeries(m.ID, data, m.ObservationTable(), m.Column)
+	if err != nil {
+		return data, err
 	}
-
-	for _, gen := range generators {
-		data, err := gen.fn()
-		if err != nil {
-			fmt.Printf("    Warning: failed to generate %s: %v\n", gen.name, err)
-			continue
+	if h.Analytics {
+		data.Analysis = Analyze(data.Years, analytics.DefaultOptions)
+		for i := range data.Series {
+			data.Series[i].Analysis = Analyze(data.Series[i].Data, analytics.DefaultOptions)
 		}
-
-		if len(data.Years) == 0 {
-			fmt.Printf("    ⚠ %s: no data available\n", gen.name)
-			continue
+	}
+	if len(data.Years) > 0 && h.Resample != "" && h.Resample != ResampleNone {
+		data.Years = Resample(data.Years, h.Resample)
+		for i := range data.Series {
+			data.Series[i].Data = Resample(data.Series[i].Data, h.Resample)
 		}
+		data.Resampled = string(h.Resample)
+	}
+	return data, nil
+}
 
-		outputPath := filepath.Join(outputDir, gen.filename)
-		file, err := os.Create(outputPath)
+// Stats returns every stat that has data, keyed as in cohorts.Stages, exactly
+// as generate-assets would write it.
+func (h *HugoGenerator) Stats() (map[string]StatData, error) {
+	stats := make(map[string]StatData)
+	for _, m := range catalog.Published {
+		data, err := h.generateStat(m)
 		if err != nil {
-			return fmt.Errorf("failed to create %s: %w", gen.filename, err)
+			return nil, fmt.Errorf("%s: %w", m.Title, err)
 		}
-
-		encoder := json.NewEncoder(file)
-		encoder.SetIndent("", "  ")
-		if err := encoder.Encode(data); err != nil {
-			file.Close()
-			return fmt.Errorf("failed to write %s: %w", gen.filename, err)
+		if len(data.Years) > 0 {
+			stats[m.ID] = data
 		}
-		file.Close()
-
-		fmt.Printf("    ✓ Generated %s (%d data points)\n", gen.filename, len(data.Years))
 	}
+	return stats, nil
+}
 
-	// Generate stats index
-	if err := h.generateStatsIndex(outputDir); err != nil {
-		return fmt.Errorf("failed to generate stats index: %w", err)
+// generateToDir builds every output file in a staging directory next to
+// outputDir and then swaps it into place, so the site never serves a
+// half-written file or one left over from an earlier run. Stats whose
+// tables have not changed since the last run are copied from the current
+// output instead of being queried again. It is separated from GenerateAll
+// to allow testing with a temp directory.
+func (h *HugoGenerator) generateToDir(outputDir string) error {
+	version := h.schemaVersion()
+	if err := schema.CheckVersion(version); err != nil {
+		return err
 	}
 
-	fmt.Println("  ✓ Hugo asset generation complete")
-	return nil
-}
-
-func (h *HugoGenerator) generateLiteracyData() (StatData, error) {
-	rows, err := h.db.Query(`
-		SELECT year, AVG(rate) as avg_rate
-		FROM literacy_rates
-		GROUP BY year
-		ORDER BY year
-	`)
+	plan, err := h.plan(outputDir, version)
 	if err != nil {
-		return StatData{}, err
+		return err
 	}
-	defer rows.Close()
-
-	var data StatData
-	data.Name = "Literacy Rates"
-	data.Description = "Adult literacy and high school completion rates"
-	data.Source = "NCES Digest"
-
-	for rows.Next() {
-		var dp DataPoint
-		if err := rows.Scan(&dp.Year, &dp.Value); err != nil {
-			continue
-		}
-		data.Years = append(data.Years, dp)
+	if plan.upToDate() && h.contentCurrent() {
+		fmt.Printf("  ✓ Hugo assets up to date (run %s); use --full to regenerate\n", plan.previousRun)
+		return nil
 	}
 
-	return data, nil
-}
-
-func (h *HugoGenerator) generateAttainmentData() (StatData, error) {
-	rows, err := h.db.Query(`
-		SELECT year, AVG(percentage) as avg_pct
-		FROM educational_attainment
-		WHERE education_level = 'bachelors_plus'
-		GROUP BY year
-		ORDER BY year
-	`)
+	parent := filepath.Dir(outputDir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory %s: %w", parent, err)
+	}
+	stage, err := os.MkdirTemp(parent, "."+filepath.Base(outputDir)+"-")
 	if err != nil {
-		return StatData{}, err
+		return fmt.Errorf("failed to create staging directory: %w", err)
 	}
-	defer rows.Close()
-
-	var data StatData
-	data.Name = "Educational Attainment"
-	data.Description = "Percentage with bachelor's degree or higher (25+)"
-	data.Source = "US Census Bureau"
-
-	for rows.Next() {
-		var dp DataPoint
-		if err := rows.Scan(&dp.Year, &dp.Value); err != nil {
-			continue
-		}
-		data.Years = append(data.Years, dp)
+	// Removes the staging directory unless it was swapped in.
+	defer os.RemoveAll(stage)
+	if err := os.Chmod(stage, 0755); err != nil {
+		return err
 	}
 
-	return data, nil
-}
-
-func (h *HugoGenerator) generateGraduationData() (StatData, error) {
-	rows, err := h.db.Query(`
-		SELECT year, AVG(rate) as avg_rate
-		FROM graduation_rates
-		WHERE state = 'US' OR state IS NULL
-		GROUP BY year
-		ORDER BY year
-	`)
+	generated, err := h.writeFiles(stage, version, plan)
 	if err != nil {
-		return StatData{}, err
+		return err
 	}
-	defer rows.Close()
-
-	var data StatData
-	data.Name = "High School Graduation Rates"
-	data.Description = "4-year adjusted cohort graduation rate (ACGR)"
-	data.Source = "NCES Digest"
-
-	for rows.Next() {
-		var dp DataPoint
-		if err := rows.Scan(&dp.Year, &dp.Value); err != nil {
-			continue
+	runID := ""
+	if schema.Has(schema.KindManifest, version) {
+		manifest, err := h.writeManifest(stage, version)
+		if err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+		runID = manifest.RunID
+		fmt.Printf("    ✓ Generated manifest.json (%d files, run %s)\n", len(manifest.Files), manifest.RunID)
+	}
+	if err := keepHiddenFiles(outputDir, stage); err != nil {
+		return err
+	}
+	if err := swapDir(stage, outputDir); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", outputDir, err)
+	}
+	if h.contentDir != "" {
+		if err := h.writeContent(generated); err != nil {
+			return fmt.Errorf("failed to write content pages: %w", err)
 		}
-		data.Years = append(data.Years, dp)
+	}
+	if err := h.recordPlan(plan, runID); err != nil {
+		fmt.Printf("    Warning: could not record generation state: %v\n", err)
 	}
 
-	return data, nil
+	fmt.Println("  ✓ Hugo asset generation complete")
+	return nil
 }
 
-func (h *HugoGenerator) generateEnrollmentData() (StatData, error) {
-	rows, err := h.db.Query(`
-		SELECT year, AVG(enrollment_rate) as avg_rate
-		FROM enrollment_rates
-		WHERE state = 'US' OR state IS NULL
-		GROUP BY year
-		ORDER BY year
-	`)
-	if err != nil {
-		return StatData{}, err
+// writeFiles writes the schemas, every stat file, cohorts.json,
+// generations.json, correlations.json and index.json into dir, copying the stats plan reuses
+// from the current output. It returns the stats written.
+func (h *HugoGenerator) writeFiles(dir string, version int, plan *generationPlan) (map[string]StatData, error) {
+	if err := publishSchemas(dir); err != nil {
+		return nil, fmt.Errorf("failed to publish schemas: %w", err)
 	}
-	defer rows.Close()
 
-	var data StatData
-	data.Name = "Enrollment Rates"
-	data.Description = "School enrollment rates (averaged across age groups)"
-	data.Source = "NCES Digest"
+	generated := make(map[string]StatData)
+	for _, m := range catalog.Published {
+		if plan.reuse[m.ID] {
+			data, ok, err := reuseStat(plan.outputDir, dir, m)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				generated[m.ID] = data
+				fmt.Printf("    = Kept %s (%s unchanged)\n", m.Filename, m.Table)
+			}
+			continue
+		}
 
-	for rows.Next() {
-		var dp DataPoint
-		if err := rows.Scan(&dp.Year, &dp.Value); err != nil {
+		data, err := h.generateStat(m)
+		if err != nil {
+			plan.failed++
+			kept, ok, keepErr := keepPrevious(plan, dir, m, err)
+			if keepErr != nil {
+				return nil, keepErr
+			}
+			if ok {
+				generated[m.ID] = kept
+				fmt.Printf("    Warning: failed to generate %s, keeping the previous %s: %v\n", m.Title, m.Filename, err)
+				continue
+			}
+			fmt.Printf("    Warning: failed to generate %s: %v\n", m.Title, err)
 			continue
 		}
-		data.Years = append(data.Years, dp)
-	}
 
-	return data, nil
-}
+		if len(data.Years) == 0 {
+			fmt.Printf("    ⚠ %s: no data available\n", m.Title)
+			continue
+		}
 
-func (h *HugoGenerator) generateProficiencyData() (StatData, error) {
-	rows, err := h.db.Query(`
-		SELECT year, AVG(avg_score) as avg_score
-		FROM test_proficiency
-		WHERE subject = 'reading' AND grade = 8
-		GROUP BY year
-		ORDER BY year
-	`)
-	if err != nil {
-		return StatData{}, err
-	}
-	defer rows.Close()
+		if err := writeOutput(dir, m.Filename, schema.KindStat, version, statOutput(data, version)); err != nil {
+			return nil, err
+		}
 
-	var data StatData
-	data.Name = "Test Proficiency"
-	data.Description = "NAEP Reading scores (Grade 8)"
-	data.Source = "NAEP"
+		generated[m.ID] = data
+		fmt.Printf("    ✓ Generated %s (%d data points)\n", m.Filename, len(data.Years))
+	}
 
-	for rows.Next() {
-		var dp DataPoint
-		if err := rows.Scan(&dp.Year, &dp.Value); err != nil {
-			continue
+	if schema.Has(schema.KindCohorts, version) {
+		if err := h.generateCohorts(dir, generated); err != nil {
+			return nil, fmt.Errorf("failed to generate cohorts: %w", err)
 		}
-		data.Years = append(data.Years, dp)
+	} else {
+		fmt.Printf("    ⚠ cohorts.json and generations.json are not part of schema version %d; skipped\n", version)
 	}
-
-	return data, nil
-}
-
-func (h *HugoGenerator) generateEarlyChildhoodData() (StatData, error) {
-	rows, err := h.db.Query(`
-		SELECT year, AVG(metric_value) as avg_value
-		FROM early_childhood
-		GROUP BY year
-		ORDER BY year
-	`)
-	if err != nil {
-		return StatData{}, err
+	if schema.Has(schema.KindCorrelations, version) {
+		if err := h.generateCorrelations(dir, generated); err != nil {
+			return nil, fmt.Errorf("failed to generate correlations: %w", err)
+		}
 	}
-	defer rows.Close()
 
-	var data StatData
-	data.Name = "Early Childhood Metrics"
-	data.Description = "Early literacy and readiness indicators"
-	data.Source = "NCES ECLS"
+	// Generate stats index
+	if err := h.generateStatsIndex(dir, generated); err != nil {
+		return nil, fmt.Errorf("failed to generate stats index: %w", err)
+	}
 
-	for rows.Next() {
-		var dp DataPoint
-		if err := rows.Scan(&dp.Year, &dp.Value); err != nil {
-			continue
+	if len(h.Charts) > 0 {
+		if err := h.writeCharts(dir, generated); err != nil {
+			return nil, err
 		}
-		data.Years = append(data.Years, dp)
 	}
-
-	return data, nil
+	return generated, nil
 }
 
-func (h *HugoGenerator) generateStatsIndex(outputDir string) error {
-	type StatIndexEntry struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		Filename    string `json:"filename"`
-		Available   bool   `json:"available"`
-		YearMin     int    `json:"yearMin,omitempty"`
-		YearMax     int    `json:"yearMax,omitempty"`
-		DataPoints  int    `json:"dataPoints,omitempty"`
-	}
+// StatIndexEntry is one stat in index.json: its catalog entry plus what the
+// generated file holds.
+type StatIndexEntry struct {
+	Name          string            `json:"name"`
+	ShortName     string            `json:"shortName"`
+	Description   string            `json:"description"`
+	Unit          string            `json:"unit"`
+	UnitSuffix    string            `json:"unitSuffix"`
+	Source        string            `json:"source"`
+	Citation      string            `json:"citation"`
+	CohortOffset  int               `json:"cohortOffset"`
+	CohortLabel   string            `json:"cohortLabel"`
+	SearchWindow  int               `json:"searchWindow"`
+	DefaultSeries map[string]string `json:"defaultSeries"`
+	Filename      string            `json:"filename"`
+	Available     bool              `json:"available"`
+	YearMin       int               `json:"yearMin,omitempty"`
+	YearMax       int               `json:"yearMax,omitempty"`
+	DataPoints    int               `json:"dataPoints,omitempty"`
+	SeriesCount   int               `json:"seriesCount,omitempty"`
+}
 
+// generateStatsIndex writes index.json listing every catalog stat, with the
+// year range and size of those in generated.
+func (h *HugoGenerator) generateStatsIndex(outputDir string, generated map[string]StatData) error {
 	type IndexData struct {
-		Generated string                    `json:"generated"`
-		Stats     map[string]StatIndexEntry `json:"stats"`
+		SchemaVersion int                       `json:"schemaVersion"`
+		Generated     string                    `json:"generated"`
+		Stats         map[string]StatIndexEntry `json:"stats"`
 	}
 
+	date, err := h.GeneratedDate()
+	if err != nil {
+		return err
+	}
 	index := IndexData{
-		Generated: time.Now().Format("2006-01-02"),
+		Generated: date,
 		Stats:     make(map[string]StatIndexEntry),
 	}
 
-	// Check each stat file to see if it exists and has data
-	statsToCheck := []struct {
-		key         string
-		name        string
-		description string
-		filename    string
-	}{
-		{"literacy", "Literacy Rates", "Adult literacy and high school completion rates", "literacy.json"},
-		{"attainment", "Educational Attainment", "Educational attainment levels by degree", "attainment.json"},
-		{"graduation", "Graduation Rates", "High school graduation rates", "graduation.json"},
-		{"enrollment", "Enrollment Rates", "School enrollment rates by age group", "enrollment.json"},
-		{"proficiency", "Test Proficiency", "NAEP test proficiency scores", "proficiency.json"},
-		{"early_childhood", "Early Childhood", "Early childhood readiness metrics", "early_childhood.json"},
-	}
-
-	for _, stat := range statsToCheck {
+	for _, m := range catalog.Published {
 		entry := StatIndexEntry{
-			Name:        stat.name,
-			Description: stat.description,
-			Filename:    stat.filename,
-			Available:   false,
+			Name:          m.Title,
+			ShortName:     m.ShortTitle,
+			Description:   m.Description,
+			Unit:          m.Unit,
+			UnitSuffix:    m.UnitSuffix,
+			Source:        m.Source,
+			Citation:      m.Citation,
+			CohortOffset:  m.Cohort.Offset,
+			CohortLabel:   m.Cohort.Label,
+			SearchWindow:  m.Cohort.SearchWindow,
+			DefaultSeries: m.DefaultSeries,
+			Filename:      m.Filename,
+			Available:     false,
+		}
+		if selector, ok := h.DefaultSeries[m.ID]; ok {
+			entry.DefaultSeries = selector.Dimensions
 		}
 
-		// Check if file exists and read its metadata
-		filePath := filepath.Join(outputDir, stat.filename)
-		if fileData, err := os.ReadFile(filePath); err == nil {
-			var statData StatData
-			if err := json.Unmarshal(fileData, &statData); err == nil && len(statData.Years) > 0 {
-				entry.Available = true
-				entry.DataPoints = len(statData.Years)
-				
-				// Calculate year range
-				minYear := statData.Years[0].Year
-				maxYear := statData.Years[0].Year
-				for _, dp := range statData.Years {
-					if dp.Year < minYear {
-						minYear = dp.Year
-					}
-					if dp.Year > maxYear {
-						maxYear = dp.Year
-					}
+		if statData, ok := generated[m.ID]; ok && len(statData.Years) > 0 {
+			entry.Available = true
+			entry.DataPoints = len(statData.Years)
+			entry.SeriesCount = len(statData.Series)
+
+			// Calculate year range
+			minYear := statData.Years[0].Year
+			maxYear := statData.Years[0].Year
+			for _, dp := range statData.Years {
+				if dp.Year < minYear {
+					minYear = dp.Year
+				}
+				if dp.Year > maxYear {
+					maxYear = dp.Year
 				}
-				entry.YearMin = minYear
-				entry.YearMax = maxYear
 			}
+			entry.YearMin = minYear
+			entry.YearMax = maxYear
 		}
 
-		index.Stats[stat.key] = entry
+		index.Stats[m.ID] = entry
 	}
 
-	outputPath := filepath.Join(outputDir, "index.json")
-	file, err := os.Create(outputPath)
-	if err != nil {
+	version := h.schemaVersion()
+	index.SchemaVersion = version
+	var out interface{} = index
+	if version == 1 {
+		out = legacyIndex(index.Generated, index.Stats)
+	}
+	if err := writeOutput(outputDir, "index.json", schema.KindIndex, version, out); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(index); err != nil {
+	fmt.Printf("    ✓ Generated index.json (%d stats)\n", len(index.Stats))
+	return nil
+}
+
+// CohortsFile is the layout of cohorts.json: the life-stage offsets and
+// generations used, plus every birth year's measurement per stat.
+type CohortsFile struct {
+	SchemaVersion int                      `json:"schemaVersion"`
+	Stages        map[string]cohorts.Stage `json:"stages"`
+	Generations   []cohorts.Generation     `json:"generations"`
+	Cohorts       []cohorts.Cohort         `json:"cohorts"`
+}
+
+// cohortPoints converts a stat's data points for the cohorts package.
+func cohortPoints(data StatData) []cohorts.Point {
+	points := make([]cohorts.Point, len(data.Years))
+	for i, dp := range data.Years {
+		points[i] = cohorts.Point{Year: dp.Year, Value: dp.Value, Imputed: dp.Imputed, StandardError: dp.StandardError}
+	}
+	return points
+}
+
+// BuildCohorts maps each birth year from the first generation through the
+// latest observed year to the measurement of each stat at its life-stage
+// offset.
+func BuildCohorts(stats map[string]StatData) []cohorts.Cohort {
+	series := make(map[string][]cohorts.Point)
+	lastYear := 0
+	for key, data := range stats {
+		series[key] = cohortPoints(data)
+		for _, dp := range data.Years {
+			if dp.Year > lastYear {
+				lastYear = dp.Year
+			}
+		}
+	}
+	firstYear := cohorts.Generations[0].Start
+	if lastYear < firstYear {
+		lastYear = firstYear
+	}
+	return cohorts.Build(series, firstYear, lastYear)
+}
+
+// GenerationsFile is the layout of generations.json: each generation's
+// aggregate of every stat over its cohort years.
+type GenerationsFile struct {
+	SchemaVersion int                         `json:"schemaVersion"`
+	Stages        map[string]cohorts.Stage    `json:"stages"`
+	Generations   []cohorts.GenerationSummary `json:"generations"`
+}
+
+// generateCohorts writes cohorts.json with every birth year's measurement
+// per stat, and generations.json with those measurements aggregated by
+// generation.
+func (h *HugoGenerator) generateCohorts(outputDir string, generated map[string]StatData) error {
+	built := BuildCohorts(generated)
+	version := h.schemaVersion()
+	out := CohortsFile{
+		SchemaVersion: version,
+		Stages:        cohorts.Stages,
+		Generations:   cohorts.Generations,
+		Cohorts:       built,
+	}
+	if err := writeOutput(outputDir, "cohorts.json", schema.KindCohorts, version, out); err != nil {
 		return err
 	}
+	fmt.Printf("    ✓ Generated cohorts.json (%d birth years)\n", len(out.Cohorts))
 
-	fmt.Printf("    ✓ Generated index.json (%d stats)\n", len(index.Stats))
+	gens := GenerationsFile{
+		SchemaVersion: version,
+		Stages:        cohorts.Stages,
+		Generations:   cohorts.Summarize(built),
+	}
+	if err := writeOutput(outputDir, "generations.json", schema.KindGenerations, version, gens); err != nil {
+		return err
+	}
+	fmt.Printf("    ✓ Generated generations.json (%d generations)\n", len(gens.Generations))
 	return nil
 }
+
+func writeJSONFile(path string, v interface{}) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
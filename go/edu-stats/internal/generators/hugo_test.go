@@ -3,11 +3,18 @@ package generators
 import (
 	"database/sql"
 	"encoding/json"
+	"image/png"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/cohorts"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/schema"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -24,10 +31,14 @@ func setupGeneratorTestDB(t *testing.T) *sql.DB {
 	CREATE TABLE educational_attainment (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		year INTEGER NOT NULL,
+		age_group TEXT NOT NULL DEFAULT '25plus',
 		education_level TEXT NOT NULL,
 		percentage REAL,
+		gender TEXT,
+		race TEXT,
 		source TEXT NOT NULL,
-		UNIQUE(year, education_level, source)
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(year, age_group, education_level, gender, race, source)
 	);
 	CREATE TABLE literacy_rates (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -36,23 +47,31 @@ func setupGeneratorTestDB(t *testing.T) *sql.DB {
 		rate REAL,
 		gender TEXT,
 		source TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		UNIQUE(year, age_group, gender, source)
 	);
 	CREATE TABLE graduation_rates (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		year INTEGER NOT NULL,
 		rate REAL,
+		cohort_year INTEGER,
 		state TEXT,
+		demographics TEXT,
 		source TEXT NOT NULL,
-		UNIQUE(year, state, source)
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(year, cohort_year, state, demographics, source)
 	);
 	CREATE TABLE enrollment_rates (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		year INTEGER NOT NULL,
+		age_group TEXT NOT NULL,
 		enrollment_rate REAL,
+		level TEXT,
 		state TEXT,
+		demographics TEXT,
 		source TEXT NOT NULL,
-		UNIQUE(year, state, source)
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(year, age_group, level, state, demographics, source)
 	);
 	CREATE TABLE test_proficiency (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -60,15 +79,41 @@ func setupGeneratorTestDB(t *testing.T) *sql.DB {
 		subject TEXT NOT NULL,
 		grade INTEGER NOT NULL,
 		avg_score REAL,
+		proficiency_level TEXT,
+		percentage_proficient REAL,
+		state TEXT,
+		demographics TEXT,
+		standard_error REAL,
+		ci_low REAL,
+		ci_high REAL,
 		source TEXT NOT NULL,
-		UNIQUE(year, subject, grade, source)
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(year, subject, grade, proficiency_level, state, demographics, source)
 	);
 	CREATE TABLE early_childhood (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		year INTEGER NOT NULL,
+		cohort_year INTEGER,
+		metric_name TEXT NOT NULL,
 		metric_value REAL,
+		age_months INTEGER,
+		demographics TEXT,
 		source TEXT NOT NULL,
-		UNIQUE(year, source)
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(year, cohort_year, metric_name, age_months, demographics, source)
+	);
+	CREATE TABLE revision_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id TEXT NOT NULL,
+		source TEXT NOT NULL,
+		table_name TEXT NOT NULL,
+		year INTEGER NOT NULL,
+		series_key TEXT,
+		column_name TEXT NOT NULL,
+		old_value REAL,
+		new_value REAL,
+		change_type TEXT NOT NULL,
+		recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);`
 
 	if _, err := db.Exec(schema); err != nil {
@@ -134,9 +179,9 @@ func TestGenerateAttainmentData(t *testing.T) {
 	}
 
 	gen := &HugoGenerator{db: db}
-	data, err := gen.generateAttainmentData()
+	data, err := gen.generateStat(mustMetric(t, "attainment"))
 	if err != nil {
-		t.Fatalf("generateAttainmentData: %v", err)
+		t.Fatalf("generateStat(attainment): %v", err)
 	}
 
 	if len(data.Years) != 3 {
@@ -175,9 +220,9 @@ func TestGenerateProficiencyData(t *testing.T) {
 	}
 
 	gen := &HugoGenerator{db: db}
-	data, err := gen.generateProficiencyData()
+	data, err := gen.generateStat(mustMetric(t, "proficiency"))
 	if err != nil {
-		t.Fatalf("generateProficiencyData: %v", err)
+		t.Fatalf("generateStat(proficiency): %v", err)
 	}
 
 	if len(data.Years) != 3 {
@@ -188,19 +233,18 @@ func TestGenerateProficiencyData(t *testing.T) {
 func TestGenerateStatsIndexMarksUnavailable(t *testing.T) {
 	dir := t.TempDir()
 
-	// Write only attainment.json — other stat files absent
+	// Only attainment was generated — other stats have no data
 	attainment := StatData{
 		Name:   "Educational Attainment",
 		Source: "test",
 		Years:  []DataPoint{{Year: 2020, Value: 21.5}, {Year: 2021, Value: 22.0}},
 	}
-	writeJSON(t, filepath.Join(dir, "attainment.json"), attainment)
 
 	db := setupGeneratorTestDB(t)
 	defer db.Close()
 	gen := &HugoGenerator{db: db}
 
-	if err := gen.generateStatsIndex(dir); err != nil {
+	if err := gen.generateStatsIndex(dir, map[string]StatData{"attainment": attainment}); err != nil {
 		t.Fatalf("generateStatsIndex: %v", err)
 	}
 
@@ -211,8 +255,11 @@ func TestGenerateStatsIndexMarksUnavailable(t *testing.T) {
 
 	var idx struct {
 		Stats map[string]struct {
-			Available  bool `json:"available"`
-			DataPoints int  `json:"dataPoints"`
+			Available     bool              `json:"available"`
+			DataPoints    int               `json:"dataPoints"`
+			Unit          string            `json:"unit"`
+			CohortOffset  int               `json:"cohortOffset"`
+			DefaultSeries map[string]string `json:"defaultSeries"`
 		} `json:"stats"`
 	}
 	if err := json.Unmarshal(data, &idx); err != nil {
@@ -227,23 +274,1024 @@ func TestGenerateStatsIndexMarksUnavailable(t *testing.T) {
 		t.Errorf("attainment should have 2 data points, got %d", att.DataPoints)
 	}
 
+	if att.Unit != "percent" || att.CohortOffset != 26 || att.DefaultSeries["education_level"] != "bachelors_plus" {
+		t.Errorf("attainment should carry its catalog metadata, got %+v", att)
+	}
+
 	grad := idx.Stats["graduation"]
 	if grad.Available {
 		t.Error("graduation has no file, should be marked unavailable")
 	}
+	if len(idx.Stats) != len(catalog.Published) {
+		t.Errorf("index should list every catalog metric, got %d", len(idx.Stats))
+	}
+}
+
+// mustMetric returns the catalog entry for id.
+func mustMetric(t *testing.T, id string) catalog.Metric {
+	t.Helper()
+	m, ok := catalog.Get(id)
+	if !ok {
+		t.Fatalf("metric %s not in catalog", id)
+	}
+	return m
+}
+
+func TestResampleMethods(t *testing.T) {
+	points := []DataPoint{{Year: 2000, Value: 10}, {Year: 2004, Value: 18}, {Year: 2006, Value: 14}}
+
+	if got := Resample(points, ResampleNone); len(got) != 3 {
+		t.Errorf("none should leave 3 points, got %d", len(got))
+	}
+
+	linear := Resample(points, ResampleLinear)
+	if len(linear) != 7 {
+		t.Fatalf("linear: expected 7 annual points 2000-2006, got %d", len(linear))
+	}
+	if linear[1].Year != 2001 || linear[1].Value != 12 || !linear[1].Imputed || linear[1].Method != "linear" {
+		t.Errorf("linear 2001 = %+v, want imputed 12", linear[1])
+	}
+	if linear[4].Imputed {
+		t.Errorf("measured 2004 point should not be flagged imputed: %+v", linear[4])
+	}
+
+	locf := Resample(points, ResampleLOCF)
+	if locf[3].Value != 10 || locf[5].Value != 18 {
+		t.Errorf("locf should carry values forward, got 2003=%v 2005=%v", locf[3].Value, locf[5].Value)
+	}
+
+	spline := Resample(points, ResampleSpline)
+	if len(spline) != 7 {
+		t.Fatalf("spline: expected 7 points, got %d", len(spline))
+	}
+	for _, p := range spline {
+		if p.Year == 2004 && p.Value != 18 {
+			t.Errorf("spline must pass through measured points, 2004 = %v", p.Value)
+		}
+	}
+}
+
+func TestResampleCollapsesDuplicateYears(t *testing.T) {
+	points := []DataPoint{
+		{Year: 2000, Value: 10}, {Year: 2002, Value: 99}, {Year: 2002, Value: 14},
+		{Year: 2004, Value: 18}, {Year: 2006, Value: 14},
+	}
+	for _, method := range []ResampleMethod{ResampleLinear, ResampleLOCF, ResampleSpline} {
+		got := Resample(points, method)
+		if len(got) != 7 {
+			t.Fatalf("%s: expected 7 annual points 2000-2006, got %d: %+v", method, len(got), got)
+		}
+		for i, p := range got {
+			if p.Year != 2000+i || math.IsNaN(p.Value) {
+				t.Errorf("%s: point %d = %+v", method, i, p)
+			}
+		}
+		if got[2].Value != 14 || got[2].Imputed {
+			t.Errorf("%s: 2002 = %+v, want the last measured value 14", method, got[2])
+		}
+	}
+}
+
+func TestParseResampleMethod(t *testing.T) {
+	if _, err := ParseResampleMethod("cubic"); err == nil {
+		t.Error("expected error for unknown method")
+	}
+	if m, err := ParseResampleMethod(""); err != nil || m != ResampleNone {
+		t.Errorf("empty method = %q, %v; want none", m, err)
+	}
+}
+
+func TestGenerateCohortsFile(t *testing.T) {
+	db := setupGeneratorTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO test_proficiency (year, subject, grade, avg_score, source)
+		VALUES (1971, 'reading', 8, 255.0, 'naep'), (1984, 'reading', 8, 257.0, 'naep')
+	`)
+	if err != nil {
+		t.Fatalf("insert test data: %v", err)
+	}
+
+	dir := t.TempDir()
+	gen := &HugoGenerator{db: db}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("generateToDir: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "cohorts.json"))
+	if err != nil {
+		t.Fatalf("cohorts.json not written: %v", err)
+	}
+	var out CohortsFile
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("parse cohorts.json: %v", err)
+	}
+	if out.Stages["proficiency"].Offset != 14 {
+		t.Errorf("proficiency offset = %d, want 14", out.Stages["proficiency"].Offset)
+	}
+
+	for _, c := range out.Cohorts {
+		if c.BirthYear != 1970 {
+			continue
+		}
+		m, ok := c.Stats["proficiency"]
+		if !ok || m.Year != 1984 || m.Value != 257 || c.Generation != "x" {
+			t.Errorf("1970 cohort = %+v", c)
+		}
+		break
+	}
+	if len(out.Cohorts) == 0 || out.Cohorts[0].BirthYear > 1970 {
+		t.Error("cohorts.json has no 1970 birth year")
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, "generations.json"))
+	if err != nil {
+		t.Fatalf("generations.json not written: %v", err)
+	}
+	var gens GenerationsFile
+	if err := json.Unmarshal(data, &gens); err != nil {
+		t.Fatalf("parse generations.json: %v", err)
+	}
+	for _, g := range gens.Generations {
+		if g.Key != "x" {
+			continue
+		}
+		s := g.Stats["proficiency"]
+		if s.Observations != 1 || s.Mean != 257 || s.Slope != nil {
+			t.Errorf("Gen X proficiency = %+v", s)
+		}
+		return
+	}
+	t.Error("generations.json has no Gen X summary")
+}
+
+func TestGenerateSeriesWithoutAveraging(t *testing.T) {
+	db := setupGeneratorTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO enrollment_rates (year, age_group, enrollment_rate, state, source) VALUES
+		(2000, '5_to_17', 93.0, NULL, 'nces'), (2010, '5_to_17', 94.0, NULL, 'nces'),
+		(2000, '18_to_24', 35.0, NULL, 'nces'), (2010, '18_to_24', 41.0, NULL, 'nces'),
+		(2010, '5_to_17', 96.0, 'OR', 'district')
+	`)
+	if err != nil {
+		t.Fatalf("insert test data: %v", err)
+	}
+
+	gen := &HugoGenerator{db: db}
+	data, err := gen.generateStat(mustMetric(t, "enrollment"))
+	if err != nil {
+		t.Fatalf("generateStat(enrollment): %v", err)
+	}
+	if len(data.Series) != 3 {
+		t.Fatalf("expected 3 series, got %+v", data.Series)
+	}
+	if data.DefaultSeries != "nces;age_group=5_to_17" {
+		t.Errorf("defaultSeries = %q", data.DefaultSeries)
+	}
+	if len(data.Years) != 2 || data.Years[1].Value != 94.0 {
+		t.Errorf("headline should be the 5-17 national series unaveraged, got %+v", data.Years)
+	}
+	for _, s := range data.Series {
+		if s.Source == "district" && (s.Dimensions["state"] != "OR" || s.Dimensions["age_group"] != "5_to_17") {
+			t.Errorf("district series dimensions = %v", s.Dimensions)
+		}
+	}
+
+	gen.DefaultSeries = map[string]SeriesSelector{
+		"enrollment": {Dimensions: map[string]string{"age_group": "18_to_24"}},
+	}
+	data, err = gen.generateStat(mustMetric(t, "enrollment"))
+	if err != nil {
+		t.Fatalf("generateStat(enrollment): %v", err)
+	}
+	if len(data.Years) != 2 || data.Years[0].Value != 35.0 {
+		t.Errorf("override should select 18-24, got %+v", data.Years)
+	}
+
+	gen.DefaultSeries["enrollment"] = SeriesSelector{Dimensions: map[string]string{"age_group": "25_plus"}}
+	data, err = gen.generateStat(mustMetric(t, "enrollment"))
+	if err != nil {
+		t.Fatalf("generateStat(enrollment): %v", err)
+	}
+	if len(data.Years) != 0 || len(data.Series) != 3 {
+		t.Errorf("unmatched default should leave the headline empty, got %d points", len(data.Years))
+	}
+}
+
+func TestParseSeriesSelector(t *testing.T) {
+	stat, sel, err := ParseSeriesSelector("proficiency:subject=mathematics,grade=4,source=naep")
+	if err != nil {
+		t.Fatalf("ParseSeriesSelector: %v", err)
+	}
+	if stat != "proficiency" || sel.Source != "naep" || sel.Dimensions["subject"] != "mathematics" || sel.Dimensions["grade"] != "4" {
+		t.Errorf("got %s %+v", stat, sel)
+	}
+
+	if _, sel, err := ParseSeriesSelector("graduation:"); err != nil || len(sel.Dimensions) != 0 {
+		t.Errorf("empty selector: %+v, %v", sel, err)
+	}
+	for _, bad := range []string{"proficiency", "nope:grade=4", "proficiency:grade"} {
+		if _, _, err := ParseSeriesSelector(bad); err == nil {
+			t.Errorf("ParseSeriesSelector(%q) should fail", bad)
+		}
+	}
+}
+
+func TestGenerateSchemaVersions(t *testing.T) {
+	db := setupGeneratorTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO test_proficiency (year, subject, grade, avg_score, source)
+		VALUES (1971, 'reading', 8, 255.0, 'naep'), (1984, 'reading', 8, 257.0, 'naep')
+	`)
+	if err != nil {
+		t.Fatalf("insert test data: %v", err)
+	}
+
+	current := t.TempDir()
+	if err := (&HugoGenerator{db: db}).generateToDir(current); err != nil {
+		t.Fatalf("generateToDir: %v", err)
+	}
+	var stat map[string]interface{}
+	data, err := os.ReadFile(filepath.Join(current, "proficiency.json"))
+	if err != nil {
+		t.Fatalf("read proficiency.json: %v", err)
+	}
+	if err := json.Unmarshal(data, &stat); err != nil {
+		t.Fatalf("parse proficiency.json: %v", err)
+	}
+	if stat["schemaVersion"] != float64(schema.Current) {
+		t.Errorf("schemaVersion = %v, want %d", stat["schemaVersion"], schema.Current)
+	}
+	if _, err := os.Stat(filepath.Join(current, SchemaDir, "stat.v2.schema.json")); err != nil {
+		t.Errorf("schemas not published: %v", err)
+	}
+
+	legacy := t.TempDir()
+	if err := (&HugoGenerator{db: db, SchemaVersion: 1}).generateToDir(legacy); err != nil {
+		t.Fatalf("generateToDir (v1): %v", err)
+	}
+	for file, kind := range map[string]string{"proficiency.json": schema.KindStat, "index.json": schema.KindIndex} {
+		data, err := os.ReadFile(filepath.Join(legacy, file))
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		if err := schema.Validate(kind, 1, data); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(legacy, "cohorts.json")); !os.IsNotExist(err) {
+		t.Error("version 1 should not write cohorts.json")
+	}
+
+	if err := (&HugoGenerator{db: db, SchemaVersion: 9}).generateToDir(t.TempDir()); err == nil {
+		t.Error("expected an error for an unsupported schema version")
+	}
 }
 
-// writeJSON is a test helper that writes v as JSON to path.
-func writeJSON(t *testing.T, path string, v interface{}) {
+func TestGenerateIsAtomicAndReproducible(t *testing.T) {
+	db := setupGeneratorTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO test_proficiency (year, subject, grade, avg_score, source)
+		VALUES (1971, 'reading', 8, 255.0, 'naep'), (1984, 'reading', 8, 257.0, 'naep');
+		INSERT INTO graduation_rates (year, rate, source) VALUES (2010, 78.2, 'nces');
+	`)
+	if err != nil {
+		t.Fatalf("insert test data: %v", err)
+	}
+
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "data")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{".gitkeep", "stale.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gen := &HugoGenerator{db: db}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("generateToDir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stale.json")); !os.IsNotExist(err) {
+		t.Error("files from an earlier run should be removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".gitkeep")); err != nil {
+		t.Error(".gitkeep should be kept")
+	}
+	if entries, _ := os.ReadDir(parent); len(entries) != 1 {
+		t.Errorf("staging directories left behind: %v", entries)
+	}
+
+	first := readTree(t, dir)
+	delete(first, ".gitkeep")
+	again := filepath.Join(t.TempDir(), "data")
+	if err := gen.generateToDir(again); err != nil {
+		t.Fatalf("generateToDir again: %v", err)
+	}
+	second := readTree(t, again)
+	if len(first) != len(second) {
+		t.Fatalf("second run wrote %d files, first %d", len(second), len(first))
+	}
+	for name, data := range first {
+		if string(second[name]) != string(data) {
+			t.Errorf("%s differs between runs of the same database", name)
+		}
+	}
+
+	checks, err := CheckOutput(dir)
+	if err != nil {
+		t.Fatalf("CheckOutput: %v", err)
+	}
+	for _, c := range checks {
+		if c.Err != nil {
+			t.Errorf("%s: %v", c.File, c.Err)
+		}
+	}
+
+	// A stat that loses its data disappears rather than keeping its old file.
+	if _, err := db.Exec(`DELETE FROM graduation_rates`); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("generateToDir after delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "graduation.json")); !os.IsNotExist(err) {
+		t.Error("graduation.json should be removed once graduation has no data")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "proficiency.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyManifest(dir, readTree(t, dir)["manifest.json"]); err == nil || !strings.Contains(err.Error(), "proficiency.json has changed") {
+		t.Errorf("expected a changed-file error, got %v", err)
+	}
+}
+
+// readTree returns every file under dir keyed by its relative path.
+func TestSwapDir(t *testing.T) {
+	parent := t.TempDir()
+	dst := filepath.Join(parent, "data")
+	write := func(dir, body string) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "a.json"), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func() string {
+		data, _ := os.ReadFile(filepath.Join(dst, "a.json"))
+		return string(data)
+	}
+
+	write(dst, "old")
+	stage := filepath.Join(parent, ".stage")
+	write(stage, "new")
+	if err := swapDir(stage, dst); err != nil {
+		t.Fatalf("swapDir: %v", err)
+	}
+	if read() != "new" {
+		t.Errorf("dst holds %q after the swap", read())
+	}
+	if entries, _ := os.ReadDir(parent); len(entries) != 1 {
+		t.Errorf("swap left %v behind", entries)
+	}
+
+	// A backup left by a failed restore is never overwritten.
+	backup := filepath.Join(parent, ".data.old")
+	write(backup, "older")
+	write(stage, "newer")
+	err := swapDir(stage, dst)
+	if err == nil || !strings.Contains(err.Error(), backup) {
+		t.Errorf("swap over a leftover backup: err = %v, want one naming %s", err, backup)
+	}
+	if read() != "new" {
+		t.Errorf("a refused swap changed dst to %q", read())
+	}
+	if data, _ := os.ReadFile(filepath.Join(backup, "a.json")); string(data) != "older" {
+		t.Errorf("the leftover backup was modified: %q", data)
+	}
+}
+
+func readTree(t *testing.T, dir string) map[string][]byte {
 	t.Helper()
-	f, err := os.Create(path)
+	files := make(map[string][]byte)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		files[rel] = data
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("read %s: %v", dir, err)
+	}
+	return files
+}
+
+func TestGenerateOnlyRewritesChangedStats(t *testing.T) {
+	db := setupGeneratorTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE data_versions (table_name TEXT PRIMARY KEY, version INTEGER NOT NULL DEFAULT 0, changed_at DATETIME);
+		CREATE TABLE generation_state (output_dir TEXT NOT NULL, table_name TEXT NOT NULL, data_version INTEGER NOT NULL,
+			settings TEXT NOT NULL, run_id TEXT NOT NULL, generated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (output_dir, table_name));
+	`)
+	if err != nil {
+		t.Fatalf("create version tables: %v", err)
+	}
+	if err := database.TrackDataVersions(db); err != nil {
+		t.Fatalf("TrackDataVersions: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO test_proficiency (year, subject, grade, avg_score, source)
+		VALUES (1971, 'reading', 8, 255.0, 'naep'), (1984, 'reading', 8, 257.0, 'naep');
+		INSERT INTO graduation_rates (year, rate, source) VALUES (2010, 78.2, 'nces');
+	`)
+	if err != nil {
+		t.Fatalf("insert test data: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "data")
+	gen := &HugoGenerator{db: db}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	first := readTree(t, dir)
+
+	// Nothing changed: the directory is left exactly as it was.
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("unchanged run: %v", err)
+	}
+	if second := readTree(t, dir); string(second["manifest.json"]) != string(first["manifest.json"]) {
+		t.Error("an unchanged database should not rewrite the output")
+	}
+
+	// Change proficiency behind the triggers' back, then change graduation.
+	// Only graduation is re-queried; proficiency is copied from the last run.
+	if _, err := db.Exec(`UPDATE test_proficiency SET avg_score = 300 WHERE year = 1984`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE data_versions SET version = version - 1 WHERE table_name = 'test_proficiency'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO graduation_rates (year, rate, source) VALUES (2011, 79.0, 'nces')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("incremental run: %v", err)
+	}
+	third := readTree(t, dir)
+	if string(third["proficiency.json"]) != string(first["proficiency.json"]) {
+		t.Error("proficiency.json should be reused when test_proficiency is unchanged")
+	}
+	if string(third["graduation.json"]) == string(first["graduation.json"]) {
+		t.Error("graduation.json should be regenerated after graduation_rates changed")
+	}
+	var idx struct {
+		Stats map[string]StatIndexEntry `json:"stats"`
+	}
+	if err := json.Unmarshal(third["index.json"], &idx); err != nil {
+		t.Fatalf("parse index.json: %v", err)
+	}
+	if g := idx.Stats["graduation"]; g.YearMax != 2011 || g.DataPoints != 2 {
+		t.Errorf("index graduation = %+v, want 2 points through 2011", g)
+	}
+	if p := idx.Stats["proficiency"]; !p.Available || p.YearMax != 1984 {
+		t.Errorf("index proficiency = %+v, want the reused file's range", p)
+	}
+
+	// --full re-queries everything.
+	full := &HugoGenerator{db: db, Full: true}
+	if err := full.generateToDir(dir); err != nil {
+		t.Fatalf("full run: %v", err)
+	}
+	if !strings.Contains(string(readTree(t, dir)["proficiency.json"]), "300") {
+		t.Error("a full run should pick up the proficiency change")
+	}
+}
+
+func TestGenerateKeepsLastGoodFileOfFailedStat(t *testing.T) {
+	db := setupGeneratorTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE data_versions (table_name TEXT PRIMARY KEY, version INTEGER NOT NULL DEFAULT 0, changed_at DATETIME);
+		CREATE TABLE generation_state (output_dir TEXT NOT NULL, table_name TEXT NOT NULL, data_version INTEGER NOT NULL,
+			settings TEXT NOT NULL, run_id TEXT NOT NULL, generated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (output_dir, table_name));
+	`)
+	if err != nil {
+		t.Fatalf("create version tables: %v", err)
+	}
+	if err := database.TrackDataVersions(db); err != nil {
+		t.Fatalf("TrackDataVersions: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO graduation_rates (year, rate, source) VALUES (2010, 78.2, 'nces')`); err != nil {
+		t.Fatalf("insert test data: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "data")
+	gen := &HugoGenerator{db: db}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	first := readTree(t, dir)
+
+	// graduation changes, then can no longer be queried.
+	if _, err := db.Exec(`INSERT INTO graduation_rates (year, rate, source) VALUES (2011, 79.0, 'nces')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`ALTER TABLE graduation_rates RENAME COLUMN rate TO rate_old`); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("run with a failing stat: %v", err)
+	}
+	if got := readTree(t, dir)["graduation.json"]; string(got) != string(first["graduation.json"]) {
+		t.Errorf("graduation.json should keep the last good copy, got %s", got)
+	}
+
+	// A full run was not built from that output, so it refuses to swap
+	// rather than publish without graduation.
+	before := readTree(t, dir)
+	full := &HugoGenerator{db: db, Full: true}
+	if err := full.generateToDir(dir); err == nil || !strings.Contains(err.Error(), "cannot be kept") {
+		t.Errorf("full run with a failing stat: err = %v, want it to abort", err)
+	}
+	if after := readTree(t, dir); string(after["manifest.json"]) != string(before["manifest.json"]) {
+		t.Error("an aborted run should leave the output untouched")
+	}
+}
+
+func TestGenerateCharts(t *testing.T) {
+	db := setupGeneratorTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO graduation_rates (year, rate, source)
+		VALUES (2009, 75.5, 'nces'), (2010, 78.2, 'nces'), (2011, 79.0, 'nces'), (2012, 80.0, 'nces');
+	`)
+	if err != nil {
+		t.Fatalf("insert test data: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "data")
+	gen := &HugoGenerator{db: db, Charts: []string{ChartSVG, ChartPNG}}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("generateToDir: %v", err)
+	}
+	files := readTree(t, dir)
+
+	svg := string(files[filepath.Join(ChartDir, "graduation.svg")])
+	for _, want := range []string{
+		"<title>High School Graduation Rates</title>",
+		"2011: 4-year ACGR replaces AFGR",
+		`stroke-dasharray="8 6"`,
+		"Source: National Center for Education Statistics",
+		"80.0%",
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("graduation.svg is missing %q", want)
+		}
+	}
+	// The series is not joined across the 2011 break: one line each side.
+	if n := strings.Count(svg, `stroke="`+colorLine+`" stroke-width="3"`); n != 2 {
+		t.Errorf("graduation.svg has %d series lines, want 2", n)
+	}
+
+	img, err := png.Decode(strings.NewReader(string(files[filepath.Join(ChartDir, "graduation.png")])))
+	if err != nil {
+		t.Fatalf("graduation.png: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != chartWidth || b.Dy() != chartHeight {
+		t.Errorf("graduation.png is %v, want %dx%d", b, chartWidth, chartHeight)
+	}
+	if _, ok := files[filepath.Join(ChartDir, "generations-graduation.svg")]; !ok {
+		t.Error("generations-graduation.svg not written")
+	}
+	if _, ok := files[filepath.Join(ChartDir, "proficiency.svg")]; ok {
+		t.Error("a stat without data should have no chart")
+	}
+	if !strings.Contains(string(files["manifest.json"]), `"path": "charts/graduation.png"`) {
+		t.Error("manifest.json should list the charts")
+	}
+
+	// Charts are part of the settings, so turning them off regenerates.
+	gen.Charts = nil
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("generateToDir without charts: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ChartDir)); !os.IsNotExist(err) {
+		t.Error("charts should be removed when no longer requested")
+	}
+}
+
+func TestChartsMarkSourceBreaks(t *testing.T) {
+	db := setupGeneratorTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO test_proficiency (year, subject, grade, avg_score, source)
+		VALUES (1994, 'reading', 8, 260, 'naep'), (1999, 'reading', 8, 259, 'naep'),
+		       (2002, 'reading', 8, 264, 'naep'), (2005, 'reading', 8, 262, 'naep');
+		INSERT INTO educational_attainment (year, education_level, percentage, source)
+		VALUES (2008, 'bachelors_plus', 29.4, 'census'), (2009, 'bachelors_plus', 29.5, 'census'),
+		       (2010, 'bachelors_plus', 28.5, 'census'), (2011, 'bachelors_plus', 28.8, 'census');
+	`)
+	if err != nil {
+		t.Fatalf("insert test data: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "data")
+	gen := &HugoGenerator{db: db, Charts: []string{ChartSVG}}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("generateToDir: %v", err)
+	}
+	files := readTree(t, dir)
+
+	for stat, label := range map[string]string{
+		"proficiency": "2002: Main NAEP Grade 8 replaces Long-Term Trend Age 13",
+		"attainment":  "2010: ACS 1-year estimates replace CPS",
+	} {
+		svg := string(files[filepath.Join(ChartDir, stat+".svg")])
+		if !strings.Contains(svg, label) || !strings.Contains(svg, `stroke-dasharray="8 6"`) {
+			t.Errorf("%s.svg does not mark the break %q", stat, label)
+		}
+		if n := strings.Count(svg, `stroke="`+colorLine+`" stroke-width="3"`); n != 2 {
+			t.Errorf("%s.svg has %d series lines, want one each side of the break", stat, n)
+		}
+	}
+}
+
+func TestParseChartFormats(t *testing.T) {
+	for in, want := range map[string]string{"svg": "svg", "png,SVG": "svg,png", "": "", "svg,svg": "svg"} {
+		got, err := ParseChartFormats(in)
+		if err != nil || strings.Join(got, ",") != want {
+			t.Errorf("ParseChartFormats(%q) = %v, %v; want %s", in, got, err, want)
+		}
+	}
+	if _, err := ParseChartFormats("gif"); err == nil {
+		t.Error("expected an error for gif")
+	}
+}
+
+func TestComparisonChart(t *testing.T) {
+	a := cohorts.Cohort{BirthYear: 1965, Stats: map[string]cohorts.Measurement{
+		"graduation": {Year: 1983, Value: 70.5},
+		"literacy":   {Year: 1985, Value: 99.0},
+	}}
+	b := cohorts.Cohort{BirthYear: 1995, Stats: map[string]cohorts.Measurement{
+		"graduation": {Year: 2013, Value: 81.4, Imputed: true},
+	}}
+	svg := string(ComparisonChart([2]int{1965, 1995}, cohorts.Compare(a, b, nil)).SVG())
+	for _, want := range []string{"Born 1965 vs born 1995", "HS Graduation", "+10.9%", "81.4% (2013) est.", "n/a"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("comparison chart is missing %q", want)
+		}
+	}
+}
+
+func TestGenerateContentPages(t *testing.T) {
+	db := setupGeneratorTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO graduation_rates (year, rate, source)
+		VALUES (1973, 70.0, 'nces'), (1985, 72.0, 'nces'), (2003, 74.0, 'nces'), (2010, 78.0, 'nces'), (2015, 83.0, 'nces');
+	`)
+	if err != nil {
+		t.Fatalf("insert test data: %v", err)
+	}
+
+	site := t.TempDir()
+	dir := filepath.Join(site, "static", "data")
+	content := ContentDir(dir)
+	if content != filepath.Join(site, "content") {
+		t.Fatalf("ContentDir = %s, want the site's content directory", content)
+	}
+	// A hand-written section beside the generated ones is left alone.
+	if err := os.MkdirAll(filepath.Join(content, "about"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(content, "about", "_index.md"), []byte("---\ntitle: About\n---\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gen := &HugoGenerator{db: db, contentDir: content}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("generateToDir: %v", err)
+	}
+	files := readTree(t, content)
+
+	stat := string(files[filepath.Join(StatsSection, "graduation.md")])
+	for _, want := range []string{
+		"title: \"High School Graduation Rates\"\n",
+		"yearMin: 1973\n",
+		"yearMax: 2015\n",
+		"latestValue: 83\n",
+		"latestLabel: \"83.0%\"\n",
+		"dataFile: \"/data/graduation.json\"\n",
+		"- **2011:** 4-year ACGR replaces AFGR",
+		"| 1985 | 72.0% |",
+	} {
+		if !strings.Contains(stat, want) {
+			t.Errorf("stats/graduation.md is missing %q", want)
+		}
+	}
+	if _, ok := files[filepath.Join(StatsSection, "proficiency.md")]; ok {
+		t.Error("a stat without data should have no page")
+	}
+
+	// Born 1985: graduation at ~18 is 2003, compared with born 1955 (1973).
+	cohort := string(files[filepath.Join(CohortsSection, "1985.md")])
+	for _, want := range []string{
+		"title: \"Born in 1985\"\n",
+		"generationKey: \"millennial\"\n",
+		"comparedWith: 1955\n",
+		"Generation: [Millennial](../../generations/millennial/), born 1981–1996.",
+		"| 74.0% (2003) | 70.0% (1973) | +4.0 percentage points (+5.7%) |",
+	} {
+		if !strings.Contains(cohort, want) {
+			t.Errorf("cohorts/1985.md is missing %q:\n%s", want, cohort)
+		}
+	}
+	if _, ok := files[filepath.Join(CohortsSection, "1930.md")]; ok {
+		t.Error("a birth year without measurements should have no page")
+	}
+
+	gens := string(files[filepath.Join(GenerationsSection, "millennial.md")])
+	if !strings.Contains(gens, "vs Gen X |") || !strings.Contains(gens, "[1985](../../cohorts/1985/)") {
+		t.Errorf("generations/millennial.md should compare with Gen X and link its birth years:\n%s", gens)
+	}
+	for _, section := range contentSections {
+		if _, ok := files[filepath.Join(section, "_index.md")]; !ok {
+			t.Errorf("%s/_index.md not written", section)
+		}
+	}
+	if _, ok := files[filepath.Join("about", "_index.md")]; !ok {
+		t.Error("hand-written content should be kept")
+	}
+	entries, _ := os.ReadDir(content)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			t.Errorf("staging directory %s left behind", e.Name())
+		}
+	}
+
+	// A removed section is rewritten even when the data is unchanged.
+	if err := os.RemoveAll(filepath.Join(content, CohortsSection)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("second generateToDir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(content, CohortsSection, "1985.md")); err != nil {
+		t.Errorf("cohorts section not restored: %v", err)
+	}
+}
+
+func TestContentPagesAnnotateSeriesBreaks(t *testing.T) {
+	db := setupGeneratorTestDB(t)
+	defer db.Close()
+
+	// Long-Term Trend to 1999, Main NAEP from 2002.
+	_, err := db.Exec(`
+		INSERT INTO test_proficiency (year, subject, grade, avg_score, source)
+		VALUES (1971, 'reading', 8, 250, 'naep'), (1975, 'reading', 8, 255, 'naep'), (1980, 'reading', 8, 258, 'naep'),
+		       (1999, 'reading', 8, 259, 'naep'), (2005, 'reading', 8, 262, 'naep'), (2015, 'reading', 8, 265, 'naep');
+	`)
+	if err != nil {
+		t.Fatalf("insert test data: %v", err)
+	}
+
+	site := t.TempDir()
+	dir := filepath.Join(site, "static", "data")
+	gen := &HugoGenerator{db: db, contentDir: ContentDir(dir)}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("generateToDir: %v", err)
+	}
+	files := readTree(t, ContentDir(dir))
+
+	for _, tc := range []struct {
+		page, want string
+	}{
+		// Born 1990 is read in 2005 (Main NAEP), born 1960 in 1975 (LTT).
+		{"1990.md", "| 262.0 pts (2005) | 255.0 pts (1975) | +7.0 points (+2.7%), across the 2002 series break |"},
+		// Born 1985 (1999) and 1955 (1971) are both LTT.
+		{"1985.md", "| 259.0 pts (1999) | 250.0 pts (1971) | +9.0 points (+3.6%) |"},
+	} {
+		if page := string(files[filepath.Join(CohortsSection, tc.page)]); !strings.Contains(page, tc.want) {
+			t.Errorf("cohorts/%s is missing %q:\n%s", tc.page, tc.want, page)
+		}
+	}
+
+	// Millennials were read in 1999 and 2005, Gen X and Boomers before 2002.
+	millennial := string(files[filepath.Join(GenerationsSection, "millennial.md")])
+	if !strings.Contains(millennial, "across the 2002 series break |") {
+		t.Errorf("generations/millennial.md should flag the comparison with Gen X:\n%s", millennial)
+	}
+	genX := string(files[filepath.Join(GenerationsSection, "x.md")])
+	if !strings.Contains(genX, "vs Boomer |") || strings.Contains(genX, "series break") {
+		t.Errorf("generations/x.md compares LTT years only and needs no note:\n%s", genX)
+	}
+}
+
+func TestGenerateAnalytics(t *testing.T) {
+	db := setupGeneratorTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO graduation_rates (year, rate, source)
+		VALUES (2000, 70.0, 'nces'), (2002, 72.0, 'nces'), (2004, 74.0, 'nces'), (2006, 76.0, 'nces');
+	`)
+	if err != nil {
+		t.Fatalf("insert test data: %v", err)
+	}
+
+	dir := t.TempDir()
+	gen := &HugoGenerator{db: db, Analytics: true, Resample: ResampleLinear}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("generateToDir: %v", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "graduation.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data StatData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatal(err)
+	}
+	a := data.Analysis
+	if a == nil {
+		t.Fatal("graduation.json has no analysis")
+	}
+	// Resampled years are left out of the analysis.
+	if a.Points != 4 || a.Trend == nil || a.Trend.Slope != 1 || a.CAGR == nil {
+		t.Errorf("analysis = %+v, want 4 measured points rising 1 point a year", a)
+	}
+	if len(data.Series) == 0 || data.Series[0].Analysis == nil {
+		t.Error("each series should carry its own analysis")
+	}
+	if err := schema.Validate(schema.KindStat, schema.Current, raw); err != nil {
+		t.Errorf("graduation.json does not match its schema: %v", err)
+	}
+
+	gen.Analytics = false
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("generateToDir without analytics: %v", err)
+	}
+	if raw, _ := os.ReadFile(filepath.Join(dir, "graduation.json")); strings.Contains(string(raw), `"analysis"`) {
+		t.Error("analysis should only be written with Analytics set")
+	}
+}
+
+func TestGenerateCorrelations(t *testing.T) {
+	db := setupGeneratorTestDB(t)
+	defer db.Close()
+
+	// Graduation (age ~18) rises with enrollment (age ~10) eight years
+	// earlier, so they line up by birth cohort but barely by year.
+	for i := 0; i < 8; i++ {
+		if _, err := db.Exec(`INSERT INTO graduation_rates (year, rate, source) VALUES (?, ?, 'nces')`, 2008+i, 70+float64(i*i)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(`INSERT INTO enrollment_rates (year, age_group, enrollment_rate, source) VALUES (?, '5_to_17', ?, 'nces')`, 2000+i, 90+float64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	gen := &HugoGenerator{db: db}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("generateToDir: %v", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "correlations.json"))
+	if err != nil {
+		t.Fatalf("correlations.json not written: %v", err)
+	}
+	var file CorrelationsFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.SchemaVersion != schema.Current || len(file.Stats) != 2 || len(file.Pairs) != 2 {
+		t.Fatalf("correlations.json = %+v, want one pair per alignment", file)
+	}
+	for _, p := range file.Pairs {
+		if p.A != "graduation" || p.B != "enrollment" {
+			t.Errorf("pair %s/%s should follow catalog order", p.A, p.B)
+		}
+		switch p.Alignment {
+		case AlignYear:
+			if p.Overlap != 0 || !p.LowOverlap || p.Pearson != nil {
+				t.Errorf("by year = %+v, want no overlap", p.Correlation)
+			}
+		case AlignCohort:
+			if p.Overlap != 8 || p.Spearman == nil || *p.Spearman != 1 {
+				t.Errorf("by cohort = %+v, want 8 birth years in perfect rank order", p.Correlation)
+			}
+		}
+	}
+	checks, err := CheckOutput(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range checks {
+		if c.File == "correlations.json" && c.Err != nil {
+			t.Errorf("check-output: %v", c.Err)
+		}
+	}
+}
+
+func TestGenerateUncertainty(t *testing.T) {
+	db := setupGeneratorTestDB(t)
+	defer db.Close()
+
+	// Only test_proficiency has the uncertainty columns here; the other
+	// tables stand in for a database that predates them.
+	_, err := db.Exec(`
+		INSERT INTO test_proficiency (year, subject, grade, avg_score, standard_error, ci_low, ci_high, source)
+		VALUES (1984, 'reading', 8, 257.0, 0.5, 256.0, 258.0, 'naep'),
+		       (1988, 'reading', 8, 258.0, NULL, NULL, NULL, 'naep'),
+		       (1992, 'reading', 8, 260.0, 1.2, 257.6, 262.4, 'naep');
+		INSERT INTO graduation_rates (year, rate, source) VALUES (2010, 78.2, 'nces'), (2012, 80.0, 'nces');
+	`)
+	if err != nil {
+		t.Fatalf("insert test data: %v", err)
+	}
+
+	dir := t.TempDir()
+	gen := &HugoGenerator{db: db, Resample: ResampleLinear, Charts: []string{ChartSVG}}
+	if err := gen.generateToDir(dir); err != nil {
+		t.Fatalf("generateToDir: %v", err)
+	}
+	files := readTree(t, dir)
+
+	var prof StatData
+	if err := json.Unmarshal(files["proficiency.json"], &prof); err != nil {
+		t.Fatal(err)
+	}
+	byYear := make(map[int]DataPoint)
+	for _, dp := range prof.Years {
+		byYear[dp.Year] = dp
+	}
+	if dp := byYear[1992]; dp.StandardError == nil || *dp.StandardError != 1.2 || *dp.CILow != 257.6 || *dp.CIHigh != 262.4 {
+		t.Errorf("1992 = %+v, want its standard error and interval", dp)
+	}
+	if dp := byYear[1988]; dp.StandardError != nil || dp.CILow != nil {
+		t.Errorf("1988 has no published error, got %+v", dp)
+	}
+	if dp := byYear[1990]; !dp.Imputed || dp.StandardError != nil {
+		t.Errorf("imputed 1990 should carry no error, got %+v", dp)
+	}
+	if strings.Contains(string(files["graduation.json"]), "standardError") {
+		t.Error("graduation has no uncertainty columns and should have no error")
+	}
+
+	var cohortsFile CohortsFile
+	if err := json.Unmarshal(files["cohorts.json"], &cohortsFile); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cohortsFile.Cohorts {
+		// NAEP Grade 8 is measured at ~14: born 1978 maps to 1992.
+		if c.BirthYear == 1978 {
+			if m := c.Stats["proficiency"]; m.Year != 1992 || m.StandardError == nil || *m.StandardError != 1.2 {
+				t.Errorf("1978 cohort proficiency = %+v, want the 1992 standard error", m)
+			}
+		}
+	}
+
+	svg := string(files[filepath.Join(ChartDir, "proficiency.svg")])
+	if !strings.Contains(svg, "95% confidence interval") {
+		t.Error("proficiency.svg should explain its interval bars")
+	}
+	if strings.Contains(string(files[filepath.Join(ChartDir, "graduation.svg")]), "confidence interval") {
+		t.Error("graduation.svg has no intervals to explain")
+	}
+
+	checks, err := CheckOutput(dir)
 	if err != nil {
-		t.Fatalf("create %s: %v", path, err)
+		t.Fatal(err)
 	}
-	defer f.Close()
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(v); err != nil {
-		t.Fatalf("encode %s: %v", path, err)
+	for _, c := range checks {
+		if c.Err != nil {
+			t.Errorf("check-output %s: %v", c.File, c.Err)
+		}
 	}
 }
@@ -0,0 +1,184 @@
+package generators
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/schema"
+)
+
+// generationPlan records which stats a run can copy from the current
+// output because their tables are at the same data version as when that
+// output was generated with the same settings.
+type generationPlan struct {
+	outputDir string
+	// stateKey is the absolute output directory generation_state is
+	// recorded under.
+	stateKey string
+	// versions is nil when the database does not track data versions, in
+	// which case everything is regenerated and no state is recorded.
+	versions    map[string]int64
+	settings    string
+	previousRun string
+	reuse       map[string]bool
+	// failed counts stats that could not be generated; a run with failures
+	// is not recorded, so the next run retries them.
+	failed int
+}
+
+// upToDate reports whether every stat would be copied unchanged.
+func (p *generationPlan) upToDate() bool {
+	return p.previousRun != "" && len(p.reuse) == len(catalog.Published)
+}
+
+// plan compares the database's data versions with those recorded for
+// outputDir's last generation and marks the stats whose tables have not
+// changed. It reuses nothing when Full is set, the settings differ, the
+// schema version has no manifest, or outputDir no longer matches the
+// manifest of the recorded run.
+func (h *HugoGenerator) plan(outputDir string, version int) (*generationPlan, error) {
+	abs, err := filepath.Abs(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	plan := &generationPlan{
+		outputDir: outputDir,
+		stateKey:  abs,
+		settings:  h.settings(version),
+		reuse:     make(map[string]bool),
+	}
+
+	plan.versions, err = database.DataVersions(h.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data versions: %w", err)
+	}
+	if plan.versions == nil {
+		fmt.Println("    ℹ Database does not track data versions (run 'edu-stats step check-schema'); regenerating everything")
+		return plan, nil
+	}
+	if h.Full || !schema.Has(schema.KindManifest, version) {
+		return plan, nil
+	}
+
+	state, found, err := database.LoadGenerationState(h.db, plan.stateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generation state: %w", err)
+	}
+	if !found || state.Settings != plan.settings || !outputMatchesRun(outputDir, state.RunID) {
+		return plan, nil
+	}
+
+	plan.previousRun = state.RunID
+	for _, m := range catalog.Published {
+		if plan.versions[m.Table] == state.Versions[m.Table] {
+			plan.reuse[m.ID] = true
+		}
+	}
+	return plan, nil
+}
+
+// recordPlan saves the data versions the run was built from.
+func (h *HugoGenerator) recordPlan(plan *generationPlan, runID string) error {
+	if plan.versions == nil || runID == "" || plan.failed > 0 {
+		return nil
+	}
+	return database.SaveGenerationState(h.db, plan.stateKey, database.GenerationState{
+		RunID:    runID,
+		Settings: plan.settings,
+		Versions: plan.versions,
+	})
+}
+
+// settings fingerprints everything besides the data that shapes the
+// output: schema version, resampling, default series overrides, chart
+// formats, analytics, content pages, the metric catalog and SOURCE_DATE_EPOCH.
+func (h *HugoGenerator) settings(version int) string {
+	resample := h.Resample
+	if resample == "" {
+		resample = ResampleNone
+	}
+	parts := []string{
+		fmt.Sprintf("schema=%d", version),
+		"resample=" + string(resample),
+		"epoch=" + os.Getenv("SOURCE_DATE_EPOCH"),
+	}
+	ids := make([]string, 0, len(h.DefaultSeries))
+	for id := range h.DefaultSeries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		parts = append(parts, fmt.Sprintf("default.%s=%s", id, h.DefaultSeries[id]))
+	}
+	if len(h.Charts) > 0 {
+		parts = append(parts, "charts="+strings.Join(h.Charts, ","))
+	}
+	if h.Analytics {
+		parts = append(parts, "analytics")
+	}
+	if h.contentDir != "" {
+		parts = append(parts, "content")
+	}
+	metrics, _ := json.Marshal(catalog.Metrics)
+	sum := sha256.Sum256(metrics)
+	parts = append(parts, "catalog="+hex.EncodeToString(sum[:8]))
+	return strings.Join(parts, ";")
+}
+
+// outputMatchesRun reports whether dir still holds exactly the files of
+// the given run.
+func outputMatchesRun(dir, runID string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return false
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.RunID != runID {
+		return false
+	}
+	return verifyManifest(dir, data) == nil
+}
+
+// keepPrevious handles m failing to generate with cause. When the last run
+// was built with the same settings, its file for m is copied into stage so
+// the swap does not delete the last good copy. A published file from other
+// settings cannot stand in for it, so the run is aborted before the swap.
+// It reports false when there is nothing to keep.
+func keepPrevious(plan *generationPlan, stage string, m catalog.Metric, cause error) (StatData, bool, error) {
+	if plan.previousRun != "" {
+		return reuseStat(plan.outputDir, stage, m)
+	}
+	if _, err := os.Stat(filepath.Join(plan.outputDir, m.Filename)); err == nil {
+		return StatData{}, false, fmt.Errorf("failed to generate %s, and the published %s was built with other settings so it cannot be kept: %w",
+			m.Title, m.Filename, cause)
+	}
+	return StatData{}, false, nil
+}
+
+// reuseStat copies m's file from the current output into stage and returns
+// its contents. It reports false when the last run had no data for m.
+func reuseStat(outputDir, stage string, m catalog.Metric) (StatData, bool, error) {
+	raw, err := os.ReadFile(filepath.Join(outputDir, m.Filename))
+	if os.IsNotExist(err) {
+		return StatData{}, false, nil
+	}
+	if err != nil {
+		return StatData{}, false, err
+	}
+	var data StatData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return StatData{}, false, fmt.Errorf("failed to read previous %s: %w", m.Filename, err)
+	}
+	if err := os.WriteFile(filepath.Join(stage, m.Filename), raw, 0644); err != nil {
+		return StatData{}, false, err
+	}
+	return data, true, nil
+}
@@ -0,0 +1,385 @@
+package generators
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/schema"
+)
+
+// SchemaDir is the directory, inside the output directory, the JSON Schema
+// documents are published to.
+const SchemaDir = "schemas"
+
+func (h *HugoGenerator) schemaVersion() int {
+	if h.SchemaVersion == 0 {
+		return schema.Current
+	}
+	return h.SchemaVersion
+}
+
+// writeOutput validates v against the schema for kind at version and only
+// then writes it to outputDir/filename, so a file that breaks the contract
+// never replaces a good one.
+func writeOutput(outputDir, filename, kind string, version int, v interface{}) error {
+	if err := schema.ValidateValue(kind, version, v); err != nil {
+		return fmt.Errorf("generated %s %w", filename, err)
+	}
+	if err := writeJSONFile(filepath.Join(outputDir, filename), v); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
+
+// publishSchemas writes every schema version's documents to
+// outputDir/schemas so the site and its consumers can fetch them.
+func publishSchemas(outputDir string) error {
+	docs, err := schema.Documents()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(outputDir, SchemaDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(docs))
+	for name := range docs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), docs[name], 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacyPoint and legacyStat are the version 1 stat file: the headline
+// series only, without breakdowns or catalog metadata.
+type legacyPoint struct {
+	Year  int     `json:"year"`
+	Value float64 `json:"value"`
+	Label string  `json:"label,omitempty"`
+}
+
+type legacyStat struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Source      string        `json:"source"`
+	Years       []legacyPoint `json:"data"`
+}
+
+// statOutput returns data in the shape of the given schema version.
+func statOutput(data StatData, version int) interface{} {
+	if version != 1 {
+		data.SchemaVersion = version
+		return data
+	}
+	out := legacyStat{
+		Name:        data.Name,
+		Description: data.Description,
+		Source:      data.Source,
+		Years:       make([]legacyPoint, len(data.Years)),
+	}
+	for i, dp := range data.Years {
+		out.Years[i] = legacyPoint{Year: dp.Year, Value: dp.Value, Label: dp.Label}
+	}
+	return out
+}
+
+type legacyIndexEntry struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Filename    string `json:"filename"`
+	Available   bool   `json:"available"`
+	YearMin     int    `json:"yearMin,omitempty"`
+	YearMax     int    `json:"yearMax,omitempty"`
+	DataPoints  int    `json:"dataPoints,omitempty"`
+}
+
+type legacyIndexData struct {
+	Generated string                      `json:"generated"`
+	Stats     map[string]legacyIndexEntry `json:"stats"`
+}
+
+// legacyIndex returns index.json in the version 1 shape.
+func legacyIndex(generated string, stats map[string]StatIndexEntry) legacyIndexData {
+	out := legacyIndexData{Generated: generated, Stats: make(map[string]legacyIndexEntry, len(stats))}
+	for key, e := range stats {
+		out.Stats[key] = legacyIndexEntry{
+			Name:        e.Name,
+			Description: e.Description,
+			Filename:    e.Filename,
+			Available:   e.Available,
+			YearMin:     e.YearMin,
+			YearMax:     e.YearMax,
+			DataPoints:  e.DataPoints,
+		}
+	}
+	return out
+}
+
+// OutputCheck is the result of validating one published file.
+type OutputCheck struct {
+	File    string
+	Kind    string
+	Version int
+	Err     error
+}
+
+// CheckOutput validates every generated file in outputDir against the
+// schema version it declares (files without schemaVersion are version 1),
+// and checks that the files still match manifest.json's hashes.
+func CheckOutput(outputDir string) ([]OutputCheck, error) {
+	kinds := map[string]string{
+		"index.json":        schema.KindIndex,
+		"cohorts.json":      schema.KindCohorts,
+		"generations.json":  schema.KindGenerations,
+		"correlations.json": schema.KindCorrelations,
+		"manifest.json":     schema.KindManifest,
+	}
+	for _, m := range catalog.Published {
+		kinds[m.Filename] = schema.KindStat
+	}
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	var checks []OutputCheck
+	for _, e := range entries {
+		kind, ok := kinds[e.Name()]
+		if !ok || e.IsDir() {
+			continue
+		}
+		check := OutputCheck{File: e.Name(), Kind: kind, Version: 1}
+		data, err := os.ReadFile(filepath.Join(outputDir, e.Name()))
+		if err != nil {
+			check.Err = err
+			checks = append(checks, check)
+			continue
+		}
+		var header struct {
+			SchemaVersion int `json:"schemaVersion"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			check.Err = err
+			checks = append(checks, check)
+			continue
+		}
+		if header.SchemaVersion != 0 {
+			check.Version = header.SchemaVersion
+		}
+		if err := schema.CheckVersion(check.Version); err != nil {
+			check.Err = err
+		} else {
+			check.Err = schema.Validate(kind, check.Version, data)
+		}
+		if check.Err == nil && kind == schema.KindManifest {
+			check.Err = verifyManifest(outputDir, data)
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// ManifestFile is one published file with its SHA-256 and size.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// Manifest is manifest.json: every file a run published. RunID is derived
+// from the files' hashes, so regenerating from the same database reproduces
+// it, and two manifests with the same RunID describe identical output.
+type Manifest struct {
+	SchemaVersion int            `json:"schemaVersion"`
+	RunID         string         `json:"runId"`
+	Generated     string         `json:"generated"`
+	Files         []ManifestFile `json:"files"`
+}
+
+// hashFiles lists every file under dir, in path order, with its hash.
+func hashFiles(dir string) ([]ManifestFile, error) {
+	var files []ManifestFile
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "manifest.json" || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		files = append(files, ManifestFile{
+			Path:   filepath.ToSlash(rel),
+			SHA256: hex.EncodeToString(sum[:]),
+			Bytes:  int64(len(data)),
+		})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, err
+}
+
+// writeManifest hashes everything already written to dir and writes
+// manifest.json beside it.
+func (h *HugoGenerator) writeManifest(dir string, version int) (Manifest, error) {
+	files, err := hashFiles(dir)
+	if err != nil {
+		return Manifest{}, err
+	}
+	generated, err := h.GeneratedDate()
+	if err != nil {
+		return Manifest{}, err
+	}
+	run := sha256.New()
+	for _, f := range files {
+		fmt.Fprintf(run, "%s\x00%s\n", f.Path, f.SHA256)
+	}
+	manifest := Manifest{
+		SchemaVersion: version,
+		RunID:         "assets-" + hex.EncodeToString(run.Sum(nil))[:16],
+		Generated:     generated,
+		Files:         files,
+	}
+	return manifest, writeOutput(dir, "manifest.json", schema.KindManifest, version, manifest)
+}
+
+// GeneratedDate is the date output files report as generated: the day the
+// data last changed, so identical databases produce identical files.
+// SOURCE_DATE_EPOCH, the reproducible-builds convention, overrides it.
+func (h *HugoGenerator) GeneratedDate() (string, error) {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		secs, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid SOURCE_DATE_EPOCH %q", epoch)
+		}
+		return time.Unix(secs, 0).UTC().Format("2006-01-02"), nil
+	}
+	changed, err := database.LastDataChange(h.db)
+	if err != nil {
+		return "", fmt.Errorf("failed to read last data change: %w", err)
+	}
+	if changed.IsZero() {
+		changed = time.Unix(0, 0)
+	}
+	return changed.UTC().Format("2006-01-02"), nil
+}
+
+// keepHiddenFiles copies dotfiles such as .gitkeep from the current output
+// directory into the staging one; everything else is regenerated.
+func keepHiddenFiles(outputDir, stage string) error {
+	entries, err := os.ReadDir(outputDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), ".") || !e.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(outputDir, e.Name()))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(stage, e.Name()), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// swapDir replaces dst with stage using two renames within one parent
+// directory: dst moves to a hidden backup beside it, then stage takes its
+// place. Each rename is atomic but the pair is not, so dst is briefly
+// missing, though never half written. If the second rename fails the backup
+// is moved back; if that fails too the error names the backup, which is left
+// in place and blocks later swaps until it is restored or removed.
+func swapDir(stage, dst string) error {
+	backup := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".old")
+	if _, err := os.Lstat(backup); err == nil {
+		return fmt.Errorf("%s is left from an interrupted run; restore it to %s or remove it", backup, dst)
+	}
+	hadOld := false
+	if _, err := os.Lstat(dst); err == nil {
+		if err := os.Rename(dst, backup); err != nil {
+			return err
+		}
+		hadOld = true
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(stage, dst); err != nil {
+		if hadOld {
+			if restoreErr := os.Rename(backup, dst); restoreErr != nil {
+				return fmt.Errorf("%w; restoring the previous copy also failed (%v), it is kept in %s", err, restoreErr, backup)
+			}
+		}
+		return err
+	}
+	if hadOld {
+		if err := os.RemoveAll(backup); err != nil {
+			return fmt.Errorf("swapped in, but failed to remove the previous copy %s: %w", backup, err)
+		}
+	}
+	return nil
+}
+
+// verifyManifest compares the files in dir with the manifest's list.
+func verifyManifest(dir string, data []byte) error {
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+	actual, err := hashFiles(dir)
+	if err != nil {
+		return err
+	}
+	byPath := make(map[string]ManifestFile, len(actual))
+	for _, f := range actual {
+		byPath[f.Path] = f
+	}
+	var problems []string
+	for _, want := range manifest.Files {
+		got, ok := byPath[want.Path]
+		switch {
+		case !ok:
+			problems = append(problems, want.Path+" is missing")
+		case got.SHA256 != want.SHA256:
+			problems = append(problems, want.Path+" has changed")
+		}
+		delete(byPath, want.Path)
+	}
+	for _, f := range actual {
+		if _, extra := byPath[f.Path]; extra {
+			problems = append(problems, f.Path+" is not in the manifest")
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("run %s: %s", manifest.RunID, strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package generators
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ResampleMethod selects how missing years are filled when a sparse series
+// is expanded to an annual one.
+type ResampleMethod string
+
+const (
+	ResampleNone   ResampleMethod = "none"
+	ResampleLinear ResampleMethod = "linear"
+	ResampleLOCF   ResampleMethod = "locf"
+	ResampleSpline ResampleMethod = "spline"
+)
+
+// ParseResampleMethod validates a --resample flag value.
+func ParseResampleMethod(s string) (ResampleMethod, error) {
+	switch m := ResampleMethod(s); m {
+	case ResampleNone, ResampleLinear, ResampleLOCF, ResampleSpline:
+		return m, nil
+	case "":
+		return ResampleNone, nil
+	default:
+		return "", fmt.Errorf("unknown resample method %q, use none, linear, locf or spline", s)
+	}
+}
+
+// Resample returns points expanded to one point per year between the first
+// and last observation. Measured points are kept as-is; every filled year is
+// flagged Imputed with the method that produced it. Years outside the
+// observed span are never extrapolated. Points sharing a year collapse to
+// the last one given, as analytics does.
+func Resample(points []DataPoint, method ResampleMethod) []DataPoint {
+	if method == ResampleNone || method == "" || len(points) < 2 {
+		return points
+	}
+
+	sorted := make([]DataPoint, len(points))
+	copy(sorted, points)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Year < sorted[j].Year })
+	unique := sorted[:0]
+	for _, p := range sorted {
+		if n := len(unique); n > 0 && unique[n-1].Year == p.Year {
+			unique[n-1] = p
+			continue
+		}
+		unique = append(unique, p)
+	}
+	sorted = unique
+	if len(sorted) < 2 {
+		return sorted
+	}
+
+	var interpolate func(year int) float64
+	switch method {
+	case ResampleLinear:
+		interpolate = linearInterpolator(sorted)
+	case ResampleLOCF:
+		interpolate = locfInterpolator(sorted)
+	case ResampleSpline:
+		interpolate = splineInterpolator(sorted)
+	default:
+		return points
+	}
+
+	first, last := sorted[0].Year, sorted[len(sorted)-1].Year
+	out := make([]DataPoint, 0, last-first+1)
+	next := 0
+	for year := first; year <= last; year++ {
+		if next < len(sorted) && sorted[next].Year == year {
+			out = append(out, sorted[next])
+			next++
+			continue
+		}
+		out = append(out, DataPoint{
+			Year:    year,
+			Value:   interpolate(year),
+			Imputed: true,
+			Method:  string(method),
+		})
+	}
+	return out
+}
+
+// bracket returns the index i such that points[i].Year < year < points[i+1].Year.
+func bracket(points []DataPoint, year int) int {
+	i := sort.Search(len(points), func(i int) bool { return points[i].Year > year })
+	return i - 1
+}
+
+func linearInterpolator(points []DataPoint) func(int) float64 {
+	return func(year int) float64 {
+		i := bracket(points, year)
+		a, b := points[i], points[i+1]
+		t := float64(year-a.Year) / float64(b.Year-a.Year)
+		return a.Value + t*(b.Value-a.Value)
+	}
+}
+
+func locfInterpolator(points []DataPoint) func(int) float64 {
+	return func(year int) float64 {
+		return points[bracket(points, year)].Value
+	}
+}
+
+// splineInterpolator fits a natural cubic spline through points, falling
+// back to linear interpolation when there are too few points to curve.
+func splineInterpolator(points []DataPoint) func(int) float64 {
+	n := len(points)
+	if n < 3 {
+		return linearInterpolator(points)
+	}
+
+	x := make([]float64, n)
+	y := make([]float64, n)
+	for i, p := range points {
+		x[i] = float64(p.Year)
+		y[i] = p.Value
+	}
+
+	// Solve the tridiagonal system for second derivatives m with natural
+	// boundary conditions m[0] = m[n-1] = 0.
+	h := make([]float64, n-1)
+	for i := range h {
+		h[i] = x[i+1] - x[i]
+	}
+	m := make([]float64, n)
+	c := make([]float64, n)
+	d := make([]float64, n)
+	for i := 1; i < n-1; i++ {
+		a := h[i-1]
+		b := 2 * (h[i-1] + h[i])
+		cc := h[i]
+		r := 6 * ((y[i+1]-y[i])/h[i] - (y[i]-y[i-1])/h[i-1])
+		denom := b - a*c[i-1]
+		c[i] = cc / denom
+		d[i] = (r - a*d[i-1]) / denom
+	}
+	for i := n - 2; i >= 1; i-- {
+		m[i] = d[i] - c[i]*m[i+1]
+	}
+
+	return func(year int) float64 {
+		i := bracket(points, year)
+		t := float64(year)
+		a := x[i+1] - t
+		b := t - x[i]
+		return m[i]*a*a*a/(6*h[i]) + m[i+1]*b*b*b/(6*h[i]) +
+			(y[i]/h[i]-m[i]*h[i]/6)*a + (y[i+1]/h[i]-m[i+1]*h[i]/6)*b
+	}
+}
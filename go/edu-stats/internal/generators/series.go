@@ -0,0 +1,201 @@
+package generators
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aallbrig/proficiency-comparison/internal/analytics"
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+)
+
+// Series is one dimensional breakdown of a stat from one source, e.g.
+// enrollment for ages 5–17 from the NCES Digest.
+type Series struct {
+	// Key identifies the series as "source;col=value,...".
+	Key    string `json:"key"`
+	Source string `json:"source"`
+	// Dimensions holds the series' non-empty key columns; a dimension that
+	// is absent means the figure covers every member of it.
+	Dimensions map[string]string `json:"dimensions"`
+	Data       []DataPoint       `json:"data"`
+	// Analysis is set when HugoGenerator.Analytics is.
+	Analysis *analytics.Analysis `json:"analysis,omitempty"`
+}
+
+// SeriesSelector picks the series published as a stat's headline data. A
+// series matches when every listed dimension has the given value and every
+// other dimension is empty. Source narrows the match when several sources
+// publish the same breakdown.
+type SeriesSelector struct {
+	Dimensions map[string]string `json:"dimensions"`
+	Source     string            `json:"source,omitempty"`
+}
+
+func (s SeriesSelector) String() string {
+	parts := make([]string, 0, len(s.Dimensions)+1)
+	for k, v := range s.Dimensions {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	if s.Source != "" {
+		parts = append(parts, "source="+s.Source)
+	}
+	if len(parts) == 0 {
+		return "(all totals)"
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s SeriesSelector) matches(series Series) bool {
+	if s.Source != "" && series.Source != s.Source {
+		return false
+	}
+	if len(series.Dimensions) != len(s.Dimensions) {
+		return false
+	}
+	for k, v := range s.Dimensions {
+		if series.Dimensions[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// DefaultSeries is the headline series of each stat from the metric
+// catalog, unless overridden with HugoGenerator.DefaultSeries.
+var DefaultSeries = defaultSeriesFromCatalog()
+
+func defaultSeriesFromCatalog() map[string]SeriesSelector {
+	selectors := make(map[string]SeriesSelector, len(catalog.Published))
+	for _, m := range catalog.Published {
+		dims := make(map[string]string, len(m.DefaultSeries))
+		for k, v := range m.DefaultSeries {
+			dims[k] = v
+		}
+		selectors[m.ID] = SeriesSelector{Dimensions: dims}
+	}
+	return selectors
+}
+
+// ParseSeriesSelector parses a --default-series value of the form
+// "stat:col=value,col=value[,source=name]". "stat:" alone selects the series
+// with every dimension empty.
+func ParseSeriesSelector(s string) (string, SeriesSelector, error) {
+	stat, spec, ok := strings.Cut(s, ":")
+	if !ok || stat == "" {
+		return "", SeriesSelector{}, fmt.Errorf("invalid series %q, use stat:col=value,...", s)
+	}
+	if _, known := DefaultSeries[stat]; !known {
+		return "", SeriesSelector{}, fmt.Errorf("unknown stat %q", stat)
+	}
+	sel := SeriesSelector{Dimensions: make(map[string]string)}
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		if !ok || k == "" || v == "" {
+			return "", SeriesSelector{}, fmt.Errorf("invalid dimension %q in %q, use col=value", part, s)
+		}
+		if k == "source" {
+			sel.Source = v
+		} else {
+			sel.Dimensions[k] = v
+		}
+	}
+	return stat, sel, nil
+}
+
+// withSeries fills data with one series per source and dimension
+// combination of table.column, and publishes the stat's selected default
+// series as the headline data. Nothing is averaged: when no series matches
+// the selector the headline is left empty.
+func (h *HugoGenerator) withSeries(stat string, data StatData, table database.ObservationTable, column string) (StatData, error) {
+	loaded, err := database.LoadSeries(h.db, table, column)
+	if err != nil {
+		return data, err
+	}
+
+	for _, s := range loaded {
+		series := Series{
+			Key:        s.Source + ";" + database.SeriesKey(table, s.Key),
+			Source:     s.Source,
+			Dimensions: make(map[string]string),
+		}
+		for i, col := range table.KeyColumns {
+			if v := s.Key[i]; v != nil {
+				series.Dimensions[col] = fmt.Sprint(v)
+			}
+		}
+		for _, p := range s.Points {
+			series.Data = append(series.Data, DataPoint{
+				Year:          p.Year,
+				Value:         p.Value,
+				StandardError: nullable(p.Uncertainty.StandardError),
+				CILow:         nullable(p.Uncertainty.CILow),
+				CIHigh:        nullable(p.Uncertainty.CIHigh),
+			})
+		}
+		data.Series = append(data.Series, series)
+	}
+
+	selector, ok := h.DefaultSeries[stat]
+	if !ok {
+		selector = DefaultSeries[stat]
+	}
+	var candidates []Series
+	for _, s := range data.Series {
+		if selector.matches(s) {
+			candidates = append(candidates, s)
+		}
+	}
+	if len(candidates) == 0 {
+		if len(data.Series) > 0 {
+			fmt.Printf("    ⚠ %s: no series matches default %s (%d series available)\n",
+				stat, selector, len(data.Series))
+		}
+		return data, nil
+	}
+	// Several sources can publish the same breakdown; prefer the longest
+	// series, then the first source alphabetically, and say so.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if len(candidates[i].Data) != len(candidates[j].Data) {
+			return len(candidates[i].Data) > len(candidates[j].Data)
+		}
+		return candidates[i].Source < candidates[j].Source
+	})
+	if len(candidates) > 1 {
+		fmt.Printf("    ℹ %s: %d sources match default %s, using %s\n",
+			stat, len(candidates), selector, candidates[0].Source)
+	}
+	data.DefaultSeries = candidates[0].Key
+	data.Years = candidates[0].Data
+	return data, nil
+}
+
+// nullable returns v's value, or nil when it is NULL.
+func nullable(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// Analyze runs the trend analysis on a series' measured points; imputed
+// years are left out. It returns nil for a series with no measured points.
+func Analyze(points []DataPoint, opts analytics.Options) *analytics.Analysis {
+	measured := make([]analytics.Point, 0, len(points))
+	for _, dp := range points {
+		if !dp.Imputed {
+			measured = append(measured, analytics.Point{Year: dp.Year, Value: dp.Value})
+		}
+	}
+	if len(measured) == 0 {
+		return nil
+	}
+	a := analytics.Analyze(measured, opts)
+	return &a
+}
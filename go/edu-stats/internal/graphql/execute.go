@@ -0,0 +1,639 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// Response is the result of a request. Data is omitted when the request
+// was rejected before execution and is null when a non-null root field
+// failed.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Rejected reports whether the request failed to parse or validate, in
+// which case nothing was executed.
+func (r *Response) Rejected() bool {
+	return r.Data == nil
+}
+
+// orderedMap is an object result that keeps fields in query order.
+type orderedMap struct {
+	keys   []string
+	values []interface{}
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		b.Write(key)
+		b.WriteByte(':')
+		v, err := json.Marshal(m.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// plannedField is a field to execute with its arguments coerced and its
+// sub-selections merged across fragments.
+type plannedField struct {
+	key   string
+	def   *Field
+	args  map[string]interface{}
+	sub   []*plannedField
+	index map[string]*plannedField
+	loc   Location
+}
+
+var typenameField = &Field{Name: "__typename", Type: &NonNull{Of: String}}
+
+// Execute parses, validates and runs a query.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{asError(err)}}
+	}
+	op, err := pickOperation(doc, req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{asError(err)}}
+	}
+
+	p := &planner{doc: doc, root: s.Query, meta: s.metaFields(), visiting: make(map[string]bool)}
+	p.vars = p.coerceVariables(op, req.Variables)
+	if len(p.errs) > 0 {
+		return &Response{Errors: p.errs}
+	}
+	root := &plannedField{index: make(map[string]*plannedField)}
+	p.collect(s.Query, op.selection, root)
+	if len(p.errs) > 0 {
+		return &Response{Errors: p.errs}
+	}
+	if s.MaxDepth > 0 {
+		if d := depth(root.sub); d > s.MaxDepth {
+			return &Response{Errors: []*Error{{Message: fmt.Sprintf("Query depth %d exceeds the limit of %d.", d, s.MaxDepth)}}}
+		}
+	}
+	if n := introspectionLists(root.sub); n > maxIntrospectionLists {
+		return &Response{Errors: []*Error{{Message: fmt.Sprintf(
+			"Introspection nests fields, interfaces, possibleTypes or inputFields %d deep; the limit is %d.", n, maxIntrospectionLists)}}}
+	}
+	if s.MaxComplexity > 0 {
+		if c := complexity(root.sub, s.MaxComplexity); c > s.MaxComplexity {
+			return &Response{Errors: []*Error{{Message: fmt.Sprintf(
+				"Query complexity exceeds the limit of %d; select fewer fields or pass a smaller limit.", s.MaxComplexity)}}}
+		}
+	}
+
+	e := &executor{ctx: ctx}
+	data, ok := e.fields(s.Query, nil, root.sub, nil)
+	resp := &Response{Data: json.RawMessage("null"), Errors: e.errs}
+	if ok {
+		resp.Data = data
+	}
+	return resp
+}
+
+func asError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return &Error{Message: err.Error()}
+}
+
+func pickOperation(doc *document, name string) (*operation, error) {
+	var op *operation
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+		}
+		op = doc.operations[0]
+	} else {
+		for _, o := range doc.operations {
+			if o.name == name {
+				op = o
+			}
+		}
+		if op == nil {
+			return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}
+		}
+	}
+	if op.kind != "query" {
+		return nil, &Error{Message: fmt.Sprintf("Only queries are supported, not %s.", op.kind), Locations: []Location{op.loc}}
+	}
+	return op, nil
+}
+
+type planner struct {
+	doc *document
+	// root is the query type, which also has the fields in meta.
+	root     *Object
+	meta     map[string]*Field
+	vars     map[string]interface{}
+	visiting map[string]bool
+	errs     []*Error
+}
+
+func (p *planner) fail(loc Location, format string, args ...interface{}) {
+	p.errs = append(p.errs, &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}})
+}
+
+// coerceVariables checks the request's variables against the operation's
+// definitions and applies defaults. Omitted variables without a default are
+// left out, so the arguments using them count as omitted too.
+func (p *planner) coerceVariables(op *operation, given map[string]interface{}) map[string]interface{} {
+	vars := make(map[string]interface{})
+	for _, def := range op.variables {
+		t, err := inputType(def.typ)
+		if err != nil {
+			p.fail(def.loc, "%v", err)
+			continue
+		}
+		raw, ok := given[def.name]
+		if !ok && def.hasValue {
+			raw, ok = p.plain(def.def), true
+		}
+		if !ok {
+			if _, required := t.(*NonNull); required {
+				p.fail(def.loc, "Variable \"$%s\" of required type %q was not provided.", def.name, t)
+			}
+			continue
+		}
+		v, err := coerce(raw, t)
+		if err != nil {
+			p.fail(def.loc, "Variable \"$%s\" got invalid value: %v", def.name, err)
+			continue
+		}
+		vars[def.name] = v
+	}
+	return vars
+}
+
+func inputType(t typeRef) (Type, error) {
+	var out Type
+	if t.list != nil {
+		of, err := inputType(*t.list)
+		if err != nil {
+			return nil, err
+		}
+		out = &List{Of: of}
+	} else {
+		s, ok := scalars[t.name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown type %q.", t.name)
+		}
+		out = s
+	}
+	if t.nonNull {
+		out = &NonNull{Of: out}
+	}
+	return out, nil
+}
+
+// plain turns a literal into the Go value a decoded JSON variable would
+// have, substituting variables. It returns missing{} for a variable that
+// was not provided.
+func (p *planner) plain(v value) interface{} {
+	switch v := v.(type) {
+	case variableRef:
+		if val, ok := p.vars[string(v)]; ok {
+			return val
+		}
+		return missing{}
+	case nullValue:
+		return nil
+	case []value:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = p.plain(item)
+		}
+		return out
+	case objectValue:
+		out := make(map[string]interface{}, len(v))
+		for _, f := range v {
+			out[f.name] = p.plain(f.val)
+		}
+		return out
+	}
+	return v
+}
+
+// missing stands in for a variable that was not provided.
+type missing struct{}
+
+// coerce converts an input value to t, returning the value resolvers see.
+func coerce(v interface{}, t Type) (interface{}, error) {
+	if nn, ok := t.(*NonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("expected non-null %s", t)
+		}
+		return coerce(v, nn.Of)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch t := t.(type) {
+	case *List:
+		items, ok := v.([]interface{})
+		if !ok {
+			items = []interface{}{v}
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := coerce(item, t.Of)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = c
+		}
+		return out, nil
+	case *Scalar:
+		if c, ok := t.coerce(v); ok {
+			return c, nil
+		}
+		return nil, fmt.Errorf("%s cannot represent %s", t.Name, describe(v))
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case enumValue:
+		return string(v)
+	case string:
+		return fmt.Sprintf("%q", v)
+	}
+	return fmt.Sprint(v)
+}
+
+func (p *planner) args(parent *Object, def *Field, given []*argument, loc Location) map[string]interface{} {
+	args := make(map[string]interface{})
+	seen := make(map[string]bool)
+	for _, a := range given {
+		var argDef *Argument
+		for _, d := range def.Args {
+			if d.Name == a.name {
+				argDef = d
+			}
+		}
+		if argDef == nil {
+			p.fail(a.loc, "Unknown argument %q on field \"%s.%s\".", a.name, parent.Name, def.Name)
+			continue
+		}
+		raw := p.plain(a.val)
+		if _, omitted := raw.(missing); omitted {
+			continue
+		}
+		v, err := coerce(raw, argDef.Type)
+		if err != nil {
+			p.fail(a.loc, "Argument %q on field \"%s.%s\": %v.", a.name, parent.Name, def.Name, err)
+			continue
+		}
+		args[a.name] = v
+		seen[a.name] = true
+	}
+	for _, d := range def.Args {
+		if seen[d.Name] {
+			continue
+		}
+		if d.Default != nil {
+			args[d.Name] = d.Default
+		} else if _, required := d.Type.(*NonNull); required {
+			p.fail(loc, "Field \"%s.%s\" argument %q of type %q is required.", parent.Name, def.Name, d.Name, d.Type)
+		}
+	}
+	return args
+}
+
+// included evaluates @skip and @include.
+func (p *planner) included(dirs []*directive) bool {
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			p.fail(d.loc, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		var cond interface{}
+		for _, a := range d.args {
+			if a.name == "if" {
+				cond = p.plain(a.val)
+			}
+		}
+		b, ok := cond.(bool)
+		if !ok {
+			p.fail(d.loc, "Directive \"@%s\" needs a Boolean \"if\" argument.", d.name)
+			continue
+		}
+		if (d.name == "skip") == b {
+			return false
+		}
+	}
+	return true
+}
+
+// collect plans sels on obj into parent, merging fields that share a
+// response key.
+func (p *planner) collect(obj *Object, sels []selection, parent *plannedField) {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *field:
+			if !p.included(s.directives) {
+				continue
+			}
+			def := typenameField
+			if s.name != "__typename" {
+				if def = obj.Field(s.name); def == nil && obj == p.root {
+					def = p.meta[s.name]
+				}
+				if def == nil {
+					p.fail(s.loc, "Cannot query field %q on type %q.", s.name, obj.Name)
+					continue
+				}
+			}
+			args := p.args(obj, def, s.args, s.loc)
+			planned, exists := parent.index[s.responseKey()]
+			if exists {
+				if planned.def != def || !reflect.DeepEqual(planned.args, args) {
+					p.fail(s.loc, "Fields %q conflict because they select different fields or arguments; use an alias.", s.responseKey())
+					continue
+				}
+			} else {
+				planned = &plannedField{key: s.responseKey(), def: def, args: args, index: make(map[string]*plannedField), loc: s.loc}
+				parent.index[planned.key] = planned
+				parent.sub = append(parent.sub, planned)
+			}
+			inner, isObject := namedType(def.Type).(*Object)
+			switch {
+			case isObject && s.selection == nil:
+				p.fail(s.loc, "Field %q of type %q must have a selection of subfields.", s.name, def.Type)
+			case !isObject && s.selection != nil:
+				p.fail(s.loc, "Field %q must not have a selection since type %q has no subfields.", s.name, def.Type)
+			case isObject:
+				p.collect(inner, s.selection, planned)
+			}
+		case *fragmentSpread:
+			if !p.included(s.directives) {
+				continue
+			}
+			frag, ok := p.doc.fragments[s.name]
+			if !ok {
+				p.fail(s.loc, "Unknown fragment %q.", s.name)
+				continue
+			}
+			if p.visiting[s.name] {
+				p.fail(s.loc, "Cannot spread fragment %q within itself.", s.name)
+				continue
+			}
+			if frag.typeCond != obj.Name {
+				p.fail(s.loc, "Fragment %q cannot be spread here as objects of type %q can never be of type %q.", s.name, obj.Name, frag.typeCond)
+				continue
+			}
+			p.visiting[s.name] = true
+			p.collect(obj, frag.selection, parent)
+			delete(p.visiting, s.name)
+		case *inlineFragment:
+			if !p.included(s.directives) {
+				continue
+			}
+			if s.typeCond != "" && s.typeCond != obj.Name {
+				p.fail(s.loc, "Fragment cannot be spread here as objects of type %q can never be of type %q.", obj.Name, s.typeCond)
+				continue
+			}
+			p.collect(obj, s.selection, parent)
+		}
+	}
+}
+
+func namedType(t Type) Type {
+	for {
+		switch w := t.(type) {
+		case *NonNull:
+			t = w.Of
+		case *List:
+			t = w.Of
+		default:
+			return t
+		}
+	}
+}
+
+func isList(t Type) bool {
+	if nn, ok := t.(*NonNull); ok {
+		t = nn.Of
+	}
+	_, ok := t.(*List)
+	return ok
+}
+
+// depth measures how deeply fields nest. Introspection is left out: its
+// types are finite and introspectionLists bounds it instead, while the
+// standard introspection query nests ofType further than most limits.
+func depth(fields []*plannedField) int {
+	max := 0
+	for _, f := range fields {
+		if isMetaField(f.def) {
+			continue
+		}
+		if d := 1 + depth(f.sub); d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// complexity estimates the cost of fields, stopping once it passes limit.
+func complexity(fields []*plannedField, limit int) int {
+	total := 0
+	for _, f := range fields {
+		items := 1
+		if isList(f.def.Type) && f.def.Items != nil {
+			items = f.def.Items(f.args)
+		}
+		cost := 1
+		if f.def.Cost > 0 {
+			cost = f.def.Cost
+		}
+		if len(f.sub) > 0 {
+			inner := complexity(f.sub, limit)
+			if items > 0 && inner > limit/items {
+				return limit + 1
+			}
+			cost += items * inner
+		}
+		total += cost
+		if total > limit {
+			return limit + 1
+		}
+	}
+	return total
+}
+
+type executor struct {
+	ctx  context.Context
+	errs []*Error
+}
+
+func (e *executor) fail(f *plannedField, path []interface{}, message string) {
+	e.errs = append(e.errs, &Error{Message: message, Locations: []Location{f.loc}, Path: path})
+}
+
+func appendPath(path []interface{}, key interface{}) []interface{} {
+	out := make([]interface{}, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, key)
+}
+
+// fields resolves every planned field on source. It reports false when a
+// non-null field came back null, which makes the whole object null.
+func (e *executor) fields(obj *Object, source interface{}, fields []*plannedField, path []interface{}) (*orderedMap, bool) {
+	out := &orderedMap{}
+	for _, f := range fields {
+		fieldPath := appendPath(path, f.key)
+		v, err := e.resolve(obj, f, source)
+		if err != nil {
+			e.fail(f, fieldPath, err.Error())
+			if _, required := f.def.Type.(*NonNull); required {
+				return nil, false
+			}
+			v = nil
+		} else {
+			var ok bool
+			if v, ok = e.complete(f.def.Type, f, v, fieldPath); !ok {
+				return nil, false
+			}
+		}
+		out.keys = append(out.keys, f.key)
+		out.values = append(out.values, v)
+	}
+	return out, true
+}
+
+func (e *executor) resolve(obj *Object, f *plannedField, source interface{}) (interface{}, error) {
+	if err := e.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if f.def == typenameField {
+		return obj.Name, nil
+	}
+	if f.def.Resolve != nil {
+		return f.def.Resolve(Params{Context: e.ctx, Source: source, Args: f.args})
+	}
+	if m, ok := source.(map[string]interface{}); ok {
+		return m[f.def.Name], nil
+	}
+	return nil, fmt.Errorf("no resolver for %s.%s", obj.Name, f.def.Name)
+}
+
+// complete shapes a resolved value to t. It reports false when the value
+// must be null because of an error, so the caller can propagate it to the
+// nearest nullable field.
+func (e *executor) complete(t Type, f *plannedField, v interface{}, path []interface{}) (interface{}, bool) {
+	if nn, required := t.(*NonNull); required {
+		out, ok := e.completeNullable(nn.Of, f, v, path)
+		if ok && out == nil {
+			e.fail(f, path, fmt.Sprintf("Cannot return null for non-nullable field %s.", f.def.Name))
+			return nil, false
+		}
+		return out, ok
+	}
+	out, ok := e.completeNullable(t, f, v, path)
+	if !ok {
+		return nil, true
+	}
+	return out, true
+}
+
+func (e *executor) completeNullable(t Type, f *plannedField, v interface{}, path []interface{}) (interface{}, bool) {
+	if v == nil {
+		return nil, true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if rv.IsNil() {
+			return nil, true
+		}
+	}
+	switch t := t.(type) {
+	case *List:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.fail(f, path, fmt.Sprintf("Expected a list for field %s, got %T.", f.def.Name, v))
+			return nil, false
+		}
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			item, ok := e.complete(t.Of, f, rv.Index(i).Interface(), appendPath(path, i))
+			if !ok {
+				return nil, false
+			}
+			out[i] = item
+		}
+		return out, true
+	case *Object:
+		return e.fields(t, v, f.sub, path)
+	case *Enum:
+		if s, ok := v.(string); ok && t.has(s) {
+			return s, true
+		}
+		e.fail(f, path, fmt.Sprintf("%s cannot represent %v (%T).", t.Name, v, v))
+		return nil, false
+	case *Scalar:
+		out, ok := serialize(t, v)
+		if !ok {
+			e.fail(f, path, fmt.Sprintf("%s cannot represent %v (%T).", t.Name, v, v))
+		}
+		return out, ok
+	}
+	return nil, false
+}
+
+// serialize converts a resolved leaf value to its scalar's JSON form.
+func serialize(t *Scalar, v interface{}) (interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	switch t {
+	case Int:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return rv.Int(), true
+		case reflect.Float32, reflect.Float64:
+			if f := rv.Float(); f == float64(int64(f)) {
+				return int64(f), true
+			}
+		}
+	case Float:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(rv.Int()), true
+		case reflect.Float32, reflect.Float64:
+			return rv.Float(), true
+		}
+	case String:
+		if rv.Kind() == reflect.String {
+			return rv.String(), true
+		}
+	case Boolean:
+		if rv.Kind() == reflect.Bool {
+			return rv.Bool(), true
+		}
+	}
+	return nil, false
+}
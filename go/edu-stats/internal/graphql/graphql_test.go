@@ -0,0 +1,258 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testSchema has a list of books, each with an author, and a field that
+// always fails.
+func testSchema() *Schema {
+	author := &Object{Name: "Author", Fields: []*Field{
+		{Name: "name", Type: &NonNull{Of: String}},
+		{Name: "born", Type: Int},
+	}}
+	book := &Object{Name: "Book", Fields: []*Field{
+		{Name: "title", Type: &NonNull{Of: String}},
+		{Name: "year", Type: &NonNull{Of: Int}},
+		{Name: "rating", Type: Float},
+		{Name: "author", Type: &NonNull{Of: author}},
+		{Name: "broken", Type: &NonNull{Of: String}, Resolve: func(Params) (interface{}, error) {
+			return nil, errors.New("out of ink")
+		}},
+	}}
+	books := []map[string]interface{}{
+		{"title": "Dune", "year": 1965, "rating": 4.3, "author": map[string]interface{}{"name": "Herbert", "born": 1920}},
+		{"title": "Emma", "year": 1815, "author": map[string]interface{}{"name": "Austen"}},
+	}
+	query := &Object{Name: "Query", Fields: []*Field{
+		{
+			Name: "books", Type: &NonNull{Of: &List{Of: &NonNull{Of: book}}},
+			Args: []*Argument{
+				{Name: "after", Type: Int},
+				{Name: "limit", Type: Int, Default: 10},
+			},
+			Items: func(args map[string]interface{}) int { return args["limit"].(int) },
+			Resolve: func(p Params) (interface{}, error) {
+				var out []map[string]interface{}
+				for _, b := range books {
+					if after, ok := p.Args["after"].(int); ok && b["year"].(int) <= after {
+						continue
+					}
+					out = append(out, b)
+				}
+				return out, nil
+			},
+		},
+		{
+			Name: "book", Type: book,
+			Args: []*Argument{{Name: "title", Type: &NonNull{Of: String}}},
+			Resolve: func(p Params) (interface{}, error) {
+				for _, b := range books {
+					if b["title"] == p.Args["title"] {
+						return b, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+	}}
+	return &Schema{Query: query, MaxDepth: 4, MaxComplexity: 100}
+}
+
+func run(t *testing.T, req Request) (string, *Response) {
+	t.Helper()
+	resp := testSchema().Execute(context.Background(), req)
+	out, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("marshal response: %v", err)
+	}
+	return string(out), resp
+}
+
+func TestExecuteSelectsFieldsInQueryOrder(t *testing.T) {
+	got, _ := run(t, Request{Query: `
+		# aliases, arguments and a nested object
+		query Recent {
+			recent: books(after: 1900) { year title author { name } }
+			emma: book(title: "Emma") { __typename rating }
+		}`})
+	want := `{"data":{"recent":[{"year":1965,"title":"Dune","author":{"name":"Herbert"}}],` +
+		`"emma":{"__typename":"Book","rating":null}}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestExecuteVariablesFragmentsAndDirectives(t *testing.T) {
+	got, _ := run(t, Request{
+		Query: `
+			query ($after: Int, $withAuthor: Boolean!) {
+				books(after: $after) { ...bookFields author @include(if: $withAuthor) { born } }
+			}
+			fragment bookFields on Book { title ... on Book { year } rating @skip(if: true) }`,
+		Variables: map[string]interface{}{"after": 1800.0, "withAuthor": false},
+	})
+	want := `{"data":{"books":[{"title":"Dune","year":1965},{"title":"Emma","year":1815}]}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestExecuteRejectsInvalidQueries(t *testing.T) {
+	cases := map[string]string{
+		`{ books { pages } }`:                             `Cannot query field "pages" on type "Book"`,
+		`{ books(sort: "asc") { title } }`:                `Unknown argument "sort"`,
+		`{ book { title } }`:                              `argument "title" of type "String!" is required`,
+		`{ books(after: "1900") { title } }`:              `Int cannot represent "1900"`,
+		`{ books }`:                                       `must have a selection of subfields`,
+		`{ books { title { x } } }`:                       `must not have a selection`,
+		`{ books { ...missing } }`:                        `Unknown fragment "missing"`,
+		`{ books { t: title t: year } }`:                  `conflict`,
+		`mutation { books { title } }`:                    `Only queries are supported`,
+		`query ($n: Int!) { books(after: $n) { title } }`: `"$n" of required type "Int!" was not provided`,
+		`{ books { title `:                                `Syntax Error`,
+		`{ books { author { name } author { born } } } { books { year } }`: `Must provide operation name`,
+	}
+	for query, want := range cases {
+		got, resp := run(t, Request{Query: query})
+		if !resp.Rejected() || !strings.Contains(resp.Errors[0].Message, want) {
+			t.Errorf("%s\n  got %s\n  want an error containing %q", query, got, want)
+		}
+	}
+}
+
+func TestExecuteLimitsDepthAndComplexity(t *testing.T) {
+	// Two books pages of 10 with four fields each cost 2*(1+10*4) = 82.
+	if _, resp := run(t, Request{Query: `{ a: books { title year rating author { name } } b: books { title } }`}); resp.Rejected() {
+		t.Errorf("query within the limit was rejected: %v", resp.Errors[0])
+	}
+	got, resp := run(t, Request{Query: `{ books(limit: 50) { title year rating } }`})
+	if !resp.Rejected() || !strings.Contains(got, "complexity exceeds the limit of 100") {
+		t.Errorf("expected a complexity error, got %s", got)
+	}
+	// A field with a Cost counts it in place of one: 3*(40+1) = 123.
+	costly := testSchema()
+	costly.Query.Field("book").Cost = 40
+	resp = costly.Execute(context.Background(), Request{Query: `{ a: book(title: "Emma") { title } b: book(title: "Dune") { title } c: book(title: "Emma") { year } }`})
+	if !resp.Rejected() || !strings.Contains(resp.Errors[0].Message, "complexity exceeds the limit of 100") {
+		t.Errorf("expected the field costs to exceed the limit, got %+v", resp.Errors)
+	}
+
+	deep := &Object{Name: "Node", Fields: []*Field{{Name: "id", Type: Int}}}
+	deep.Fields = append(deep.Fields, &Field{Name: "next", Type: deep})
+	s := &Schema{Query: &Object{Name: "Query", Fields: []*Field{{Name: "node", Type: deep}}}, MaxDepth: 3}
+	resp = s.Execute(context.Background(), Request{Query: `{ node { next { next { id } } } }`})
+	if !resp.Rejected() || !strings.Contains(resp.Errors[0].Message, "depth 4 exceeds the limit of 3") {
+		t.Errorf("expected a depth error, got %+v", resp.Errors)
+	}
+}
+
+func TestExecuteNullsTheNearestNullableParent(t *testing.T) {
+	got, resp := run(t, Request{Query: `{ dune: book(title: "Dune") { title broken } books { title } }`})
+	want := `{"data":{"dune":null,"books":[{"title":"Dune"},{"title":"Emma"}]},` +
+		`"errors":[{"message":"out of ink","locations":[{"line":1,"column":37}],"path":["dune","broken"]}]}`
+	if resp.Rejected() || got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	// A non-null root field that fails takes data with it.
+	got, _ = run(t, Request{Query: `{ books { broken } }`})
+	if !strings.HasPrefix(got, `{"data":null,"errors":[{"message":"out of ink"`) {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestSDL(t *testing.T) {
+	sdl := testSchema().SDL()
+	for _, want := range []string{
+		"schema {\n  query: Query\n}",
+		"books(after: Int, limit: Int = 10): [Book!]!",
+		"type Book {\n  title: String!",
+		"author: Author!",
+		"type Author {",
+	} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("SDL is missing %q:\n%s", want, sdl)
+		}
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	s := testSchema()
+	s.MaxComplexity = 1000
+	introspect := func(query string) (string, *Response) {
+		t.Helper()
+		resp := s.Execute(context.Background(), Request{Query: query})
+		out, err := json.Marshal(resp)
+		if err != nil {
+			t.Fatalf("marshal response: %v", err)
+		}
+		return string(out), resp
+	}
+
+	// The shape of the standard introspection query: ofType nests deeper
+	// than MaxDepth, which introspection is not held to.
+	got, resp := introspect(`
+		query IntrospectionQuery {
+			__schema {
+				queryType { name }
+				mutationType { name }
+				types { ...FullType }
+				directives { name locations args { ...InputValue } }
+			}
+		}
+		fragment FullType on __Type {
+			kind name description
+			fields(includeDeprecated: true) { name args { ...InputValue } type { ...TypeRef } isDeprecated deprecationReason }
+			inputFields { ...InputValue }
+			interfaces { ...TypeRef }
+			enumValues(includeDeprecated: true) { name }
+			possibleTypes { ...TypeRef }
+		}
+		fragment InputValue on __InputValue { name type { ...TypeRef } defaultValue }
+		fragment TypeRef on __Type {
+			kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } }
+		}`)
+	if resp.Rejected() || len(resp.Errors) > 0 {
+		t.Fatalf("introspection query failed: %s", got)
+	}
+	for _, want := range []string{
+		`"queryType":{"name":"Query"},"mutationType":null`,
+		`{"kind":"OBJECT","name":"Book","description":null,"fields":[{"name":"title","args":[],` +
+			`"type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"String","ofType":null}}`,
+		`{"name":"limit","type":{"kind":"SCALAR","name":"Int","ofType":null},"defaultValue":"10"}`,
+		`"interfaces":[],"enumValues":null`,
+		`{"kind":"ENUM","name":"__TypeKind"`,
+		`{"name":"NON_NULL"}`,
+		`{"kind":"SCALAR","name":"Boolean"`,
+		`{"name":"skip","locations":["FIELD","FRAGMENT_SPREAD","INLINE_FRAGMENT"],"args":[{"name":"if","type":{"kind":"NON_NULL"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("introspection is missing %s", want)
+		}
+	}
+	if strings.Contains(got, `"name":"__schema"`) {
+		t.Error("__schema should not be listed among the query type's fields")
+	}
+
+	got, _ = introspect(`{ author: __type(name: "Author") { name fields { name } } none: __type(name: "Nope") { name } __typename }`)
+	want := `{"data":{"author":{"name":"Author","fields":[{"name":"name"},{"name":"born"}]},"none":null,"__typename":"Query"}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	// Walking fields -> type -> fields over and over is refused.
+	got, resp = introspect(`{ __schema { types { fields { type { fields { type { fields { name } } } } } } } }`)
+	if !resp.Rejected() || !strings.Contains(got, "Introspection nests fields, interfaces, possibleTypes or inputFields 3 deep") {
+		t.Errorf("expected the introspection nesting limit, got %s", got)
+	}
+	// The meta fields exist only on the query type.
+	got, resp = introspect(`{ books { __schema { queryType { name } } } }`)
+	if !resp.Rejected() || !strings.Contains(got, `Cannot query field \"__schema\" on type \"Book\"`) {
+		t.Errorf("expected __schema to be rejected below the root, got %s", got)
+	}
+}
@@ -0,0 +1,262 @@
+package graphql
+
+import "fmt"
+
+// The introspection types, answering __schema and __type from the schema's
+// own Go values: a __Type is a Type, a __Field a *Field, an __InputValue an
+// *Argument, an __EnumValue a string and a __Directive a *directiveDef.
+var (
+	typeKindEnum = &Enum{
+		Name:        "__TypeKind",
+		Description: "The kind of a type.",
+		Values:      []string{"SCALAR", "OBJECT", "INTERFACE", "UNION", "ENUM", "INPUT_OBJECT", "LIST", "NON_NULL"},
+	}
+	directiveLocationEnum = &Enum{
+		Name:        "__DirectiveLocation",
+		Description: "Where a directive may be used.",
+		Values: []string{
+			"QUERY", "MUTATION", "SUBSCRIPTION", "FIELD", "FRAGMENT_DEFINITION", "FRAGMENT_SPREAD",
+			"INLINE_FRAGMENT", "VARIABLE_DEFINITION", "SCHEMA", "SCALAR", "OBJECT", "FIELD_DEFINITION",
+			"ARGUMENT_DEFINITION", "INTERFACE", "UNION", "ENUM", "ENUM_VALUE", "INPUT_OBJECT",
+			"INPUT_FIELD_DEFINITION",
+		},
+	}
+	schemaType     = &Object{Name: "__Schema", Description: "The types and directives of the schema."}
+	typeType       = &Object{Name: "__Type", Description: "A type: a named type, or a list or non-null wrapper of one."}
+	fieldType      = &Object{Name: "__Field", Description: "A field of an object type."}
+	inputValueType = &Object{Name: "__InputValue", Description: "An argument of a field or directive."}
+	enumValueType  = &Object{Name: "__EnumValue", Description: "One value of an enum."}
+	directiveType  = &Object{Name: "__Directive", Description: "A directive the server accepts."}
+)
+
+// directiveDef describes one of the directives Execute understands.
+type directiveDef struct {
+	name, description string
+	args              []*Argument
+}
+
+var directives = []*directiveDef{
+	{
+		name:        "include",
+		description: "Includes the selection only when if is true.",
+		args:        []*Argument{{Name: "if", Type: &NonNull{Of: Boolean}, Description: "Included when true."}},
+	},
+	{
+		name:        "skip",
+		description: "Skips the selection when if is true.",
+		args:        []*Argument{{Name: "if", Type: &NonNull{Of: Boolean}, Description: "Skipped when true."}},
+	},
+}
+
+// maxIntrospectionLists limits how deeply a query may nest the list fields
+// of __Type (fields, interfaces, possibleTypes, inputFields). Types refer to
+// each other, so without it a short query could walk the schema
+// exponentially often. The standard introspection query nests one.
+const maxIntrospectionLists = 2
+
+// nestedLists are the __Type fields maxIntrospectionLists counts.
+var nestedLists = make(map[*Field]bool)
+
+func init() {
+	nonNull := func(t Type) Type { return &NonNull{Of: t} }
+	listOf := func(t Type) Type { return &List{Of: nonNull(t)} }
+	includeDeprecated := []*Argument{{Name: "includeDeprecated", Type: Boolean, Default: false}}
+	constant := func(v interface{}) func(Params) (interface{}, error) {
+		return func(Params) (interface{}, error) { return v, nil }
+	}
+
+	schemaType.Fields = []*Field{
+		{Name: "description", Type: String, Resolve: constant(nil)},
+		{Name: "types", Type: nonNull(listOf(typeType)), Resolve: func(p Params) (interface{}, error) {
+			return types(p.Source.(*Schema).Query, schemaType), nil
+		}},
+		{Name: "queryType", Type: nonNull(typeType), Resolve: func(p Params) (interface{}, error) {
+			return p.Source.(*Schema).Query, nil
+		}},
+		{Name: "mutationType", Type: typeType, Resolve: constant(nil)},
+		{Name: "subscriptionType", Type: typeType, Resolve: constant(nil)},
+		{Name: "directives", Type: nonNull(listOf(directiveType)), Resolve: constant(directives)},
+	}
+
+	typeType.Fields = []*Field{
+		{Name: "kind", Type: nonNull(typeKindEnum), Resolve: func(p Params) (interface{}, error) {
+			switch p.Source.(type) {
+			case *Scalar:
+				return "SCALAR", nil
+			case *Enum:
+				return "ENUM", nil
+			case *Object:
+				return "OBJECT", nil
+			case *List:
+				return "LIST", nil
+			case *NonNull:
+				return "NON_NULL", nil
+			}
+			return nil, fmt.Errorf("unknown type %T", p.Source)
+		}},
+		{Name: "name", Type: String, Resolve: func(p Params) (interface{}, error) {
+			switch t := p.Source.(type) {
+			case *Scalar, *Enum, *Object:
+				return t.(Type).String(), nil
+			}
+			return nil, nil
+		}},
+		{Name: "description", Type: String, Resolve: func(p Params) (interface{}, error) {
+			switch t := p.Source.(type) {
+			case *Enum:
+				return optional(t.Description), nil
+			case *Object:
+				return optional(t.Description), nil
+			}
+			return nil, nil
+		}},
+		{Name: "specifiedByURL", Type: String, Resolve: constant(nil)},
+		{Name: "fields", Type: listOf(fieldType), Args: includeDeprecated, Resolve: func(p Params) (interface{}, error) {
+			if o, ok := p.Source.(*Object); ok {
+				return o.Fields, nil
+			}
+			return nil, nil
+		}},
+		{Name: "interfaces", Type: listOf(typeType), Resolve: func(p Params) (interface{}, error) {
+			if _, ok := p.Source.(*Object); ok {
+				return []Type{}, nil
+			}
+			return nil, nil
+		}},
+		{Name: "possibleTypes", Type: listOf(typeType), Resolve: constant(nil)},
+		{Name: "enumValues", Type: listOf(enumValueType), Args: includeDeprecated, Resolve: func(p Params) (interface{}, error) {
+			if e, ok := p.Source.(*Enum); ok {
+				return e.Values, nil
+			}
+			return nil, nil
+		}},
+		{Name: "inputFields", Type: listOf(inputValueType), Resolve: constant(nil)},
+		{Name: "ofType", Type: typeType, Resolve: func(p Params) (interface{}, error) {
+			switch t := p.Source.(type) {
+			case *List:
+				return t.Of, nil
+			case *NonNull:
+				return t.Of, nil
+			}
+			return nil, nil
+		}},
+	}
+	for _, f := range typeType.Fields {
+		switch f.Name {
+		case "fields", "interfaces", "possibleTypes", "inputFields":
+			nestedLists[f] = true
+		}
+	}
+
+	fieldType.Fields = []*Field{
+		{Name: "name", Type: nonNull(String), Resolve: func(p Params) (interface{}, error) {
+			return p.Source.(*Field).Name, nil
+		}},
+		{Name: "description", Type: String, Resolve: func(p Params) (interface{}, error) {
+			return optional(p.Source.(*Field).Description), nil
+		}},
+		{Name: "args", Type: nonNull(listOf(inputValueType)), Resolve: func(p Params) (interface{}, error) {
+			if args := p.Source.(*Field).Args; args != nil {
+				return args, nil
+			}
+			return []*Argument{}, nil
+		}},
+		{Name: "type", Type: nonNull(typeType), Resolve: func(p Params) (interface{}, error) {
+			return p.Source.(*Field).Type, nil
+		}},
+		{Name: "isDeprecated", Type: nonNull(Boolean), Resolve: constant(false)},
+		{Name: "deprecationReason", Type: String, Resolve: constant(nil)},
+	}
+
+	inputValueType.Fields = []*Field{
+		{Name: "name", Type: nonNull(String), Resolve: func(p Params) (interface{}, error) {
+			return p.Source.(*Argument).Name, nil
+		}},
+		{Name: "description", Type: String, Resolve: func(p Params) (interface{}, error) {
+			return optional(p.Source.(*Argument).Description), nil
+		}},
+		{Name: "type", Type: nonNull(typeType), Resolve: func(p Params) (interface{}, error) {
+			return p.Source.(*Argument).Type, nil
+		}},
+		{Name: "defaultValue", Type: String, Resolve: func(p Params) (interface{}, error) {
+			if d := p.Source.(*Argument).Default; d != nil {
+				return literal(d), nil
+			}
+			return nil, nil
+		}},
+	}
+
+	enumValueType.Fields = []*Field{
+		{Name: "name", Type: nonNull(String), Resolve: func(p Params) (interface{}, error) {
+			return p.Source, nil
+		}},
+		{Name: "description", Type: String, Resolve: constant(nil)},
+		{Name: "isDeprecated", Type: nonNull(Boolean), Resolve: constant(false)},
+		{Name: "deprecationReason", Type: String, Resolve: constant(nil)},
+	}
+
+	directiveType.Fields = []*Field{
+		{Name: "name", Type: nonNull(String), Resolve: func(p Params) (interface{}, error) {
+			return p.Source.(*directiveDef).name, nil
+		}},
+		{Name: "description", Type: String, Resolve: func(p Params) (interface{}, error) {
+			return optional(p.Source.(*directiveDef).description), nil
+		}},
+		{Name: "locations", Type: nonNull(listOf(directiveLocationEnum)), Resolve: constant(
+			[]string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"})},
+		{Name: "args", Type: nonNull(listOf(inputValueType)), Resolve: func(p Params) (interface{}, error) {
+			return p.Source.(*directiveDef).args, nil
+		}},
+		{Name: "isRepeatable", Type: nonNull(Boolean), Resolve: constant(false)},
+	}
+}
+
+// metaFields returns the __schema and __type fields the query type has in
+// addition to its own. They are not listed among its fields.
+func (s *Schema) metaFields() map[string]*Field {
+	return map[string]*Field{
+		"__schema": {Name: "__schema", Type: &NonNull{Of: schemaType}, Resolve: func(Params) (interface{}, error) {
+			return s, nil
+		}},
+		"__type": {
+			Name: "__type", Type: typeType,
+			Args: []*Argument{{Name: "name", Type: &NonNull{Of: String}}},
+			Resolve: func(p Params) (interface{}, error) {
+				for _, t := range types(s.Query, schemaType) {
+					if t.String() == p.Args["name"] {
+						return t, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+	}
+}
+
+func isMetaField(f *Field) bool {
+	return f.Name == "__schema" || f.Name == "__type"
+}
+
+// introspectionLists returns how deeply fields nest the list fields of
+// __Type.
+func introspectionLists(fields []*plannedField) int {
+	max := 0
+	for _, f := range fields {
+		d := introspectionLists(f.sub)
+		if nestedLists[f.def] {
+			d++
+		}
+		if d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// optional returns s, or nil when it is empty.
+func optional(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
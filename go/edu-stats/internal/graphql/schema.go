@@ -0,0 +1,272 @@
+// Package graphql executes GraphQL queries against a schema of Go resolver
+// functions. It implements the query side of the language: operations with
+// variables, aliases, fragments, inline fragments and the @include and
+// @skip directives, over object, list, non-null, scalar and (output only)
+// enum types, and the __schema and __type introspection fields. Mutations,
+// subscriptions, interfaces, unions and input objects are not supported.
+// Schema.SDL prints the schema for clients that prefer it.
+//
+// Before running any resolver, Execute checks the query's depth and an
+// estimate of its cost, so a client cannot ask for more work than the
+// schema's limits allow.
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Type is a GraphQL output or input type: *Scalar, *Enum, *Object, *List
+// or *NonNull.
+type Type interface {
+	String() string
+}
+
+// Scalar is a built-in leaf type.
+type Scalar struct {
+	Name string
+	// coerce converts an input value (a literal or decoded JSON variable)
+	// to the Go value resolvers receive.
+	coerce func(interface{}) (interface{}, bool)
+}
+
+func (s *Scalar) String() string { return s.Name }
+
+// The built-in scalars. Int arguments reach resolvers as int, Float as
+// float64, String as string and Boolean as bool.
+var (
+	Int = &Scalar{Name: "Int", coerce: func(v interface{}) (interface{}, bool) {
+		switch n := v.(type) {
+		case int:
+			return n, true
+		case int64:
+			if n >= -1<<31 && n < 1<<31 {
+				return int(n), true
+			}
+		case float64:
+			if n == float64(int64(n)) && n >= -1<<31 && n < 1<<31 {
+				return int(n), true
+			}
+		}
+		return nil, false
+	}}
+	Float = &Scalar{Name: "Float", coerce: func(v interface{}) (interface{}, bool) {
+		switch n := v.(type) {
+		case int:
+			return float64(n), true
+		case int64:
+			return float64(n), true
+		case float64:
+			return n, true
+		}
+		return nil, false
+	}}
+	String = &Scalar{Name: "String", coerce: func(v interface{}) (interface{}, bool) {
+		s, ok := v.(string)
+		return s, ok
+	}}
+	Boolean = &Scalar{Name: "Boolean", coerce: func(v interface{}) (interface{}, bool) {
+		b, ok := v.(bool)
+		return b, ok
+	}}
+)
+
+var scalars = map[string]*Scalar{"Int": Int, "Float": Float, "String": String, "Boolean": Boolean}
+
+// Enum is a leaf type whose values are one of a fixed set of names. It is
+// an output type only: resolvers return one of Values as a string.
+type Enum struct {
+	Name        string
+	Description string
+	Values      []string
+}
+
+func (e *Enum) String() string { return e.Name }
+
+func (e *Enum) has(v string) bool {
+	for _, value := range e.Values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Object is a type with named fields.
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+}
+
+func (o *Object) String() string { return o.Name }
+
+// Field returns the named field, or nil.
+func (o *Object) Field(name string) *Field {
+	for _, f := range o.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// List is a list of another type.
+type List struct{ Of Type }
+
+func (l *List) String() string { return "[" + l.Of.String() + "]" }
+
+// NonNull marks a type whose values are never null.
+type NonNull struct{ Of Type }
+
+func (n *NonNull) String() string { return n.Of.String() + "!" }
+
+// Field is one field of an Object.
+type Field struct {
+	Name        string
+	Description string
+	Type        Type
+	Args        []*Argument
+	// Resolve returns the field's value. When nil, the value is looked up by
+	// field name in a map[string]interface{} parent.
+	Resolve func(p Params) (interface{}, error)
+	// Items estimates how many elements a list field returns for the given
+	// arguments, for the complexity limit. Nil counts a list as one item.
+	Items func(args map[string]interface{}) int
+	// Cost is what the field itself counts towards the complexity limit,
+	// for resolvers that do more work than reading a value, such as a
+	// database query. Zero counts one.
+	Cost int
+}
+
+// Argument is one argument of a Field.
+type Argument struct {
+	Name        string
+	Description string
+	Type        Type
+	// Default is used when the argument is omitted; nil means no default.
+	Default interface{}
+}
+
+// Params is what a resolver receives.
+type Params struct {
+	Context context.Context
+	// Source is the parent object's value.
+	Source interface{}
+	// Args holds every argument given or defaulted, coerced to Go values.
+	Args map[string]interface{}
+}
+
+// Schema is the root query type and the limits queries must stay within.
+type Schema struct {
+	Query *Object
+	// MaxDepth limits how deeply selections may nest; 0 means no limit.
+	MaxDepth int
+	// MaxComplexity limits a query's estimated cost: each field costs one,
+	// multiplied by the estimated items of every list above it. 0 means no
+	// limit.
+	MaxComplexity int
+}
+
+// Error is a GraphQL error as it appears in a response.
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Location    `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// SDL prints the schema in the GraphQL schema definition language.
+func (s *Schema) SDL() string {
+	var b strings.Builder
+	b.WriteString("schema {\n  query: " + s.Query.Name + "\n}\n")
+	for _, t := range types(s.Query) {
+		if e, ok := t.(*Enum); ok {
+			b.WriteString("\n")
+			writeDescription(&b, "", e.Description)
+			b.WriteString("enum " + e.Name + " {\n  " + strings.Join(e.Values, "\n  ") + "\n}\n")
+		}
+		o, ok := t.(*Object)
+		if !ok {
+			continue
+		}
+		b.WriteString("\n")
+		writeDescription(&b, "", o.Description)
+		b.WriteString("type " + o.Name + " {\n")
+		for _, f := range o.Fields {
+			writeDescription(&b, "  ", f.Description)
+			b.WriteString("  " + f.Name)
+			if len(f.Args) > 0 {
+				args := make([]string, len(f.Args))
+				for i, a := range f.Args {
+					args[i] = a.Name + ": " + a.Type.String()
+					if a.Default != nil {
+						args[i] += " = " + literal(a.Default)
+					}
+				}
+				b.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			b.WriteString(": " + f.Type.String() + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+// types lists the named types reachable from roots, in the order they are
+// first reached.
+func types(roots ...Type) []Type {
+	seen := map[string]bool{}
+	var out []Type
+	var walk func(t Type)
+	walk = func(t Type) {
+		switch t := t.(type) {
+		case *NonNull:
+			walk(t.Of)
+		case *List:
+			walk(t.Of)
+		case *Scalar:
+			if !seen[t.Name] {
+				seen[t.Name] = true
+				out = append(out, t)
+			}
+		case *Enum:
+			if !seen[t.Name] {
+				seen[t.Name] = true
+				out = append(out, t)
+			}
+		case *Object:
+			if seen[t.Name] {
+				return
+			}
+			seen[t.Name] = true
+			out = append(out, t)
+			for _, f := range t.Fields {
+				for _, a := range f.Args {
+					walk(a.Type)
+				}
+				walk(f.Type)
+			}
+		}
+	}
+	for _, t := range roots {
+		walk(t)
+	}
+	return out
+}
+
+func writeDescription(b *strings.Builder, indent, text string) {
+	if text == "" {
+		return
+	}
+	fmt.Fprintf(b, "%s%q\n", indent, text)
+}
+
+func literal(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(v)
+}
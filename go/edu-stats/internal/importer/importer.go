@@ -0,0 +1,275 @@
+// Package importer parses user-supplied CSV files into observations for one
+// of the observation tables, enforcing the same types and constraints as
+// schema.sql.
+package importer
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+)
+
+// maxRowErrors caps how many row errors Parse collects before giving up.
+const maxRowErrors = 20
+
+// RowError is a problem with one cell of the input.
+type RowError struct {
+	Line    int
+	Column  string
+	Message string
+}
+
+func (e RowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d, %s: %s", e.Line, e.Column, e.Message)
+}
+
+// Result is a parsed file. Rows are only safe to load when Errors is empty.
+type Result struct {
+	Rows      []database.Observation
+	StartYear int
+	EndYear   int
+	// Columns maps each table column to the CSV header it was read from.
+	Columns map[string]string
+	Errors  []RowError
+}
+
+// Parse reads a CSV file with a header row into observations for t. Header
+// names match table columns case-insensitively; mapping renames headers
+// first, and mapping a header to "" ignores it. The file must provide year
+// and at least one value column; key columns it omits are stored as NULL.
+// The optional standard_error, ci_low and ci_high columns give the
+// uncertainty of the first value column; a standard error without an
+// interval gets a normal 95% one.
+func Parse(r io.Reader, t database.ObservationTable, mapping map[string]string) (Result, error) {
+	res := Result{Columns: make(map[string]string)}
+
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return res, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	known := map[string]bool{"year": true}
+	columns := append(append([]string{}, t.KeyColumns...), t.ValueColumns...)
+	for _, c := range append(columns, database.UncertaintyColumns...) {
+		known[c] = true
+	}
+
+	// index maps table column -> CSV field position.
+	index := make(map[string]int)
+	for i, h := range header {
+		name := strings.TrimSpace(h)
+		if target, ok := mapping[name]; ok {
+			if target == "" {
+				continue
+			}
+			name = target
+		}
+		col := strings.ToLower(name)
+		if !known[col] {
+			return res, fmt.Errorf("column %q is not in %s; use --map '%s=<column>' or --map '%s=' to ignore it",
+				h, t.Name, h, h)
+		}
+		if _, dup := index[col]; dup {
+			return res, fmt.Errorf("column %s is provided twice", col)
+		}
+		index[col] = i
+		res.Columns[col] = h
+	}
+	for from, to := range mapping {
+		if to != "" && !known[strings.ToLower(to)] {
+			return res, fmt.Errorf("--map %s=%s: %s has no column %s", from, to, t.Name, to)
+		}
+	}
+
+	if _, ok := index["year"]; !ok {
+		return res, fmt.Errorf("no year column")
+	}
+	hasValue := false
+	for _, c := range t.ValueColumns {
+		if _, ok := index[c]; ok {
+			hasValue = true
+		}
+	}
+	if !hasValue {
+		return res, fmt.Errorf("no value column; %s needs one of %s", t.Name, strings.Join(t.ValueColumns, ", "))
+	}
+	for _, c := range t.NotNull {
+		if _, ok := index[c]; !ok {
+			return res, fmt.Errorf("missing required column %s", c)
+		}
+	}
+
+	seen := make(map[string]int)
+	line := 1
+	for {
+		record, err := cr.Read()
+		line++
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		obs, errs := parseRow(t, index, record, line)
+		if len(errs) > 0 {
+			res.Errors = append(res.Errors, errs...)
+			if len(res.Errors) >= maxRowErrors {
+				break
+			}
+			continue
+		}
+
+		key := fmt.Sprintf("%d|%s", obs.Year, database.SeriesKey(t, obs.Key))
+		if first, dup := seen[key]; dup {
+			res.Errors = append(res.Errors, RowError{Line: line,
+				Message: fmt.Sprintf("duplicates the year and dimensions of line %d", first)})
+			continue
+		}
+		seen[key] = line
+
+		if len(res.Rows) == 0 || obs.Year < res.StartYear {
+			res.StartYear = obs.Year
+		}
+		if len(res.Rows) == 0 || obs.Year > res.EndYear {
+			res.EndYear = obs.Year
+		}
+		res.Rows = append(res.Rows, obs)
+	}
+
+	sort.SliceStable(res.Rows, func(i, j int) bool { return res.Rows[i].Year < res.Rows[j].Year })
+	return res, nil
+}
+
+func parseRow(t database.ObservationTable, index map[string]int, record []string, line int) (database.Observation, []RowError) {
+	var errs []RowError
+	cell := func(col string) (string, bool) {
+		i, ok := index[col]
+		if !ok || i >= len(record) {
+			return "", false
+		}
+		v := strings.TrimSpace(record[i])
+		return v, v != ""
+	}
+	fail := func(col, format string, args ...interface{}) {
+		errs = append(errs, RowError{Line: line, Column: col, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var obs database.Observation
+	if v, ok := cell("year"); !ok {
+		fail("year", "missing")
+	} else if y, err := strconv.Atoi(v); err != nil || y < 1800 || y > 2100 {
+		fail("year", "%q is not a year", v)
+	} else {
+		obs.Year = y
+	}
+
+	obs.Key = make([]interface{}, len(t.KeyColumns))
+	for i, col := range t.KeyColumns {
+		v, ok := cell(col)
+		if !ok {
+			if contains(t.NotNull, col) {
+				fail(col, "required")
+			}
+			continue
+		}
+		if t.IsIntegerKey(col) {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				fail(col, "%q is not an integer", v)
+				continue
+			}
+			v = strconv.Itoa(n)
+			obs.Key[i] = n
+		} else {
+			obs.Key[i] = v
+		}
+		if allowed, ok := t.Allowed[col]; ok && !contains(allowed, v) {
+			fail(col, "%q is not one of %s", v, strings.Join(allowed, ", "))
+		}
+	}
+
+	obs.Values = make([]sql.NullFloat64, len(t.ValueColumns))
+	hasValue := false
+	for i, col := range t.ValueColumns {
+		v, ok := cell(col)
+		if !ok {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		if err != nil {
+			fail(col, "%q is not a number", v)
+			continue
+		}
+		obs.Values[i] = database.Float(f)
+		hasValue = true
+	}
+	if !hasValue {
+		if len(errs) == 0 {
+			fail("", "no value in %s", strings.Join(t.ValueColumns, ", "))
+		}
+		return obs, errs
+	}
+
+	var u [3]sql.NullFloat64
+	for i, col := range database.UncertaintyColumns {
+		v, ok := cell(col)
+		if !ok {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		if err != nil {
+			fail(col, "%q is not a number", v)
+			continue
+		}
+		u[i] = database.Float(f)
+	}
+	se, low, high := u[0], u[1], u[2]
+	value := obs.Values[0]
+	switch {
+	case !se.Valid && !low.Valid && !high.Valid:
+	case !value.Valid:
+		fail("", "uncertainty given without a value in %s", t.ValueColumns[0])
+	case se.Valid && se.Float64 < 0:
+		fail("standard_error", "must not be negative")
+	case low.Valid != high.Valid:
+		fail("", "ci_low and ci_high must be given together")
+	case low.Valid && !(low.Float64 <= value.Float64 && value.Float64 <= high.Float64):
+		fail("", "confidence interval %g-%g does not contain the value %g", low.Float64, high.Float64, value.Float64)
+	case low.Valid:
+		obs.Uncertainty = database.Uncertainty{StandardError: se, CILow: low, CIHigh: high}
+	default:
+		obs.Uncertainty = database.StandardError(value.Float64, se.Float64)
+	}
+	return obs, errs
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Table returns the observation table with the given name.
+func Table(name string) (database.ObservationTable, bool) {
+	for _, t := range database.ObservationTables {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return database.ObservationTable{}, false
+}
@@ -0,0 +1,133 @@
+package importer
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+)
+
+func TestParseWithMapping(t *testing.T) {
+	input := `School Year,Grad Rate,State,Notes
+2020,90.2,OR,covid
+2018,88.1,OR,
+2019,89.5%,,
+`
+	mapping := map[string]string{"School Year": "year", "Grad Rate": "rate", "Notes": ""}
+	res, err := Parse(strings.NewReader(input), database.GraduationRates, mapping)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(res.Errors) != 0 {
+		t.Fatalf("unexpected row errors: %v", res.Errors)
+	}
+	if len(res.Rows) != 3 || res.StartYear != 2018 || res.EndYear != 2020 {
+		t.Fatalf("got %d rows, %d-%d", len(res.Rows), res.StartYear, res.EndYear)
+	}
+
+	first := res.Rows[0]
+	if first.Year != 2018 || first.Key[1] != "OR" || first.Key[0] != nil || first.Values[0].Float64 != 88.1 {
+		t.Errorf("rows should be sorted by year with NULL for missing keys, got %+v", first)
+	}
+	if r := res.Rows[1]; r.Key[1] != nil || r.Values[0].Float64 != 89.5 {
+		t.Errorf("empty state should be NULL and %% stripped, got %+v", r)
+	}
+}
+
+func TestParseChecksConstraints(t *testing.T) {
+	input := `year,subject,grade,avg_score
+2019,reading,7,260
+2019,art,8,250
+20x9,reading,8,255
+2019,reading,8,
+2019,reading,8,263
+2019,reading,8,264
+`
+	res, err := Parse(strings.NewReader(input), database.TestProficiency, nil)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []string{
+		`line 2, grade: "7" is not one of 4, 8, 12`,
+		`line 3, subject: "art" is not one of reading, mathematics, science, writing`,
+		`line 4, year: "20x9" is not a year`,
+		`line 5: no value in avg_score, percentage_proficient`,
+		`line 7: duplicates the year and dimensions of line 6`,
+	}
+	if len(res.Errors) != len(want) {
+		t.Fatalf("got errors %v", res.Errors)
+	}
+	for i, e := range res.Errors {
+		if e.Error() != want[i] {
+			t.Errorf("error %d = %q, want %q", i, e.Error(), want[i])
+		}
+	}
+	if len(res.Rows) != 1 || res.Rows[0].Key[1] != 8 {
+		t.Errorf("expected the one valid row with integer grade, got %+v", res.Rows)
+	}
+}
+
+func TestParseRejectsBadHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		table  database.ObservationTable
+		header string
+	}{
+		{"unknown column", database.GraduationRates, "year,rate,district"},
+		{"no year", database.GraduationRates, "rate,state"},
+		{"no value column", database.GraduationRates, "year,state"},
+		{"missing required", database.EducationalAttainment, "year,age_group,percentage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(tt.header+"\n"), tt.table, nil); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseUncertainty(t *testing.T) {
+	input := `year,subject,grade,avg_score,percentage_proficient,standard_error,ci_low,ci_high
+2019,reading,8,263,,0.5,,
+2017,reading,8,267,,0.2,266.5,267.5
+2015,reading,8,265,,,,
+2013,reading,8,,31,0.3,,
+2011,reading,8,265,,-1,,
+2009,reading,8,264,,,263,
+2007,reading,8,263,,,264,266
+`
+	res, err := Parse(strings.NewReader(input), database.TestProficiency, nil)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []string{
+		`line 5: uncertainty given without a value in avg_score`,
+		`line 6, standard_error: must not be negative`,
+		`line 7: ci_low and ci_high must be given together`,
+		`line 8: confidence interval 264-266 does not contain the value 263`,
+	}
+	if len(res.Errors) != len(want) {
+		t.Fatalf("got errors %v", res.Errors)
+	}
+	for i, e := range res.Errors {
+		if e.Error() != want[i] {
+			t.Errorf("error %d = %q, want %q", i, e.Error(), want[i])
+		}
+	}
+
+	byYear := make(map[int]database.Uncertainty)
+	for _, r := range res.Rows {
+		byYear[r.Year] = r.Uncertainty
+	}
+	if u := byYear[2019]; u.StandardError.Float64 != 0.5 || math.Abs(u.CILow.Float64-262.02) > 0.01 || math.Abs(u.CIHigh.Float64-263.98) > 0.01 {
+		t.Errorf("a standard error alone should get a 95%% interval, got %+v", u)
+	}
+	if u := byYear[2017]; u.CILow.Float64 != 266.5 || u.CIHigh.Float64 != 267.5 {
+		t.Errorf("a published interval should be kept, got %+v", u)
+	}
+	if u := byYear[2015]; !u.IsZero() {
+		t.Errorf("no uncertainty columns should leave it empty, got %+v", u)
+	}
+}
@@ -0,0 +1,287 @@
+// Package preview serves a site's files for local development and tells
+// open browser tabs to reload, over server-sent events, when the files or
+// the data behind them change.
+//
+// HTML pages are served with a small script injected before </body> that
+// subscribes to /__preview/events; every other file is served as is. Files
+// are looked up in each root directory in turn, so generated data in the
+// first root shadows stale copies in a built site.
+package preview
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Paths the preview server reserves for itself.
+const (
+	EventsPath = "/__preview/events"
+	ScriptPath = "/__preview/reload.js"
+)
+
+// heartbeat keeps idle event streams from being closed by proxies.
+const heartbeat = 15 * time.Second
+
+const reloadScript = `(function () {
+  var events = new EventSource(%q);
+  events.addEventListener('reload', function () { location.reload(); });
+  events.addEventListener('data', function (e) {
+    console.info('[preview] data regenerated', JSON.parse(e.data));
+    location.reload();
+  });
+})();
+`
+
+// Event is one server-sent event.
+type Event struct {
+	Name string
+	Data string
+}
+
+// Server serves files from its roots and streams reload events.
+type Server struct {
+	roots []string
+
+	mu      sync.Mutex
+	clients map[chan Event]bool
+	done    chan struct{}
+	closed  bool
+}
+
+// New returns a Server for the given root directories, searched in order.
+func New(roots ...string) *Server {
+	return &Server{roots: roots, clients: make(map[chan Event]bool), done: make(chan struct{})}
+}
+
+// Notify sends an event to every connected browser.
+func (s *Server) Notify(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.clients {
+		select {
+		case ch <- e:
+		default:
+			// A client that has not read its last event will reload
+			// anyway; don't block the watcher on it.
+		}
+	}
+}
+
+// Clients returns the number of connected browsers.
+func (s *Server) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// Close ends every open event stream, so an http.Server can shut down.
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case EventsPath:
+		s.serveEvents(w, r)
+		return
+	case ScriptPath:
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprintf(w, reloadScript, EventsPath)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	file, ok := s.lookup(r.URL.Path)
+	if !ok {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+	// Browsers must always see the latest data and pages.
+	w.Header().Set("Cache-Control", "no-store")
+	f, err := os.Open(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !strings.HasSuffix(file, ".html") {
+		http.ServeContent(w, r, file, info.ModTime(), f)
+		return
+	}
+	page, err := io.ReadAll(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeContent(w, r, file, info.ModTime(), bytes.NewReader(InjectScript(page)))
+}
+
+// lookup finds the file for a URL path in the first root that has it,
+// using index.html for directories.
+func (s *Server) lookup(urlPath string) (string, bool) {
+	rel := filepath.FromSlash(path.Clean("/" + urlPath))
+	for _, root := range s.roots {
+		p := filepath.Join(root, rel)
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			p = filepath.Join(p, "index.html")
+			if info, err = os.Stat(p); err != nil || info.IsDir() {
+				continue
+			}
+		}
+		return p, true
+	}
+	return "", false
+}
+
+// InjectScript adds the reload script to an HTML page, before </body> when
+// there is one.
+func InjectScript(page []byte) []byte {
+	tag := []byte(`<script src="` + ScriptPath + `"></script>`)
+	i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>"))
+	if i < 0 {
+		return append(append(page[:len(page):len(page)], '\n'), tag...)
+	}
+	out := make([]byte, 0, len(page)+len(tag))
+	out = append(out, page[:i]...)
+	out = append(out, tag...)
+	return append(out, page[i:]...)
+}
+
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch := make(chan Event, 4)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	s.clients[ch] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, ch)
+		s.mu.Unlock()
+	}()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	io.WriteString(w, "retry: 1000\n\n")
+	flusher.Flush()
+
+	tick := time.NewTicker(heartbeat)
+	defer tick.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case e := <-ch:
+			fmt.Fprintf(w, "event: %s\n", e.Name)
+			for _, line := range strings.Split(e.Data, "\n") {
+				fmt.Fprintf(w, "data: %s\n", line)
+			}
+			io.WriteString(w, "\n")
+			flusher.Flush()
+		case <-tick.C:
+			io.WriteString(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// Watch calls check every interval and onChange whenever its result
+// differs from the previous one, until ctx is done. A check that fails is
+// reported to onError and retried on the next tick.
+func Watch(ctx context.Context, interval time.Duration, check func() (string, error), onChange func(), onError func(error)) {
+	last, err := check()
+	if err != nil {
+		onError(err)
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		current, err := check()
+		if err != nil {
+			onError(err)
+			continue
+		}
+		if current != last {
+			last = current
+			onChange()
+		}
+	}
+}
+
+// Fingerprint summarises the size and modification time of files, and of
+// every file under directories, skipping any path in skip. Missing paths
+// count as empty, so creating one is a change.
+func Fingerprint(paths []string, skip ...string) (string, error) {
+	var b strings.Builder
+	for _, p := range paths {
+		err := filepath.Walk(p, func(file string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			for _, s := range skip {
+				if file == s {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+			}
+			if !info.IsDir() {
+				fmt.Fprintf(&b, "%s %d %d\n", file, info.Size(), info.ModTime().UnixNano())
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,135 @@
+package preview
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInjectScript(t *testing.T) {
+	tag := `<script src="/__preview/reload.js"></script>`
+	got := string(InjectScript([]byte("<html><BODY><p>hi</p></BODY></html>")))
+	if want := "<html><BODY><p>hi</p>" + tag + "</BODY></html>"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got := string(InjectScript([]byte("<p>fragment</p>"))); got != "<p>fragment</p>\n"+tag {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestServeFilesFromRootsInOrder(t *testing.T) {
+	static, built := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(static, "data", "stats.json"), `{"fresh":true}`)
+	writeFile(t, filepath.Join(built, "data", "stats.json"), `{"fresh":false}`)
+	writeFile(t, filepath.Join(built, "index.html"), "<html><body>home</body></html>")
+
+	srv := httptest.NewServer(New(static, built))
+	defer srv.Close()
+	for path, want := range map[string]string{
+		"/data/stats.json":       `{"fresh":true}`,
+		"/":                      `<html><body>home<script src="/__preview/reload.js"></script></body></html>`,
+		"/../../data/stats.json": `{"fresh":true}`,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != want {
+			t.Errorf("GET %s = %d %s, want %s", path, resp.StatusCode, body, want)
+		}
+		if cc := resp.Header.Get("Cache-Control"); cc != "no-store" {
+			t.Errorf("GET %s Cache-Control = %q", path, cc)
+		}
+	}
+	if resp, _ := http.Get(srv.URL + "/missing.json"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing file: got %d", resp.StatusCode)
+	}
+}
+
+func TestEventsStreamNotifications(t *testing.T) {
+	s := New(t.TempDir())
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + EventsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() && lines.Text() != "" {
+	}
+	if n := s.Clients(); n != 1 {
+		t.Fatalf("Clients() = %d, want 1", n)
+	}
+
+	s.Notify(Event{Name: "data", Data: `{"files":["a.json"]}`})
+	var got []string
+	for lines.Scan() && lines.Text() != "" {
+		got = append(got, lines.Text())
+	}
+	if want := []string{"event: data", `data: {"files":["a.json"]}`}; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	s.Close()
+	if lines.Scan() {
+		t.Errorf("stream still open after Close: %q", lines.Text())
+	}
+}
+
+func TestWatchAndFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "site", "page.html")
+	skipped := filepath.Join(dir, "site", "data")
+	writeFile(t, file, "one")
+	writeFile(t, filepath.Join(skipped, "stats.json"), "{}")
+	paths := []string{filepath.Join(dir, "site"), filepath.Join(dir, "missing.toml")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{}, 1)
+	go Watch(ctx, 10*time.Millisecond,
+		func() (string, error) { return Fingerprint(paths, skipped) },
+		func() { changed <- struct{}{} },
+		func(err error) { t.Error(err) },
+	)
+
+	// Let the first check run before touching anything.
+	time.Sleep(30 * time.Millisecond)
+	writeFile(t, filepath.Join(skipped, "stats.json"), `{"ignored":true}`)
+	select {
+	case <-changed:
+		t.Fatal("a change in a skipped directory was reported")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	writeFile(t, file, "two, longer")
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change reported after a file was modified")
+	}
+}
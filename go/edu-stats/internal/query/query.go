@@ -0,0 +1,231 @@
+// Package query selects observations for one metric with filters on
+// canonical dimension names, independent of how each table names its columns.
+package query
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/export"
+)
+
+// Dimension maps a canonical dimension name to a table's key column.
+type Dimension struct {
+	Name   string
+	Column string
+	Type   export.ColumnType
+}
+
+// Metric is one value column of an observation table with its unit and the
+// dimensions it varies by, as defined in the metric catalog.
+type Metric struct {
+	ID          string
+	Description string
+	Table       database.ObservationTable
+	Column      string
+	// Unit is percent (0–100), scale_score (NAEP 0–500) or value for
+	// metrics whose unit depends on the measure.
+	Unit       string
+	Dimensions []Dimension
+}
+
+// Metrics lists every queryable metric in catalog order. IDs match the stat
+// keys used by the generated site data where one exists. Tables that record
+// a free-text demographics column expose it as "group"; --gender and --race
+// filter on it when the table has no dedicated column.
+var Metrics = fromCatalog(catalog.Metrics)
+
+func fromCatalog(metrics []catalog.Metric) []Metric {
+	out := make([]Metric, len(metrics))
+	for i, cm := range metrics {
+		t := cm.ObservationTable()
+		m := Metric{ID: cm.ID, Description: cm.Description, Table: t, Column: cm.Column, Unit: cm.Unit}
+		for _, d := range cm.Dimensions {
+			typ := export.String
+			if t.IsIntegerKey(d.Column) {
+				typ = export.Int
+			}
+			m.Dimensions = append(m.Dimensions, Dimension{Name: d.Name, Column: d.Column, Type: typ})
+		}
+		out[i] = m
+	}
+	return out
+}
+
+// Lookup returns the metric with the given ID.
+func Lookup(id string) (Metric, bool) {
+	for _, m := range Metrics {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Metric{}, false
+}
+
+// IDs returns every metric ID in catalog order.
+func IDs() []string {
+	ids := make([]string, len(Metrics))
+	for i, m := range Metrics {
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+// dimension resolves a canonical filter name against the metric, falling
+// back to the demographic group for gender and race.
+func (m Metric) dimension(name string) (Dimension, bool) {
+	for _, d := range m.Dimensions {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	if name == "gender" || name == "race" {
+		return m.dimension("group")
+	}
+	return Dimension{}, false
+}
+
+// Filter narrows a query. Zero values match everything.
+type Filter struct {
+	FromYear, ToYear int
+	Source           string
+	// Dimensions maps canonical dimension names (state, gender, race,
+	// grade, subject, ...) to the value they must equal.
+	Dimensions map[string]string
+}
+
+// Run returns the metric's non-null observations matching f as a table with
+// columns metric, year, the metric's dimensions, value, unit and source,
+// ordered by year, source, dimensions and id.
+func Run(db *sql.DB, m Metric, f Filter) (export.Table, error) {
+	where := []string{m.Column + " IS NOT NULL"}
+	var args []interface{}
+	if f.FromYear > 0 {
+		where = append(where, "year >= ?")
+		args = append(args, f.FromYear)
+	}
+	if f.ToYear > 0 {
+		where = append(where, "year <= ?")
+		args = append(args, f.ToYear)
+	}
+	if f.Source != "" {
+		where = append(where, "source = ?")
+		args = append(args, f.Source)
+	}
+
+	names := make([]string, 0, len(f.Dimensions))
+	for name := range f.Dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	filtered := make(map[string]string)
+	for _, name := range names {
+		d, ok := m.dimension(name)
+		if !ok {
+			return export.Table{}, fmt.Errorf("metric %s has no %s dimension", m.ID, name)
+		}
+		// gender and race share the group column where the table has no
+		// dedicated one, and a row has only one group.
+		if other, dup := filtered[d.Column]; dup {
+			return export.Table{}, fmt.Errorf("metric %s records %s and %s in the same %s column; filter on one of them",
+				m.ID, other, name, d.Name)
+		}
+		filtered[d.Column] = name
+		var value interface{} = f.Dimensions[name]
+		if d.Type == export.Int {
+			n, err := strconv.Atoi(f.Dimensions[name])
+			if err != nil {
+				return export.Table{}, fmt.Errorf("%s must be a number, got %q", name, f.Dimensions[name])
+			}
+			value = n
+		}
+		where = append(where, d.Column+" = ?")
+		args = append(args, value)
+	}
+
+	cols := []string{"year"}
+	order := []string{"year", "source"}
+	table := export.Table{Columns: []export.Column{
+		{Name: "metric", Type: export.String},
+		{Name: "year", Type: export.Int},
+	}}
+	for _, d := range m.Dimensions {
+		cols = append(cols, d.Column)
+		order = append(order, d.Column)
+		table.Columns = append(table.Columns, export.Column{Name: d.Name, Type: d.Type})
+	}
+	cols = append(cols, m.Column, "source")
+	table.Columns = append(table.Columns,
+		export.Column{Name: "value", Type: export.Float},
+		export.Column{Name: "unit", Type: export.String},
+		export.Column{Name: "source", Type: export.String},
+	)
+
+	// id breaks ties between rows whose NULL keys UNIQUE let through.
+	order = append(order, "id")
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s",
+		strings.Join(cols, ", "), m.Table.Name, strings.Join(where, " AND "), strings.Join(order, ", "))
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return table, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var year int64
+		var value float64
+		var source string
+		dims := make([]sql.NullString, len(m.Dimensions))
+		dest := []interface{}{&year}
+		for i := range dims {
+			dest = append(dest, &dims[i])
+		}
+		dest = append(dest, &value, &source)
+		if err := rows.Scan(dest...); err != nil {
+			return table, err
+		}
+
+		row := []interface{}{m.ID, year}
+		for i, d := range m.Dimensions {
+			row = append(row, dimensionValue(dims[i], d.Type))
+		}
+		row = append(row, value, m.Unit, source)
+		table.Rows = append(table.Rows, row)
+	}
+	return table, rows.Err()
+}
+
+func dimensionValue(v sql.NullString, t export.ColumnType) interface{} {
+	if !v.Valid {
+		return nil
+	}
+	if t == export.Int {
+		n, err := strconv.ParseInt(v.String, 10, 64)
+		if err != nil {
+			return nil
+		}
+		return n
+	}
+	return v.String
+}
+
+// Coverage is the span of years a metric has values for.
+type Coverage struct {
+	YearMin, YearMax int
+	Observations     int
+}
+
+// MetricCoverage measures the non-null values of m.
+func MetricCoverage(db *sql.DB, m Metric) (Coverage, error) {
+	var c Coverage
+	var min, max sql.NullInt64
+	err := db.QueryRow(fmt.Sprintf("SELECT MIN(year), MAX(year), COUNT(*) FROM %s WHERE %s IS NOT NULL",
+		m.Table.Name, m.Column)).Scan(&min, &max, &c.Observations)
+	c.YearMin, c.YearMax = int(min.Int64), int(max.Int64)
+	return c, err
+}
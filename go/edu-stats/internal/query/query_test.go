@@ -0,0 +1,205 @@
+package query
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/export"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func setupQueryTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	schema := `
+	CREATE TABLE educational_attainment (id INTEGER PRIMARY KEY, year INTEGER, age_group TEXT, education_level TEXT,
+		percentage REAL, gender TEXT, race TEXT, source TEXT, created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+	CREATE TABLE test_proficiency (id INTEGER PRIMARY KEY, year INTEGER, subject TEXT, grade INTEGER, avg_score REAL,
+		proficiency_level TEXT, percentage_proficient REAL, state TEXT, demographics TEXT, source TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+	INSERT INTO educational_attainment (year, age_group, education_level, percentage, gender, race, source) VALUES
+		(2020, '25plus', 'bachelors_plus', 37.5, NULL, NULL, 'census'),
+		(2020, '25plus', 'bachelors_plus', 40.1, 'female', NULL, 'census');
+	INSERT INTO test_proficiency (year, subject, grade, avg_score, percentage_proficient, state, demographics, source) VALUES
+		(2019, 'reading', 8, 263, NULL, NULL, NULL, 'naep'),
+		(2019, 'reading', 4, 220, NULL, NULL, NULL, 'naep'),
+		(2022, 'reading', 8, 260, 31, NULL, 'female', 'naep'),
+		(2022, 'mathematics', 8, 274, NULL, 'CA', NULL, 'naep');`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	return db
+}
+
+func TestRunFilters(t *testing.T) {
+	db := setupQueryTestDB(t)
+	defer db.Close()
+
+	m, _ := Lookup("proficiency")
+	table, err := Run(db, m, Filter{Dimensions: map[string]string{"grade": "8", "subject": "reading"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(table.Rows) != 2 {
+		t.Fatalf("expected 2 grade 8 reading rows, got %v", table.Rows)
+	}
+	first := table.Rows[0]
+	if first[0] != "proficiency" || first[1] != int64(2019) || first[3] != int64(8) {
+		t.Errorf("unexpected first row %v", first)
+	}
+	last := len(table.Columns) - 1
+	if table.Columns[last-2].Name != "value" || first[last-1] != "scale_score" || first[last] != "naep" {
+		t.Errorf("expected value, unit, source columns last, got %v", first)
+	}
+
+	// --gender falls back to the demographic group on tables without a
+	// gender column; --years bounds are inclusive.
+	table, err = Run(db, m, Filter{FromYear: 2022, ToYear: 2022, Dimensions: map[string]string{"gender": "female"}})
+	if err != nil || len(table.Rows) != 1 {
+		t.Errorf("gender filter: rows %v, err %v", table.Rows, err)
+	}
+}
+
+func TestRunSkipsNullValues(t *testing.T) {
+	db := setupQueryTestDB(t)
+	defer db.Close()
+
+	m, _ := Lookup("proficiency_pct")
+	table, err := Run(db, m, Filter{})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(table.Rows) != 1 || table.Rows[0][len(table.Columns)-3] != 31.0 {
+		t.Errorf("expected only the row with a percentage, got %v", table.Rows)
+	}
+}
+
+func TestRunRejectsUnknownDimension(t *testing.T) {
+	db := setupQueryTestDB(t)
+	defer db.Close()
+
+	m, _ := Lookup("attainment")
+	if _, err := Run(db, m, Filter{Dimensions: map[string]string{"grade": "8"}}); err == nil {
+		t.Error("expected an error filtering attainment by grade")
+	}
+	table, err := Run(db, m, Filter{Dimensions: map[string]string{"gender": "female"}})
+	if err != nil || len(table.Rows) != 1 {
+		t.Errorf("gender filter: rows %v, err %v", table.Rows, err)
+	}
+
+	prof, _ := Lookup("proficiency")
+	if _, err := Run(db, prof, Filter{Dimensions: map[string]string{"grade": "eight"}}); err == nil {
+		t.Error("expected an error for a non-numeric grade")
+	}
+
+	both := map[string]string{"gender": "female", "race": "black"}
+	if _, err := Run(db, prof, Filter{Dimensions: both}); err == nil || !strings.Contains(err.Error(), "group") {
+		t.Errorf("gender and race on the shared group column: err = %v", err)
+	}
+	if _, err := Run(db, m, Filter{Dimensions: both}); err != nil {
+		t.Errorf("gender and race have their own columns in attainment: %v", err)
+	}
+}
+
+func TestMetricsFollowCatalog(t *testing.T) {
+	if len(Metrics) != len(catalog.Metrics) {
+		t.Fatalf("%d metrics, catalog has %d", len(Metrics), len(catalog.Metrics))
+	}
+	for i, m := range Metrics {
+		cm := catalog.Metrics[i]
+		if m.ID != cm.ID || m.Description != cm.Description || m.Unit != cm.Unit || m.Table.Name != cm.Table {
+			t.Errorf("%s differs from its catalog entry: %+v", cm.ID, m)
+		}
+	}
+	prof, _ := Lookup("proficiency")
+	if d, ok := prof.dimension("grade"); !ok || d.Type != export.Int {
+		t.Errorf("grade should be an integer dimension: %+v", d)
+	}
+	if d, ok := prof.dimension("race"); !ok || d.Column != "demographics" {
+		t.Errorf("race should fall back to the demographics column: %+v", d)
+	}
+}
+
+func TestDumpOrderAndColumns(t *testing.T) {
+	db := setupQueryTestDB(t)
+	defer db.Close()
+
+	table, err := Dump(db, database.TestProficiency)
+	if err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	var names []string
+	for _, c := range table.Columns {
+		names = append(names, c.Name)
+	}
+	want := "year,subject,grade,proficiency_level,state,demographics,avg_score,percentage_proficient,standard_error,ci_low,ci_high,source,first_loaded_at"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("columns = %s, want %s", got, want)
+	}
+	if len(table.Rows) != 4 {
+		t.Fatalf("expected every row, got %d", len(table.Rows))
+	}
+	// Ordered by year, source, then key columns: grade 4 before grade 8,
+	// mathematics before reading.
+	if table.Rows[0][2] != int64(4) || table.Rows[2][1] != "mathematics" {
+		t.Errorf("unexpected order %v", table.Rows)
+	}
+	if table.Rows[0][len(names)-1] == nil {
+		t.Error("first_loaded_at should be filled from created_at")
+	}
+	// This database predates the uncertainty columns; they export empty.
+	if table.Rows[0][8] != nil {
+		t.Errorf("standard_error = %v, want empty", table.Rows[0][8])
+	}
+}
+
+func TestOrderBreaksTiesByID(t *testing.T) {
+	db := setupQueryTestDB(t)
+	defer db.Close()
+
+	// NULL keys do not collide under UNIQUE, so a reload can leave rows
+	// that tie on every ORDER BY column but id. An index that orders such
+	// ties by value lets SQLite return them out of id order.
+	if _, err := db.Exec(`
+		INSERT INTO test_proficiency (id, year, subject, grade, avg_score, source) VALUES
+			(100, 2024, 'reading', 8, 257, 'naep'), (101, 2024, 'reading', 8, 258, 'naep');
+		CREATE INDEX idx_ties ON test_proficiency
+			(year, source, subject, grade, proficiency_level, state, demographics, avg_score DESC)`); err != nil {
+		t.Fatal(err)
+	}
+	dump, err := Dump(db, database.TestProficiency)
+	if err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	if n := len(dump.Rows); dump.Rows[n-2][6] != 257.0 || dump.Rows[n-1][6] != 258.0 {
+		t.Errorf("Dump ties out of id order: %v", dump.Rows[n-2:])
+	}
+
+	m, _ := Lookup("proficiency")
+	table, err := Run(db, m, Filter{FromYear: 2024})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	value := len(table.Columns) - 3
+	if len(table.Rows) != 2 || table.Rows[0][value] != 257.0 || table.Rows[1][value] != 258.0 {
+		t.Errorf("Run ties out of id order: %v", table.Rows)
+	}
+}
+
+func TestDictionaryCoversEveryColumn(t *testing.T) {
+	dict := Dictionary()
+	for _, row := range dict.Rows {
+		if row[5] == "" {
+			t.Errorf("%s.%s has no description", row[0], row[1])
+		}
+		if row[3] == RoleMeasure && row[4] == "" {
+			t.Errorf("%s.%s measure has no unit", row[0], row[1])
+		}
+	}
+}
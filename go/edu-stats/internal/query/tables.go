@@ -0,0 +1,217 @@
+package query
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/export"
+)
+
+// Column roles in the data dictionary.
+const (
+	RoleDimension  = "dimension"
+	RoleMeasure    = "measure"
+	RoleAttribute  = "attribute"
+	RoleProvenance = "provenance"
+)
+
+// ColumnInfo describes one column of a full-table export.
+type ColumnInfo struct {
+	Table       string
+	Name        string
+	Type        export.ColumnType
+	Role        string
+	Unit        string
+	Description string
+}
+
+var columnDescriptions = map[string]string{
+	"year":              "Year the value applies to",
+	"age_group":         "Age band the value covers (e.g. 25plus, 5_to_17)",
+	"gender":            "Gender; empty for all",
+	"race":              "Race/ethnicity; empty for all",
+	"state":             "State; empty for the national figure",
+	"demographics":      "Demographic group; empty for all students",
+	"cohort_year":       "Year the cohort started",
+	"education_level":   "high_school, associates, bachelors_plus or graduate",
+	"level":             "elementary, secondary or postsecondary",
+	"subject":           "reading, mathematics, science or writing",
+	"grade":             "Grade tested: 4, 8 or 12",
+	"proficiency_level": "NAEP achievement level; empty for the average score",
+	"metric_name":       "Early childhood measure the value belongs to",
+	"age_months":        "Child age in months at assessment",
+	"standard_error":    "Published standard error of the row's first measure; empty where the source publishes none",
+	"ci_low":            "Lower bound of the 95% confidence interval of the row's first measure",
+	"ci_high":           "Upper bound of the 95% confidence interval of the row's first measure",
+	"source":            "Source identifier; see sources.csv",
+	"first_loaded_at":   "When the row was first loaded (UTC); revisions are listed by 'edu-stats changes'",
+}
+
+// TableColumns lists the columns Dump writes for t, in order: year, the
+// table's key columns, its value columns, their uncertainty, then
+// provenance.
+func TableColumns(t database.ObservationTable) []ColumnInfo {
+	dims := make(map[string]Dimension)
+	measures := make(map[string]Metric)
+	for _, m := range Metrics {
+		if m.Table.Name != t.Name {
+			continue
+		}
+		measures[m.Column] = m
+		for _, d := range m.Dimensions {
+			dims[d.Column] = d
+		}
+	}
+
+	cols := []ColumnInfo{{Table: t.Name, Name: "year", Type: export.Int, Role: RoleDimension}}
+	for _, c := range t.KeyColumns {
+		cols = append(cols, ColumnInfo{Table: t.Name, Name: c, Type: dims[c].Type, Role: RoleDimension})
+	}
+	for _, c := range t.ValueColumns {
+		m := measures[c]
+		cols = append(cols, ColumnInfo{Table: t.Name, Name: c, Type: export.Float, Role: RoleMeasure,
+			Unit: m.Unit, Description: fmt.Sprintf("%s (metric %s)", m.Description, m.ID)})
+	}
+	for _, c := range database.UncertaintyColumns {
+		cols = append(cols, ColumnInfo{Table: t.Name, Name: c, Type: export.Float, Role: RoleAttribute,
+			Unit: measures[t.ValueColumns[0]].Unit})
+	}
+	cols = append(cols,
+		ColumnInfo{Table: t.Name, Name: "source", Type: export.String, Role: RoleAttribute},
+		ColumnInfo{Table: t.Name, Name: "first_loaded_at", Type: export.String, Role: RoleProvenance},
+	)
+	for i := range cols {
+		if cols[i].Description == "" {
+			cols[i].Description = columnDescriptions[cols[i].Name]
+		}
+	}
+	return cols
+}
+
+// Dump returns every row of t with all columns from TableColumns, ordered by
+// year, source, key columns and id so repeated exports of the same data are
+// byte-identical. A database without the uncertainty columns exports them
+// empty.
+func Dump(db *sql.DB, t database.ObservationTable) (export.Table, error) {
+	hasUncertainty, err := database.HasUncertainty(db, t)
+	if err != nil {
+		return export.Table{}, err
+	}
+	uncertainty := make(map[string]bool)
+	for _, c := range database.UncertaintyColumns {
+		uncertainty[c] = true
+	}
+
+	info := TableColumns(t)
+	table := export.Table{}
+	selects := make([]string, len(info))
+	for i, c := range info {
+		table.Columns = append(table.Columns, export.Column{Name: c.Name, Type: c.Type})
+		selects[i] = c.Name
+		if uncertainty[c.Name] && !hasUncertainty {
+			selects[i] = "NULL"
+		}
+	}
+	selects[len(selects)-1] = "created_at"
+	order := append(append([]string{"year", "source"}, t.KeyColumns...), "id")
+
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM %s ORDER BY %s",
+		strings.Join(selects, ", "), t.Name, strings.Join(order, ", ")))
+	if err != nil {
+		return table, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		dest := make([]interface{}, len(info))
+		for i, c := range info {
+			switch c.Type {
+			case export.Int:
+				dest[i] = new(sql.NullInt64)
+			case export.Float:
+				dest[i] = new(sql.NullFloat64)
+			default:
+				dest[i] = new(sql.NullString)
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return table, err
+		}
+
+		row := make([]interface{}, len(dest))
+		for i, d := range dest {
+			switch v := d.(type) {
+			case *sql.NullInt64:
+				if v.Valid {
+					row[i] = v.Int64
+				}
+			case *sql.NullFloat64:
+				if v.Valid {
+					row[i] = v.Float64
+				}
+			case *sql.NullString:
+				if v.Valid {
+					row[i] = v.String
+				}
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table, rows.Err()
+}
+
+// Dictionary describes every column of every observation table.
+func Dictionary() export.Table {
+	table := export.Table{Columns: []export.Column{
+		{Name: "table", Type: export.String},
+		{Name: "column", Type: export.String},
+		{Name: "type", Type: export.String},
+		{Name: "role", Type: export.String},
+		{Name: "unit", Type: export.String},
+		{Name: "description", Type: export.String},
+	}}
+	typeNames := map[export.ColumnType]string{export.String: "string", export.Int: "integer", export.Float: "number"}
+	for _, t := range database.ObservationTables {
+		for _, c := range TableColumns(t) {
+			table.Rows = append(table.Rows, []interface{}{c.Table, c.Name, typeNames[c.Type], c.Role, c.Unit, c.Description})
+		}
+	}
+	return table
+}
+
+// Sources lists each source's download bookkeeping alongside the years
+// and rows it actually has loaded.
+func Sources(db *sql.DB) (export.Table, error) {
+	table := export.Table{Columns: []export.Column{
+		{Name: "source", Type: export.String},
+		{Name: "last_download", Type: export.String},
+		{Name: "status", Type: export.String},
+		{Name: "year_min", Type: export.Int},
+		{Name: "year_max", Type: export.Int},
+		{Name: "year_count", Type: export.Int},
+		{Name: "row_count", Type: export.Int},
+	}}
+	sources, err := database.GetSourceMetadata(db)
+	if err != nil {
+		return table, err
+	}
+	for _, s := range sources {
+		c, err := database.GetSourceCoverage(db, s.Name)
+		if err != nil {
+			return table, err
+		}
+		var lastDownload interface{}
+		if s.LastDownload != nil {
+			lastDownload = s.LastDownload.UTC().Format(time.RFC3339)
+		}
+		row := []interface{}{s.Name, lastDownload, s.Status, nil, nil, int64(c.YearCount), int64(c.RowCount)}
+		if c.YearCount > 0 {
+			row[3], row[4] = int64(c.YearMin), int64(c.YearMax)
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table, nil
+}
@@ -0,0 +1,250 @@
+package report
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/aallbrig/proficiency-comparison/internal/coverage"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/generators"
+	"github.com/aallbrig/proficiency-comparison/internal/validate"
+)
+
+// Formats the report can be written in.
+const (
+	FormatMarkdown = "md"
+	FormatHTML     = "html"
+)
+
+// maxFindings is how many validation findings a stat's section lists.
+const maxFindings = 5
+
+// Write renders r in format.
+func Write(w io.Writer, r Report, format string) error {
+	switch format {
+	case FormatMarkdown:
+		return markdownTemplate.Execute(w, r)
+	case FormatHTML:
+		return htmlTemplate.Execute(w, r)
+	}
+	return fmt.Errorf("unknown format %q, use md or html", format)
+}
+
+// funcs are shared by both templates; each returns plain text, which the
+// HTML template escapes.
+var funcs = map[string]interface{}{
+	"change": func(m Metric, span int) string {
+		for _, c := range m.Changes {
+			if c.Span == span {
+				s := fmt.Sprintf("%s since %d", m.Difference(c), c.From.Year)
+				// A change across a break partly measures the change of
+				// method, so it is never shown without saying so.
+				for _, b := range c.Breaks {
+					s += fmt.Sprintf(", across the %d series break", b.Year)
+				}
+				return s
+			}
+		}
+		return "n/a"
+	},
+	"gaps": func(gaps []coverage.Gap) string {
+		if len(gaps) == 0 {
+			return "none"
+		}
+		parts := make([]string, 0, len(gaps))
+		for i, g := range gaps {
+			if i == maxFindings {
+				parts = append(parts, fmt.Sprintf("%d more", len(gaps)-i))
+				break
+			}
+			if g.From == g.To {
+				parts = append(parts, fmt.Sprint(g.From))
+			} else {
+				parts = append(parts, fmt.Sprintf("%d-%d", g.From, g.To))
+			}
+		}
+		return strings.Join(parts, ", ")
+	},
+	"quality": func(m Metric) string {
+		switch {
+		case m.Errors == 0 && m.Warnings == 0:
+			return "no findings"
+		case m.Errors == 0:
+			return plural(m.Warnings, "warning")
+		}
+		return plural(m.Errors, "error") + ", " + plural(m.Warnings, "warning")
+	},
+	"findings": func(m Metric) []string {
+		var out []string
+		for i, f := range m.Findings {
+			if i == maxFindings {
+				out = append(out, fmt.Sprintf("and %d more", len(m.Findings)-i))
+				break
+			}
+			line := fmt.Sprintf("%s [%s] %s", f.Severity, f.Rule, f.Series)
+			if f.Year != 0 {
+				line += fmt.Sprintf(" %d", f.Year)
+			}
+			out = append(out, line+": "+f.Message)
+		}
+		return out
+	},
+	"validation": func(s validate.Summary) string {
+		if s.RunID == "" {
+			return "The data has not been validated; run 'edu-stats step validate'."
+		}
+		return fmt.Sprintf("Latest validation run %s: %s, %s.", s.RunID, plural(s.Errors, "error"), plural(s.Warnings, "warning"))
+	},
+	"downloaded": func(s database.SourceMetadata) string {
+		if s.LastDownload == nil {
+			return "never"
+		}
+		return s.LastDownload.Format("2006-01-02")
+	},
+	// cell escapes text for a Markdown table cell.
+	"cell": func(s string) string {
+		return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+	},
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+var markdownTemplate = template.Must(template.New("md").Funcs(funcs).Parse(`# Education Statistics Report
+
+Data as of {{.Generated}}. {{validation .Validation}}
+
+| Stat | Latest | 10-year change | 30-year change | Data quality |
+|------|--------|----------------|----------------|--------------|
+{{- range .Metrics}}
+{{- if .Available}}
+| {{cell .Title}} | {{.Value .Latest.Value}} ({{.Latest.Year}}) | {{cell (change . 10)}} | {{cell (change . 30)}} | {{quality .}} |
+{{- else}}
+| {{cell .Title}} | no data | | | {{quality .}} |
+{{- end}}
+{{- end}}
+{{range .Metrics}}
+## {{.Title}}
+
+{{.Description}}.
+{{if .Available}}
+{{.Summary}}
+
+| Measure | Value |
+|---------|-------|
+| Latest | {{.Value .Latest.Value}} ({{.Latest.Year}}) |
+| 10-year change | {{cell (change . 10)}} |
+| 30-year change | {{cell (change . 30)}} |
+| Record high | {{.Value .High.Value}} ({{.High.Year}}) |
+| Record low | {{.Value .Low.Value}} ({{.Low.Year}}) |
+| Coverage | {{.FirstYear}}-{{.LastYear}}, {{.Years}} measured years, {{.Series}} series |
+| Missing years | {{gaps .Gaps}} |
+{{- range .Breaks}}
+| Series break | {{.Year}}: {{cell .Label}} |
+{{- end}}
+| Data quality | {{quality .}} |
+{{else}}
+No data has been loaded for this stat.
+{{end}}
+{{- with findings .}}
+{{range .}}- {{.}}
+{{end}}{{end}}
+Source: {{.Citation}}
+{{end}}
+## Sources
+
+| Source | Last download | Rows | Years | Status |
+|--------|---------------|------|-------|--------|
+{{- range .Sources}}
+| {{cell .Name}} | {{downloaded .}} | {{.RowCount}} | {{cell .YearsAvailable}} | {{cell .Status}} |
+{{- end}}
+`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Funcs(htmltemplate.FuncMap{
+	"chart": func(m Metric) htmltemplate.HTML {
+		return htmltemplate.HTML(generators.StatChart(m.data, m.Metric).SVG())
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Education Statistics Report</title>
+<style>
+body { font-family: system-ui, -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; color: #212529; max-width: 60rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.5; }
+table { border-collapse: collapse; margin: 1rem 0; }
+th, td { border: 1px solid #dee2e6; padding: .35rem .6rem; text-align: left; vertical-align: top; }
+th { background: #f8f9fa; }
+svg { max-width: 100%; height: auto; }
+.muted { color: #6c757d; }
+.error { color: #dc3545; }
+</style>
+</head>
+<body>
+<h1>Education Statistics Report</h1>
+<p>Data as of {{.Generated}}. {{validation .Validation}}</p>
+
+<table>
+<thead><tr><th>Stat</th><th>Latest</th><th>10-year change</th><th>30-year change</th><th>Data quality</th></tr></thead>
+<tbody>
+{{- range .Metrics}}
+{{- if .Available}}
+<tr><td><a href="#{{.ID}}">{{.Title}}</a></td><td>{{.Value .Latest.Value}} ({{.Latest.Year}})</td><td>{{change . 10}}</td><td>{{change . 30}}</td><td>{{quality .}}</td></tr>
+{{- else}}
+<tr><td>{{.Title}}</td><td class="muted">no data</td><td></td><td></td><td>{{quality .}}</td></tr>
+{{- end}}
+{{- end}}
+</tbody>
+</table>
+{{range .Metrics}}
+<section id="{{.ID}}">
+<h2>{{.Title}}</h2>
+<p class="muted">{{.Description}}.</p>
+{{- if .Available}}
+<p>{{.Summary}}</p>
+{{chart .}}
+<table>
+<tr><th>Latest</th><td>{{.Value .Latest.Value}} ({{.Latest.Year}})</td></tr>
+<tr><th>10-year change</th><td>{{change . 10}}</td></tr>
+<tr><th>30-year change</th><td>{{change . 30}}</td></tr>
+<tr><th>Record high</th><td>{{.Value .High.Value}} ({{.High.Year}})</td></tr>
+<tr><th>Record low</th><td>{{.Value .Low.Value}} ({{.Low.Year}})</td></tr>
+<tr><th>Coverage</th><td>{{.FirstYear}}-{{.LastYear}}, {{.Years}} measured years, {{.Series}} series</td></tr>
+<tr><th>Missing years</th><td>{{gaps .Gaps}}</td></tr>
+{{- range .Breaks}}
+<tr><th>Series break</th><td>{{.Year}}: {{.Label}}</td></tr>
+{{- end}}
+<tr><th>Data quality</th><td>{{quality .}}</td></tr>
+</table>
+{{- else}}
+<p>No data has been loaded for this stat.</p>
+{{- end}}
+{{- with findings .}}
+<ul>
+{{- range .}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+<p class="muted">Source: {{.Citation}}</p>
+</section>
+{{end}}
+<h2>Sources</h2>
+<table>
+<thead><tr><th>Source</th><th>Last download</th><th>Rows</th><th>Years</th><th>Status</th></tr></thead>
+<tbody>
+{{- range .Sources}}
+<tr><td>{{.Name}}</td><td>{{downloaded .}}</td><td>{{.RowCount}}</td><td>{{.YearsAvailable}}</td><td>{{.Status}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,268 @@
+// Package report summarises every published stat for readers who want a
+// document rather than JSON: the latest value, how it changed over ten and
+// thirty years, its record high and low, series breaks, coverage, data
+// quality findings and citations.
+//
+// Figures come from generators.HugoGenerator's Stats, the same series
+// generate-assets publishes, so the report and the site agree.
+package report
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aallbrig/proficiency-comparison/internal/catalog"
+	"github.com/aallbrig/proficiency-comparison/internal/cohorts"
+	"github.com/aallbrig/proficiency-comparison/internal/coverage"
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	"github.com/aallbrig/proficiency-comparison/internal/generators"
+	"github.com/aallbrig/proficiency-comparison/internal/validate"
+)
+
+// ChangeSpans are the periods, in years, each stat's change is reported
+// over.
+var ChangeSpans = []int{10, 30}
+
+// Point is one measured value.
+type Point struct {
+	Year  int     `json:"year"`
+	Value float64 `json:"value"`
+}
+
+// Change compares the latest value with the one measured Span years
+// earlier, or the nearest year to it within the stat's search window.
+type Change struct {
+	Span     int     `json:"span"`
+	From     Point   `json:"from"`
+	Absolute float64 `json:"absolute"`
+	// Relative is Absolute as a percentage of From; nil when From is zero.
+	Relative *float64 `json:"relative"`
+	// Breaks are the series breaks between From and the latest year.
+	Breaks []catalog.Break `json:"breaks,omitempty"`
+}
+
+// Metric is one stat's section of the report.
+type Metric struct {
+	catalog.Metric
+	// Available is false when the stat has no data; the other fields are
+	// then empty.
+	Available bool     `json:"available"`
+	Latest    Point    `json:"latest"`
+	Changes   []Change `json:"changes"`
+	High      Point    `json:"high"`
+	Low       Point    `json:"low"`
+	// Breaks are the catalog's series breaks within the years covered.
+	Breaks []catalog.Break `json:"breaks,omitempty"`
+	// Coverage of the headline series, and how many breakdowns the stat
+	// publishes in all.
+	FirstYear int            `json:"firstYear"`
+	LastYear  int            `json:"lastYear"`
+	Years     int            `json:"years"`
+	Gaps      []coverage.Gap `json:"gaps,omitempty"`
+	Series    int            `json:"series"`
+	// Findings are the latest validation run's results for the stat's
+	// table.
+	Findings []validate.Result `json:"findings,omitempty"`
+	Errors   int               `json:"errors"`
+	Warnings int               `json:"warnings"`
+	// Summary is the stat's figures as a sentence or two.
+	Summary string `json:"summary"`
+
+	// data is the published series, kept for charting.
+	data generators.StatData
+}
+
+// Report is the whole document.
+type Report struct {
+	// Generated is the date the data last changed, as in index.json.
+	Generated  string                    `json:"generated"`
+	Metrics    []Metric                  `json:"metrics"`
+	Validation validate.Summary          `json:"validation"`
+	Sources    []database.SourceMetadata `json:"sources"`
+}
+
+// Build reads every stat through gen and the latest validation run and
+// source downloads from db.
+func Build(db *sql.DB, gen *generators.HugoGenerator) (Report, error) {
+	var r Report
+	var err error
+	if r.Generated, err = gen.GeneratedDate(); err != nil {
+		return r, err
+	}
+	stats, err := gen.Stats()
+	if err != nil {
+		return r, fmt.Errorf("failed to load stats: %w", err)
+	}
+	if r.Validation, err = validate.LatestSummary(db); err != nil {
+		return r, fmt.Errorf("failed to read validation results: %w", err)
+	}
+	if r.Sources, err = database.GetSourceMetadata(db); err != nil {
+		return r, fmt.Errorf("failed to read source metadata: %w", err)
+	}
+
+	for _, m := range catalog.Published {
+		metric := Metric{Metric: m}
+		if data, ok := stats[m.ID]; ok {
+			metric = summarize(m, data)
+		}
+		for _, res := range r.Validation.Results {
+			if res.Table != m.Table {
+				continue
+			}
+			metric.Findings = append(metric.Findings, res)
+			if res.Severity == validate.SeverityError {
+				metric.Errors++
+			} else {
+				metric.Warnings++
+			}
+		}
+		r.Metrics = append(r.Metrics, metric)
+	}
+	return r, nil
+}
+
+// summarize computes a stat's figures from its measured headline points;
+// years filled in by resampling are left out.
+func summarize(m catalog.Metric, data generators.StatData) Metric {
+	out := Metric{Metric: m, Series: len(data.Series), data: data}
+	var points []cohorts.Point
+	for _, dp := range data.Years {
+		if !dp.Imputed {
+			points = append(points, cohorts.Point{Year: dp.Year, Value: dp.Value})
+		}
+	}
+	if len(points) == 0 {
+		return out
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Year < points[j].Year })
+
+	out.Available = true
+	latest := points[len(points)-1]
+	out.Latest = Point{latest.Year, latest.Value}
+	out.High, out.Low = out.Latest, out.Latest
+	years := make([]int, len(points))
+	for i, p := range points {
+		years[i] = p.Year
+		// Ties go to the most recent year.
+		if p.Value >= out.High.Value {
+			out.High = Point{p.Year, p.Value}
+		}
+		if p.Value <= out.Low.Value {
+			out.Low = Point{p.Year, p.Value}
+		}
+	}
+	out.FirstYear, out.LastYear, out.Years = years[0], latest.Year, len(years)
+	out.Gaps = coverage.FindGaps(years)
+	out.Breaks = m.BreaksBetween(out.FirstYear, out.LastYear)
+
+	for _, span := range ChangeSpans {
+		from, ok := cohorts.Nearest(points, latest.Year-span, m.Cohort.SearchWindow)
+		if !ok || from.Year >= latest.Year {
+			continue
+		}
+		c := Change{
+			Span:     span,
+			From:     Point{from.Year, from.Value},
+			Absolute: latest.Value - from.Value,
+			Breaks:   m.BreaksBetween(from.Year, latest.Year),
+		}
+		if from.Value != 0 {
+			rel := c.Absolute / from.Value * 100
+			c.Relative = &rel
+		}
+		out.Changes = append(out.Changes, c)
+	}
+	out.Summary = narrative(out)
+	return out
+}
+
+// Value formats v in the metric's unit, e.g. "86.5%" or "264.0 pts".
+func (m Metric) Value(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64) + m.UnitSuffix
+}
+
+// Difference formats a change, e.g. "+7.3 points (+9.3%)".
+func (m Metric) Difference(c Change) string {
+	s := fmt.Sprintf("%+.1f", c.Absolute)
+	if unit := m.changeUnit(); unit != "" {
+		s += " " + unit
+	}
+	if c.Relative != nil {
+		s += fmt.Sprintf(" (%+.1f%%)", *c.Relative)
+	}
+	return s
+}
+
+// changeUnit names the unit of a difference: a change in a percentage is
+// in percentage points.
+func (m Metric) changeUnit() string {
+	switch m.Unit {
+	case "percent":
+		return "percentage points"
+	case "scale_score":
+		return "points"
+	}
+	return ""
+}
+
+// narrative describes a stat's figures in plain sentences.
+func narrative(m Metric) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s was %s in %d", m.ShortTitle, m.Value(m.Latest.Value), m.Latest.Year)
+	for i, c := range m.Changes {
+		sep := ", "
+		if i > 0 {
+			sep = " and "
+		}
+		fmt.Fprintf(&b, "%s%s from %s in %d", sep, direction(m, c.Absolute), m.Value(c.From.Value), c.From.Year)
+	}
+	b.WriteString(".")
+
+	switch {
+	case m.Years == 1:
+	case m.High.Year == m.Latest.Year:
+		fmt.Fprintf(&b, " That is the highest value on record; the low was %s in %d.", m.Value(m.Low.Value), m.Low.Year)
+	case m.Low.Year == m.Latest.Year:
+		fmt.Fprintf(&b, " That is the lowest value on record; the high was %s in %d.", m.Value(m.High.Value), m.High.Year)
+	default:
+		fmt.Fprintf(&b, " The record high was %s in %d and the low %s in %d.",
+			m.Value(m.High.Value), m.High.Year, m.Value(m.Low.Value), m.Low.Year)
+	}
+
+	var crossed []string
+	seen := make(map[int]bool)
+	for _, c := range m.Changes {
+		for _, br := range c.Breaks {
+			if !seen[br.Year] {
+				seen[br.Year] = true
+				crossed = append(crossed, fmt.Sprintf("%d (%s)", br.Year, br.Label))
+			}
+		}
+	}
+	if len(crossed) > 0 {
+		fmt.Fprintf(&b, " These changes span the series break in %s, so part of the difference reflects the change of method.",
+			strings.Join(crossed, " and "))
+	}
+	return b.String()
+}
+
+// direction describes a change as "up 7.3 percentage points" or
+// "unchanged".
+func direction(m Metric, delta float64) string {
+	if math.Abs(delta) < 0.05 {
+		return "unchanged"
+	}
+	word := "up"
+	if delta < 0 {
+		word = "down"
+	}
+	s := fmt.Sprintf("%s %.1f", word, math.Abs(delta))
+	if unit := m.changeUnit(); unit != "" {
+		s += " " + unit
+	}
+	return s
+}
@@ -0,0 +1,234 @@
+package report
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/aallbrig/proficiency-comparison/internal/generators"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func setupReportTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema := `
+	CREATE TABLE educational_attainment (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		year INTEGER NOT NULL, age_group TEXT NOT NULL DEFAULT '25plus',
+		education_level TEXT NOT NULL, percentage REAL, gender TEXT, race TEXT,
+		source TEXT NOT NULL
+	);
+	CREATE TABLE literacy_rates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		year INTEGER NOT NULL, age_group TEXT NOT NULL, rate REAL, gender TEXT,
+		source TEXT NOT NULL
+	);
+	CREATE TABLE graduation_rates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		year INTEGER NOT NULL, rate REAL, cohort_year INTEGER,
+		state TEXT, demographics TEXT, source TEXT NOT NULL
+	);
+	CREATE TABLE enrollment_rates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		year INTEGER NOT NULL, age_group TEXT NOT NULL, enrollment_rate REAL,
+		level TEXT, state TEXT, demographics TEXT, source TEXT NOT NULL
+	);
+	CREATE TABLE test_proficiency (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		year INTEGER NOT NULL, subject TEXT NOT NULL, grade INTEGER NOT NULL,
+		avg_score REAL, proficiency_level TEXT, percentage_proficient REAL,
+		state TEXT, demographics TEXT, source TEXT NOT NULL
+	);
+	CREATE TABLE early_childhood (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		year INTEGER NOT NULL, cohort_year INTEGER, metric_name TEXT NOT NULL,
+		metric_value REAL, age_months INTEGER, demographics TEXT, source TEXT NOT NULL
+	);
+	CREATE TABLE validation_results (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id TEXT NOT NULL, rule TEXT NOT NULL, severity TEXT NOT NULL,
+		table_name TEXT NOT NULL, series TEXT, year INTEGER, message TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE source_metadata (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source_name TEXT NOT NULL UNIQUE, last_download DATETIME,
+		years_available TEXT, row_count INTEGER DEFAULT 0, status TEXT,
+		error_message TEXT
+	);`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	return db
+}
+
+func buildTestReport(t *testing.T) Report {
+	t.Helper()
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	db := setupReportTestDB(t)
+	rates := map[int]float64{1985: 70.0, 1990: 74.0, 2000: 80.0, 2005: 68.5, 2010: 78.0, 2020: 86.0, 2021: 86.0}
+	for year, rate := range rates {
+		if _, err := db.Exec(`INSERT INTO graduation_rates (year, rate, source) VALUES (?, ?, 'nces_digest')`, year, rate); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`
+		INSERT INTO validation_results (run_id, rule, severity, table_name, series, year, message) VALUES
+			('run-1', 'yoy_delta', 'warning', 'graduation_rates', 'rate', 2005, 'changed <fast>'),
+			('run-1', 'ok', 'ok', '', '', 0, '');
+		INSERT INTO source_metadata (source_name, last_download, years_available, row_count, status)
+			VALUES ('nces_digest', '2024-03-01 10:00:00', '1985-2021', 7, 'success');`); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Build(db, generators.NewHugoGenerator(db))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	return r
+}
+
+func findMetric(t *testing.T, r Report, id string) Metric {
+	t.Helper()
+	for _, m := range r.Metrics {
+		if m.ID == id {
+			return m
+		}
+	}
+	t.Fatalf("report has no %s section", id)
+	return Metric{}
+}
+
+func TestBuildSummarizesStat(t *testing.T) {
+	r := buildTestReport(t)
+	if r.Generated != "2023-11-14" {
+		t.Errorf("Generated = %q, want 2023-11-14", r.Generated)
+	}
+	if r.Validation.RunID != "run-1" || r.Validation.Warnings != 1 {
+		t.Errorf("Validation = %+v, want run-1 with 1 warning", r.Validation)
+	}
+
+	m := findMetric(t, r, "graduation")
+	if !m.Available {
+		t.Fatal("graduation should be available")
+	}
+	if m.Latest != (Point{2021, 86.0}) {
+		t.Errorf("Latest = %+v, want 2021 86.0", m.Latest)
+	}
+	// Ties go to the most recent year.
+	if m.High != (Point{2021, 86.0}) || m.Low != (Point{2005, 68.5}) {
+		t.Errorf("High/Low = %+v/%+v, want 2021 86.0 and 2005 68.5", m.High, m.Low)
+	}
+	if len(m.Changes) != 2 {
+		t.Fatalf("Changes = %+v, want 10- and 30-year changes", m.Changes)
+	}
+	// 2011 is missing, so the nearest measured year is used; 1991 falls back
+	// to 1990 within the search window.
+	if c := m.Changes[0]; c.From.Year != 2010 || c.Absolute != 8 {
+		t.Errorf("10-year change = %+v, want +8 since 2010", c)
+	}
+	if c := m.Changes[1]; c.From.Year != 1990 || c.Absolute != 12 || len(c.Breaks) != 1 {
+		t.Errorf("30-year change = %+v, want +12 since 1990 across one break", c)
+	}
+	if m.FirstYear != 1985 || m.LastYear != 2021 || m.Years != 7 || len(m.Gaps) == 0 {
+		t.Errorf("coverage = %d-%d, %d years, gaps %v", m.FirstYear, m.LastYear, m.Years, m.Gaps)
+	}
+	if m.Warnings != 1 || m.Errors != 0 || len(m.Findings) != 1 {
+		t.Errorf("findings = %+v, want one warning", m.Findings)
+	}
+	for _, want := range []string{"HS Graduation was 86.0% in 2021", "up 8.0 percentage points from 78.0% in 2010", "highest value on record", "series break in 2011"} {
+		if !strings.Contains(m.Summary, want) {
+			t.Errorf("Summary %q missing %q", m.Summary, want)
+		}
+	}
+
+	if findMetric(t, r, "literacy").Available {
+		t.Error("literacy has no rows and should not be available")
+	}
+}
+
+func TestLongRunNAEPChangeFlagsBreak(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	db := setupReportTestDB(t)
+	// Long-Term Trend Age 13 to 1999, Main NAEP Grade 8 from 2002.
+	scores := map[int]float64{1990: 257, 1994: 260, 1999: 259, 2002: 264, 2013: 268, 2022: 260}
+	for year, score := range scores {
+		if _, err := db.Exec(`INSERT INTO test_proficiency (year, subject, grade, avg_score, source) VALUES (?, 'reading', 8, ?, 'naep')`, year, score); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := Build(db, generators.NewHugoGenerator(db))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	m := findMetric(t, r, "proficiency")
+	if len(m.Changes) != 2 {
+		t.Fatalf("Changes = %+v, want 10- and 30-year changes", m.Changes)
+	}
+	if c := m.Changes[0]; c.From.Year != 2013 || len(c.Breaks) != 0 {
+		t.Errorf("10-year change = %+v, want one since 2013 within Main NAEP", c)
+	}
+	if c := m.Changes[1]; c.From.Year != 1990 || len(c.Breaks) != 1 || c.Breaks[0].Year != 2002 {
+		t.Errorf("30-year change = %+v, want one since 1990 across the 2002 break", c)
+	}
+	if !strings.Contains(m.Summary, "series break in 2002 (Main NAEP Grade 8 replaces Long-Term Trend Age 13)") {
+		t.Errorf("Summary %q does not caveat the 2002 break", m.Summary)
+	}
+
+	var md bytes.Buffer
+	if err := Write(&md, r, FormatMarkdown); err != nil {
+		t.Fatalf("Write md: %v", err)
+	}
+	for _, want := range []string{
+		"| 10-year change | -8.0 points (-3.0%) since 2013 |",
+		"| 30-year change | +3.0 points (+1.2%) since 1990, across the 2002 series break |",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("markdown missing %q", want)
+		}
+	}
+}
+
+func TestWriteFormats(t *testing.T) {
+	r := buildTestReport(t)
+	m := findMetric(t, r, "graduation")
+
+	var md bytes.Buffer
+	if err := Write(&md, r, FormatMarkdown); err != nil {
+		t.Fatalf("Write md: %v", err)
+	}
+	for _, want := range []string{
+		"| High School Graduation Rates | 86.0% (2021) |",
+		"| 10-year change | +8.0 percentage points (+10.3%) since 2010, across the 2011 series break |",
+		"| Series break | 2011: ",
+		"Source: " + m.Citation,
+		"| nces_digest | 2024-03-01 | 7 | 1985-2021 | success |",
+		"run-1: 0 errors, 1 warning.",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("markdown missing %q", want)
+		}
+	}
+
+	var html bytes.Buffer
+	if err := Write(&html, r, FormatHTML); err != nil {
+		t.Fatalf("Write html: %v", err)
+	}
+	out := html.String()
+	if !strings.Contains(out, `<section id="graduation">`) || !strings.Contains(out, "<svg") {
+		t.Error("html report should have a section with an inline chart per stat")
+	}
+	if strings.Contains(out, "<fast>") || !strings.Contains(out, "&lt;fast&gt;") {
+		t.Error("html report should escape finding messages")
+	}
+
+	if err := Write(&html, r, "pdf"); err == nil {
+		t.Error("Write should reject unknown formats")
+	}
+}
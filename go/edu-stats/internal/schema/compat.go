@@ -0,0 +1,154 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Breaking lists the ways a document valid under kind's schema at version
+// newer could break a reader written against version older: a property
+// older required that newer no longer requires, or one whose type newer
+// widens. Properties newer adds are not breaking.
+func Breaking(kind string, older, newer int) ([]string, error) {
+	oldName, newName := Name(kind, older), Name(kind, newer)
+	oldSchema, err := load(oldName)
+	if err != nil {
+		return nil, err
+	}
+	newSchema, err := load(newName)
+	if err != nil {
+		return nil, err
+	}
+	c := &compatChecker{seen: make(map[string]bool)}
+	c.compare(oldName, oldSchema, newName, newSchema, "")
+	return c.breaks, nil
+}
+
+type compatChecker struct {
+	breaks []string
+	// seen stops recursion through self-referencing schemas.
+	seen map[string]bool
+}
+
+func (c *compatChecker) follow(doc string, s map[string]interface{}) (string, map[string]interface{}) {
+	for {
+		ref, ok := s["$ref"].(string)
+		if !ok {
+			return doc, s
+		}
+		refDoc, target, err := resolve(doc, ref)
+		if err != nil {
+			return doc, s
+		}
+		doc, s = refDoc, target
+	}
+}
+
+func (c *compatChecker) compare(oldDoc string, oldS map[string]interface{}, newDoc string, newS map[string]interface{}, at string) {
+	key := fmt.Sprintf("%s|%s", at, oldDoc)
+	if c.seen[key] {
+		return
+	}
+	c.seen[key] = true
+
+	oldDoc, oldS = c.follow(oldDoc, oldS)
+	newDoc, newS = c.follow(newDoc, newS)
+
+	oldTypes, newTypes := types(oldS), types(newS)
+	if oldTypes != nil && newTypes != nil {
+		for t := range newTypes {
+			if !oldTypes[t] && !(t == "integer" && oldTypes["number"]) {
+				c.breaks = append(c.breaks, fmt.Sprintf("%s: type %s was not allowed before", pathOrRoot(at), t))
+			}
+		}
+	}
+
+	newRequired := make(map[string]bool)
+	if req, ok := newS["required"].([]interface{}); ok {
+		for _, r := range req {
+			if name, ok := r.(string); ok {
+				newRequired[name] = true
+			}
+		}
+	}
+	if req, ok := oldS["required"].([]interface{}); ok {
+		for _, r := range req {
+			if name, ok := r.(string); ok && !newRequired[name] {
+				c.breaks = append(c.breaks, fmt.Sprintf("%s: %q is no longer required", pathOrRoot(at), name))
+			}
+		}
+	}
+
+	oldProps, _ := oldS["properties"].(map[string]interface{})
+	newProps, _ := newS["properties"].(map[string]interface{})
+	names := make([]string, 0, len(oldProps))
+	for name := range oldProps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		op, _ := oldProps[name].(map[string]interface{})
+		np, _ := newProps[name].(map[string]interface{})
+		if op == nil || np == nil {
+			continue
+		}
+		c.compare(oldDoc, op, newDoc, np, at+"/"+escapePointer(name))
+	}
+
+	if oi, ok := oldS["items"].(map[string]interface{}); ok {
+		if ni, ok := newS["items"].(map[string]interface{}); ok {
+			c.compare(oldDoc, oi, newDoc, ni, at+"/*")
+		}
+	}
+	if oa, ok := oldS["additionalProperties"].(map[string]interface{}); ok {
+		if na, ok := newS["additionalProperties"].(map[string]interface{}); ok {
+			c.compare(oldDoc, oa, newDoc, na, at+"/*")
+		}
+	}
+}
+
+func pathOrRoot(at string) string {
+	if at == "" {
+		return "/"
+	}
+	return at
+}
+
+// types returns the JSON types a schema admits, or nil when it does not
+// constrain them.
+func types(s map[string]interface{}) map[string]bool {
+	set := make(map[string]bool)
+	switch t := s["type"].(type) {
+	case string:
+		set[t] = true
+	case []interface{}:
+		for _, v := range t {
+			if name, ok := v.(string); ok {
+				set[name] = true
+			}
+		}
+	}
+	literals := []interface{}{}
+	if v, ok := s["const"]; ok {
+		literals = append(literals, v)
+	}
+	if opts, ok := s["enum"].([]interface{}); ok {
+		literals = append(literals, opts...)
+	}
+	for _, v := range literals {
+		switch v.(type) {
+		case string:
+			set["string"] = true
+		case float64:
+			set["number"] = true
+		case bool:
+			set["boolean"] = true
+		case nil:
+			set["null"] = true
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	return set
+}
@@ -0,0 +1,357 @@
+// Package schema holds the JSON Schema documents for the files
+// generate-assets publishes (stat files, index.json, cohorts.json,
+// generations.json, correlations.json and manifest.json) and checks documents against them. Each version of the
+// output contract has its own set of schemas so the generator can keep
+// emitting an older shape while the site moves to a newer one.
+//
+// Validation covers the keywords the schemas use: $ref (within a document
+// or to another published schema), type, const, enum, required,
+// properties, additionalProperties, items and minimum.
+package schema
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed schemas/*.json
+var files embed.FS
+
+// Current is the schema version generate-assets writes by default.
+const Current = 2
+
+// Versions lists every supported schema version, oldest first.
+var Versions = []int{1, 2}
+
+// Kinds of generated file, in the order they are published.
+const (
+	KindStat         = "stat"
+	KindIndex        = "index"
+	KindCohorts      = "cohorts"
+	KindGenerations  = "generations"
+	KindCorrelations = "correlations"
+	KindManifest     = "manifest"
+)
+
+// Name returns the file name of a kind's schema at version.
+func Name(kind string, version int) string {
+	return fmt.Sprintf("%s.v%d.schema.json", kind, version)
+}
+
+// CheckVersion reports whether version is supported.
+func CheckVersion(version int) error {
+	for _, v := range Versions {
+		if v == version {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported schema version %d (supported: %s)", version, versionList())
+}
+
+func versionList() string {
+	parts := make([]string, len(Versions))
+	for i, v := range Versions {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Has reports whether a kind is part of the contract at version. Version 1
+// predates cohorts.json, generations.json, correlations.json and
+// manifest.json.
+func Has(kind string, version int) bool {
+	_, err := files.ReadFile(path.Join("schemas", Name(kind, version)))
+	return err == nil
+}
+
+// Documents returns every schema file by name, for publishing next to the
+// generated data.
+func Documents() (map[string][]byte, error) {
+	entries, err := files.ReadDir("schemas")
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string][]byte, len(entries))
+	for _, e := range entries {
+		data, err := files.ReadFile(path.Join("schemas", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		docs[e.Name()] = data
+	}
+	return docs, nil
+}
+
+// Problem is one place a document does not match its schema.
+type Problem struct {
+	// Path is a JSON pointer to the offending value ("" is the root).
+	Path    string
+	Message string
+}
+
+// Error lists every problem found in a document.
+type Error struct {
+	Schema   string
+	Problems []Problem
+}
+
+func (e *Error) Error() string {
+	const shown = 5
+	parts := make([]string, 0, shown)
+	for i, p := range e.Problems {
+		if i == shown {
+			parts = append(parts, fmt.Sprintf("and %d more", len(e.Problems)-shown))
+			break
+		}
+		at := p.Path
+		if at == "" {
+			at = "/"
+		}
+		parts = append(parts, at+": "+p.Message)
+	}
+	return fmt.Sprintf("does not match %s: %s", e.Schema, strings.Join(parts, "; "))
+}
+
+// Validate checks a JSON document against the schema for kind at version.
+// It returns an *Error listing every mismatch.
+func Validate(kind string, version int, doc []byte) error {
+	name := Name(kind, version)
+	s, err := load(name)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("parse document: %w", err)
+	}
+	v := &validator{}
+	v.check(name, s, value, "")
+	if len(v.problems) > 0 {
+		return &Error{Schema: name, Problems: v.problems}
+	}
+	return nil
+}
+
+// ValidateValue marshals v and validates it like Validate.
+func ValidateValue(kind string, version int, v interface{}) error {
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return Validate(kind, version, doc)
+}
+
+func load(name string) (map[string]interface{}, error) {
+	data, err := files.ReadFile(path.Join("schemas", name))
+	if err != nil {
+		return nil, fmt.Errorf("no schema %s", name)
+	}
+	var s map[string]interface{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("schema %s: %w", name, err)
+	}
+	return s, nil
+}
+
+// resolve follows a $ref relative to the document named base and returns
+// the target schema and the document it lives in.
+func resolve(base, ref string) (string, map[string]interface{}, error) {
+	docName, pointer := base, ref
+	if i := strings.Index(ref, "#"); i >= 0 {
+		if i > 0 {
+			docName = ref[:i]
+		}
+		pointer = ref[i+1:]
+	} else {
+		docName, pointer = ref, ""
+	}
+	doc, err := load(docName)
+	if err != nil {
+		return "", nil, err
+	}
+	var node interface{} = doc
+	for _, part := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return "", nil, fmt.Errorf("bad $ref %s in %s", ref, base)
+		}
+		if node, ok = m[part]; !ok {
+			return "", nil, fmt.Errorf("bad $ref %s in %s", ref, base)
+		}
+	}
+	target, ok := node.(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("bad $ref %s in %s", ref, base)
+	}
+	return docName, target, nil
+}
+
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) fail(at, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Path: at, Message: fmt.Sprintf(format, args...)})
+}
+
+// check validates value against schema s, which lives in document doc.
+func (v *validator) check(doc string, s map[string]interface{}, value interface{}, at string) {
+	if ref, ok := s["$ref"].(string); ok {
+		refDoc, target, err := resolve(doc, ref)
+		if err != nil {
+			v.fail(at, "%v", err)
+			return
+		}
+		v.check(refDoc, target, value, at)
+		return
+	}
+
+	if want, ok := s["type"]; ok && !matchesType(want, value) {
+		v.fail(at, "expected %s, got %s", typeList(want), typeOf(value))
+		return
+	}
+	if want, ok := s["const"]; ok && !equal(want, value) {
+		v.fail(at, "expected %v, got %v", want, value)
+	}
+	if options, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, o := range options {
+			if equal(o, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(at, "%v is not one of %v", value, options)
+		}
+	}
+	if min, ok := s["minimum"].(float64); ok {
+		if n, isNum := value.(json.Number); isNum {
+			if f, _ := n.Float64(); f < min {
+				v.fail(at, "%v is below the minimum %v", n, min)
+			}
+		}
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		v.checkObject(doc, s, val, at)
+	case []interface{}:
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			for i, item := range val {
+				v.check(doc, items, item, fmt.Sprintf("%s/%d", at, i))
+			}
+		}
+	}
+}
+
+func (v *validator) checkObject(doc string, s map[string]interface{}, obj map[string]interface{}, at string) {
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := obj[name]; !ok {
+				v.fail(at, "missing required property %q", name)
+			}
+		}
+	}
+	props, _ := s["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		child := at + "/" + escapePointer(k)
+		if ps, ok := props[k].(map[string]interface{}); ok {
+			v.check(doc, ps, obj[k], child)
+			continue
+		}
+		switch extra := s["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				v.fail(at, "unexpected property %q", k)
+			}
+		case map[string]interface{}:
+			v.check(doc, extra, obj[k], child)
+		}
+	}
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func typeOf(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if f, err := val.Float64(); err == nil && f == math.Trunc(f) && !strings.ContainsAny(val.String(), ".eE") {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func typeList(want interface{}) string {
+	switch w := want.(type) {
+	case string:
+		return w
+	case []interface{}:
+		parts := make([]string, len(w))
+		for i, t := range w {
+			parts[i] = fmt.Sprint(t)
+		}
+		return strings.Join(parts, " or ")
+	}
+	return fmt.Sprint(want)
+}
+
+func matchesType(want, value interface{}) bool {
+	got := typeOf(value)
+	accepts := func(t string) bool {
+		return t == got || (t == "number" && got == "integer")
+	}
+	switch w := want.(type) {
+	case string:
+		return accepts(w)
+	case []interface{}:
+		for _, t := range w {
+			if s, ok := t.(string); ok && accepts(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// equal compares a schema literal (decoded as float64) with a document value
+// (decoded with json.Number).
+func equal(literal, value interface{}) bool {
+	if n, ok := value.(json.Number); ok {
+		f, err := n.Float64()
+		lf, isNum := literal.(float64)
+		return err == nil && isNum && f == lf
+	}
+	return literal == value
+}
@@ -0,0 +1,126 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateAcceptsBothVersions(t *testing.T) {
+	v1 := `{"name":"Literacy","description":"d","source":"s","data":[{"year":2000,"value":99.5}]}`
+	if err := Validate(KindStat, 1, []byte(v1)); err != nil {
+		t.Errorf("v1 stat: %v", err)
+	}
+	v2 := `{"schemaVersion":2,"id":"literacy","name":"Literacy","description":"d","source":"s","unit":"percent",
+		"data":[{"year":2000,"value":99.5,"imputed":true,"method":"linear"}],
+		"series":[{"key":"wb;age_group=adult","source":"wb","dimensions":{"age_group":"adult"},"data":[]}]}`
+	if err := Validate(KindStat, 2, []byte(v2)); err != nil {
+		t.Errorf("v2 stat: %v", err)
+	}
+}
+
+func TestValidateReportsProblems(t *testing.T) {
+	doc := `{"schemaVersion":1,"id":"x","name":"X","description":"d","unit":"furlongs",
+		"data":[{"year":2000.5,"value":"high"}],"extra":true}`
+	err := Validate(KindStat, 2, []byte(doc))
+	var verr *Error
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	want := map[string]string{
+		"/schemaVersion": "expected 2",
+		"/unit":          "not one of",
+		"/data/0/year":   "expected integer",
+		"/data/0/value":  "expected number",
+		"":               "missing required property \"source\"",
+	}
+	for at, msg := range want {
+		found := false
+		for _, p := range verr.Problems {
+			if p.Path == at && strings.Contains(p.Message, msg) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("no problem %q at %q in %v", msg, at, verr.Problems)
+		}
+	}
+	if !strings.Contains(err.Error(), `unexpected property "extra"`) {
+		t.Errorf("error should name the unexpected property: %v", err)
+	}
+}
+
+func TestValidateFollowsReferencesAcrossDocuments(t *testing.T) {
+	doc := `{"schemaVersion":2,"stages":{"literacy":{"offset":20,"label":"at age ~20"}},"generations":[]}`
+	err := Validate(KindGenerations, 2, []byte(doc))
+	if err == nil || !strings.Contains(err.Error(), `/stages/literacy: missing required property "searchWindow"`) {
+		t.Errorf("expected the stage schema from cohorts.v2 to apply, got %v", err)
+	}
+}
+
+func TestEveryVersionHasStatAndIndex(t *testing.T) {
+	for _, v := range Versions {
+		for _, kind := range []string{KindStat, KindIndex} {
+			if !Has(kind, v) {
+				t.Errorf("version %d has no %s schema", v, kind)
+			}
+		}
+	}
+	if Has(KindCohorts, 1) {
+		t.Error("cohorts.json is not part of version 1")
+	}
+	if err := CheckVersion(3); err == nil {
+		t.Error("version 3 should be unsupported")
+	}
+	docs, err := Documents()
+	if err != nil {
+		t.Fatalf("Documents: %v", err)
+	}
+	for name, data := range docs {
+		if !json.Valid(data) {
+			t.Errorf("%s is not valid JSON", name)
+		}
+	}
+}
+
+// TestCurrentVersionIsCompatible guards the site's JavaScript: every field a
+// version 1 reader relies on must still be there, with the same type, in
+// the current version.
+func TestCurrentVersionIsCompatible(t *testing.T) {
+	for _, kind := range []string{KindStat, KindIndex} {
+		breaks, err := Breaking(kind, 1, Current)
+		if err != nil {
+			t.Fatalf("Breaking(%s): %v", kind, err)
+		}
+		if len(breaks) > 0 {
+			t.Errorf("%s v%d breaks v1 readers: %v", kind, Current, breaks)
+		}
+	}
+}
+
+func TestBreakingDetectsRemovedAndWidenedFields(t *testing.T) {
+	older := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"name", "value"},
+		"properties": map[string]interface{}{
+			"name":  map[string]interface{}{"type": "string"},
+			"value": map[string]interface{}{"type": "number"},
+		},
+	}
+	newer := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"value"},
+		"properties": map[string]interface{}{
+			"value": map[string]interface{}{"type": []interface{}{"number", "null"}},
+		},
+	}
+	c := &compatChecker{seen: make(map[string]bool)}
+	c.compare("old", older, "new", newer, "")
+	if len(c.breaks) != 2 {
+		t.Fatalf("expected 2 breaking changes, got %v", c.breaks)
+	}
+	if !strings.Contains(c.breaks[0], `"name" is no longer required`) || !strings.Contains(c.breaks[1], "/value: type null") {
+		t.Errorf("unexpected breaking changes %v", c.breaks)
+	}
+}
@@ -0,0 +1,386 @@
+package validate
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Unit is the unit a value column is published in. Each unit carries the
+// physically possible range for its values.
+type Unit string
+
+const (
+	UnitPercent    Unit = "percent"
+	UnitScaleScore Unit = "scale_score"
+)
+
+// unitBounds are the hard limits for each unit: a percentage of 350 or a
+// NAEP scale score of 600 can only come from a bad parse.
+var unitBounds = map[Unit][2]float64{
+	UnitPercent:    {0, 100},
+	UnitScaleScore: {0, 500},
+}
+
+// TableRules declares the checks for one value column of an observation
+// table. Zero-valued fields disable their check.
+type TableRules struct {
+	Table  database.ObservationTable
+	Column string
+	Unit   Unit
+	// Min and Max narrow the unit's bounds to the plausible range for this
+	// measure.
+	Min, Max *float64
+	// MaxYoYDelta is the largest change per year allowed between consecutive
+	// observations of a series, in the column's unit.
+	MaxYoYDelta float64
+	// MaxYearGap is the widest spacing allowed between consecutive years of
+	// a series; years must also be strictly increasing.
+	MaxYearGap int
+	// RequiredYears must be present in every series that spans them.
+	RequiredYears []int
+	// UniqueKeys rejects rows that share year, source and key columns.
+	UniqueKeys bool
+}
+
+func bound(v float64) *float64 { return &v }
+
+// DefaultRules are the checks run by the validate pipeline step.
+var DefaultRules = []TableRules{
+	{
+		Table: database.LiteracyRates, Column: "rate", Unit: UnitPercent,
+		Min: bound(50), MaxYoYDelta: 2, MaxYearGap: 15, UniqueKeys: true,
+	},
+	{
+		Table: database.EducationalAttainment, Column: "percentage", Unit: UnitPercent,
+		MaxYoYDelta: 3, MaxYearGap: 10, UniqueKeys: true,
+	},
+	{
+		Table: database.GraduationRates, Column: "rate", Unit: UnitPercent,
+		Min: bound(40), MaxYoYDelta: 5, MaxYearGap: 5, UniqueKeys: true,
+		RequiredYears: []int{2010, 2011},
+	},
+	{
+		Table: database.EnrollmentRates, Column: "enrollment_rate", Unit: UnitPercent,
+		Min: bound(50), MaxYoYDelta: 3, MaxYearGap: 5, UniqueKeys: true,
+	},
+	{
+		Table: database.TestProficiency, Column: "avg_score", Unit: UnitScaleScore,
+		Min: bound(150), Max: bound(350), MaxYoYDelta: 10, MaxYearGap: 5, UniqueKeys: true,
+		RequiredYears: []int{2019, 2022},
+	},
+	{
+		Table: database.TestProficiency, Column: "percentage_proficient", Unit: UnitPercent,
+		MaxYoYDelta: 10,
+	},
+	{
+		Table: database.EarlyChildhood, Column: "metric_value", UniqueKeys: true,
+	},
+}
+
+// Result is one finding from a validation run.
+type Result struct {
+	Rule     string
+	Severity Severity
+	Table    string
+	Series   string
+	Year     int
+	Message  string
+}
+
+// Summary is the outcome of a validation run.
+type Summary struct {
+	RunID    string
+	Results  []Result
+	Errors   int
+	Warnings int
+}
+
+// Run checks every rule set against db and stores the findings in
+// validation_results under a new run ID.
+func Run(db *sql.DB, rules []TableRules) (Summary, error) {
+	summary := Summary{RunID: fmt.Sprintf("validate-%s", time.Now().UTC().Format("20060102T150405.000Z"))}
+	// Taken before checking, so a load that lands mid-run leaves the run
+	// stale rather than vouching for rows it never saw.
+	version, tracked, err := database.DataVersion(db)
+	if err != nil {
+		return summary, err
+	}
+
+	for _, r := range rules {
+		results, err := Check(db, r)
+		if err != nil {
+			return summary, fmt.Errorf("%s.%s: %w", r.Table.Name, r.Column, err)
+		}
+		summary.Results = append(summary.Results, results...)
+	}
+
+	for _, res := range summary.Results {
+		if res.Severity == SeverityError {
+			summary.Errors++
+		} else {
+			summary.Warnings++
+		}
+	}
+
+	var dataVersion interface{}
+	if tracked {
+		dataVersion = version
+	}
+	if err := saveResults(db, summary, dataVersion); err != nil {
+		return summary, fmt.Errorf("failed to save validation results: %w", err)
+	}
+	return summary, nil
+}
+
+// Check evaluates one rule set and returns its findings without storing them.
+func Check(db *sql.DB, r TableRules) ([]Result, error) {
+	var results []Result
+
+	if r.UniqueKeys {
+		dups, err := duplicateKeys(db, r)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, dups...)
+	}
+
+	series, err := database.LoadSeries(db, r.Table, r.Column)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range series {
+		results = append(results, checkSeries(r, s)...)
+	}
+	return results, nil
+}
+
+func checkSeries(r TableRules, s database.Series) []Result {
+	var results []Result
+	add := func(rule string, sev Severity, year int, format string, args ...interface{}) {
+		results = append(results, Result{
+			Rule:     rule,
+			Severity: sev,
+			Table:    r.Table.Name,
+			Series:   s.Label(),
+			Year:     year,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	lo, hi := math.Inf(-1), math.Inf(1)
+	if b, ok := unitBounds[r.Unit]; ok {
+		lo, hi = b[0], b[1]
+	}
+	for _, p := range s.Points {
+		if p.Value < lo || p.Value > hi {
+			add("unit", SeverityError, p.Year, "value %.2f is outside the %s range %.0f–%.0f", p.Value, r.Unit, lo, hi)
+			continue
+		}
+		if r.Min != nil && p.Value < *r.Min {
+			add("range", SeverityError, p.Year, "value %.2f is below the minimum %.2f", p.Value, *r.Min)
+		}
+		if r.Max != nil && p.Value > *r.Max {
+			add("range", SeverityError, p.Year, "value %.2f is above the maximum %.2f", p.Value, *r.Max)
+		}
+	}
+
+	// A percentage series that never exceeds 1 was almost certainly stored as
+	// a fraction.
+	if r.Unit == UnitPercent && len(s.Points) > 1 {
+		fractional := true
+		for _, p := range s.Points {
+			if p.Value > 1 {
+				fractional = false
+				break
+			}
+		}
+		if fractional {
+			add("unit", SeverityError, 0, "all values are ≤ 1; series looks like fractions rather than percent")
+		}
+	}
+
+	for i := 1; i < len(s.Points); i++ {
+		prev, cur := s.Points[i-1], s.Points[i]
+		gap := cur.Year - prev.Year
+		if gap <= 0 {
+			add("year_coverage", SeverityError, cur.Year, "year %d follows %d; years must be strictly increasing", cur.Year, prev.Year)
+			continue
+		}
+		if r.MaxYearGap > 0 && gap > r.MaxYearGap {
+			add("year_coverage", SeverityWarning, cur.Year, "%d-year gap after %d exceeds %d", gap, prev.Year, r.MaxYearGap)
+		}
+		if r.MaxYoYDelta > 0 {
+			perYear := math.Abs(cur.Value-prev.Value) / float64(gap)
+			if perYear > r.MaxYoYDelta {
+				add("yoy_delta", SeverityError, cur.Year, "changed %.2f per year since %d (%.2f → %.2f), limit %.2f",
+					perYear, prev.Year, prev.Value, cur.Value, r.MaxYoYDelta)
+			}
+		}
+	}
+
+	if len(s.Points) > 0 {
+		first, last := s.Points[0].Year, s.Points[len(s.Points)-1].Year
+		have := make(map[int]bool, len(s.Points))
+		for _, p := range s.Points {
+			have[p.Year] = true
+		}
+		for _, y := range r.RequiredYears {
+			if y >= first && y <= last && !have[y] {
+				add("required_years", SeverityWarning, y, "required year %d is missing", y)
+			}
+		}
+	}
+
+	return results
+}
+
+func duplicateKeys(db *sql.DB, r TableRules) ([]Result, error) {
+	cols := append([]string{"year", "source"}, r.Table.KeyColumns...)
+	query := fmt.Sprintf("SELECT %s, COUNT(*) FROM %s GROUP BY %s HAVING COUNT(*) > 1",
+		strings.Join(cols, ", "), r.Table.Name, strings.Join(cols, ", "))
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Result
+	for rows.Next() {
+		var year, count int
+		var source string
+		key := make([]interface{}, len(r.Table.KeyColumns))
+		dest := []interface{}{&year, &source}
+		for i := range key {
+			dest = append(dest, &key[i])
+		}
+		dest = append(dest, &count)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		s := database.Series{Table: r.Table, Column: r.Column, Source: source, Key: key}
+		results = append(results, Result{
+			Rule:     "unique_keys",
+			Severity: SeverityError,
+			Table:    r.Table.Name,
+			Series:   s.Label(),
+			Year:     year,
+			Message:  fmt.Sprintf("%d rows share the same logical key", count),
+		})
+	}
+	return results, rows.Err()
+}
+
+func saveResults(db *sql.DB, summary Summary, dataVersion interface{}) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A run with no findings still gets a row so LatestRun can tell
+	// "validated clean" apart from "never validated".
+	if len(summary.Results) == 0 {
+		if _, err := tx.Exec(`
+			INSERT INTO validation_results (run_id, rule, severity, table_name, message, data_version)
+			VALUES (?, 'summary', 'ok', '', 'no issues found', ?)
+		`, summary.RunID, dataVersion); err != nil {
+			return err
+		}
+	}
+	for _, res := range summary.Results {
+		if _, err := tx.Exec(`
+			INSERT INTO validation_results (run_id, rule, severity, table_name, series, year, message, data_version)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, summary.RunID, res.Rule, string(res.Severity), res.Table, res.Series, nullYear(res.Year), res.Message, dataVersion); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func nullYear(y int) interface{} {
+	if y == 0 {
+		return nil
+	}
+	return y
+}
+
+// LatestRun returns the ID and error count of the most recent validation
+// run. An empty ID means validation has never run.
+func LatestRun(db *sql.DB) (string, int, error) {
+	var runID string
+	err := db.QueryRow(`SELECT run_id FROM validation_results ORDER BY id DESC LIMIT 1`).Scan(&runID)
+	if err == sql.ErrNoRows {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	var errors int
+	err = db.QueryRow(`SELECT COUNT(*) FROM validation_results WHERE run_id = ? AND severity = 'error'`, runID).Scan(&errors)
+	return runID, errors, err
+}
+
+// Current reports whether observation data is unchanged since validation
+// run runID started, by comparing the data_versions counters then and now.
+// A run recorded without counters (before they existed, or in a database
+// without data_versions) is never current.
+func Current(db *sql.DB, runID string) (bool, error) {
+	var then sql.NullInt64
+	err := db.QueryRow(`SELECT data_version FROM validation_results WHERE run_id = ? ORDER BY id DESC LIMIT 1`, runID).Scan(&then)
+	if err != nil {
+		return false, err
+	}
+	now, tracked, err := database.DataVersion(db)
+	if err != nil || !tracked || !then.Valid {
+		return false, err
+	}
+	return now == then.Int64, nil
+}
+
+// LatestSummary loads the findings of the most recent validation run. An
+// empty RunID means validation has never run.
+func LatestSummary(db *sql.DB) (Summary, error) {
+	var summary Summary
+	runID, _, err := LatestRun(db)
+	if err != nil || runID == "" {
+		return summary, err
+	}
+	summary.RunID = runID
+	rows, err := db.Query(`
+		SELECT rule, severity, table_name, COALESCE(series, ''), COALESCE(year, 0), COALESCE(message, '')
+		FROM validation_results
+		WHERE run_id = ? AND severity != 'ok'
+		ORDER BY id
+	`, runID)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r Result
+		if err := rows.Scan(&r.Rule, &r.Severity, &r.Table, &r.Series, &r.Year, &r.Message); err != nil {
+			return summary, err
+		}
+		switch r.Severity {
+		case SeverityError:
+			summary.Errors++
+		case SeverityWarning:
+			summary.Warnings++
+		}
+		summary.Results = append(summary.Results, r)
+	}
+	return summary, rows.Err()
+}
@@ -0,0 +1,180 @@
+package validate
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/aallbrig/proficiency-comparison/internal/database"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func setupValidateTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open in-memory db: %v", err)
+	}
+	schema := `
+	CREATE TABLE graduation_rates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		year INTEGER NOT NULL, rate REAL, cohort_year INTEGER,
+		state TEXT, demographics TEXT, source TEXT NOT NULL
+	);
+	CREATE TABLE test_proficiency (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		year INTEGER NOT NULL, subject TEXT, grade INTEGER, avg_score REAL,
+		proficiency_level TEXT, percentage_proficient REAL, state TEXT,
+		demographics TEXT, source TEXT NOT NULL
+	);
+	CREATE TABLE validation_results (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id TEXT NOT NULL, rule TEXT NOT NULL, severity TEXT NOT NULL,
+		table_name TEXT NOT NULL, series TEXT, year INTEGER, message TEXT,
+		data_version INTEGER, created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE data_versions (
+		table_name TEXT PRIMARY KEY, version INTEGER NOT NULL DEFAULT 0,
+		changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	return db
+}
+
+func countRule(results []Result, rule string) int {
+	n := 0
+	for _, r := range results {
+		if r.Rule == rule {
+			n++
+		}
+	}
+	return n
+}
+
+func TestCheckFlagsOutOfRangeAndJumps(t *testing.T) {
+	db := setupValidateTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO graduation_rates (year, rate, source) VALUES
+		(2000, 72.6, 'test'), (2001, 350, 'test'), (2002, 73.9, 'test'), (2003, 93.9, 'test')
+	`)
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	rules := TableRules{Table: database.GraduationRates, Column: "rate", Unit: UnitPercent, MaxYoYDelta: 5}
+	results, err := Check(db, rules)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if countRule(results, "unit") != 1 {
+		t.Errorf("expected 1 unit violation for 350%%, got %d: %+v", countRule(results, "unit"), results)
+	}
+	// 2000→2001, 2001→2002 (through the bad value) and 2002→2003 (+20 points).
+	if countRule(results, "yoy_delta") != 3 {
+		t.Errorf("expected 3 yoy_delta violations, got %d: %+v", countRule(results, "yoy_delta"), results)
+	}
+}
+
+func TestCheckDuplicatesAndRequiredYears(t *testing.T) {
+	db := setupValidateTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO test_proficiency (year, subject, grade, avg_score, source) VALUES
+		(2017, 'reading', 8, 267, 'naep'), (2022, 'reading', 8, 260, 'naep'),
+		(2022, 'reading', 8, 260, 'naep')
+	`)
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	rules := TableRules{
+		Table: database.TestProficiency, Column: "avg_score", Unit: UnitScaleScore,
+		UniqueKeys: true, RequiredYears: []int{2019},
+	}
+	results, err := Check(db, rules)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if countRule(results, "unique_keys") != 1 {
+		t.Errorf("expected 1 duplicate key finding, got %+v", results)
+	}
+	if countRule(results, "required_years") != 1 {
+		t.Errorf("expected missing 2019 to be reported, got %+v", results)
+	}
+}
+
+func TestRunStoresResults(t *testing.T) {
+	db := setupValidateTestDB(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO graduation_rates (year, rate, source) VALUES (2000, 0.72, 'test'), (2001, 0.73, 'test')`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	rules := []TableRules{{Table: database.GraduationRates, Column: "rate", Unit: UnitPercent}}
+	summary, err := Run(db, rules)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if summary.Errors != 1 {
+		t.Errorf("expected fraction-as-percent error, got %+v", summary.Results)
+	}
+
+	runID, errors, err := LatestRun(db)
+	if err != nil {
+		t.Fatalf("LatestRun: %v", err)
+	}
+	if runID != summary.RunID || errors != 1 {
+		t.Errorf("LatestRun = (%s, %d), want (%s, 1)", runID, errors, summary.RunID)
+	}
+
+	loaded, err := LatestSummary(db)
+	if err != nil {
+		t.Fatalf("LatestSummary: %v", err)
+	}
+	if loaded.RunID != summary.RunID || loaded.Errors != summary.Errors || len(loaded.Results) != len(summary.Results) {
+		t.Errorf("LatestSummary = %+v, want %+v", loaded, summary)
+	}
+}
+
+func TestCurrentTracksDataChanges(t *testing.T) {
+	db := setupValidateTestDB(t)
+	defer db.Close()
+
+	rules := []TableRules{{Table: database.GraduationRates, Column: "rate", Unit: UnitPercent}}
+	summary, err := Run(db, rules)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if current, err := Current(db, summary.RunID); err != nil || !current {
+		t.Errorf("Current right after the run = (%v, %v), want true", current, err)
+	}
+
+	// What the data_versions trigger does on a load.
+	if _, err := db.Exec(`INSERT INTO data_versions (table_name, version) VALUES ('graduation_rates', 1)`); err != nil {
+		t.Fatal(err)
+	}
+	if current, err := Current(db, summary.RunID); err != nil || current {
+		t.Errorf("Current after a load = (%v, %v), want false", current, err)
+	}
+
+	summary, err = Run(db, rules)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if current, err := Current(db, summary.RunID); err != nil || !current {
+		t.Errorf("Current after re-validating = (%v, %v), want true", current, err)
+	}
+
+	// A run recorded before the counters existed must be repeated.
+	if _, err := db.Exec(`UPDATE validation_results SET data_version = NULL`); err != nil {
+		t.Fatal(err)
+	}
+	if current, err := Current(db, summary.RunID); err != nil || current {
+		t.Errorf("Current for a run without counters = (%v, %v), want false", current, err)
+	}
+}
@@ -59,3 +59,50 @@ describe('Data charts functionality', () => {
     expect(values).toEqual([95.0, 95.2, 95.5]);
   });
 });
+
+// ─── 95% confidence interval band ─────────────────────────────────────────────
+
+describe('confidence interval band', () => {
+  const { renderChart, intervalDatasets, hasInterval, formatInterval } = require('../data-charts.js');
+
+  const points = [
+    { year: 2019, value: 263.0, standardError: 0.4, ciLow: 262.2, ciHigh: 263.8 },
+    { year: 2020, value: 262.0 },
+    { year: 2022, value: 260.0, standardError: 0.3, ciLow: 259.4, ciHigh: 260.6 }
+  ];
+
+  test('band is drawn from ciHigh down to ciLow', () => {
+    const [upper, lower] = intervalDatasets(points, 'rgba(0, 0, 0, 0.1)');
+    expect(upper.data).toEqual([263.8, null, 260.6]);
+    expect(upper.fill).toBe('+1');
+    expect(upper.backgroundColor).toBe('rgba(0, 0, 0, 0.1)');
+    expect(lower.data).toEqual([262.2, null, 259.4]);
+    expect(lower.fill).toBe(false);
+  });
+
+  test('no band without ciLow and ciHigh', () => {
+    expect(intervalDatasets([{ year: 2020, value: 95.5 }], 'red')).toEqual([]);
+    // A standard error alone, or half an interval, is not drawn.
+    expect(intervalDatasets([{ year: 2020, value: 95.5, standardError: 0.2, ciLow: 95.1 }], 'red')).toEqual([]);
+    expect(hasInterval(points)).toBe(true);
+  });
+
+  test('renderChart adds the band after the series', () => {
+    document.body.innerHTML = '<canvas id="ciChart"></canvas>';
+    let config;
+    global.Chart = function(ctx, c) { config = c; };
+
+    renderChart('ciChart', { name: 'Test Proficiency', data: points }, 'proficiency');
+    expect(config.data.datasets.map(d => d.label)).toEqual(['Test Proficiency', '95% CI upper', '95% CI lower']);
+    expect(config.data.datasets[1].data).toEqual([263.8, null, 260.6]);
+
+    renderChart('ciChart', { name: 'Literacy Rates', data: [{ year: 2020, value: 95.5 }] }, 'literacy');
+    expect(config.data.datasets).toHaveLength(1);
+    delete global.Chart;
+  });
+
+  test('interval text', () => {
+    expect(formatInterval(points[0])).toBe('262.2–263.8');
+  });
+});
+
@@ -202,6 +202,26 @@ describe('findCohortDataPoint', () => {
     });
 });
 
+describe('findCohortDataPoint with resampled series', () => {
+    // Annual series from `generate-assets --resample linear`
+    const resampled = [
+        { year: 1983, value: 257.5, imputed: true, method: 'linear' },
+        { year: 1984, value: 257 },
+        { year: 1985, value: 257.25, imputed: true, method: 'linear' },
+    ];
+
+    test('exact year match with window 0', () => {
+        const pt = findCohortDataPoint(resampled, 1985, 0);
+        expect(pt).not.toBeNull();
+        expect(pt.imputed).toBe(true);
+        expect(pt.method).toBe('linear');
+    });
+
+    test('no guessing outside the resampled span', () => {
+        expect(findCohortDataPoint(resampled, 1986, 0)).toBeNull();
+    });
+});
+
 // ─── Marker position calculation ──────────────────────────────────────────────
 
 describe('markerPosition', () => {
@@ -309,3 +329,50 @@ describe('GENERATIONS constant', () => {
         });
     });
 });
+
+// ─── index.json metadata (loaded from timeline.js itself) ─────────────────────
+
+describe('applyIndexMetadata', () => {
+    const { applyIndexMetadata, statMetadata } = require('../timeline.js');
+
+    test('takes labels, unit and life stage from the index.json entry', () => {
+        const metadata = applyIndexMetadata('proficiency', {
+            name: 'Test Proficiency',
+            shortName: 'NAEP Reading',
+            description: 'NAEP Reading score (Grade 8, age ~14)',
+            unitSuffix: ' pts',
+            cohortOffset: 14,
+            cohortLabel: 'at age ~14 (Gr.8)',
+            searchWindow: 4,
+        });
+        expect(metadata).toEqual({
+            name: 'NAEP Reading',
+            description: 'NAEP Reading score (Grade 8, age ~14)',
+            unit: ' pts',
+            cohortOffset: 14,
+            cohortLabel: 'at age ~14 (Gr.8)',
+            searchWindow: 4,
+        });
+        expect(statMetadata.proficiency).toBe(metadata);
+    });
+
+    test('keeps a zero offset and an empty unit suffix', () => {
+        const metadata = applyIndexMetadata('early_childhood', {
+            shortName: 'Early Childhood', unitSuffix: '', cohortOffset: 0, searchWindow: 0,
+        });
+        expect(metadata.cohortOffset).toBe(0);
+        expect(metadata.searchWindow).toBe(0);
+        expect(metadata.unit).toBe('');
+    });
+
+    test('a version 1 entry has a name but no life stage', () => {
+        const metadata = applyIndexMetadata('literacy', { name: 'Literacy Rates', description: 'Adult literacy' });
+        expect(metadata.name).toBe('Literacy Rates');
+        expect(metadata.cohortOffset).toBeUndefined();
+        expect(metadata.cohortLabel).toBe('');
+    });
+
+    test('has no built-in stats before index.json is read', () => {
+        expect(Object.keys(statMetadata).sort()).toEqual(['early_childhood', 'literacy', 'proficiency']);
+    });
+});
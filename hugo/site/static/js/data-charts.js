@@ -65,6 +65,9 @@ function createStatSection(container, statName, statInfo) {
     const color = colors[statName] || 'secondary';
     const icon = icons[statName] || 'graph-up';
     const yearRange = `(${statInfo.yearMin}-${statInfo.yearMax})`;
+    const citation = statInfo.citation
+        ? `<p class="small text-muted fst-italic mb-2">Source: ${statInfo.citation}</p>`
+        : '';
     
     const col = document.createElement('div');
     col.className = 'col';
@@ -79,6 +82,7 @@ function createStatSection(container, statName, statInfo) {
             </div>
             <div class="card-body">
                 <p class="small text-muted">${statInfo.description}</p>
+                ${citation}
                 <div style="height: 250px;">
                     <canvas id="${statName}-chart"></canvas>
                 </div>
@@ -233,20 +237,22 @@ function renderChart(canvasId, statData, statName) {
     
     const color = colors[statName] || { border: 'rgb(75, 192, 192)', bg: 'rgba(75, 192, 192, 0.2)' };
     
+    const datasets = [{
+        label: statData.name,
+        data: values,
+        borderColor: color.border,
+        backgroundColor: color.bg,
+        borderWidth: 2,
+        tension: 0.4,
+        pointRadius: years.length > 50 ? 0 : 3,
+        pointHoverRadius: 5
+    }].concat(intervalDatasets(statData.data, color.bg));
+    
     charts[statName] = new Chart(ctx, {
         type: 'line',
         data: {
             labels: years,
-            datasets: [{
-                label: statData.name,
-                data: values,
-                borderColor: color.border,
-                backgroundColor: color.bg,
-                borderWidth: 2,
-                tension: 0.4,
-                pointRadius: years.length > 50 ? 0 : 3,
-                pointHoverRadius: 5
-            }]
+            datasets: datasets
         },
         options: {
             responsive: true,
@@ -265,6 +271,9 @@ function renderChart(canvasId, statData, statName) {
                 tooltip: {
                     mode: 'index',
                     intersect: false,
+                    filter: function(item) {
+                        return item.datasetIndex === 0;
+                    },
                     callbacks: {
                         label: function(context) {
                             let label = context.dataset.label || '';
@@ -275,6 +284,10 @@ function renderChart(canvasId, statData, statName) {
                             if (statName !== 'proficiency' && statName !== 'early_childhood') {
                                 label += '%';
                             }
+                            const point = statData.data[context.dataIndex];
+                            if (hasInterval([point])) {
+                                label += ` (95% CI ${formatInterval(point)})`;
+                            }
                             return label;
                         }
                     }
@@ -326,9 +339,45 @@ function populateTable(tableId, statData) {
         row.insertCell(0).textContent = point.year;
         const valueCell = row.insertCell(1);
         valueCell.textContent = point.value.toFixed(1);
+        if (hasInterval([point])) {
+            valueCell.textContent += ` (95% CI ${formatInterval(point)})`;
+        }
     });
 }
 
+// intervalDatasets shades the published 95% confidence interval: the upper
+// bound is filled down to the lower one. Points without an interval are
+// gaps in the band; a series with none gets no band.
+function intervalDatasets(points, fillColor) {
+    if (!hasInterval(points)) {
+        return [];
+    }
+    return [{
+        label: '95% CI upper',
+        data: points.map(d => hasInterval([d]) ? d.ciHigh : null),
+        borderWidth: 0,
+        pointRadius: 0,
+        backgroundColor: fillColor,
+        fill: '+1'
+    }, {
+        label: '95% CI lower',
+        data: points.map(d => hasInterval([d]) ? d.ciLow : null),
+        borderWidth: 0,
+        pointRadius: 0,
+        fill: false
+    }];
+}
+
+// hasInterval reports whether any point carries a published confidence
+// interval (ciLow and ciHigh in the stat file).
+function hasInterval(points) {
+    return points.some(d => d && d.ciLow != null && d.ciHigh != null);
+}
+
+function formatInterval(point) {
+    return `${point.ciLow.toFixed(1)}–${point.ciHigh.toFixed(1)}`;
+}
+
 function getYAxisLabel(statName) {
     const labels = {
         literacy: 'Literacy Rate (%)',
@@ -341,3 +390,7 @@ function getYAxisLabel(statName) {
     return labels[statName] || 'Value';
 }
 
+// Node (the Jest tests) loads this file as a module.
+if (typeof module !== 'undefined' && module.exports) {
+    module.exports = { renderChart, intervalDatasets, hasInterval, formatInterval };
+}
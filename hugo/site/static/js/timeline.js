@@ -3,6 +3,7 @@
 let markers = []; // Array of {year: number, id: string}
 let selectedStats = ['literacy', 'attainment', 'proficiency', 'graduation']; // Default enabled stats
 let statData = {}; // Cached stat data
+let cohortData = {}; // birthYear -> per-stat measurements from cohorts.json
 let availableStats = []; // Stats with actual data
 let dragState = null; // Current drag operation
 
@@ -11,6 +12,9 @@ let dragState = null; // Current drag operation
 let MIN_YEAR = 1928;  // Default fallback - Silent Generation
 let MAX_YEAR = 2024;  // Default fallback
 const MARKER_COLORS = ['red', 'blue', 'green', 'orange', 'purple', 'teal'];
+// Newest data schemaVersion this script understands (files without one are
+// version 1). See /data/schemas/ for the published JSON Schemas.
+const SUPPORTED_SCHEMA_VERSION = 2;
 
 // Generation definitions used for timeline bands and labels
 const GENERATIONS = [
@@ -22,60 +26,12 @@ const GENERATIONS = [
     { name: 'Generation Alpha', short: 'Gen Alpha', start: 2013, end: 2030, class: 'alpha' },
 ];
 
-// Stat metadata with cohort-based life-stage mapping.
+// Stat metadata with cohort-based life-stage mapping, filled from index.json,
+// which the generator writes from the metric catalog.
 // cohortOffset: years after birth when this stat is measured
 // cohortLabel: human-readable life stage description
 // searchWindow: ±years to search for nearest data point (larger for sparse datasets)
-const statMetadata = {
-    literacy: {
-        name: 'Literacy Rate',
-        description: 'Adult literacy / HS completion rate (15+)',
-        unit: '%',
-        cohortOffset: 20,
-        cohortLabel: 'at age ~20',
-        searchWindow: 6,
-    },
-    attainment: {
-        name: "Bachelor's+",
-        description: "Percentage with bachelor's degree or higher (age 25+)",
-        unit: '%',
-        cohortOffset: 26,
-        cohortLabel: 'at age ~26',
-        searchWindow: 3,
-    },
-    graduation: {
-        name: 'HS Graduation',
-        description: 'High school graduation rate (4-year ACGR)',
-        unit: '%',
-        cohortOffset: 18,
-        cohortLabel: 'at age ~18',
-        searchWindow: 2,
-    },
-    enrollment: {
-        name: 'Enrollment',
-        description: 'School enrollment rate',
-        unit: '%',
-        cohortOffset: 10,
-        cohortLabel: 'at age ~10',
-        searchWindow: 2,
-    },
-    proficiency: {
-        name: 'NAEP Reading',
-        description: 'NAEP Reading score (Grade 8, age ~14)',
-        unit: ' pts',
-        cohortOffset: 14,
-        cohortLabel: 'at age ~14 (Gr.8)',
-        searchWindow: 4,
-    },
-    early_childhood: {
-        name: 'Early Childhood',
-        description: 'Early literacy and kindergarten readiness (age ~5)',
-        unit: '',
-        cohortOffset: 5,
-        cohortLabel: 'at age ~5',
-        searchWindow: 2,
-    },
-};
+const statMetadata = {};
 
 // Initialize on page load
 document.addEventListener('DOMContentLoaded', function() {
@@ -133,8 +89,12 @@ async function loadAvailableStats() {
         const response = await fetch('/data/index.json');
         if (response.ok) {
             const indexData = await response.json();
+            if ((indexData.schemaVersion || 1) > SUPPORTED_SCHEMA_VERSION) {
+                console.warn(`index.json uses schema version ${indexData.schemaVersion}; this page understands up to ${SUPPORTED_SCHEMA_VERSION}`);
+            }
             
             for (const [statName, statInfo] of Object.entries(indexData.stats || {})) {
+                applyIndexMetadata(statName, statInfo);
                 if (statInfo.available) {
                     // Load the actual stat data
                     try {
@@ -152,6 +112,8 @@ async function loadAvailableStats() {
                 }
             }
             
+            await loadCohorts();
+            
             if (availableStats.length === 0) {
                 showNoDataWarning();
             } else {
@@ -165,11 +127,41 @@ async function loadAvailableStats() {
             return;
         }
     } catch (error) {
-        console.log('Index not available, falling back to individual stat checks');
+        console.log('Index not available:', error);
     }
     
-    // Fallback: try individual stat files
-    await loadStatsLegacyWay();
+    // Without index.json there is no stat list or metadata to show.
+    showNoDataWarning();
+}
+
+// Take a stat's display metadata from its index.json entry. Version 1
+// indexes carry only a name and description, so their stats have no life
+// stage and are left out of the cohort tables.
+function applyIndexMetadata(statName, statInfo) {
+    statMetadata[statName] = {
+        name: statInfo.shortName || statInfo.name || statName,
+        description: statInfo.description || '',
+        unit: statInfo.unitSuffix || '',
+        cohortOffset: statInfo.cohortOffset,
+        cohortLabel: statInfo.cohortLabel || '',
+        searchWindow: statInfo.searchWindow,
+    };
+    return statMetadata[statName];
+}
+
+// Load the Go-generated birth-cohort mapping. When present it replaces the
+// nearest-point search below, so cohort logic lives in one tested place.
+async function loadCohorts() {
+    try {
+        const response = await fetch('/data/cohorts.json');
+        if (!response.ok) return;
+        const data = await response.json();
+        (data.cohorts || []).forEach(c => {
+            cohortData[c.birthYear] = c.stats || {};
+        });
+    } catch (error) {
+        console.log('cohorts.json not available, using client-side lookup');
+    }
 }
 
 // Event listeners setup
@@ -509,26 +501,38 @@ function renderStatsForMarker(marker) {
         const data = statData[stat];
         const metadata = statMetadata[stat];
         
-        if (!data || !metadata) return;
+        if (!data || !metadata || metadata.cohortOffset === undefined) return;
         
-        const offset = metadata.cohortOffset || 25;
+        const offset = metadata.cohortOffset;
         const targetYear = marker.year + offset;
-        const window = metadata.searchWindow || 3;
+        // Resampled series already have a point for every year, so only an
+        // exact match counts; sparse series fall back to the search window.
+        const window = data.resampled && data.resampled !== 'none' ? 0 : (metadata.searchWindow || 3);
         
-        // Find closest data point within the search window
+        // Prefer the generated cohort mapping; otherwise find the closest
+        // data point within the search window
         let bestPoint = null;
-        let bestDist = Infinity;
-        data.data.forEach(d => {
-            const dist = Math.abs(d.year - targetYear);
-            if (dist <= window && dist < bestDist) {
-                bestDist = dist;
-                bestPoint = d;
-            }
-        });
+        const cohort = cohortData[marker.year];
+        if (cohort && cohort[stat]) {
+            const m = cohort[stat];
+            bestPoint = { year: m.year, value: m.value, imputed: m.imputed, method: data.resampled };
+        } else if (!cohort) {
+            let bestDist = Infinity;
+            data.data.forEach(d => {
+                const dist = Math.abs(d.year - targetYear);
+                if (dist <= window && dist < bestDist) {
+                    bestDist = dist;
+                    bestPoint = d;
+                }
+            });
+        }
         
         const valueStr = bestPoint
             ? `${bestPoint.value.toFixed(1)}${metadata.unit}`
             : 'N/A';
+        const imputedStr = bestPoint && bestPoint.imputed
+            ? ` <small class="stat-imputed text-muted" title="Estimated (${bestPoint.method}) between measured years">est.</small>`
+            : '';
         const yearStr = bestPoint
             ? `<span class="stat-measured-year">${bestPoint.year}</span>`
             : `<span class="stat-measured-year text-muted">~${targetYear}</span>`;
@@ -540,7 +544,7 @@ function renderStatsForMarker(marker) {
                     <small class="stat-cohort-label">${metadata.cohortLabel}</small>
                 </div>
                 <div class="stat-value-group">
-                    <span class="stat-value">${valueStr}</span>
+                    <span class="stat-value">${valueStr}${imputedStr}</span>
                     ${yearStr}
                 </div>
             </div>
@@ -668,31 +672,7 @@ function hideNoDataWarning() {
 window.removeMarker = removeMarker;
 window.highlightMarker = highlightMarker;
 
-async function loadStatsLegacyWay() {
-    const statsToTry = ['literacy', 'attainment', 'proficiency', 'graduation', 'enrollment', 'early_childhood'];
-    
-    for (const stat of statsToTry) {
-        try {
-            const response = await fetch(`/data/${stat}.json`);
-            if (response.ok) {
-                const data = await response.json();
-                if (data && data.data && data.data.length > 0) {
-                    statData[stat] = data;
-                    availableStats.push(stat);
-                }
-            }
-        } catch (error) {
-            console.log(`Stat ${stat} not available`);
-        }
-    }
-    
-    if (availableStats.length === 0) {
-        showNoDataWarning();
-    } else {
-        hideNoDataWarning();
-        selectedStats = selectedStats.filter(s => availableStats.includes(s));
-        if (selectedStats.length === 0) {
-            selectedStats = [availableStats[0]];
-        }
-    }
+// Node (the Jest tests) loads this file as a module.
+if (typeof module !== 'undefined' && module.exports) {
+    module.exports = { applyIndexMetadata, statMetadata };
 }
@@ -22,7 +22,10 @@ CREATE TABLE IF NOT EXISTS source_metadata (
     years_available TEXT,
     row_count INTEGER DEFAULT 0,
     status TEXT CHECK(status IN ('success', 'partial', 'failed')),
-    error_message TEXT
+    error_message TEXT,
+    year_min INTEGER,
+    year_max INTEGER,
+    year_count INTEGER DEFAULT 0
 );
 
 -- Raw file storage (for Excel/CSV files we download)
@@ -41,6 +44,10 @@ CREATE TABLE IF NOT EXISTS raw_files (
     UNIQUE(source_name, file_url)
 );
 
+-- Observation tables. standard_error, ci_low and ci_high qualify each row's
+-- first value column: the published standard error and the 95% confidence
+-- interval, NULL where the source publishes none (see database.Uncertainty).
+
 -- Literacy rates data
 CREATE TABLE IF NOT EXISTS literacy_rates (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -48,6 +55,9 @@ CREATE TABLE IF NOT EXISTS literacy_rates (
     age_group TEXT NOT NULL,
     rate REAL,
     gender TEXT,
+    standard_error REAL,
+    ci_low REAL,
+    ci_high REAL,
     source TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     UNIQUE(year, age_group, gender, source)
@@ -62,6 +72,9 @@ CREATE TABLE IF NOT EXISTS educational_attainment (
     percentage REAL,
     gender TEXT,
     race TEXT,
+    standard_error REAL,
+    ci_low REAL,
+    ci_high REAL,
     source TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     UNIQUE(year, age_group, education_level, gender, race, source)
@@ -75,6 +88,9 @@ CREATE TABLE IF NOT EXISTS graduation_rates (
     cohort_year INTEGER,
     state TEXT,
     demographics TEXT,
+    standard_error REAL,
+    ci_low REAL,
+    ci_high REAL,
     source TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     UNIQUE(year, cohort_year, state, demographics, source)
@@ -89,6 +105,9 @@ CREATE TABLE IF NOT EXISTS enrollment_rates (
     level TEXT CHECK(level IN ('elementary', 'secondary', 'postsecondary')),
     state TEXT,
     demographics TEXT,
+    standard_error REAL,
+    ci_low REAL,
+    ci_high REAL,
     source TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     UNIQUE(year, age_group, level, state, demographics, source)
@@ -105,6 +124,9 @@ CREATE TABLE IF NOT EXISTS test_proficiency (
     percentage_proficient REAL,
     state TEXT,
     demographics TEXT,
+    standard_error REAL,
+    ci_low REAL,
+    ci_high REAL,
     source TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     UNIQUE(year, subject, grade, proficiency_level, state, demographics, source)
@@ -119,6 +141,9 @@ CREATE TABLE IF NOT EXISTS early_childhood (
     metric_value REAL,
     age_months INTEGER,
     demographics TEXT,
+    standard_error REAL,
+    ci_low REAL,
+    ci_high REAL,
     source TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     UNIQUE(year, cohort_year, metric_name, age_months, demographics, source)
@@ -135,6 +160,57 @@ CREATE TABLE IF NOT EXISTS reset_audit (
     deletion_summary TEXT
 );
 
+-- Value revisions made by incremental loads (see database.ReplaceWindow)
+CREATE TABLE IF NOT EXISTS revision_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id TEXT NOT NULL,
+    source TEXT NOT NULL,
+    table_name TEXT NOT NULL,
+    year INTEGER NOT NULL,
+    series_key TEXT,
+    column_name TEXT NOT NULL,
+    old_value REAL,
+    new_value REAL,
+    change_type TEXT NOT NULL CHECK(change_type IN ('updated', 'removed')),
+    recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Data quality findings written by the validate step
+CREATE TABLE IF NOT EXISTS validation_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id TEXT NOT NULL,
+    rule TEXT NOT NULL,
+    severity TEXT NOT NULL CHECK(severity IN ('error', 'warning', 'ok')),
+    table_name TEXT NOT NULL,
+    series TEXT,
+    year INTEGER,
+    message TEXT,
+    -- Sum of data_versions when the run started, to tell whether data
+    -- changed after it (see validate.Current)
+    data_version INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Change counter per observation table, bumped by triggers on every insert,
+-- update and delete (see database.TrackDataVersions)
+CREATE TABLE IF NOT EXISTS data_versions (
+    table_name TEXT PRIMARY KEY,
+    version INTEGER NOT NULL DEFAULT 0,
+    changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Data versions each output directory was last generated from, so
+-- generate-assets can rewrite only the stats whose tables changed
+CREATE TABLE IF NOT EXISTS generation_state (
+    output_dir TEXT NOT NULL,
+    table_name TEXT NOT NULL,
+    data_version INTEGER NOT NULL,
+    settings TEXT NOT NULL,
+    run_id TEXT NOT NULL,
+    generated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (output_dir, table_name)
+);
+
 -- Indexes for performance
 CREATE INDEX IF NOT EXISTS idx_literacy_year ON literacy_rates(year);
 CREATE INDEX IF NOT EXISTS idx_attainment_year ON educational_attainment(year);
@@ -146,3 +222,5 @@ CREATE INDEX IF NOT EXISTS idx_pipeline_step ON pipeline_metadata(step_name, tim
 CREATE INDEX IF NOT EXISTS idx_source_name ON source_metadata(source_name);
 CREATE INDEX IF NOT EXISTS idx_raw_files_source ON raw_files(source_name);
 CREATE INDEX IF NOT EXISTS idx_raw_files_parsed ON raw_files(parsed);
+CREATE INDEX IF NOT EXISTS idx_revision_source ON revision_history(source, recorded_at);
+CREATE INDEX IF NOT EXISTS idx_validation_run ON validation_results(run_id);